@@ -0,0 +1,185 @@
+package apperrors
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"time"
+
+	gorp "github.com/go-gorp/gorp/v3"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Kind is error kind
+type Kind int
+
+const (
+	// KindInternal is unexpected error kind
+	KindInternal Kind = iota
+	// KindNotFound is missing resource error kind
+	KindNotFound
+	// KindConflict is resource state conflict error kind
+	KindConflict
+	// KindValidation is invalid data error kind
+	KindValidation
+	// KindUnavailable is temporary unavailability error kind
+	KindUnavailable
+)
+
+const (
+	// mysqlDuplicateEntry is mysql duplicate key error number
+	mysqlDuplicateEntry = 1062
+	// mysqlLockWaitTimeout is mysql lock wait timeout error number
+	mysqlLockWaitTimeout = 1205
+	// mysqlDeadlock is mysql deadlock error number
+	mysqlDeadlock = 1213
+	// mysqlTooManyConnections is mysql too many connections error number
+	mysqlTooManyConnections = 1040
+)
+
+var (
+	// ErrNotFound matches any missing resource error
+	ErrNotFound = New(KindNotFound, "resource.NotFound", "Resource not found", "")
+	// ErrConflict matches any resource state conflict error
+	ErrConflict = New(KindConflict, "resource.Conflict", "Resource state conflicts with request", "")
+	// ErrValidation matches any invalid data error
+	ErrValidation = New(KindValidation, "data.Invalid", "Data can't be processed", "")
+	// ErrUnavailable matches any temporary unavailability error
+	ErrUnavailable = New(KindUnavailable, "service.Unavailable", "Service is temporarily unavailable", "")
+
+	// ErrFlightNotFound is missing flight error
+	ErrFlightNotFound = New(KindNotFound, "flight.NotFound", "Flight not found", "flightId")
+	// ErrSeatNotFound is missing seat error
+	ErrSeatNotFound = New(KindNotFound, "seat.NotFound", "Seat not found", "index")
+	// ErrFlightFull is error of flight without free seats
+	ErrFlightFull = New(KindConflict, "flight.Full", "Flight has no free seats", "flightId")
+	// ErrSeatTaken is error of seat assigned to another owner
+	ErrSeatTaken = New(KindConflict, "seat.Taken", "Seat is already assigned", "index")
+	// ErrSeatContention is error of free seats taken concurrently on every assign attempt
+	ErrSeatContention = &Error{Kind: KindConflict, Code: "seat.Contention",
+		Message: "Seats are concurrently assigned, retry later", Field: "flightId", RetryAfter: time.Second}
+	// ErrSeatNotAssigned is error of free seat without occupant
+	ErrSeatNotAssigned = New(KindConflict, "seat.NotAssigned", "Seat is not assigned", "index")
+	// ErrSeatBlocked is error of seat blocked from assignment
+	ErrSeatBlocked = New(KindConflict, "seat.Blocked", "Seat is blocked", "index")
+	// ErrFlightClosed is error of flight closed for booking and boarding
+	ErrFlightClosed = New(KindConflict, "flight.Closed", "Flight is closed", "flightId")
+	// ErrFlightNotClosed is error of flight still open for booking
+	ErrFlightNotClosed = New(KindConflict, "flight.NotClosed", "Flight is not closed yet", "flightId")
+	// ErrOverbookingLimit is error of flight sold up to the overbooking limit
+	ErrOverbookingLimit = New(KindConflict, "flight.OverbookingLimit", "Flight is sold up to the overbooking limit",
+		"flightId")
+	// ErrBookingNotFound is missing booking error
+	ErrBookingNotFound = New(KindNotFound, "booking.NotFound", "Booking not found", "bookingId")
+	// ErrBookingNotConfirmed is error of booking already seated or denied
+	ErrBookingNotConfirmed = New(KindConflict, "booking.NotConfirmed", "Booking is not waiting for seat",
+		"bookingId")
+	// ErrCheckinNotFound is missing check-in error
+	ErrCheckinNotFound = New(KindNotFound, "checkin.NotFound", "Check-in not found", "checkinId")
+	// ErrCheckinExists is error of seat already checked in
+	ErrCheckinExists = New(KindConflict, "checkin.Exists", "Seat is already checked in", "index")
+	// ErrSeatCheckedIn is error of seat released from the checked in passenger
+	ErrSeatCheckedIn = New(KindConflict, "seat.CheckedIn", "Seat passenger is already checked in", "index")
+	// ErrWaitlistEntryNotFound is missing waitlist entry error
+	ErrWaitlistEntryNotFound = New(KindNotFound, "waitlist.NotFound", "Waitlist entry not found", "entryId")
+	// ErrWaitlistDuplicate is error of owner already waiting for the flight
+	ErrWaitlistDuplicate = New(KindConflict, "waitlist.Duplicate", "Owner is already on the waitlist", "owner")
+	// ErrWaitlistNotWaiting is error of entry already granted or cancelled
+	ErrWaitlistNotWaiting = New(KindConflict, "waitlist.NotWaiting", "Waitlist entry is not waiting", "entryId")
+
+	// kindErrors contains errors matching all errors of the kind
+	kindErrors = map[*Error]bool{ErrNotFound: true, ErrConflict: true, ErrValidation: true, ErrUnavailable: true}
+)
+
+// Error is classified service error
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Field   string
+	Details map[string]interface{}
+	// RetryAfter is delay after which the request can be retried, zero if it isn't expected to succeed
+	RetryAfter time.Duration
+	Err        error
+}
+
+// New is a constructor of classified error
+func New(kind Kind, code string, message string, field string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Field: field}
+}
+
+// Wrap classifies the cause error
+func Wrap(kind Kind, err error, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Err: err}
+}
+
+// Error returns error message
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+// Unwrap returns the cause error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the same error or the kind error of the same kind
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return e == t || (kindErrors[t] && e.Kind == t.Kind)
+}
+
+// KindOf classifies error by its type
+func KindOf(err error) Kind {
+	if err == nil {
+		return KindInternal
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Kind
+	}
+
+	var lockErr gorp.OptimisticLockError
+	if errors.As(err, &lockErr) {
+		return KindConflict
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry:
+			return KindConflict
+		case mysqlLockWaitTimeout, mysqlDeadlock, mysqlTooManyConnections:
+			return KindUnavailable
+		}
+
+		return KindInternal
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return KindNotFound
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return KindUnavailable
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return KindUnavailable
+	}
+
+	return KindInternal
+}
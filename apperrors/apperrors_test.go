@@ -0,0 +1,52 @@
+package apperrors
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	gorp "github.com/go-gorp/gorp/v3"
+	"github.com/go-sql-driver/mysql"
+)
+
+func Test_KindOf_Classified_Success(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", New(KindValidation, "a.Invalid", "A is invalid", "a"))
+
+	if KindOf(err) != KindValidation {
+		t.Error("Expected to keep kind of wrapped classified error")
+	}
+}
+
+func Test_KindOf_Database_Success(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind Kind
+	}{
+		{sql.ErrNoRows, KindNotFound},
+		{gorp.OptimisticLockError{}, KindConflict},
+		{&mysql.MySQLError{Number: mysqlDuplicateEntry}, KindConflict},
+		{&mysql.MySQLError{Number: mysqlDeadlock}, KindUnavailable},
+		{driver.ErrBadConn, KindUnavailable},
+		{mysql.ErrInvalidConn, KindUnavailable},
+		{errors.New("test"), KindInternal},
+	}
+
+	for _, c := range cases {
+		if KindOf(c.err) != c.kind {
+			t.Errorf("Expected %v to be classified as %v", c.err, c.kind)
+		}
+	}
+}
+
+func Test_Error_Is_Success(t *testing.T) {
+	err := fmt.Errorf("retrieving: %w", ErrFlightNotFound)
+
+	if !errors.Is(err, ErrFlightNotFound) || !errors.Is(err, ErrNotFound) {
+		t.Error("Expected to match specific and kind errors")
+	}
+	if errors.Is(err, ErrSeatNotFound) || errors.Is(err, ErrConflict) {
+		t.Error("Expected not to match other errors")
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/vsukhin/booking/models"
+)
+
+const (
+	// HeaderAPIKey is api key header
+	HeaderAPIKey = "X-API-Key"
+)
+
+// APIKeyAuthenticator authenticates requests by api key
+type APIKeyAuthenticator struct {
+	principals map[string]models.Principal
+}
+
+// NewAPIKeyAuthenticator is a constructor of api key authenticator
+func NewAPIKeyAuthenticator(apiKeys []APIKey) Authenticator {
+	principals := make(map[string]models.Principal, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		principals[apiKey.Key] = apiKey.Principal
+	}
+
+	return &APIKeyAuthenticator{principals: principals}
+}
+
+// Authenticate authenticates request by api key header
+func (authenticator *APIKeyAuthenticator) Authenticate(r *http.Request) (*models.Principal, error) {
+	key := r.Header.Get(HeaderAPIKey)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	principal, ok := authenticator.principals[key]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &principal, nil
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+const (
+	// HeaderTenant is tenant header
+	HeaderTenant = "X-Tenant-ID"
+	// principalKey is context key of authenticated principal
+	principalKey = "principal"
+	// tenantKey is context key of resolved tenant
+	tenantKey = "tenant"
+	// maxTenantLength is max tenant length
+	maxTenantLength = 64
+)
+
+var (
+	// ErrNoCredentials is returned when request has no credentials for authenticator
+	ErrNoCredentials = errors.New("Credentials are missing")
+	// ErrInvalidCredentials is returned when request credentials are not accepted
+	ErrInvalidCredentials = errors.New("Credentials are invalid")
+)
+
+// Authenticator is request authenticator interface
+type Authenticator interface {
+	Authenticate(r *http.Request) (*models.Principal, error)
+}
+
+// Manager is authentication manager, its authenticators are replaced on key set reload
+type Manager struct {
+	mutex          sync.RWMutex
+	authenticators []Authenticator
+}
+
+// ManagerInterface is authentication manager interface
+type ManagerInterface interface {
+	Authenticate() gin.HandlerFunc
+	Authorize(roles ...models.Role) gin.HandlerFunc
+	AuthorizeOperator() gin.HandlerFunc
+	ResolveTenant() gin.HandlerFunc
+	SetKeySet(keySet *KeySet)
+}
+
+// NewManager is a constructor of authentication manager
+func NewManager(authenticators ...Authenticator) ManagerInterface {
+	return &Manager{authenticators: authenticators}
+}
+
+// NewKeySetManager is a constructor of authentication manager using local key set
+func NewKeySetManager(keySet *KeySet) ManagerInterface {
+	return NewManager(keySetAuthenticators(keySet)...)
+}
+
+// keySetAuthenticators returns authenticators of the key set
+func keySetAuthenticators(keySet *KeySet) []Authenticator {
+	return []Authenticator{NewAPIKeyAuthenticator(keySet.APIKeys), NewJWTAuthenticator(keySet.JWTKeys)}
+}
+
+// SetKeySet replaces authenticators with authenticators of the key set, requests in progress keep former ones
+func (manager *Manager) SetKeySet(keySet *KeySet) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.authenticators = keySetAuthenticators(keySet)
+}
+
+// current returns current authenticators
+func (manager *Manager) current() []Authenticator {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.authenticators
+}
+
+// GetPrincipal gets authenticated principal from the context
+func GetPrincipal(c *gin.Context) *models.Principal {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil
+	}
+
+	principal, _ := value.(*models.Principal)
+	return principal
+}
+
+// SetPrincipal sets authenticated principal to the context
+func SetPrincipal(c *gin.Context, principal *models.Principal) {
+	c.Set(principalKey, principal)
+}
+
+// GetTenant gets resolved tenant from the context
+func GetTenant(c *gin.Context) string {
+	return c.GetString(tenantKey)
+}
+
+// SetTenant sets resolved tenant to the context
+func SetTenant(c *gin.Context, tenant string) {
+	c.Set(tenantKey, tenant)
+}
+
+// Authenticate is authentication middleware
+func (manager *Manager) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, authenticator := range manager.current() {
+			principal, err := authenticator.Authenticate(c.Request)
+			if err == ErrNoCredentials {
+				continue
+			}
+
+			if err != nil {
+				errs := []models.Error{models.Error{
+					Code:    "credentials.Invalid",
+					Message: "Credentials are invalid",
+					Field:   HeaderAuthorization,
+				}}
+
+				logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+					"error":  err,
+					"errors": errs,
+					"path":   c.Request.URL.Path,
+				}).Error("Credentials are invalid")
+
+				helpers.AbortWithErrors(c, http.StatusUnauthorized, errs)
+				return
+			}
+
+			SetPrincipal(c, principal)
+			c.Next()
+			return
+		}
+
+		errs := []models.Error{models.Error{
+			Code:    "credentials.Missing",
+			Message: "Credentials are missing",
+			Field:   HeaderAuthorization,
+		}}
+
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"errors": errs,
+			"path":   c.Request.URL.Path,
+		}).Error("Credentials are missing")
+
+		helpers.AbortWithErrors(c, http.StatusUnauthorized, errs)
+	}
+}
+
+// Authorize is role authorization middleware
+func (manager *Manager) Authorize(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := GetPrincipal(c)
+		if principal == nil || !principal.HasRole(roles...) {
+			errs := []models.Error{models.Error{
+				Code:    "role.Forbidden",
+				Message: "Role is not allowed to access resource",
+				Field:   "role",
+			}}
+
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"errors":    errs,
+				"principal": principal,
+				"roles":     roles,
+				"path":      c.Request.URL.Path,
+			}).Error("Role is not allowed to access resource")
+
+			helpers.AbortWithErrors(c, http.StatusForbidden, errs)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AuthorizeOperator is authorization middleware of the admin credentials not bound to tenant, it guards
+// resources of all tenants
+func (manager *Manager) AuthorizeOperator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := GetPrincipal(c)
+		if principal == nil || !principal.HasRole(models.RoleAdmin) || principal.Tenant != "" {
+			errs := []models.Error{models.Error{
+				Code:    "role.Forbidden",
+				Message: "Resource of all tenants requires admin credentials not bound to tenant",
+				Field:   "role",
+			}}
+
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"errors":    errs,
+				"principal": principal,
+				"path":      c.Request.URL.Path,
+			}).Error("Resource of all tenants requires admin credentials not bound to tenant")
+
+			helpers.AbortWithErrors(c, http.StatusForbidden, errs)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ResolveTenant is tenant resolution middleware, credential tenant takes precedence over the header,
+// only admin credentials without tenant select it with the header
+func (manager *Manager) ResolveTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tenant string
+
+		header := c.Request.Header.Get(HeaderTenant)
+
+		principal := GetPrincipal(c)
+		if principal != nil {
+			tenant = principal.Tenant
+		}
+
+		if tenant != "" && header != "" && header != tenant {
+			errs := []models.Error{models.Error{
+				Code:    "tenant.Forbidden",
+				Message: "Tenant is not allowed for credentials",
+				Field:   HeaderTenant,
+			}}
+
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"errors":    errs,
+				"principal": principal,
+				"tenant":    header,
+			}).Error("Tenant is not allowed for credentials")
+
+			helpers.AbortWithErrors(c, http.StatusForbidden, errs)
+			return
+		}
+
+		if tenant == "" && header != "" && (principal == nil || !principal.HasRole(models.RoleAdmin)) {
+			errs := []models.Error{models.Error{
+				Code:    "tenant.Forbidden",
+				Message: "Tenant can be selected by admin credentials only",
+				Field:   HeaderTenant,
+			}}
+
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"errors":    errs,
+				"principal": principal,
+				"tenant":    header,
+			}).Error("Tenant can be selected by admin credentials only")
+
+			helpers.AbortWithErrors(c, http.StatusForbidden, errs)
+			return
+		}
+
+		if tenant == "" {
+			tenant = header
+		}
+
+		if tenant == "" || len(tenant) > maxTenantLength {
+			errs := []models.Error{models.Error{
+				Code:    "tenant.Invalid",
+				Message: "Tenant is missing or too long",
+				Field:   HeaderTenant,
+			}}
+
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"errors":    errs,
+				"principal": principal,
+				"tenant":    tenant,
+			}).Error("Tenant is missing or too long")
+
+			helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+			return
+		}
+
+		SetTenant(c, tenant)
+		c.Next()
+	}
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+func init() {
+	logging.Log = NewFakeLogger()
+}
+
+// FakeLogger is fake logger
+type FakeLogger struct {
+	*logrus.Logger
+}
+
+// NewFakeLogger is a constructor of fake logger
+func NewFakeLogger() logging.LoggerInterface {
+	log := logrus.New()
+
+	return &FakeLogger{log}
+}
+
+// Init initiates logging
+func (logger *FakeLogger) Init(mode string) {
+}
+
+// WithFields logs with fields
+func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// Info logs info
+func (logger *FakeLogger) Info(args ...interface{}) {
+}
+
+func newTestKeySet() *KeySet {
+	return &KeySet{
+		APIKeys: []APIKey{
+			{Key: "admin-key", Principal: models.Principal{Subject: "admin", Role: models.RoleAdmin}},
+			{Key: "tenant-admin-key", Principal: models.Principal{Subject: "admin", Role: models.RoleAdmin,
+				Tenant: "t1"}},
+		},
+		JWTKeys: []JWTKey{
+			{ID: "k1", Secret: "secret"},
+		},
+	}
+}
+
+func newTestRouter(manager ManagerInterface, roles ...models.Role) *gin.Engine {
+	r := gin.New()
+	r.Use(manager.Authenticate())
+	r.GET("/", manager.Authorize(roles...), func(c *gin.Context) {
+		c.String(http.StatusOK, GetPrincipal(c).Subject)
+	})
+
+	return r
+}
+
+func Test_APIKeyAuthenticator_Authenticate_Success(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAPIKey, "admin-key")
+
+	authenticator := NewAPIKeyAuthenticator(newTestKeySet().APIKeys)
+
+	principal, err := authenticator.Authenticate(req)
+	if err != nil {
+		t.Error("Expected to authenticate api key successfully")
+	}
+	if principal == nil || principal.Subject != "admin" || principal.Role != models.RoleAdmin {
+		t.Error("Expected to have matching principal")
+	}
+}
+
+func Test_APIKeyAuthenticator_Authenticate_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAPIKey, "unknown-key")
+
+	authenticator := NewAPIKeyAuthenticator(newTestKeySet().APIKeys)
+
+	_, err := authenticator.Authenticate(req)
+	if err != ErrInvalidCredentials {
+		t.Error("Expected to have invalid credentials error")
+	}
+}
+
+func Test_JWTAuthenticator_Authenticate_Success(t *testing.T) {
+	token, err := SignToken("k1", "secret", Claims{
+		Subject:   "agent",
+		Role:      models.RoleAgent,
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal("Expected to sign token successfully")
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAuthorization, "Bearer "+token)
+
+	authenticator := NewJWTAuthenticator(newTestKeySet().JWTKeys)
+
+	principal, err := authenticator.Authenticate(req)
+	if err != nil {
+		t.Error("Expected to authenticate token successfully")
+	}
+	if principal == nil || principal.Subject != "agent" || principal.Role != models.RoleAgent {
+		t.Error("Expected to have matching principal")
+	}
+}
+
+func Test_JWTAuthenticator_Authenticate_Expired_Failure(t *testing.T) {
+	token, _ := SignToken("k1", "secret", Claims{
+		Subject:   "agent",
+		Role:      models.RoleAgent,
+		ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAuthorization, "Bearer "+token)
+
+	authenticator := NewJWTAuthenticator(newTestKeySet().JWTKeys)
+
+	_, err := authenticator.Authenticate(req)
+	if err != ErrInvalidCredentials {
+		t.Error("Expected to have expired token error")
+	}
+}
+
+func Test_JWTAuthenticator_Authenticate_Signature_Failure(t *testing.T) {
+	token, _ := SignToken("k1", "other", Claims{
+		Subject: "agent",
+		Role:    models.RoleAgent,
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAuthorization, "Bearer "+token)
+
+	authenticator := NewJWTAuthenticator(newTestKeySet().JWTKeys)
+
+	_, err := authenticator.Authenticate(req)
+	if err != ErrInvalidCredentials {
+		t.Error("Expected to have invalid signature error")
+	}
+}
+
+func Test_Manager_Authenticate_Missing_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+
+	r := newTestRouter(NewKeySetManager(newTestKeySet()), models.RoleAdmin)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Error("Expected to return unauthorized")
+	}
+}
+
+func Test_Manager_Authorize_Success(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAPIKey, "admin-key")
+	w := httptest.NewRecorder()
+
+	r := newTestRouter(NewKeySetManager(newTestKeySet()), models.RoleAdmin)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Error("Expected to return success")
+	}
+	if w.Body.String() != "admin" {
+		t.Error("Expected to have matching principal")
+	}
+}
+
+func Test_Manager_Authorize_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAPIKey, "admin-key")
+	w := httptest.NewRecorder()
+
+	r := newTestRouter(NewKeySetManager(newTestKeySet()), models.RoleCustomer)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Error("Expected to return forbidden")
+	}
+}
+
+func Test_Manager_AuthorizeOperator_Failure(t *testing.T) {
+	manager := NewKeySetManager(newTestKeySet())
+	r := gin.New()
+	r.GET("/", manager.Authenticate(), manager.AuthorizeOperator(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for key, status := range map[string]int{"admin-key": http.StatusOK, "tenant-admin-key": http.StatusForbidden} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set(HeaderAPIKey, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected %v to get %v, got %v", key, status, w.Code)
+		}
+	}
+}
+
+func Test_Manager_SetKeySet_Success(t *testing.T) {
+	manager := NewKeySetManager(newTestKeySet())
+	r := newTestRouter(manager, models.RoleAdmin)
+
+	manager.SetKeySet(&KeySet{APIKeys: []APIKey{
+		{Key: "rotated-key", Principal: models.Principal{Subject: "admin", Role: models.RoleAdmin}},
+	}})
+
+	for key, status := range map[string]int{"admin-key": http.StatusUnauthorized, "rotated-key": http.StatusOK} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set(HeaderAPIKey, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected %v for key %v after reload, got %v", status, key, w.Code)
+		}
+	}
+}
+
+func Test_LoadKeySet_Success(t *testing.T) {
+	file, err := ioutil.TempFile("", "keys")
+	if err != nil {
+		t.Fatal("Expected to create temp file successfully")
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString("api_keys:\n  - key: k\n    subject: s\n    role: agent\njwt_keys:\n  - id: k1\n    secret: x\n")
+	if err != nil {
+		t.Fatal("Expected to write temp file successfully")
+	}
+	file.Close()
+
+	keySet, err := LoadKeySet(file.Name())
+	if err != nil {
+		t.Error("Expected to load key set successfully")
+	}
+	if keySet == nil || len(keySet.APIKeys) != 1 || keySet.APIKeys[0].Role != models.RoleAgent ||
+		len(keySet.JWTKeys) != 1 {
+		t.Error("Expected to have matching key set")
+	}
+}
+
+func Test_KeySet_Validate_Failure(t *testing.T) {
+	keySet := &KeySet{
+		APIKeys: []APIKey{
+			{Key: "k", Principal: models.Principal{Subject: "s", Role: "unknown"}},
+		},
+	}
+
+	err := keySet.Validate()
+	if err == nil {
+		t.Error("Expected to have unknown role error")
+	}
+}
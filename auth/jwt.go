@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vsukhin/booking/models"
+)
+
+const (
+	// HeaderAuthorization is authorization header
+	HeaderAuthorization = "Authorization"
+	// bearerPrefix is bearer token prefix
+	bearerPrefix = "Bearer "
+	// jwtAlgorithm is supported jwt signing algorithm
+	jwtAlgorithm = "HS256"
+	// jwtParts is number of jwt parts
+	jwtParts = 3
+)
+
+// jwtHeader is jwt header
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Claims contains jwt claims
+type Claims struct {
+	Subject   string      `json:"sub"`
+	Role      models.Role `json:"role"`
+	Tenant    string      `json:"tenant,omitempty"`
+	Tier      models.Tier `json:"tier,omitempty"`
+	ExpiresAt int64       `json:"exp,omitempty"`
+	NotBefore int64       `json:"nbf,omitempty"`
+	IssuedAt  int64       `json:"iat,omitempty"`
+}
+
+// JWTAuthenticator authenticates requests by jwt bearer token
+type JWTAuthenticator struct {
+	keys map[string][]byte
+	now  func() time.Time
+}
+
+// NewJWTAuthenticator is a constructor of jwt authenticator
+func NewJWTAuthenticator(jwtKeys []JWTKey) Authenticator {
+	keys := make(map[string][]byte, len(jwtKeys))
+	for _, jwtKey := range jwtKeys {
+		keys[jwtKey.ID] = []byte(jwtKey.Secret)
+	}
+
+	return &JWTAuthenticator{keys: keys, now: time.Now}
+}
+
+// Authenticate authenticates request by bearer token
+func (authenticator *JWTAuthenticator) Authenticate(r *http.Request) (*models.Principal, error) {
+	header := r.Header.Get(HeaderAuthorization)
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := authenticator.Parse(strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix)))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &models.Principal{
+		Subject: claims.Subject,
+		Role:    claims.Role,
+		Tenant:  claims.Tenant,
+		Tier:    claims.Tier,
+	}, nil
+}
+
+// Parse parses and verifies token
+func (authenticator *JWTAuthenticator) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != jwtParts {
+		return nil, errors.New("Token is malformed")
+	}
+
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Algorithm != jwtAlgorithm {
+		return nil, errors.New("Token algorithm is not supported")
+	}
+
+	key, ok := authenticator.keys[header.KeyID]
+	if !ok {
+		return nil, errors.New("Token key is unknown")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(signature, sign(key, parts[0]+"."+parts[1])) {
+		return nil, errors.New("Token signature is invalid")
+	}
+
+	var claims Claims
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	now := authenticator.now().Unix()
+	if claims.ExpiresAt != 0 && now >= claims.ExpiresAt {
+		return nil, errors.New("Token is expired")
+	}
+
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return nil, errors.New("Token is not valid yet")
+	}
+
+	if claims.Subject == "" || !claims.Role.IsValid() || !claims.Tier.IsValid() {
+		return nil, errors.New("Token claims are invalid")
+	}
+
+	return &claims, nil
+}
+
+// SignToken signs claims with the key
+func SignToken(keyID string, secret string, claims Claims) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: jwtAlgorithm, Type: "JWT", KeyID: keyID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(secret), unsigned)), nil
+}
+
+func sign(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, object interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, object)
+}
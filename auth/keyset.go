@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+// APIKey contains api key and its principal
+type APIKey struct {
+	Key              string `yaml:"key"`
+	models.Principal `yaml:",inline"`
+}
+
+// JWTKey contains jwt signing key
+type JWTKey struct {
+	ID     string `yaml:"id"`
+	Secret string `yaml:"secret"`
+}
+
+// KeySet contains local keys used for authentication
+type KeySet struct {
+	APIKeys []APIKey `yaml:"api_keys"`
+	JWTKeys []JWTKey `yaml:"jwt_keys"`
+}
+
+// LoadKeySet loads key set from the yaml file
+func LoadKeySet(fileName string) (*KeySet, error) {
+	keySet := &KeySet{}
+	if fileName == "" {
+		return keySet, nil
+	}
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"fileName": fileName,
+		}).Error("Error reading key set")
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, keySet)
+	if err != nil {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"fileName": fileName,
+		}).Error("Error parsing key set")
+		return nil, err
+	}
+
+	err = keySet.Validate()
+	if err != nil {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"fileName": fileName,
+		}).Error("Error validating key set")
+		return nil, err
+	}
+
+	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+		"fileName": fileName,
+		"apiKeys":  len(keySet.APIKeys),
+		"jwtKeys":  len(keySet.JWTKeys),
+	}).Debug("Key set successfully loaded")
+	return keySet, nil
+}
+
+// Validate validates key set
+func (keySet *KeySet) Validate() error {
+	keys := map[string]bool{}
+	for i, apiKey := range keySet.APIKeys {
+		if apiKey.Key == "" {
+			return fmt.Errorf("api key %v is empty", i)
+		}
+		if keys[apiKey.Key] {
+			return fmt.Errorf("api key %v is duplicated", i)
+		}
+		if apiKey.Subject == "" {
+			return fmt.Errorf("api key %v has empty subject", i)
+		}
+		if !apiKey.Role.IsValid() {
+			return fmt.Errorf("api key %v has unknown role %q", i, apiKey.Role)
+		}
+		if !apiKey.Tier.IsValid() {
+			return fmt.Errorf("api key %v has unknown tier %q", i, apiKey.Tier)
+		}
+		keys[apiKey.Key] = true
+	}
+
+	ids := map[string]bool{}
+	for i, jwtKey := range keySet.JWTKeys {
+		if jwtKey.Secret == "" {
+			return fmt.Errorf("jwt key %v has empty secret", i)
+		}
+		if ids[jwtKey.ID] {
+			return errors.New("jwt key id " + jwtKey.ID + " is duplicated")
+		}
+		ids[jwtKey.ID] = true
+	}
+
+	return nil
+}
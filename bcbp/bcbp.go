@@ -0,0 +1,112 @@
+package bcbp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// formatCode is format code of the bar coded boarding pass
+	formatCode = "M"
+	// legs is number of legs encoded
+	legs = "1"
+	// electronicTicket is electronic ticket indicator
+	electronicTicket = "E"
+	// checkedIn is passenger status of checked in passenger
+	checkedIn = "1"
+	// noConditionalItems is size of conditional and airline items in hex
+	noConditionalItems = "00"
+	// nameLength is passenger name field length
+	nameLength = 20
+)
+
+// Pass contains data of the single leg boarding pass
+type Pass struct {
+	LastName     string
+	FirstName    string
+	PNR          string
+	Origin       string
+	Destination  string
+	Carrier      string
+	FlightNumber string
+	Date         time.Time
+	Compartment  string
+	Row          int
+	Line         string
+	Sequence     int
+}
+
+// Encode encodes mandatory items of the single leg pass in IATA BCBP format M
+func Encode(pass Pass) string {
+	var builder strings.Builder
+
+	builder.WriteString(formatCode)
+	builder.WriteString(legs)
+	builder.WriteString(field(name(pass.LastName, pass.FirstName), nameLength))
+	builder.WriteString(electronicTicket)
+	builder.WriteString(field(pass.PNR, 7))
+	builder.WriteString(field(pass.Origin, 3))
+	builder.WriteString(field(pass.Destination, 3))
+	builder.WriteString(field(pass.Carrier, 3))
+	builder.WriteString(field(flightNumber(pass.FlightNumber), 5))
+	builder.WriteString(field(julianDate(pass.Date), 3))
+	builder.WriteString(field(pass.Compartment, 1))
+	builder.WriteString(field(fmt.Sprintf("%03d%s", pass.Row, pass.Line), 4))
+	builder.WriteString(field(fmt.Sprintf("%04d", pass.Sequence), 5))
+	builder.WriteString(checkedIn)
+	builder.WriteString(noConditionalItems)
+
+	return builder.String()
+}
+
+// name formats passenger name as LAST/FIRST in upper case latin letters
+func name(lastName string, firstName string) string {
+	value := clean(lastName)
+	if first := clean(firstName); first != "" {
+		value += "/" + first
+	}
+
+	return value
+}
+
+// clean keeps upper case ascii letters, digits and spaces
+func clean(value string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToUpper(r)
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == ' ' {
+			return r
+		}
+		return -1
+	}, strings.TrimSpace(value))
+}
+
+// flightNumber pads numeric part of the flight number to four digits keeping the suffix
+func flightNumber(number string) string {
+	if number == "" {
+		return ""
+	}
+
+	digits := strings.TrimRightFunc(number, unicode.IsLetter)
+	return strings.Repeat("0", 4-len(digits)) + number
+}
+
+// julianDate returns day of the year, empty for unknown date
+func julianDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprintf("%03d", date.YearDay())
+}
+
+// field truncates or pads value with spaces to the length
+func field(value string, length int) string {
+	value = strings.ToUpper(value)
+	if len(value) > length {
+		return value[:length]
+	}
+
+	return value + strings.Repeat(" ", length-len(value))
+}
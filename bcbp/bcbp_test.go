@@ -0,0 +1,41 @@
+package bcbp
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Encode_Success(t *testing.T) {
+	pass := Pass{
+		LastName:     "Desmarais",
+		FirstName:    "Luc",
+		PNR:          "ABC123",
+		Origin:       "YUL",
+		Destination:  "FRA",
+		Carrier:      "AC",
+		FlightNumber: "834",
+		Date:         time.Date(2026, time.November, 22, 0, 0, 0, 0, time.UTC),
+		Compartment:  "J",
+		Row:          1,
+		Line:         "A",
+		Sequence:     25,
+	}
+
+	expected := "M1DESMARAIS/LUC       EABC123 YULFRAAC 0834 326J001A0025 100"
+	if value := Encode(pass); value != expected {
+		t.Errorf("Expected to encode %q, got %q", expected, value)
+	}
+}
+
+func Test_Encode_Truncate_Success(t *testing.T) {
+	pass := Pass{LastName: "Wolfeschlegelsteinhausenbergerdorff", FirstName: "Hubert", Row: 12, Line: "C",
+		Sequence: 7}
+
+	value := Encode(pass)
+	if len(value) != 60 {
+		t.Errorf("Expected to encode fixed size pass, got %v characters", len(value))
+	}
+	if value[2:22] != "WOLFESCHLEGELSTEINHA" || value[48:52] != "012C" {
+		t.Errorf("Expected to truncate name and format seat, got %q", value)
+	}
+}
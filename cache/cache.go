@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+const (
+	// DefaultTTL is default time to keep cached values, it bounds staleness of values
+	// cached by concurrent reads racing with invalidation
+	DefaultTTL = 30 * time.Second
+	// DefaultCapacity is default max number of values kept by the memory store
+	DefaultCapacity = 10000
+)
+
+// Manager is cache manager encoding values with gob to keep fields hidden from json,
+// store errors are logged and treated as misses
+type Manager struct {
+	store         Store
+	ttl           time.Duration
+	hits          int64
+	misses        int64
+	errors        int64
+	invalidations int64
+}
+
+// ManagerInterface is cache manager interface
+type ManagerInterface interface {
+	Get(key string, value interface{}) bool
+	Set(key string, value interface{})
+	Delete(keys ...string)
+	Stats() models.CacheStats
+}
+
+// NewManager is a constructor of cache manager
+func NewManager(store Store, ttl time.Duration) ManagerInterface {
+	return &Manager{store: store, ttl: ttl}
+}
+
+// FlightKey returns key of the flight definition
+func FlightKey(tenantID string, id int64) string {
+	return fmt.Sprintf("flight:%s:%d", tenantID, id)
+}
+
+// SeatSummaryKey returns key of the seat availability summary of the flight
+func SeatSummaryKey(tenantID string, flightID int64) string {
+	return fmt.Sprintf("seats:%s:%d:summary", tenantID, flightID)
+}
+
+// Get decodes cached value of the key into the value, returns false on miss
+func (manager *Manager) Get(key string, value interface{}) bool {
+	data, ok, err := manager.store.Get(key)
+	if err == nil && ok {
+		err = gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+	}
+	if err != nil {
+		atomic.AddInt64(&manager.errors, 1)
+
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error": err,
+			"key":   key,
+		}).Error("Error getting cached value")
+		ok = false
+	}
+
+	if !ok {
+		atomic.AddInt64(&manager.misses, 1)
+		return false
+	}
+
+	atomic.AddInt64(&manager.hits, 1)
+	return true
+}
+
+// Set caches value of the key
+func (manager *Manager) Set(key string, value interface{}) {
+	var data bytes.Buffer
+
+	err := gob.NewEncoder(&data).Encode(value)
+	if err == nil {
+		err = manager.store.Set(key, data.Bytes(), manager.ttl)
+	}
+	if err != nil {
+		atomic.AddInt64(&manager.errors, 1)
+
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error": err,
+			"key":   key,
+		}).Error("Error setting cached value")
+	}
+}
+
+// Delete invalidates cached values of the keys
+func (manager *Manager) Delete(keys ...string) {
+	atomic.AddInt64(&manager.invalidations, int64(len(keys)))
+
+	err := manager.store.Delete(keys...)
+	if err != nil {
+		atomic.AddInt64(&manager.errors, 1)
+
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error": err,
+			"keys":  keys,
+		}).Error("Error deleting cached values")
+	}
+}
+
+// Stats returns cache counters
+func (manager *Manager) Stats() models.CacheStats {
+	return models.CacheStats{
+		Hits:          atomic.LoadInt64(&manager.hits),
+		Misses:        atomic.LoadInt64(&manager.misses),
+		Errors:        atomic.LoadInt64(&manager.errors),
+		Invalidations: atomic.LoadInt64(&manager.invalidations),
+	}
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+func init() {
+	logging.Log = NewFakeLogger()
+}
+
+// FakeLogger is fake logger
+type FakeLogger struct {
+	*logrus.Logger
+}
+
+// NewFakeLogger is a constructor of fake logger
+func NewFakeLogger() logging.LoggerInterface {
+	log := logrus.New()
+
+	return &FakeLogger{log}
+}
+
+// Init initiates logging
+func (logger *FakeLogger) Init(mode string) {
+}
+
+// WithFields logs with fields
+func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// Info logs info
+func (logger *FakeLogger) Info(args ...interface{}) {
+}
+
+// FailingStore is store failing all operations
+type FailingStore struct{}
+
+// Get fails
+func (store *FailingStore) Get(key string) ([]byte, bool, error) {
+	return nil, false, errors.New("store is unavailable")
+}
+
+// Set fails
+func (store *FailingStore) Set(key string, value []byte, ttl time.Duration) error {
+	return errors.New("store is unavailable")
+}
+
+// Delete fails
+func (store *FailingStore) Delete(keys ...string) error {
+	return errors.New("store is unavailable")
+}
+
+func Test_MemoryStore_Get_Expired_Success(t *testing.T) {
+	store := NewMemoryStore(10).(*MemoryStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	_ = store.Set("key", []byte("value"), time.Minute)
+
+	value, ok, err := store.Get("key")
+	if err != nil || !ok || string(value) != "value" {
+		t.Fatal("Expected to get stored value")
+	}
+
+	now = now.Add(time.Minute)
+
+	_, ok, err = store.Get("key")
+	if err != nil || ok {
+		t.Error("Expected expired value to be missing")
+	}
+	if len(store.entries) != 0 {
+		t.Error("Expected expired value to be removed")
+	}
+}
+
+func Test_MemoryStore_Set_Evict_Success(t *testing.T) {
+	store := NewMemoryStore(2)
+
+	_ = store.Set("a", []byte("a"), time.Minute)
+	_ = store.Set("b", []byte("b"), time.Minute)
+	_, _, _ = store.Get("a")
+	_ = store.Set("c", []byte("c"), time.Minute)
+
+	_, ok, _ := store.Get("b")
+	if ok {
+		t.Error("Expected least recently used value to be evicted")
+	}
+
+	for _, key := range []string{"a", "c"} {
+		_, ok, _ = store.Get(key)
+		if !ok {
+			t.Errorf("Expected value %v to be kept", key)
+		}
+	}
+}
+
+func Test_MemoryStore_Delete_Success(t *testing.T) {
+	store := NewMemoryStore(10)
+
+	_ = store.Set("a", []byte("a"), time.Minute)
+	_ = store.Set("b", []byte("b"), time.Minute)
+
+	err := store.Delete("a", "b", "missing")
+	if err != nil {
+		t.Fatal("Expected to delete values successfully")
+	}
+
+	for _, key := range []string{"a", "b"} {
+		_, ok, _ := store.Get(key)
+		if ok {
+			t.Errorf("Expected value %v to be deleted", key)
+		}
+	}
+}
+
+func Test_Manager_Get_Stats_Success(t *testing.T) {
+	manager := NewManager(NewMemoryStore(10), time.Minute)
+	flight := models.Flight{ID: 1, TenantID: "a", Blocks: []models.Block{{ID: 2, Rows: 3}}}
+
+	var cached models.Flight
+	if manager.Get(FlightKey("a", 1), &cached) {
+		t.Fatal("Expected cache miss")
+	}
+
+	manager.Set(FlightKey("a", 1), flight)
+
+	if !manager.Get(FlightKey("a", 1), &cached) {
+		t.Fatal("Expected cache hit")
+	}
+	if cached.TenantID != "a" || len(cached.Blocks) != 1 || cached.Blocks[0].ID != 2 {
+		t.Error("Expected cached value to keep fields hidden from json")
+	}
+
+	manager.Delete(FlightKey("a", 1))
+
+	if manager.Get(FlightKey("a", 1), &cached) {
+		t.Error("Expected cache miss after invalidation")
+	}
+
+	stats := manager.Stats()
+	if stats != (models.CacheStats{Hits: 1, Misses: 2, Invalidations: 1}) {
+		t.Errorf("Unexpected cache stats %+v", stats)
+	}
+}
+
+func Test_Manager_Get_StoreError_Failure(t *testing.T) {
+	manager := NewManager(&FailingStore{}, time.Minute)
+
+	manager.Set("key", 1)
+
+	var value int
+	if manager.Get("key", &value) {
+		t.Error("Expected store error to be a miss")
+	}
+
+	stats := manager.Stats()
+	if stats.Errors != 2 || stats.Misses != 1 {
+		t.Errorf("Unexpected cache stats %+v", stats)
+	}
+}
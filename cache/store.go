@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Store is cache entry store interface, a shared store lets several instances see the same entries
+type Store interface {
+	// Get returns value of the key if it is stored and not expired
+	Get(key string) ([]byte, bool, error)
+	// Set stores value of the key for the time to live
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes values of the keys
+	Delete(keys ...string) error
+}
+
+// entry is stored value of the memory store
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryStore is in-memory cache entry store evicting least recently used entries over the capacity
+type MemoryStore struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+// NewMemoryStore is a constructor of in-memory cache entry store keeping up to capacity entries
+func NewMemoryStore(capacity int) Store {
+	return &MemoryStore{capacity: capacity, entries: map[string]*list.Element{}, order: list.New(), now: time.Now}
+}
+
+// Get returns value of the key if it is stored and not expired
+func (store *MemoryStore) Get(key string) ([]byte, bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	element, ok := store.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	stored := element.Value.(*entry)
+	if !stored.expiresAt.After(store.now()) {
+		store.remove(element)
+		return nil, false, nil
+	}
+
+	store.order.MoveToFront(element)
+	return stored.value, true, nil
+}
+
+// Set stores value of the key for the time to live
+func (store *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	stored := &entry{key: key, value: value, expiresAt: store.now().Add(ttl)}
+
+	element, ok := store.entries[key]
+	if ok {
+		element.Value = stored
+		store.order.MoveToFront(element)
+		return nil
+	}
+
+	store.entries[key] = store.order.PushFront(stored)
+	for store.order.Len() > store.capacity {
+		store.remove(store.order.Back())
+	}
+
+	return nil
+}
+
+// Delete removes values of the keys
+func (store *MemoryStore) Delete(keys ...string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, key := range keys {
+		element, ok := store.entries[key]
+		if ok {
+			store.remove(element)
+		}
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) remove(element *list.Element) {
+	store.order.Remove(element)
+	delete(store.entries, element.Value.(*entry).key)
+}
@@ -0,0 +1,493 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/lifecycle"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/ratelimit"
+	"github.com/vsukhin/booking/tracing"
+)
+
+const (
+	// FlagConfig is flag of the configuration file
+	FlagConfig = "config"
+	// EnvConfig is env var of the configuration file
+	EnvConfig = "BOOKING_API_CONFIG"
+	// flagSetName is name of the service flag set
+	flagSetName = "booking"
+	// tagYAML is yaml key tag
+	tagYAML = "yaml"
+	// tagEnv is env var tag
+	tagEnv = "env"
+	// tagFlag is flag tag
+	tagFlag = "flag"
+	// tagReload is tag of the setting reloaded without restart
+	tagReload = "reload"
+)
+
+var (
+	// usages contains usages of the flags
+	usages = map[string]string{
+		"mode":              "Service running mode: dev, staging, prod",
+		"host":              "HTTP server host address",
+		"port":              "HTTP server port",
+		"request-timeout":   "Deadline of the request handling including its db operations, 0 for none",
+		"drain-delay":       "Time between readiness going off on SIGTERM and shutdown of the http server",
+		"drain-timeout":     "Time given to the service to stop including drain delay, the stop is forced after it",
+		"log-level":         "Logging level: debug, info, warn, error; by mode by default",
+		"db":                "DB connection string",
+		"db-max-idle-conns": "Maximum idle db connections",
+		"db-max-open-conns": "Maximum open db connections, 0 for unlimited",
+		"keys":              "Auth key set file with api keys and jwt keys",
+		"trace-exporter":    "Trace exporter: none, stdout, file",
+		"trace-file":        "OTLP JSON file of the file trace exporter",
+		"max-rows":          "Max rows of the flight",
+		"max-lines":         "Max seats in the row of the flight",
+		"page-size":         "Page size of lists requested without limit",
+		"rate-read":         "Reads and requests not changing seats per minute of the client, 0 for unlimited",
+		"rate-read-burst":   "Reads of the client allowed at once",
+		"rate-write":        "Seat changes per minute of the client, 0 for unlimited",
+		"rate-write-burst":  "Seat changes of the client allowed at once",
+		"rate-ip":           "Requests per minute of the client ip before authentication, 0 for unlimited",
+		"rate-ip-burst":     "Requests of the client ip allowed at once",
+		"trusted-proxies":   "Comma separated ips and cidrs of the proxies whose X-Forwarded-For is trusted",
+	}
+)
+
+// HTTP contains http server settings
+type HTTP struct {
+	Host           string        `yaml:"host" env:"BOOKING_API_HTTP_HOST" flag:"host"`
+	Port           int           `yaml:"port" env:"BOOKING_API_HTTP_PORT" flag:"port"`
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"BOOKING_API_REQUEST_TIMEOUT" flag:"request-timeout"`
+	DrainDelay     time.Duration `yaml:"drain_delay" env:"BOOKING_API_DRAIN_DELAY" flag:"drain-delay"`
+	DrainTimeout   time.Duration `yaml:"drain_timeout" env:"BOOKING_API_DRAIN_TIMEOUT" flag:"drain-timeout"`
+}
+
+// Log contains logging settings
+type Log struct {
+	Level string `yaml:"level" env:"BOOKING_API_LOG_LEVEL" flag:"log-level" reload:"true"`
+}
+
+// DB contains db settings
+type DB struct {
+	Connection   string `yaml:"connection" env:"BOOKING_API_DB" flag:"db"`
+	MaxIdleConns int    `yaml:"max_idle_conns" env:"BOOKING_API_DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" reload:"true"`
+	MaxOpenConns int    `yaml:"max_open_conns" env:"BOOKING_API_DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" reload:"true"`
+}
+
+// Auth contains authentication settings
+type Auth struct {
+	KeySetFile string `yaml:"keys" env:"BOOKING_API_KEYS" flag:"keys" reload:"true"`
+}
+
+// Trace contains tracing settings
+type Trace struct {
+	Exporter string `yaml:"exporter" env:"BOOKING_API_TRACE_EXPORTER" flag:"trace-exporter"`
+	File     string `yaml:"file" env:"BOOKING_API_TRACE_FILE" flag:"trace-file"`
+}
+
+// Limits contains limits of the requests
+type Limits struct {
+	MaxRows  int   `yaml:"max_rows" env:"BOOKING_API_MAX_ROWS" flag:"max-rows" reload:"true"`
+	MaxLines int   `yaml:"max_lines" env:"BOOKING_API_MAX_LINES" flag:"max-lines" reload:"true"`
+	PageSize int64 `yaml:"page_size" env:"BOOKING_API_PAGE_SIZE" flag:"page-size" reload:"true"`
+}
+
+// RateLimit contains per client rate limits of the requests
+type RateLimit struct {
+	Read           int    `yaml:"read" env:"BOOKING_API_RATE_READ" flag:"rate-read" reload:"true"`
+	ReadBurst      int    `yaml:"read_burst" env:"BOOKING_API_RATE_READ_BURST" flag:"rate-read-burst" reload:"true"`
+	Write          int    `yaml:"write" env:"BOOKING_API_RATE_WRITE" flag:"rate-write" reload:"true"`
+	WriteBurst     int    `yaml:"write_burst" env:"BOOKING_API_RATE_WRITE_BURST" flag:"rate-write-burst" reload:"true"`
+	IP             int    `yaml:"ip" env:"BOOKING_API_RATE_IP" flag:"rate-ip" reload:"true"`
+	IPBurst        int    `yaml:"ip_burst" env:"BOOKING_API_RATE_IP_BURST" flag:"rate-ip-burst" reload:"true"`
+	TrustedProxies string `yaml:"trusted_proxies" env:"BOOKING_API_TRUSTED_PROXIES" flag:"trusted-proxies"`
+}
+
+// Config is service configuration
+type Config struct {
+	Mode      string    `yaml:"mode" env:"BOOKING_API_MODE" flag:"mode"`
+	HTTP      HTTP      `yaml:"http"`
+	Log       Log       `yaml:"log"`
+	DB        DB        `yaml:"db"`
+	Auth      Auth      `yaml:"auth"`
+	Trace     Trace     `yaml:"trace"`
+	Limits    Limits    `yaml:"limits"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+}
+
+// Errors contains all errors of the configuration
+type Errors []error
+
+// Error joins errors of the configuration
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// setting is configuration leaf field
+type setting struct {
+	path  string
+	field reflect.StructField
+	value reflect.Value
+}
+
+// Default returns default configuration
+func Default() *Config {
+	return &Config{
+		Mode: logging.ModeDev,
+		HTTP: HTTP{
+			Port:           3000,
+			RequestTimeout: 30 * time.Second,
+			DrainDelay:     5 * time.Second,
+			DrainTimeout:   lifecycle.DefaultDrainTimeout,
+		},
+		DB: DB{
+			MaxIdleConns: sqldb.DefaultMaxIdleConns,
+			MaxOpenConns: sqldb.DefaultMaxOpenConns,
+		},
+		Trace: Trace{
+			Exporter: tracing.ExporterNone,
+			File:     "traces.json",
+		},
+		Limits: Limits{
+			MaxRows:  models.DefaultMaxRows,
+			MaxLines: models.DefaultMaxLines,
+			PageSize: helpers.DefaultLimit,
+		},
+		RateLimit: RateLimit{
+			Read:       ratelimit.DefaultReadPerMinute,
+			ReadBurst:  ratelimit.DefaultReadBurst,
+			Write:      ratelimit.DefaultMutationPerMinute,
+			WriteBurst: ratelimit.DefaultMutationBurst,
+			IP:         ratelimit.DefaultAddressPerMinute,
+			IPBurst:    ratelimit.DefaultAddressBurst,
+		},
+	}
+}
+
+// Load loads configuration from the yaml file, env vars and flags, later ones take precedence over earlier ones
+// and defaults, returns validated configuration and arguments left after flags
+func Load(args []string, getenv func(key string) string) (*Config, []string, error) {
+	config := Default()
+	settings := config.settings()
+
+	flags := flag.NewFlagSet(flagSetName, flag.ContinueOnError)
+	file := flags.String(FlagConfig, "", "YAML configuration file")
+	for _, s := range settings {
+		flags.String(s.field.Tag.Get(tagFlag), s.String(), usages[s.field.Tag.Get(tagFlag)])
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if *file == "" {
+		*file = getenv(EnvConfig)
+	}
+
+	var errs Errors
+	if *file != "" {
+		errs = append(errs, config.loadFile(*file)...)
+	}
+
+	for _, s := range settings {
+		value := getenv(s.field.Tag.Get(tagEnv))
+		if value != "" {
+			errs = append(errs, s.set("env "+s.field.Tag.Get(tagEnv), value)...)
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.field.Tag.Get(tagFlag) == f.Name {
+				errs = append(errs, s.set("flag -"+f.Name, f.Value.String())...)
+			}
+		}
+	})
+
+	if len(errs) == 0 {
+		errs = config.validate()
+	}
+	if len(errs) != 0 {
+		return nil, nil, errs
+	}
+
+	return config, flags.Args(), nil
+}
+
+// loadFile loads yaml file rejecting unknown keys
+func (config *Config) loadFile(file string) Errors {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return Errors{err}
+	}
+
+	var keys map[interface{}]interface{}
+	err = yaml.Unmarshal(data, &keys)
+	if err != nil {
+		return Errors{fmt.Errorf("%s: %v", file, err)}
+	}
+
+	errs := unknownKeys(file, "", keys, reflect.TypeOf(*config))
+	if len(errs) != 0 {
+		return errs
+	}
+
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return Errors{fmt.Errorf("%s: %v", file, err)}
+	}
+
+	return nil
+}
+
+// unknownKeys returns errors of the keys not matching yaml tags of the type
+func unknownKeys(file string, prefix string, keys map[interface{}]interface{}, t reflect.Type) Errors {
+	var errs Errors
+
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, fmt.Sprint(key))
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		value := keys[name]
+
+		field, ok := fieldByTag(t, name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %s", file, path))
+			continue
+		}
+
+		nested, ok := value.(map[interface{}]interface{})
+		if ok && field.Type.Kind() == reflect.Struct {
+			errs = append(errs, unknownKeys(file, path+".", nested, field.Type)...)
+		}
+	}
+
+	return errs
+}
+
+// fieldByTag finds struct field by yaml tag
+func fieldByTag(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get(tagYAML) == key {
+			return t.Field(i), true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// settings returns leaf fields of the configuration
+func (config *Config) settings() []setting {
+	return collect("", reflect.ValueOf(config).Elem())
+}
+
+func collect(prefix string, value reflect.Value) []setting {
+	var settings []setting
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		path := prefix + field.Tag.Get(tagYAML)
+
+		if field.Type.Kind() == reflect.Struct {
+			settings = append(settings, collect(path+".", value.Field(i))...)
+			continue
+		}
+
+		settings = append(settings, setting{path: path, field: field, value: value.Field(i)})
+	}
+
+	return settings
+}
+
+// String formats value of the setting
+func (s setting) String() string {
+	if duration, ok := s.value.Interface().(time.Duration); ok {
+		return duration.String()
+	}
+
+	return fmt.Sprint(s.value.Interface())
+}
+
+// set parses value of the setting
+func (s setting) set(source string, value string) Errors {
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(value)
+	case reflect.Int, reflect.Int64:
+		if s.value.Type() == reflect.TypeOf(time.Duration(0)) {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return Errors{fmt.Errorf("%s: %s must be duration like 30s, got %q", source, s.path, value)}
+			}
+			s.value.SetInt(int64(duration))
+			return nil
+		}
+
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Errors{fmt.Errorf("%s: %s must be integer, got %q", source, s.path, value)}
+		}
+		s.value.SetInt(number)
+	}
+
+	return nil
+}
+
+// validate validates configuration
+func (config *Config) validate() Errors {
+	var errs Errors
+
+	if config.Mode != logging.ModeDev && config.Mode != logging.ModeStaging && config.Mode != logging.ModeProd {
+		errs = append(errs, fmt.Errorf("mode must be dev, staging or prod, got %q", config.Mode))
+	}
+	if config.HTTP.Port < 1 || config.HTTP.Port > 65535 {
+		errs = append(errs, fmt.Errorf("http.port must be between 1 and 65535, got %v", config.HTTP.Port))
+	}
+	if config.HTTP.RequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("http.request_timeout must not be negative"))
+	}
+	if config.HTTP.DrainTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("http.drain_timeout must be positive"))
+	}
+	if config.HTTP.DrainDelay < 0 || config.HTTP.DrainDelay >= config.HTTP.DrainTimeout {
+		errs = append(errs, fmt.Errorf("http.drain_delay must be between 0 and http.drain_timeout %v, got %v",
+			config.HTTP.DrainTimeout, config.HTTP.DrainDelay))
+	}
+	if config.Log.Level != "" {
+		_, err := logrus.ParseLevel(config.Log.Level)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", config.Log.Level))
+		}
+	}
+	if config.DB.Connection == "" {
+		errs = append(errs, fmt.Errorf("db.connection must be provided"))
+	}
+	if config.DB.MaxIdleConns < 0 || config.DB.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("db.max_idle_conns and db.max_open_conns must not be negative"))
+	}
+	if config.DB.MaxOpenConns > 0 && config.DB.MaxIdleConns > config.DB.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("db.max_idle_conns must not exceed db.max_open_conns %v, got %v",
+			config.DB.MaxOpenConns, config.DB.MaxIdleConns))
+	}
+	if config.Trace.Exporter != tracing.ExporterNone && config.Trace.Exporter != tracing.ExporterStdout &&
+		config.Trace.Exporter != tracing.ExporterFile {
+		errs = append(errs, fmt.Errorf("trace.exporter must be none, stdout or file, got %q", config.Trace.Exporter))
+	}
+	if config.Trace.Exporter == tracing.ExporterFile && config.Trace.File == "" {
+		errs = append(errs, fmt.Errorf("trace.file must be provided for file exporter"))
+	}
+	if config.Limits.MaxRows < 1 {
+		errs = append(errs, fmt.Errorf("limits.max_rows must be positive, got %v", config.Limits.MaxRows))
+	}
+	if config.Limits.MaxLines < 1 || config.Limits.MaxLines > models.MaxLinesLimit {
+		errs = append(errs, fmt.Errorf("limits.max_lines must be between 1 and %v, got %v", models.MaxLinesLimit,
+			config.Limits.MaxLines))
+	}
+	if config.Limits.PageSize < 1 {
+		errs = append(errs, fmt.Errorf("limits.page_size must be positive, got %v", config.Limits.PageSize))
+	}
+	if config.RateLimit.Read < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.read must not be negative, got %v", config.RateLimit.Read))
+	}
+	if config.RateLimit.Read > 0 && config.RateLimit.ReadBurst < 1 {
+		errs = append(errs, fmt.Errorf("rate_limit.read_burst must be positive, got %v", config.RateLimit.ReadBurst))
+	}
+	if config.RateLimit.Write < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.write must not be negative, got %v", config.RateLimit.Write))
+	}
+	if config.RateLimit.Write > 0 && config.RateLimit.WriteBurst < 1 {
+		errs = append(errs, fmt.Errorf("rate_limit.write_burst must be positive, got %v", config.RateLimit.WriteBurst))
+	}
+	if config.RateLimit.IP < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.ip must not be negative, got %v", config.RateLimit.IP))
+	}
+	if config.RateLimit.IP > 0 && config.RateLimit.IPBurst < 1 {
+		errs = append(errs, fmt.Errorf("rate_limit.ip_burst must be positive, got %v", config.RateLimit.IPBurst))
+	}
+	if _, err := ratelimit.ParseProxies(config.RateLimit.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies must be ips or cidrs, %v", err))
+	}
+
+	return errs
+}
+
+// LogLevel returns logging level, by mode if it is not set
+func (config *Config) LogLevel() logrus.Level {
+	level, err := logrus.ParseLevel(config.Log.Level)
+	if err == nil {
+		return level
+	}
+
+	if config.Mode == logging.ModeStaging || config.Mode == logging.ModeProd {
+		return logrus.InfoLevel
+	}
+
+	return logrus.DebugLevel
+}
+
+// RateLimitBudgets returns per client budgets of the reads, the seat writes and the client ip
+func (config *Config) RateLimitBudgets() ratelimit.Budgets {
+	return ratelimit.NewBudgets(config.RateLimit.Read, config.RateLimit.ReadBurst, config.RateLimit.Write,
+		config.RateLimit.WriteBurst, config.RateLimit.IP, config.RateLimit.IPBurst)
+}
+
+// TrustedProxies returns networks of the proxies whose X-Forwarded-For is trusted
+func (config *Config) TrustedProxies() []*net.IPNet {
+	proxies, _ := ratelimit.ParseProxies(config.RateLimit.TrustedProxies)
+	return proxies
+}
+
+// IsProduction checks whether the service runs in production mode
+func (config *Config) IsProduction() bool {
+	return config.Mode == logging.ModeStaging || config.Mode == logging.ModeProd
+}
+
+// Reload returns configuration with reloadable settings of the next configuration and other settings kept,
+// changed settings requiring restart are returned to be reported
+func (config *Config) Reload(next *Config) (*Config, []string) {
+	reloaded := *config
+	var restart []string
+
+	current := config.settings()
+	updated := reloaded.settings()
+	for i, s := range next.settings() {
+		if reflect.DeepEqual(current[i].value.Interface(), s.value.Interface()) {
+			continue
+		}
+
+		if s.field.Tag.Get(tagReload) == "true" {
+			updated[i].value.Set(s.value)
+			continue
+		}
+
+		restart = append(restart, s.path)
+	}
+
+	return &reloaded, restart
+}
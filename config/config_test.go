@@ -0,0 +1,146 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func writeFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal("Expected to create temp file successfully")
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	if err != nil {
+		t.Fatal("Expected to write temp file successfully")
+	}
+
+	return file.Name()
+}
+
+func env(values map[string]string) func(key string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func Test_Load_Precedence_Success(t *testing.T) {
+	file := writeFile(t, "mode: prod\nhttp:\n  host: 10.0.0.1\n  port: 4000\n  drain_delay: 2s\n"+
+		"db:\n  connection: file\nlimits:\n  max_rows: 100\n")
+	defer os.Remove(file)
+
+	config, args, err := Load([]string{"-config", file, "-port", "6000", "import", "-tenant", "a"}, env(map[string]string{
+		"BOOKING_API_HTTP_PORT":       "5000",
+		"BOOKING_API_DB":              "env",
+		"BOOKING_API_REQUEST_TIMEOUT": "1m",
+	}))
+	if err != nil {
+		t.Fatalf("Expected to load configuration successfully, got %v", err)
+	}
+
+	if config.Mode != "prod" || config.HTTP.Host != "10.0.0.1" || config.HTTP.DrainDelay != 2*time.Second ||
+		config.Limits.MaxRows != 100 {
+		t.Errorf("Expected file to override defaults, got %+v", config)
+	}
+	if config.DB.Connection != "env" || config.HTTP.RequestTimeout != time.Minute {
+		t.Errorf("Expected env to override file, got %+v", config)
+	}
+	if config.HTTP.Port != 6000 {
+		t.Errorf("Expected flag to override env, got %v", config.HTTP.Port)
+	}
+	if config.Limits.MaxLines != 20 || config.HTTP.DrainTimeout != 30*time.Second {
+		t.Errorf("Expected defaults of settings not set, got %+v", config)
+	}
+	if strings.Join(args, " ") != "import -tenant a" {
+		t.Errorf("Expected arguments after flags, got %v", args)
+	}
+	if config.LogLevel() != logrus.InfoLevel {
+		t.Errorf("Expected log level of the mode, got %v", config.LogLevel())
+	}
+}
+
+func Test_Load_Env_Failure(t *testing.T) {
+	_, _, err := Load(nil, env(map[string]string{
+		"BOOKING_API_DB":            "env",
+		"BOOKING_API_HTTP_PORT":     "http",
+		"BOOKING_API_DRAIN_TIMEOUT": "30",
+	}))
+	if err == nil {
+		t.Fatal("Expected invalid env vars to fail")
+	}
+
+	for _, message := range []string{
+		`env BOOKING_API_HTTP_PORT: http.port must be integer, got "http"`,
+		`env BOOKING_API_DRAIN_TIMEOUT: http.drain_timeout must be duration like 30s, got "30"`,
+	} {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected error %v, got %v", message, err)
+		}
+	}
+}
+
+func Test_Load_File_Failure(t *testing.T) {
+	file := writeFile(t, "db:\n  connection: file\n  pool: 10\nlimit:\n  max_rows: 10\n")
+	defer os.Remove(file)
+
+	_, _, err := Load(nil, env(map[string]string{EnvConfig: file}))
+	if err == nil {
+		t.Fatal("Expected unknown settings to fail")
+	}
+
+	errs := err.(Errors)
+	if len(errs) != 2 || !strings.HasSuffix(errs[0].Error(), "unknown setting db.pool") ||
+		!strings.HasSuffix(errs[1].Error(), "unknown setting limit") {
+		t.Errorf("Expected unknown settings to be reported, got %v", err)
+	}
+}
+
+func Test_Load_Validate_Failure(t *testing.T) {
+	_, _, err := Load([]string{"-mode", "test", "-port", "0", "-drain-delay", "1m", "-log-level", "verbose",
+		"-db-max-idle-conns", "200", "-trace-exporter", "jaeger", "-max-lines", "30", "-rate-read-burst", "0",
+		"-rate-write", "-1", "-rate-ip-burst", "0", "-trusted-proxies", "10.0.0.0/33"}, env(nil))
+	if err == nil {
+		t.Fatal("Expected invalid configuration to fail")
+	}
+
+	for _, setting := range []string{"mode", "http.port", "http.drain_delay", "log.level", "db.connection",
+		"db.max_idle_conns", "trace.exporter", "limits.max_lines", "rate_limit.read_burst", "rate_limit.write",
+		"rate_limit.ip_burst", "rate_limit.trusted_proxies"} {
+		if !strings.Contains(err.Error(), setting+" must") {
+			t.Errorf("Expected error of %v, got %v", setting, err)
+		}
+	}
+}
+
+func Test_Config_Reload_Success(t *testing.T) {
+	current := Default()
+	current.DB.Connection = "db"
+
+	next := Default()
+	next.DB.Connection = "db"
+	next.HTTP.Port = 4000
+	next.Log.Level = "error"
+	next.Limits.PageSize = 10
+	next.DB.MaxOpenConns = 10
+	next.RateLimit.Write = 0
+
+	reloaded, restart := current.Reload(next)
+
+	if reloaded.Log.Level != "error" || reloaded.Limits.PageSize != 10 || reloaded.DB.MaxOpenConns != 10 ||
+		reloaded.RateLimitBudgets().Mutation.Rate != 0 {
+		t.Errorf("Expected reloadable settings to be reloaded, got %+v", reloaded)
+	}
+	if reloaded.HTTP.Port != 3000 || current.Log.Level != "" {
+		t.Errorf("Expected other settings and current configuration to be kept, got %+v", reloaded)
+	}
+	if len(restart) != 1 || restart[0] != "http.port" {
+		t.Errorf("Expected changed settings requiring restart to be reported, got %v", restart)
+	}
+}
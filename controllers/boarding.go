@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/services"
+)
+
+// BoardingController is a boarding controller
+type BoardingController struct {
+	boardingService services.BoardingServiceInterface
+	flightService   services.FlightServiceInterface
+}
+
+// BoardingControllerInterface is an interface for boarding controller methods
+type BoardingControllerInterface interface {
+	Manifest(c *gin.Context)
+}
+
+// NewBoardingController is a constructor for boarding controller
+func NewBoardingController(boardingService services.BoardingServiceInterface,
+	flightService services.FlightServiceInterface) BoardingControllerInterface {
+	return &BoardingController{boardingService: boardingService, flightService: flightService}
+}
+
+// Manifest lists passengers of the flight by boarding zone
+func (boardingController *BoardingController) Manifest(c *gin.Context) {
+	flight, err := getFlight(c, boardingController.flightService)
+	if err != nil {
+		return
+	}
+
+	var query models.BoardingQuery
+
+	err = c.ShouldBindWith(&query, binding.Form)
+	if err != nil {
+		abortWithBindError(c, err)
+		return
+	}
+
+	errs := query.Validate()
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"query":  query,
+			"errors": errs,
+		}).Error("Error validating boarding manifest parameters")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	manifest, err := boardingController.boardingService.Manifest(c.Request.Context(), flight, &query)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, manifest)
+}
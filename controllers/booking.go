@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/services"
+)
+
+// BookingController is a booking controller
+type BookingController struct {
+	bookingService services.BookingServiceInterface
+	flightService  services.FlightServiceInterface
+}
+
+// BookingControllerInterface is an interface for booking controller methods
+type BookingControllerInterface interface {
+	ListAll(c *gin.Context)
+	Create(c *gin.Context)
+	Close(c *gin.Context)
+	DeniedBoarding(c *gin.Context)
+}
+
+// NewBookingController is a constructor for booking controller
+func NewBookingController(bookingService services.BookingServiceInterface,
+	flightService services.FlightServiceInterface) BookingControllerInterface {
+	return &BookingController{bookingService: bookingService, flightService: flightService}
+}
+
+// ListAll lists bookings of the flight, customers see own bookings only
+func (bookingController *BookingController) ListAll(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	flight, err := getFlight(c, bookingController.flightService)
+	if err != nil {
+		return
+	}
+
+	bookings, err := bookingController.bookingService.ListAll(c.Request.Context(), flight.TenantID, flight.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	visible := []models.Booking{}
+	for _, booking := range bookings {
+		if principal.CanManage(booking.Owner) {
+			visible = append(visible, booking)
+		}
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// Create creates confirmed booking without seat
+func (bookingController *BookingController) Create(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	flight, err := getFlight(c, bookingController.flightService)
+	if err != nil {
+		return
+	}
+
+	var bookingCreate models.BookingCreate
+
+	err = c.ShouldBindWith(&bookingCreate, binding.JSON)
+	if err != nil {
+		abortWithBindError(c, err)
+		return
+	}
+
+	errs := bookingCreate.Validate()
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"bookingCreate": bookingCreate,
+			"errors":        errs,
+		}).Error("Error validating booking")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	if bookingCreate.Owner == "" {
+		bookingCreate.Owner = principal.Subject
+	}
+
+	err = checkOwner(c, principal, bookingCreate.Owner)
+	if err != nil {
+		return
+	}
+
+	booking := &models.Booking{
+		TenantID: flight.TenantID,
+		FlightID: flight.ID,
+		Owner:    bookingCreate.Owner,
+	}
+
+	err = bookingController.bookingService.Create(c.Request.Context(), booking)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	setETag(c, booking.Version)
+	c.JSON(http.StatusCreated, booking)
+}
+
+// Close closes the flight and reports passengers denied boarding
+func (bookingController *BookingController) Close(c *gin.Context) {
+	flight, err := getFlight(c, bookingController.flightService)
+	if err != nil {
+		return
+	}
+
+	err = checkPrecondition(c, flight.Version)
+	if err != nil {
+		return
+	}
+
+	report, err := bookingController.bookingService.Close(c.Request.Context(), flight)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	setETag(c, flight.Version)
+	c.JSON(http.StatusOK, report)
+}
+
+// DeniedBoarding reports passengers denied boarding on the closed flight
+func (bookingController *BookingController) DeniedBoarding(c *gin.Context) {
+	flight, err := getFlight(c, bookingController.flightService)
+	if err != nil {
+		return
+	}
+
+	report, err := bookingController.bookingService.DeniedBoarding(c.Request.Context(), flight)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/cache"
+)
+
+// CacheController is a cache controller
+type CacheController struct {
+	cacheManager cache.ManagerInterface
+}
+
+// CacheControllerInterface is an interface for cache controller methods
+type CacheControllerInterface interface {
+	Stats(c *gin.Context)
+}
+
+// NewCacheController is a constructor for cache controller
+func NewCacheController(cacheManager cache.ManagerInterface) CacheControllerInterface {
+	return &CacheController{cacheManager: cacheManager}
+}
+
+// Stats returns cache hit and miss counters
+func (cacheController *CacheController) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, cacheController.cacheManager.Stats())
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/services"
+)
+
+const (
+	// formatBCBP is boarding pass format returning bar coded boarding pass string
+	formatBCBP = "bcbp"
+)
+
+// CheckinController is a check-in controller
+type CheckinController struct {
+	checkinService services.CheckinServiceInterface
+	bookingService services.BookingServiceInterface
+	seatService    services.SeatServiceInterface
+	flightService  services.FlightServiceInterface
+}
+
+// CheckinControllerInterface is an interface for check-in controller methods
+type CheckinControllerInterface interface {
+	Create(c *gin.Context)
+	BoardingPass(c *gin.Context)
+}
+
+// NewCheckinController is a constructor for check-in controller
+func NewCheckinController(checkinService services.CheckinServiceInterface,
+	bookingService services.BookingServiceInterface, seatService services.SeatServiceInterface,
+	flightService services.FlightServiceInterface) CheckinControllerInterface {
+	return &CheckinController{checkinService: checkinService, bookingService: bookingService,
+		seatService: seatService, flightService: flightService}
+}
+
+// Create checks in passenger of the assigned seat or seats passenger of the confirmed booking first
+func (checkinController *CheckinController) Create(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	flight, err := getFlight(c, checkinController.flightService)
+	if err != nil {
+		return
+	}
+
+	var checkinCreate models.CheckinCreate
+
+	err = c.ShouldBindWith(&checkinCreate, binding.JSON)
+	if err != nil {
+		abortWithBindError(c, err)
+		return
+	}
+
+	errs := checkinCreate.Validate()
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"checkinCreate": checkinCreate,
+			"errors":        errs,
+		}).Error("Error validating check-in")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	if flight.Status == models.FlightStatusClosed {
+		abortWithError(c, apperrors.ErrFlightClosed)
+		return
+	}
+
+	var owner string
+	index := checkinCreate.Index
+
+	if checkinCreate.BookingID != 0 {
+		booking, err := checkinController.bookingService.Retrieve(c.Request.Context(), flight.TenantID, flight.ID,
+			checkinCreate.BookingID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		err = checkOwner(c, principal, booking.Owner)
+		if err != nil {
+			return
+		}
+
+		if booking.Status == models.BookingStatusConfirmed {
+			_, err = checkinController.bookingService.Seat(c.Request.Context(), booking)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+
+		if booking.Status != models.BookingStatusSeated {
+			abortWithError(c, apperrors.ErrBookingNotConfirmed)
+			return
+		}
+
+		owner = booking.Owner
+		index = booking.SeatIndex
+	} else {
+		seat, err := checkinController.seatService.Retrieve(c.Request.Context(), flight.TenantID, flight.ID, int64(index))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		if !seat.Assigned {
+			abortWithError(c, apperrors.ErrSeatNotAssigned)
+			return
+		}
+
+		err = checkOwner(c, principal, seat.Owner)
+		if err != nil {
+			return
+		}
+
+		owner = seat.Owner
+	}
+
+	checkin := &models.Checkin{
+		TenantID:  flight.TenantID,
+		FlightID:  flight.ID,
+		SeatIndex: index,
+		Owner:     owner,
+		FirstName: checkinCreate.FirstName,
+		LastName:  checkinCreate.LastName,
+		PNR:       checkinCreate.PNR,
+	}
+
+	err = checkinController.checkinService.Create(c.Request.Context(), checkin)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, checkinController.checkinService.BoardingPass(flight, checkin))
+}
+
+// BoardingPass retrieves boarding pass as json or as bar coded boarding pass string with format=bcbp
+func (checkinController *CheckinController) BoardingPass(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	flight, err := getFlight(c, checkinController.flightService)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Params.ByName("checkinId"), 10, 64)
+	if err != nil {
+		errs := []models.Error{models.Error{
+			Code:    "checkinId.Invalid",
+			Message: "Check-in id is not integer",
+			Field:   "checkinId",
+		}}
+
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"error":     err,
+			"errors":    errs,
+			"checkinId": c.Params.ByName("checkinId"),
+		}).Error("Check-in id is not integer")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	checkin, err := checkinController.checkinService.Retrieve(c.Request.Context(), flight.TenantID, flight.ID, id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	err = checkOwner(c, principal, checkin.Owner)
+	if err != nil {
+		return
+	}
+
+	boardingPass := checkinController.checkinService.BoardingPass(flight, checkin)
+	if c.Query("format") == formatBCBP {
+		c.String(http.StatusOK, boardingPass.BCBP)
+		return
+	}
+
+	c.JSON(http.StatusOK, boardingPass)
+}
@@ -2,16 +2,33 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/helpers"
 	"github.com/vsukhin/booking/logging"
 	"github.com/vsukhin/booking/models"
 	"github.com/vsukhin/booking/services"
 )
 
+const (
+	// headerETag is entity tag header
+	headerETag = "ETag"
+	// headerIfMatch is if match header
+	headerIfMatch = "If-Match"
+	// headerIfNoneMatch is if none match header
+	headerIfNoneMatch = "If-None-Match"
+	// headerRetryAfter is header of the seconds until the request can be retried
+	headerRetryAfter = "Retry-After"
+	// weakPrefix is weak entity tag prefix
+	weakPrefix = "W/"
+)
+
 func getFlight(c *gin.Context, flightService services.FlightServiceInterface) (*models.Flight, error) {
 	flightID, err := strconv.ParseInt(c.Params.ByName("flightId"), 10, 64)
 	if err != nil {
@@ -21,26 +38,126 @@ func getFlight(c *gin.Context, flightService services.FlightServiceInterface) (*
 			Field:   "flightId",
 		}}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 			"error":    err,
 			"errors":   errs,
 			"flightId": c.Params.ByName("flightId"),
 		}).Error("Flight id is not integer")
 
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return nil, err
 	}
 
-	flight, err := flightService.Retrieve(flightID)
+	flight, err := flightService.Retrieve(c.Request.Context(), auth.GetTenant(c), flightID)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return nil, err
 	}
 
-	if flight == nil {
-		c.Status(http.StatusNotFound)
-		return nil, errors.New("Flight not found")
+	return flight, nil
+}
+
+func getPrincipal(c *gin.Context) (*models.Principal, error) {
+	principal := auth.GetPrincipal(c)
+	if principal == nil {
+		errs := []models.Error{models.Error{
+			Code:    "credentials.Missing",
+			Message: "Credentials are missing",
+			Field:   auth.HeaderAuthorization,
+		}}
+
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"errors": errs,
+			"path":   c.Request.URL.Path,
+		}).Error("Credentials are missing")
+
+		helpers.AbortWithErrors(c, http.StatusUnauthorized, errs)
+		return nil, errors.New("Principal not found")
 	}
 
-	return flight, nil
+	return principal, nil
+}
+
+func checkOwner(c *gin.Context, principal *models.Principal, owner string) error {
+	if !principal.CanManage(owner) {
+		errs := []models.Error{models.Error{
+			Code:    "owner.Forbidden",
+			Message: "Seat belongs to another owner",
+			Field:   "owner",
+		}}
+
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"errors":    errs,
+			"principal": *principal,
+			"owner":     owner,
+		}).Error("Seat belongs to another owner")
+
+		helpers.AbortWithErrors(c, http.StatusForbidden, errs)
+		return errors.New("Seat owner not allowed")
+	}
+
+	return nil
+}
+
+func concealOwner(principal *models.Principal, seat *models.Seat) {
+	if !principal.CanManage(seat.Owner) {
+		seat.Owner = ""
+	}
+}
+
+func etag(version int64) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+func matchETag(header string, version int64, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, weakPrefix)
+		}
+
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setETag(c *gin.Context, version int64) {
+	c.Header(headerETag, etag(version))
+}
+
+func notModified(c *gin.Context, version int64) bool {
+	setETag(c, version)
+
+	header := c.Request.Header.Get(headerIfNoneMatch)
+	if header != "" && matchETag(header, version, true) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+func checkPrecondition(c *gin.Context, version int64) error {
+	header := c.Request.Header.Get(headerIfMatch)
+	if header == "" || matchETag(header, version, false) {
+		return nil
+	}
+
+	errs := []models.Error{models.Error{
+		Code:    "version.Mismatch",
+		Message: "Resource version does not match",
+		Field:   headerIfMatch,
+	}}
+
+	logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+		"errors":  errs,
+		"ifMatch": header,
+		"version": version,
+	}).Error("Resource version does not match")
+
+	helpers.AbortWithErrors(c, http.StatusPreconditionFailed, errs)
+	return errors.New("Precondition failed")
 }
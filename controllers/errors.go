@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	gorp "github.com/go-gorp/gorp/v3"
+
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+// kindErrors contains status and default error of the error kind
+var kindErrors = map[apperrors.Kind]struct {
+	status int
+	err    models.Error
+}{
+	apperrors.KindNotFound: {http.StatusNotFound,
+		models.Error{Code: "resource.NotFound", Message: "Resource not found"}},
+	apperrors.KindConflict: {http.StatusConflict,
+		models.Error{Code: "resource.Conflict", Message: "Resource state conflicts with request"}},
+	apperrors.KindValidation: {http.StatusUnprocessableEntity,
+		models.Error{Code: "data.Invalid", Message: "Data can't be processed"}},
+	apperrors.KindUnavailable: {http.StatusServiceUnavailable,
+		models.Error{Code: "service.Unavailable", Message: "Service is temporarily unavailable"}},
+	apperrors.KindInternal: {http.StatusInternalServerError,
+		models.Error{Code: "server.Internal", Message: "Internal server error"}},
+}
+
+// abortWithError aborts request with status and error envelope matching the service error
+func abortWithError(c *gin.Context, err error) {
+	var lockErr gorp.OptimisticLockError
+	if errors.As(err, &lockErr) {
+		helpers.AbortWithErrors(c, http.StatusPreconditionFailed, []models.Error{models.Error{
+			Code:    "version.Conflict",
+			Message: "Resource was modified concurrently",
+			Field:   headerIfMatch,
+		}})
+		return
+	}
+
+	kind := apperrors.KindOf(err)
+	kindError := kindErrors[kind]
+
+	e := kindError.err
+	var classified *apperrors.Error
+	if errors.As(err, &classified) {
+		e = models.Error{
+			Code:    classified.Code,
+			Message: classified.Message,
+			Field:   classified.Field,
+			Details: classified.Details,
+		}
+		if classified.RetryAfter > 0 {
+			c.Header(headerRetryAfter, strconv.Itoa(int(math.Ceil(classified.RetryAfter.Seconds()))))
+		}
+	}
+
+	logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+		"error":  err,
+		"status": kindError.status,
+		"path":   c.Request.URL.Path,
+	}).Error(e.Message)
+
+	helpers.AbortWithErrors(c, kindError.status, []models.Error{e})
+}
+
+func abortWithBindError(c *gin.Context, err error) {
+	errs := helpers.BindErrors(err)
+
+	logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+		"error":  err,
+		"errors": errs,
+	}).Error("Error binding request body")
+
+	helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+}
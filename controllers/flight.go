@@ -2,10 +2,11 @@ package controllers
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 
+	"github.com/vsukhin/booking/auth"
 	"github.com/vsukhin/booking/helpers"
 	"github.com/vsukhin/booking/logging"
 	"github.com/vsukhin/booking/models"
@@ -24,7 +25,9 @@ type FlightControllerInterface interface {
 	ListAll(c *gin.Context)
 	GetMeta(c *gin.Context)
 	Create(c *gin.Context)
+	Update(c *gin.Context)
 	Delete(c *gin.Context)
+	Relayout(c *gin.Context)
 }
 
 // NewFlightController is a constructor for flight controller
@@ -40,6 +43,10 @@ func (flightController *FlightController) Retrieve(c *gin.Context) {
 		return
 	}
 
+	if notModified(c, flight.Version) {
+		return
+	}
+
 	c.JSON(http.StatusOK, flight)
 }
 
@@ -47,25 +54,26 @@ func (flightController *FlightController) Retrieve(c *gin.Context) {
 func (flightController *FlightController) ListAll(c *gin.Context) {
 	limitation, errs := flightController.queryManager.GetLimitation(c)
 	if len(errs) != 0 {
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
 	sorting, errs := flightController.queryManager.GetSorting(&models.Flight{}, c)
 	if len(errs) != 0 {
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
 	filtering, errs := flightController.queryManager.GetFiltering(&models.Flight{}, c)
 	if len(errs) != 0 {
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
-	flights, err := flightController.flightService.ListAll(filtering, sorting, limitation)
+	flights, err := flightController.flightService.ListAll(c.Request.Context(), auth.GetTenant(c), filtering, sorting,
+		limitation)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
@@ -76,13 +84,13 @@ func (flightController *FlightController) ListAll(c *gin.Context) {
 func (flightController *FlightController) GetMeta(c *gin.Context) {
 	filtering, errs := flightController.queryManager.GetFiltering(&models.Flight{}, c)
 	if len(errs) != 0 {
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
-	flightMeta, err := flightController.flightService.GetMeta(filtering)
+	flightMeta, err := flightController.flightService.GetMeta(c.Request.Context(), auth.GetTenant(c), filtering)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
@@ -93,42 +101,79 @@ func (flightController *FlightController) GetMeta(c *gin.Context) {
 func (flightController *FlightController) Create(c *gin.Context) {
 	var flightCreate models.FlightCreate
 
-	err := c.BindJSON(&flightCreate)
+	err := c.ShouldBindWith(&flightCreate, binding.JSON)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"error": err,
-		}).Error("Error binding flight")
-
-		c.Status(http.StatusBadRequest)
+		abortWithBindError(c, err)
 		return
 	}
 
 	errs := flightCreate.Validate()
 	if len(errs) != 0 {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 			"flightCreate": flightCreate,
 			"errors":       errs,
 		}).Error("Error validating flight")
 
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
-	flight := &models.Flight{
-		Name:      flightCreate.Name,
-		Blocks:    flightCreate.Blocks,
-		CreatedAt: time.Now().Unix(),
-	}
+	flight := flightCreate.NewFlight(auth.GetTenant(c))
 
-	err = flightController.flightService.Create(flight)
+	err = flightController.flightService.Create(c.Request.Context(), flight)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
+	setETag(c, flight.Version)
+
 	c.JSON(http.StatusCreated, flight)
 }
 
+// Update updates overbooking limit of the flight
+func (flightController *FlightController) Update(c *gin.Context) {
+	flight, err := getFlight(c, flightController.flightService)
+	if err != nil {
+		return
+	}
+
+	err = checkPrecondition(c, flight.Version)
+	if err != nil {
+		return
+	}
+
+	var flightUpdate models.FlightUpdate
+
+	err = c.ShouldBindWith(&flightUpdate, binding.JSON)
+	if err != nil {
+		abortWithBindError(c, err)
+		return
+	}
+
+	errs := flightUpdate.Validate()
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"flightUpdate": flightUpdate,
+			"errors":       errs,
+		}).Error("Error validating flight")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	flight.Overbooking = flightUpdate.Overbooking
+
+	err = flightController.flightService.Update(c.Request.Context(), flight)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	setETag(c, flight.Version)
+	c.JSON(http.StatusOK, flight)
+}
+
 // Delete deletes flight
 func (flightController *FlightController) Delete(c *gin.Context) {
 	flight, err := getFlight(c, flightController.flightService)
@@ -136,11 +181,57 @@ func (flightController *FlightController) Delete(c *gin.Context) {
 		return
 	}
 
-	err = flightController.flightService.Delete(flight)
+	err = checkPrecondition(c, flight.Version)
+	if err != nil {
+		return
+	}
+
+	err = flightController.flightService.Delete(c.Request.Context(), flight)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// Relayout replaces flight blocks moving seat occupants to the new layout
+func (flightController *FlightController) Relayout(c *gin.Context) {
+	flight, err := getFlight(c, flightController.flightService)
+	if err != nil {
+		return
+	}
+
+	err = checkPrecondition(c, flight.Version)
+	if err != nil {
+		return
+	}
+
+	var flightLayout models.FlightLayout
+
+	err = c.ShouldBindWith(&flightLayout, binding.JSON)
+	if err != nil {
+		abortWithBindError(c, err)
+		return
+	}
+
+	errs := flightLayout.Validate()
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"flightLayout": flightLayout,
+			"errors":       errs,
+		}).Error("Error validating flight layout")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	result, err := flightController.flightService.Relayout(c.Request.Context(), flight, &flightLayout)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	setETag(c, flight.Version)
+	c.JSON(http.StatusOK, result)
+}
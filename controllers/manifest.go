@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/manifest"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/services"
+)
+
+const (
+	// manifestFlushRows is number of rows streamed to the client between flushes
+	manifestFlushRows = 100
+)
+
+// ManifestController is a flight manifest controller
+type ManifestController struct {
+	manifestService services.ManifestServiceInterface
+	flightService   services.FlightServiceInterface
+	queryManager    helpers.QueryManagerInterface
+}
+
+// ManifestControllerInterface is an interface for flight manifest controller methods
+type ManifestControllerInterface interface {
+	Export(c *gin.Context)
+}
+
+// NewManifestController is a constructor for flight manifest controller
+func NewManifestController(manifestService services.ManifestServiceInterface,
+	flightService services.FlightServiceInterface,
+	queryManager helpers.QueryManagerInterface) ManifestControllerInterface {
+	return &ManifestController{manifestService: manifestService, flightService: flightService,
+		queryManager: queryManager}
+}
+
+// Export streams all seats of the flight with passenger data as csv, ndjson or fixed-width text
+// according filter and sort parameters of the seat list
+func (manifestController *ManifestController) Export(c *gin.Context) {
+	flight, err := getFlight(c, manifestController.flightService)
+	if err != nil {
+		return
+	}
+
+	format := manifest.Format(c.DefaultQuery("format", string(manifest.FormatCSV)))
+	if !format.IsValid() {
+		errs := []models.Error{models.Error{
+			Code:    "format.Invalid",
+			Message: "Format must be csv, ndjson or text",
+			Field:   "format",
+		}}
+
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"errors": errs,
+			"format": format,
+		}).Error("Unknown manifest format")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	sorting, errs := manifestController.queryManager.GetSorting(&models.Seat{}, c)
+	if len(errs) != 0 {
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	filtering, errs := manifestController.queryManager.GetFiltering(&models.Seat{}, c)
+	if len(errs) != 0 {
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	cursor, err := manifestController.manifestService.Export(c.Request.Context(), flight, filtering, sorting)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	defer cursor.Close()
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"flight-%d-manifest.%s\"", flight.ID,
+		format.Extension()))
+	c.Status(http.StatusOK)
+
+	encoder := manifest.NewEncoder(format, c.Writer)
+	for count := 1; ; count++ {
+		row, err := cursor.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = encoder.Encode(row)
+		}
+		if err == nil && count%manifestFlushRows == 0 {
+			err = encoder.Flush()
+			c.Writer.Flush()
+		}
+		if err != nil {
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"error":    err,
+				"flightID": flight.ID,
+			}).Error("Error streaming manifest")
+			return
+		}
+	}
+
+	err = encoder.Flush()
+	if err != nil {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"flightID": flight.ID,
+		}).Error("Error streaming manifest")
+	}
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/schedule"
+	"github.com/vsukhin/booking/services"
+)
+
+// ScheduleController is a flight schedule import controller
+type ScheduleController struct {
+	scheduleService services.ScheduleServiceInterface
+}
+
+// ScheduleControllerInterface is an interface for flight schedule import controller methods
+type ScheduleControllerInterface interface {
+	Import(c *gin.Context)
+}
+
+// NewScheduleController is a constructor for flight schedule import controller
+func NewScheduleController(scheduleService services.ScheduleServiceInterface) ScheduleControllerInterface {
+	return &ScheduleController{scheduleService: scheduleService}
+}
+
+// Import creates flights of the csv or ndjson schedule in the request body reporting row errors
+func (scheduleController *ScheduleController) Import(c *gin.Context) {
+	var options models.ImportOptions
+
+	err := c.ShouldBindWith(&options, binding.Form)
+	if err != nil {
+		abortWithBindError(c, err)
+		return
+	}
+
+	errs := options.Validate()
+
+	format := schedule.FormatOf(c.Request.Header.Get("Content-Type"))
+	if options.Format != "" {
+		format = schedule.Format(options.Format)
+	}
+	if !format.IsValid() {
+		errs = append(errs, models.Error{
+			Code:    "format.Invalid",
+			Message: "Format must be csv or ndjson",
+			Field:   "format",
+		})
+	}
+
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"options": options,
+			"errors":  errs,
+		}).Error("Error validating import options")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	reader, err := schedule.NewReader(format, c.Request.Body)
+	if err != nil {
+		abortWithScheduleError(c, err)
+		return
+	}
+
+	tenantID := auth.GetTenant(c)
+	report, err := scheduleController.scheduleService.Import(c.Request.Context(), tenantID, reader, &options,
+		func(progress models.ImportProgress) {
+			logging.Log.WithContext(c.Request.Context(), logging.DepthLow, logging.Fields{
+				"tenantID": tenantID,
+				"progress": progress,
+			}).Info("Schedule import progress")
+		})
+	if err != nil {
+		abortWithScheduleError(c, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if options.DryRun || report.Created == 0 {
+		status = http.StatusOK
+	}
+
+	c.JSON(status, report)
+}
+
+func abortWithScheduleError(c *gin.Context, err error) {
+	errs := []models.Error{models.Error{
+		Code:    "schedule.Invalid",
+		Message: "Schedule can't be read: " + err.Error(),
+		Field:   "body",
+	}}
+
+	logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+		"error":  err,
+		"errors": errs,
+	}).Error("Error reading schedule")
+
+	helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+}
@@ -1,13 +1,16 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 
+	"github.com/vsukhin/booking/apperrors"
 	"github.com/vsukhin/booking/helpers"
 	"github.com/vsukhin/booking/logging"
 	"github.com/vsukhin/booking/models"
@@ -16,9 +19,10 @@ import (
 
 // SeatController is an seat controller
 type SeatController struct {
-	seatService   services.SeatServiceInterface
-	flightService services.FlightServiceInterface
-	queryManager  helpers.QueryManagerInterface
+	seatService     services.SeatServiceInterface
+	flightService   services.FlightServiceInterface
+	waitlistService services.WaitlistServiceInterface
+	queryManager    helpers.QueryManagerInterface
 }
 
 // SeatControllerInterface is an interface for seat controller methods
@@ -27,15 +31,33 @@ type SeatControllerInterface interface {
 	Find(c *gin.Context)
 	ListAll(c *gin.Context)
 	GetMeta(c *gin.Context)
+	Summary(c *gin.Context)
 	Create(c *gin.Context)
 	Update(c *gin.Context)
 	Delete(c *gin.Context)
+	Batch(c *gin.Context)
+	Move(c *gin.Context)
+	Swap(c *gin.Context)
 }
 
 // NewSeatController is a constructor for seat controller
 func NewSeatController(seatService services.SeatServiceInterface, flightService services.FlightServiceInterface,
+	waitlistService services.WaitlistServiceInterface,
 	queryManager helpers.QueryManagerInterface) SeatControllerInterface {
-	return &SeatController{seatService: seatService, flightService: flightService, queryManager: queryManager}
+	return &SeatController{seatService: seatService, flightService: flightService, waitlistService: waitlistService,
+		queryManager: queryManager}
+}
+
+// promote grants released seats to the waitlist, seat release stays successful if promotion fails
+func (seatController *SeatController) promote(ctx context.Context, tenantID string, flightID int64) {
+	_, err := seatController.waitlistService.Promote(ctx, tenantID, flightID)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error promoting waitlist")
+	}
 }
 
 func (seatController *SeatController) getSeat(c *gin.Context) (*models.Seat, error) {
@@ -52,42 +74,52 @@ func (seatController *SeatController) getSeat(c *gin.Context) (*models.Seat, err
 			Field:   "index",
 		}}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 			"error":  err,
 			"errors": errs,
 			"seatId": c.Params.ByName("index"),
 		}).Error("Index is not integer")
 
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return nil, err
 	}
 
-	seat, err := seatController.seatService.Retrieve(flight.ID, index)
+	seat, err := seatController.seatService.Retrieve(c.Request.Context(), flight.TenantID, flight.ID, index)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return nil, err
 	}
 
-	if seat == nil {
-		c.Status(http.StatusNotFound)
-		return nil, errors.New("Seat not found")
-	}
-
 	return seat, nil
 }
 
 // Retrieve retrieves seat
 func (seatController *SeatController) Retrieve(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
 	seat, err := seatController.getSeat(c)
 	if err != nil {
 		return
 	}
 
+	if notModified(c, seat.Version) {
+		return
+	}
+
+	concealOwner(principal, seat)
 	c.JSON(http.StatusOK, seat)
 }
 
 // Find finds seat
 func (seatController *SeatController) Find(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
 	flight, err := getFlight(c, seatController.flightService)
 	if err != nil {
 		return
@@ -101,13 +133,13 @@ func (seatController *SeatController) Find(c *gin.Context) {
 			Field:   "row",
 		}}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 			"error":  err,
 			"errors": errs,
 			"row":    c.Params.ByName("row"),
 		}).Error("Row is not integer")
 
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
@@ -119,31 +151,36 @@ func (seatController *SeatController) Find(c *gin.Context) {
 			Field:   "line",
 		}}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 			"errors": errs,
 			"line":   c.Params.ByName("line"),
 		}).Error("Line is not one character")
 
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
-	seat, err := seatController.seatService.Find(flight.ID, row, line)
+	seat, err := seatController.seatService.Find(c.Request.Context(), flight.TenantID, flight.ID, row, line)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
-	if seat == nil {
-		c.Status(http.StatusNotFound)
+	if notModified(c, seat.Version) {
 		return
 	}
 
+	concealOwner(principal, seat)
 	c.JSON(http.StatusOK, seat)
 }
 
 // ListAll lists all seats according filter, sort, offset, limit parameters
 func (seatController *SeatController) ListAll(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
 	flight, err := getFlight(c, seatController.flightService)
 	if err != nil {
 		return
@@ -151,28 +188,33 @@ func (seatController *SeatController) ListAll(c *gin.Context) {
 
 	limitation, errs := seatController.queryManager.GetLimitation(c)
 	if len(errs) != 0 {
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
 	sorting, errs := seatController.queryManager.GetSorting(&models.Seat{}, c)
 	if len(errs) != 0 {
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
 	filtering, errs := seatController.queryManager.GetFiltering(&models.Seat{}, c)
 	if len(errs) != 0 {
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
-	seats, err := seatController.seatService.ListAll(flight.ID, filtering, sorting, limitation)
+	seats, err := seatController.seatService.ListAll(c.Request.Context(), flight.TenantID, flight.ID, filtering, sorting,
+		limitation)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
+	for i := range seats {
+		concealOwner(principal, &seats[i])
+	}
+
 	c.JSON(http.StatusOK, seats)
 }
 
@@ -185,92 +227,337 @@ func (seatController *SeatController) GetMeta(c *gin.Context) {
 
 	filtering, errs := seatController.queryManager.GetFiltering(&models.Seat{}, c)
 	if len(errs) != 0 {
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
 		return
 	}
 
-	seatMeta, err := seatController.seatService.GetMeta(flight.ID, filtering)
+	seatMeta, err := seatController.seatService.GetMeta(c.Request.Context(), flight.TenantID, flight.ID, filtering)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, seatMeta)
 }
 
+// Summary returns seat availability counts of the flight
+func (seatController *SeatController) Summary(c *gin.Context) {
+	flight, err := getFlight(c, seatController.flightService)
+	if err != nil {
+		return
+	}
+
+	summary, err := seatController.seatService.Summary(c.Request.Context(), flight.TenantID, flight.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
 // Create creates seat
 func (seatController *SeatController) Create(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
 	flight, err := getFlight(c, seatController.flightService)
 	if err != nil {
 		return
 	}
 
-	seat, err := seatController.seatService.Assign(flight.ID)
+	seat, err := seatController.seatService.Assign(c.Request.Context(), flight.TenantID, flight.ID, principal.Subject)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
+	setETag(c, seat.Version)
 	c.JSON(http.StatusCreated, seat)
 }
 
 // Update updates seat
 func (seatController *SeatController) Update(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
 	seat, err := seatController.getSeat(c)
 	if err != nil {
 		return
 	}
 
-	var seatUpdate models.SeatUpdate
+	if seat.Assigned {
+		err = checkOwner(c, principal, seat.Owner)
+		if err != nil {
+			return
+		}
+	}
 
-	err = c.BindJSON(&seatUpdate)
+	err = checkPrecondition(c, seat.Version)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"error": err,
-		}).Error("Error binding seat")
+		return
+	}
 
-		c.Status(http.StatusBadRequest)
+	var seatUpdate models.SeatUpdate
+
+	err = c.ShouldBindWith(&seatUpdate, binding.JSON)
+	if err != nil {
+		abortWithBindError(c, err)
 		return
 	}
 
 	errs := seatUpdate.Validate()
 	if len(errs) != 0 {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 			"seatUpdate": seatUpdate,
 			"errors":     errs,
 		}).Error("Error validating seat")
 
-		c.JSON(http.StatusBadRequest, errs)
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	if seatUpdate.Owner == "" {
+		seatUpdate.Owner = principal.Subject
+	}
+
+	err = checkOwner(c, principal, seatUpdate.Owner)
+	if err != nil {
+		return
+	}
+
+	if seatUpdate.Assigned && seat.Blocked {
+		abortWithError(c, apperrors.ErrSeatBlocked)
 		return
 	}
 
 	seat.Assigned = seatUpdate.Assigned
+	if seat.Assigned {
+		seat.Owner = seatUpdate.Owner
+	} else {
+		seat.Owner = ""
+	}
 	seat.UpdatedAt = time.Now().Unix()
 
-	err = seatController.seatService.Update(seat)
+	err = seatController.seatService.Update(c.Request.Context(), seat)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
+	if !seat.Assigned {
+		seatController.promote(c.Request.Context(), seat.TenantID, seat.FlightID)
+	}
+
+	setETag(c, seat.Version)
 	c.JSON(http.StatusOK, seat)
 }
 
 // Delete deletes seat
 func (seatController *SeatController) Delete(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
 	seat, err := seatController.getSeat(c)
 	if err != nil {
 		return
 	}
 
+	err = checkOwner(c, principal, seat.Owner)
+	if err != nil {
+		return
+	}
+
+	err = checkPrecondition(c, seat.Version)
+	if err != nil {
+		return
+	}
+
 	seat.Assigned = false
+	seat.Owner = ""
 	seat.UpdatedAt = time.Now().Unix()
 
-	err = seatController.seatService.Update(seat)
+	err = seatController.seatService.Update(c.Request.Context(), seat)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
+	seatController.promote(c.Request.Context(), seat.TenantID, seat.FlightID)
+
 	c.Status(http.StatusNoContent)
 }
+
+// Batch applies action to the list of seats
+func (seatController *SeatController) Batch(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	flight, err := getFlight(c, seatController.flightService)
+	if err != nil {
+		return
+	}
+
+	var seatBatch models.SeatBatch
+
+	err = c.ShouldBindWith(&seatBatch, binding.JSON)
+	if err != nil {
+		abortWithBindError(c, err)
+		return
+	}
+
+	errs := seatBatch.Validate()
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"seatBatch": seatBatch,
+			"errors":    errs,
+		}).Error("Error validating seat batch")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	if seatBatch.Action == models.SeatActionAssign && seatBatch.Owner == "" {
+		seatBatch.Owner = principal.Subject
+	}
+
+	results, err := seatController.seatService.Batch(c.Request.Context(), flight.TenantID, flight.ID, &seatBatch)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	response := models.SeatBatchResponse{Results: results}
+	for _, result := range results {
+		if result.Succeeded {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	if seatBatch.Action == models.SeatActionRelease && response.Succeeded != 0 {
+		seatController.promote(c.Request.Context(), flight.TenantID, flight.ID)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (seatController *SeatController) getTarget(c *gin.Context) (*models.SeatTarget, error) {
+	var seatTarget models.SeatTarget
+
+	err := c.ShouldBindWith(&seatTarget, binding.JSON)
+	if err != nil {
+		abortWithBindError(c, err)
+		return nil, err
+	}
+
+	errs := seatTarget.Validate()
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"seatTarget": seatTarget,
+			"errors":     errs,
+		}).Error("Error validating target seat")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return nil, errors.New("Target seat is invalid")
+	}
+
+	return &seatTarget, nil
+}
+
+// Move moves occupant to the free seat
+func (seatController *SeatController) Move(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	seat, err := seatController.getSeat(c)
+	if err != nil {
+		return
+	}
+
+	err = checkOwner(c, principal, seat.Owner)
+	if err != nil {
+		return
+	}
+
+	err = checkPrecondition(c, seat.Version)
+	if err != nil {
+		return
+	}
+
+	seatTarget, err := seatController.getTarget(c)
+	if err != nil {
+		return
+	}
+
+	seats, err := seatController.seatService.Move(c.Request.Context(), seat, int64(seatTarget.Index))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, seats)
+}
+
+// Swap swaps occupants of two seats
+func (seatController *SeatController) Swap(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	seat, err := seatController.getSeat(c)
+	if err != nil {
+		return
+	}
+
+	err = checkOwner(c, principal, seat.Owner)
+	if err != nil {
+		return
+	}
+
+	err = checkPrecondition(c, seat.Version)
+	if err != nil {
+		return
+	}
+
+	seatTarget, err := seatController.getTarget(c)
+	if err != nil {
+		return
+	}
+
+	other, err := seatController.seatService.Retrieve(c.Request.Context(), seat.TenantID, seat.FlightID,
+		int64(seatTarget.Index))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	err = checkOwner(c, principal, other.Owner)
+	if err != nil {
+		return
+	}
+
+	seats, err := seatController.seatService.Swap(c.Request.Context(), seat, other)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	for i := range seats {
+		concealOwner(principal, &seats[i])
+	}
+
+	c.JSON(http.StatusOK, seats)
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/services"
+)
+
+// WaitlistController is a waitlist controller
+type WaitlistController struct {
+	waitlistService services.WaitlistServiceInterface
+	flightService   services.FlightServiceInterface
+}
+
+// WaitlistControllerInterface is an interface for waitlist controller methods
+type WaitlistControllerInterface interface {
+	ListAll(c *gin.Context)
+	Create(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+// NewWaitlistController is a constructor for waitlist controller
+func NewWaitlistController(waitlistService services.WaitlistServiceInterface,
+	flightService services.FlightServiceInterface) WaitlistControllerInterface {
+	return &WaitlistController{waitlistService: waitlistService, flightService: flightService}
+}
+
+// ListAll lists waiting entries of the flight, customers see own entries only
+func (waitlistController *WaitlistController) ListAll(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	flight, err := getFlight(c, waitlistController.flightService)
+	if err != nil {
+		return
+	}
+
+	entries, err := waitlistController.waitlistService.ListAll(c.Request.Context(), flight.TenantID, flight.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	visible := []models.WaitlistEntry{}
+	for _, entry := range entries {
+		if principal.CanManage(entry.Owner) {
+			visible = append(visible, entry)
+		}
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// Create puts owner to the waitlist of the flight
+func (waitlistController *WaitlistController) Create(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	flight, err := getFlight(c, waitlistController.flightService)
+	if err != nil {
+		return
+	}
+
+	var waitlistCreate models.WaitlistCreate
+
+	err = c.ShouldBindWith(&waitlistCreate, binding.JSON)
+	if err != nil {
+		abortWithBindError(c, err)
+		return
+	}
+
+	errs := waitlistCreate.Validate()
+	if len(errs) != 0 {
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"waitlistCreate": waitlistCreate,
+			"errors":         errs,
+		}).Error("Error validating waitlist entry")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	if waitlistCreate.Owner == "" {
+		waitlistCreate.Owner = principal.Subject
+	}
+
+	err = checkOwner(c, principal, waitlistCreate.Owner)
+	if err != nil {
+		return
+	}
+
+	if principal.HasRole(models.RoleCustomer) || waitlistCreate.Tier == "" {
+		waitlistCreate.Tier = principal.Tier
+	}
+
+	entry := &models.WaitlistEntry{
+		TenantID: flight.TenantID,
+		FlightID: flight.ID,
+		Owner:    waitlistCreate.Owner,
+		Tier:     waitlistCreate.Tier,
+	}
+
+	err = waitlistController.waitlistService.Join(c.Request.Context(), entry)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	setETag(c, entry.Version)
+	c.JSON(http.StatusCreated, entry)
+}
+
+// Delete cancels waiting entry
+func (waitlistController *WaitlistController) Delete(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		return
+	}
+
+	flight, err := getFlight(c, waitlistController.flightService)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Params.ByName("entryId"), 10, 64)
+	if err != nil {
+		errs := []models.Error{models.Error{
+			Code:    "entryId.Invalid",
+			Message: "Entry id is not integer",
+			Field:   "entryId",
+		}}
+
+		logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"errors":  errs,
+			"entryId": c.Params.ByName("entryId"),
+		}).Error("Entry id is not integer")
+
+		helpers.AbortWithErrors(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	entry, err := waitlistController.waitlistService.Retrieve(c.Request.Context(), flight.TenantID, flight.ID, id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	err = checkOwner(c, principal, entry.Owner)
+	if err != nil {
+		return
+	}
+
+	err = checkPrecondition(c, entry.Version)
+	if err != nil {
+		return
+	}
+
+	err = waitlistController.waitlistService.Cancel(c.Request.Context(), entry)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"time"
+
+	"github.com/vsukhin/booking/logging"
+)
+
+const (
+	// TypeSeatGranted is event of waitlisted owner granted with seat
+	TypeSeatGranted = "waitlist.SeatGranted"
+)
+
+// Event contains notification event data
+type Event struct {
+	Type     string      `json:"type"`
+	TenantID string      `json:"tenant_id"`
+	Time     int64       `json:"time"`
+	Data     interface{} `json:"data"`
+}
+
+// NewEvent is a constructor of event happened now
+func NewEvent(eventType string, tenantID string, data interface{}) *Event {
+	return &Event{Type: eventType, TenantID: tenantID, Time: time.Now().Unix(), Data: data}
+}
+
+// Publisher is notification event publisher interface
+type Publisher interface {
+	// Publish delivers event to the subscribers
+	Publish(event *Event) error
+}
+
+// LogPublisher publishes events to the service log
+type LogPublisher struct {
+}
+
+// NewLogPublisher is a constructor of log event publisher
+func NewLogPublisher() Publisher {
+	return &LogPublisher{}
+}
+
+// Publish writes event to the log
+func (publisher *LogPublisher) Publish(event *Event) error {
+	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+		"event": *event,
+	}).Info("Event published")
+
+	return nil
+}
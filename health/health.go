@@ -0,0 +1,169 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+)
+
+const (
+	// DefaultCheckTimeout is default time given to one readiness check
+	DefaultCheckTimeout = 2 * time.Second
+	// componentDB is name of the db connection component
+	componentDB = "db"
+	// componentMigrations is name of the db schema version component
+	componentMigrations = "migrations"
+)
+
+// Checker checks readiness of the service dependency
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// Manager is health manager running readiness checks, draining manager reports the service not ready
+// without running them so that load balancer stops sending traffic before shutdown
+type Manager struct {
+	checkers []Checker
+	timeout  time.Duration
+	draining int32
+}
+
+// ManagerInterface is health manager interface
+type ManagerInterface interface {
+	Live() models.HealthReport
+	Ready(ctx context.Context) models.HealthReport
+	Drain()
+	IsDraining() bool
+}
+
+// NewManager is a constructor of health manager
+func NewManager(timeout time.Duration, checkers ...Checker) ManagerInterface {
+	return &Manager{checkers: checkers, timeout: timeout}
+}
+
+// Live reports the process is alive, dependencies are not checked to keep failing db from restarting the service
+func (manager *Manager) Live() models.HealthReport {
+	return models.HealthReport{Status: models.HealthStatusOK}
+}
+
+// Ready runs readiness checks concurrently each within the check timeout and reports failed components
+func (manager *Manager) Ready(ctx context.Context) models.HealthReport {
+	if manager.IsDraining() {
+		return models.HealthReport{Status: models.HealthStatusDraining}
+	}
+
+	report := models.HealthReport{Status: models.HealthStatusOK,
+		Components: make([]models.HealthComponent, len(manager.checkers))}
+
+	var wg sync.WaitGroup
+	for i, checker := range manager.checkers {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+			report.Components[i] = manager.check(ctx, checker)
+		}(i, checker)
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		if component.Status != models.HealthStatusOK {
+			report.Status = models.HealthStatusDegraded
+		}
+	}
+
+	return report
+}
+
+// check runs readiness check of the component
+func (manager *Manager) check(ctx context.Context, checker Checker) models.HealthComponent {
+	if manager.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, manager.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := checker.Check(ctx)
+	component := models.HealthComponent{Name: checker.Name(), Status: models.HealthStatusOK,
+		Duration: time.Since(start).String()}
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":     err,
+			"component": component.Name,
+		}).Warn("Readiness check failed")
+
+		component.Status = models.HealthStatusDegraded
+		component.Error = err.Error()
+	}
+
+	return component
+}
+
+// Drain switches readiness off until the service is stopped
+func (manager *Manager) Drain() {
+	if atomic.CompareAndSwapInt32(&manager.draining, 0, 1) {
+		logging.Log.Info("Service is draining at ", time.Now())
+	}
+}
+
+// IsDraining checks whether the service is draining
+func (manager *Manager) IsDraining() bool {
+	return atomic.LoadInt32(&manager.draining) == 1
+}
+
+// DBChecker checks db connection with ping
+type DBChecker struct {
+	db sqldb.DBInterface
+}
+
+// NewDBChecker is a constructor of db connection checker
+func NewDBChecker(db sqldb.DBInterface) Checker {
+	return &DBChecker{db: db}
+}
+
+// Name returns component name
+func (checker *DBChecker) Name() string {
+	return componentDB
+}
+
+// Check pings db
+func (checker *DBChecker) Check(ctx context.Context) error {
+	return checker.db.Ping(ctx)
+}
+
+// MigrationChecker checks db schema is migrated to the version expected by the service
+type MigrationChecker struct {
+	db      sqldb.DBInterface
+	version int64
+}
+
+// NewMigrationChecker is a constructor of db schema version checker
+func NewMigrationChecker(db sqldb.DBInterface, version int64) Checker {
+	return &MigrationChecker{db: db, version: version}
+}
+
+// Name returns component name
+func (checker *MigrationChecker) Name() string {
+	return componentMigrations
+}
+
+// Check compares latest applied migration with the expected version
+func (checker *MigrationChecker) Check(ctx context.Context) error {
+	version, err := checker.db.SelectInt(ctx, nil, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+
+	if version != checker.version {
+		return fmt.Errorf("schema version %d, expected %d", version, checker.version)
+	}
+
+	return nil
+}
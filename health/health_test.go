@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gorp "github.com/go-gorp/gorp/v3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+)
+
+func init() {
+	logging.Log = NewFakeLogger()
+}
+
+// FakeLogger is fake logger
+type FakeLogger struct {
+	*logrus.Logger
+}
+
+// NewFakeLogger is a constructor of fake logger
+func NewFakeLogger() logging.LoggerInterface {
+	log := logrus.New()
+
+	return &FakeLogger{log}
+}
+
+// Init initiates logging
+func (logger *FakeLogger) Init(mode string) {
+}
+
+// WithFields logs with fields
+func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// Info logs info
+func (logger *FakeLogger) Info(args ...interface{}) {
+}
+
+// FakeDB is fake db answering ping and schema version
+type FakeDB struct {
+	sqldb.DBInterface
+	pingErr error
+	version int64
+}
+
+// Ping returns ping error
+func (db *FakeDB) Ping(ctx context.Context) error {
+	return db.pingErr
+}
+
+// SelectInt returns schema version
+func (db *FakeDB) SelectInt(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (int64, error) {
+	return db.version, nil
+}
+
+// FakeChecker is fake checker blocking until the context is done
+type FakeChecker struct {
+}
+
+// Name returns component name
+func (checker *FakeChecker) Name() string {
+	return "slow"
+}
+
+// Check waits for the context
+func (checker *FakeChecker) Check(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func Test_Manager_Ready_Success(t *testing.T) {
+	db := &FakeDB{version: sqldb.SchemaVersion}
+	manager := NewManager(DefaultCheckTimeout, NewDBChecker(db), NewMigrationChecker(db, sqldb.SchemaVersion))
+
+	report := manager.Ready(context.Background())
+	if report.Status != models.HealthStatusOK || len(report.Components) != 2 {
+		t.Fatalf("Expected service to be ready, got %+v", report)
+	}
+	if report.Components[0].Name != componentDB || report.Components[1].Name != componentMigrations {
+		t.Errorf("Expected components in order of the checkers, got %+v", report.Components)
+	}
+}
+
+func Test_Manager_Ready_Degraded_Failure(t *testing.T) {
+	db := &FakeDB{pingErr: errors.New("db is unavailable"), version: sqldb.SchemaVersion - 1}
+	manager := NewManager(10*time.Millisecond, NewDBChecker(db), NewMigrationChecker(db, sqldb.SchemaVersion),
+		&FakeChecker{})
+
+	report := manager.Ready(context.Background())
+	if report.Status != models.HealthStatusDegraded {
+		t.Fatalf("Expected service to be degraded, got %v", report.Status)
+	}
+	for _, component := range report.Components {
+		if component.Status != models.HealthStatusDegraded || component.Error == "" {
+			t.Errorf("Expected component %v to fail", component.Name)
+		}
+	}
+}
+
+func Test_Manager_Drain_Success(t *testing.T) {
+	manager := NewManager(DefaultCheckTimeout, NewDBChecker(&FakeDB{pingErr: errors.New("db is unavailable")}))
+
+	manager.Drain()
+	manager.Drain()
+
+	if !manager.IsDraining() || manager.Ready(context.Background()).Status != models.HealthStatusDraining {
+		t.Error("Expected draining service not to be ready")
+	}
+	if manager.Live().Status != models.HealthStatusOK {
+		t.Error("Expected draining service to be alive")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 
@@ -27,8 +28,8 @@ const (
 	indexOffset = 0
 	// indexLimit is limit index
 	indexLimit = 1
-	// defaultLimit is default limit
-	defaultLimit = 100
+	// DefaultLimit is default page size of lists requested without limit
+	DefaultLimit = 100
 
 	// queryParameterSortAsc is ascending sort query parameter
 	queryParameterSortAsc = "asc"
@@ -68,6 +69,21 @@ const (
 	delimiter = ':'
 )
 
+var (
+	// SortOrders contains supported sort orders
+	SortOrders = []string{queryParameterSortAsc, queryParameterSortDesc}
+	// FilterOperations contains supported filter operations
+	FilterOperations = []string{queryParameterFilterOpEq, queryParameterFilterOpNe, queryParameterFilterOpLt,
+		queryParameterFilterOpLe, queryParameterFilterOpGt, queryParameterFilterOpGe, queryParameterFilterOpLk}
+	// defaultLimit is current default page size, it is reloaded without restart
+	defaultLimit int64 = DefaultLimit
+)
+
+// SetDefaultLimit sets default page size of lists requested without limit
+func SetDefaultLimit(limit int64) {
+	atomic.StoreInt64(&defaultLimit, limit)
+}
+
 // QueryManager is query manager
 type QueryManager struct {
 }
@@ -116,7 +132,7 @@ func (manager *QueryManager) GetLimitation(c *gin.Context) (string, []models.Err
 				Field:   fields[i].name,
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"error":  err,
 				"errors": errs,
 				"query":  c.Request.URL.RawQuery,
@@ -136,7 +152,7 @@ func (manager *QueryManager) GetLimitation(c *gin.Context) (string, []models.Err
 					Field:   fields[i].name,
 				}}
 
-				logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+				logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 					"error":        err,
 					"errors":       errs,
 					fields[i].name: value,
@@ -153,7 +169,7 @@ func (manager *QueryManager) GetLimitation(c *gin.Context) (string, []models.Err
 					Field:   fields[i].name,
 				}}
 
-				logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+				logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 					"errors":       errs,
 					fields[i].name: value,
 					"query":        c.Request.URL.RawQuery,
@@ -169,10 +185,10 @@ func (manager *QueryManager) GetLimitation(c *gin.Context) (string, []models.Err
 	if fields[indexLimit].value > 0 {
 		limitation = fmt.Sprintf(" LIMIT %v, %v", fields[indexOffset].value, fields[indexLimit].value)
 	} else {
-		limitation = fmt.Sprintf(" LIMIT %v, %v", fields[indexOffset].value, defaultLimit)
+		limitation = fmt.Sprintf(" LIMIT %v, %v", fields[indexOffset].value, atomic.LoadInt64(&defaultLimit))
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(c.Request.Context(), logging.DepthLow, logging.Fields{
 		"limitation": limitation,
 		"query":      c.Request.URL.RawQuery,
 	}).Debug("Offset and limit successfully parsed")
@@ -196,7 +212,7 @@ func (manager *QueryManager) GetSorting(checker models.SortFieldChecker, c *gin.
 				Field:   "sort",
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"error":  err,
 				"errors": errs,
 				"order":  order,
@@ -218,7 +234,7 @@ func (manager *QueryManager) GetSorting(checker models.SortFieldChecker, c *gin.
 				Field:   "sort",
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"errors":  errs,
 				"element": element,
 				"query":   c.Request.URL.RawQuery,
@@ -238,7 +254,7 @@ func (manager *QueryManager) GetSorting(checker models.SortFieldChecker, c *gin.
 				Field:   "sort",
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"errors":       errs,
 				"element":      element,
 				"fieldElement": fieldElelemnt,
@@ -256,7 +272,7 @@ func (manager *QueryManager) GetSorting(checker models.SortFieldChecker, c *gin.
 				Field:   "sort",
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"errors":       errs,
 				"element":      element,
 				"orderElement": orderElement,
@@ -283,7 +299,7 @@ func (manager *QueryManager) GetSorting(checker models.SortFieldChecker, c *gin.
 		sorting += strings.Join(orders, ",")
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(c.Request.Context(), logging.DepthLow, logging.Fields{
 		"sorting": sorting,
 		"query":   c.Request.URL.RawQuery,
 	}).Debug("Sort successfully parsed")
@@ -307,7 +323,7 @@ func (manager *QueryManager) GetFiltering(checker models.SearchFieldChecker, c *
 				Field:   "filter",
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"error":      err,
 				"errors":     errs,
 				"expression": expression,
@@ -329,7 +345,7 @@ func (manager *QueryManager) GetFiltering(checker models.SearchFieldChecker, c *
 				Field:   "filter",
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"error":   err,
 				"errors":  errs,
 				"element": element,
@@ -350,7 +366,7 @@ func (manager *QueryManager) GetFiltering(checker models.SearchFieldChecker, c *
 				Field:   "filter",
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"errors":  errs,
 				"element": element,
 				"query":   c.Request.URL.RawQuery,
@@ -407,7 +423,7 @@ func (manager *QueryManager) GetFiltering(checker models.SearchFieldChecker, c *
 				Field:   "filter",
 			}}
 
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"errors":    errs,
 				"element":   element,
 				"opElement": opElement,
@@ -455,7 +471,7 @@ func (manager *QueryManager) GetFiltering(checker models.SearchFieldChecker, c *
 		filtering += strings.Join(masks, " AND ")
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(c.Request.Context(), logging.DepthLow, logging.Fields{
 		"filtering": filtering,
 		"query":     c.Request.URL.RawQuery,
 	}).Debug("Filter successfully parsed")
@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -37,6 +38,11 @@ func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *log
 	return logrus.NewEntry(logger.Logger)
 }
 
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
 // Info logs info
 func (logger *FakeLogger) Info(args ...interface{}) {
 }
@@ -75,6 +81,21 @@ func Test_GetLimitation_Empty_Success(t *testing.T) {
 	}
 }
 
+func Test_GetLimitation_DefaultLimit_Success(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/?offset=5", nil)
+
+	SetDefaultLimit(20)
+	defer SetDefaultLimit(DefaultLimit)
+
+	limitation, errs := NewQueryManager().GetLimitation(c)
+	if len(errs) != 0 || limitation != " LIMIT 5, 20" {
+		t.Errorf("Expected to get limitation of reloaded default limit, got %v", limitation)
+	}
+}
+
 func Test_GetLimitation_OffsetInteger_Failure(t *testing.T) {
 	w := httptest.NewRecorder()
 
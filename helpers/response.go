@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/models"
+)
+
+const (
+	// HeaderRequestID is request id header
+	HeaderRequestID = "X-Request-ID"
+	// HeaderForwardedFor is proxy header of the client address
+	HeaderForwardedFor = "X-Forwarded-For"
+	// ip4Local is ip 4 local
+	ip4local = "127.0.0.1"
+	// ip6Local is ip 6 local
+	ip6local = "::1"
+	// requestIDKey is request id context key
+	requestIDKey = "request_id"
+	// codePrefixHTTP is code prefix of errors without details
+	codePrefixHTTP = "http."
+	// fieldBody is request body field
+	fieldBody = "body"
+)
+
+// GetRequestID gets request id from the context
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// SetRequestID sets request id to the context
+func SetRequestID(c *gin.Context, requestID string) {
+	c.Set(requestIDKey, requestID)
+}
+
+// GetClientIP gets client ip, the first address of the proxy header is taken unless the request comes directly
+// or the header names local address
+func GetClientIP(c *gin.Context) (string, error) {
+	ip := strings.TrimSpace(strings.Split(c.Request.Header.Get(HeaderForwardedFor), ",")[0])
+	if ip == ip6local || ip == ip4local || ip == "" {
+		var err error
+
+		ip, _, err = net.SplitHostPort(c.Request.RemoteAddr)
+		return ip, err
+	}
+
+	return ip, nil
+}
+
+// AbortWithErrors aborts request with error envelope
+func AbortWithErrors(c *gin.Context, status int, errs []models.Error) {
+	requestID := GetRequestID(c)
+	for i := range errs {
+		errs[i].RequestID = requestID
+	}
+
+	c.AbortWithStatusJSON(status, errs)
+}
+
+// AbortWithStatus aborts request with error envelope describing status only
+func AbortWithStatus(c *gin.Context, status int) {
+	AbortWithErrors(c, status, []models.Error{models.Error{
+		Code:    codePrefixHTTP + strings.Replace(http.StatusText(status), " ", "", -1),
+		Message: http.StatusText(status),
+	}})
+}
+
+// BindErrors converts json decoding error to field errors
+func BindErrors(err error) []models.Error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			field = fieldBody
+		}
+
+		return []models.Error{models.Error{
+			Code:    field + ".Invalid",
+			Message: fmt.Sprintf("%v must be %v", field, typeErr.Type.Kind()),
+			Field:   field,
+			Details: map[string]interface{}{"value": typeErr.Value, "offset": typeErr.Offset},
+		}}
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return []models.Error{models.Error{
+			Code:    fieldBody + ".Invalid",
+			Message: "Request body is not valid json",
+			Field:   fieldBody,
+			Details: map[string]interface{}{"offset": syntaxErr.Offset},
+		}}
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return []models.Error{models.Error{
+			Code:    fieldBody + ".Missing",
+			Message: "Request body is missing or incomplete",
+			Field:   fieldBody,
+		}}
+	}
+
+	return []models.Error{models.Error{
+		Code:    fieldBody + ".Invalid",
+		Message: "Request body can't be decoded",
+		Field:   fieldBody,
+	}}
+}
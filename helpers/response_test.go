@@ -0,0 +1,103 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/models"
+)
+
+func Test_BindErrors_Type_Failure(t *testing.T) {
+	var seatUpdate models.SeatUpdate
+
+	err := json.NewDecoder(strings.NewReader(`{"assigned":"yes"}`)).Decode(&seatUpdate)
+
+	errs := BindErrors(err)
+	if len(errs) != 1 || errs[0].Field != "assigned" || errs[0].Code != "assigned.Invalid" {
+		t.Error("Expected to convert type error to field error")
+	}
+}
+
+func Test_BindErrors_Syntax_Failure(t *testing.T) {
+	var seatUpdate models.SeatUpdate
+
+	err := json.NewDecoder(strings.NewReader(`{"assigned":}`)).Decode(&seatUpdate)
+
+	errs := BindErrors(err)
+	if len(errs) != 1 || errs[0].Code != "body.Invalid" || errs[0].Details["offset"] == nil {
+		t.Error("Expected to convert syntax error to body error")
+	}
+}
+
+func Test_BindErrors_Empty_Failure(t *testing.T) {
+	var seatUpdate models.SeatUpdate
+
+	err := json.NewDecoder(strings.NewReader(``)).Decode(&seatUpdate)
+
+	errs := BindErrors(err)
+	if len(errs) != 1 || errs[0].Code != "body.Missing" {
+		t.Error("Expected to convert empty body to missing body error")
+	}
+}
+
+func Test_AbortWithErrors_Success(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	SetRequestID(c, "r1")
+
+	AbortWithErrors(c, http.StatusConflict, []models.Error{models.Error{Code: "a.B"}})
+
+	var errs []models.Error
+	err := json.Unmarshal(w.Body.Bytes(), &errs)
+	if err != nil || w.Code != http.StatusConflict || len(errs) != 1 || errs[0].RequestID != "r1" {
+		t.Error("Expected to write error envelope with request id")
+	}
+	if !c.IsAborted() {
+		t.Error("Expected to abort request")
+	}
+}
+
+func Test_AbortWithStatus_Success(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	AbortWithStatus(c, http.StatusServiceUnavailable)
+
+	var errs []models.Error
+	err := json.Unmarshal(w.Body.Bytes(), &errs)
+	if err != nil || len(errs) != 1 || errs[0].Code != "http.ServiceUnavailable" {
+		t.Error("Expected to write error envelope for status")
+	}
+}
+
+func Test_BindErrors_Unknown_Failure(t *testing.T) {
+	errs := BindErrors(errors.New("test"))
+	if len(errs) != 1 || errs[0].Field != "body" {
+		t.Error("Expected to convert unknown error to body error")
+	}
+}
+
+func Test_GetClientIP_Success(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":                    "10.0.0.2",
+		"127.0.0.1":           "10.0.0.2",
+		"1.2.3.4":             "1.2.3.4",
+		" 1.2.3.4, 10.0.0.1 ": "1.2.3.4",
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", "/", nil)
+		c.Request.RemoteAddr = "10.0.0.2:5000"
+		c.Request.Header.Set(HeaderForwardedFor, header)
+
+		ip, err := GetClientIP(c)
+		if err != nil || ip != expected {
+			t.Errorf("Expected client ip %v of header %q, got %v", expected, header, ip)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+const (
+	// HeaderIdempotencyKey is idempotency key header
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotencyReplayed is header marking replayed response
+	HeaderIdempotencyReplayed = "Idempotency-Replayed"
+	// DefaultTTL is default time to keep stored responses
+	DefaultTTL = 24 * time.Hour
+	// ExpireInterval is interval of removing expired responses from the store
+	ExpireInterval = time.Minute
+	// maxKeyLength is max idempotency key length
+	maxKeyLength = 255
+	// maxBodySize is max size of the request body buffered to fingerprint the request
+	maxBodySize = 1 << 20
+	// keySeparator is separator of store key parts
+	keySeparator = "\x00"
+)
+
+var (
+	// replayedHeaders contains response headers stored with the response body
+	replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+)
+
+// Manager is idempotency manager
+type Manager struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// ManagerInterface is idempotency manager interface
+type ManagerInterface interface {
+	Handle() gin.HandlerFunc
+}
+
+// NewManager is a constructor of idempotency manager
+func NewManager(store Store, ttl time.Duration) ManagerInterface {
+	return &Manager{store: store, ttl: ttl, now: time.Now}
+}
+
+// responseRecorder copies response body written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write writes data to the client and the copy
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	recorder.body.Write(data)
+	return recorder.ResponseWriter.Write(data)
+}
+
+// WriteString writes string to the client and the copy
+func (recorder *responseRecorder) WriteString(s string) (int, error) {
+	recorder.body.WriteString(s)
+	return recorder.ResponseWriter.WriteString(s)
+}
+
+func isMutating(method string) bool {
+	return method == http.MethodPost || method == http.MethodPatch || method == http.MethodDelete
+}
+
+// fingerprint hashes method, path with query and body of the request
+func fingerprint(method string, path string, query string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "?" + query + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func storeKey(c *gin.Context, key string) string {
+	client := ""
+	principal := auth.GetPrincipal(c)
+	if principal != nil {
+		client = principal.Subject
+	}
+
+	return auth.GetTenant(c) + keySeparator + client + keySeparator + key
+}
+
+func abort(c *gin.Context, status int, code string, message string) {
+	errs := []models.Error{models.Error{
+		Code:    code,
+		Message: message,
+		Field:   HeaderIdempotencyKey,
+	}}
+
+	logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+		"errors": errs,
+		"key":    c.Request.Header.Get(HeaderIdempotencyKey),
+		"path":   c.Request.URL.Path,
+	}).Error(message)
+
+	helpers.AbortWithErrors(c, status, errs)
+}
+
+// Handle is idempotency middleware storing the first response of the mutating request with its headers and replaying
+// it on retry, the key is released unless the response is stored, so a failed or panicking request can be retried
+func (manager *Manager) Handle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Request.Header.Get(HeaderIdempotencyKey)
+		if key == "" || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			abort(c, http.StatusBadRequest, HeaderIdempotencyKey+".TooLarge", "Idempotency key is too long")
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+
+			body, err = ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+			var sizeErr *http.MaxBytesError
+			if errors.As(err, &sizeErr) {
+				abort(c, http.StatusRequestEntityTooLarge, HeaderIdempotencyKey+".BodyTooLarge",
+					"Request body is too large for idempotency key")
+				return
+			}
+			if err != nil {
+				abort(c, http.StatusBadRequest, HeaderIdempotencyKey+".Body", "Request body can't be read")
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		record := &Record{
+			Fingerprint: fingerprint(c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, body),
+			ExpiresAt:   manager.now().Add(manager.ttl),
+		}
+
+		storedKey := storeKey(c, key)
+
+		existing, reserved, err := manager.store.Reserve(storedKey, record)
+		if err != nil {
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"error": err,
+				"key":   key,
+			}).Error("Error reserving idempotency key")
+
+			helpers.AbortWithStatus(c, http.StatusServiceUnavailable)
+			return
+		}
+
+		if !reserved {
+			if existing.Fingerprint != record.Fingerprint {
+				abort(c, http.StatusUnprocessableEntity, HeaderIdempotencyKey+".Mismatch",
+					"Idempotency key is reused with different request")
+				return
+			}
+
+			if !existing.Completed {
+				abort(c, http.StatusConflict, HeaderIdempotencyKey+".InProgress",
+					"Request with idempotency key is in progress")
+				return
+			}
+
+			logging.Log.WithContext(c.Request.Context(), logging.DepthLow, logging.Fields{
+				"key":    key,
+				"status": existing.Status,
+			}).Debug("Idempotent response replayed")
+
+			for name := range existing.Header {
+				c.Header(name, existing.Header.Get(name))
+			}
+			c.Header(HeaderIdempotencyReplayed, "true")
+			if len(existing.Body) == 0 {
+				c.AbortWithStatus(existing.Status)
+				return
+			}
+
+			c.Data(existing.Status, existing.Header.Get("Content-Type"), existing.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+
+			err := manager.store.Release(storedKey)
+			if err != nil {
+				logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+					"error": err,
+					"key":   key,
+				}).Error("Error releasing idempotency key")
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || c.Request.Context().Err() != nil {
+			return
+		}
+
+		record.Status = status
+		record.Header = http.Header{}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				record.Header.Set(name, value)
+			}
+		}
+		record.Body = recorder.body.Bytes()
+
+		err = manager.store.Complete(storedKey, record)
+		if err != nil {
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"error": err,
+				"key":   key,
+			}).Error("Error storing idempotent response")
+			return
+		}
+
+		completed = true
+	}
+}
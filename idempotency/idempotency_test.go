@@ -0,0 +1,299 @@
+package idempotency
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+func init() {
+	logging.Log = NewFakeLogger()
+}
+
+// FakeLogger is fake logger
+type FakeLogger struct {
+	*logrus.Logger
+}
+
+// NewFakeLogger is a constructor of fake logger
+func NewFakeLogger() logging.LoggerInterface {
+	log := logrus.New()
+
+	return &FakeLogger{log}
+}
+
+// Init initiates logging
+func (logger *FakeLogger) Init(mode string) {
+}
+
+// WithFields logs with fields
+func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// Info logs info
+func (logger *FakeLogger) Info(args ...interface{}) {
+}
+
+// identify sets principal of the subject header and tenant as authentication does
+func identify(c *gin.Context) {
+	auth.SetPrincipal(c, &models.Principal{Subject: c.Request.Header.Get("X-Subject"), Role: models.RoleAgent})
+	auth.SetTenant(c, "t1")
+}
+
+func newTestRouter(manager ManagerInterface, calls *int, status int) *gin.Engine {
+	r := gin.New()
+	r.Use(identify)
+	r.Use(manager.Handle())
+
+	handler := func(c *gin.Context) {
+		*calls++
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.JSON(status, gin.H{"call": *calls, "body": string(body)})
+	}
+	r.POST("/", handler)
+	r.GET("/", handler)
+
+	return r
+}
+
+func serve(r *gin.Engine, method string, key string, subject string, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	req.Header.Set("X-Subject", subject)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func Test_Manager_Handle_Replay_Success(t *testing.T) {
+	calls := 0
+	r := newTestRouter(NewManager(NewMemoryStore(), DefaultTTL), &calls, http.StatusCreated)
+
+	first := serve(r, "POST", "k1", "a", `{"x":1}`)
+	second := serve(r, "POST", "k1", "a", `{"x":1}`)
+
+	if calls != 1 {
+		t.Error("Expected handler to be called once")
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Error("Expected to replay stored response")
+	}
+	if second.Header().Get(HeaderIdempotencyReplayed) != "true" {
+		t.Error("Expected replayed response to be marked")
+	}
+}
+
+func Test_Manager_Handle_Mismatch_Failure(t *testing.T) {
+	calls := 0
+	r := newTestRouter(NewManager(NewMemoryStore(), DefaultTTL), &calls, http.StatusCreated)
+
+	serve(r, "POST", "k1", "a", `{"x":1}`)
+	w := serve(r, "POST", "k1", "a", `{"x":2}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Error("Expected to reject reused key with different payload")
+	}
+	if calls != 1 {
+		t.Error("Expected handler to be called once")
+	}
+}
+
+func Test_Manager_Handle_Query_Failure(t *testing.T) {
+	calls := 0
+	r := newTestRouter(NewManager(NewMemoryStore(), DefaultTTL), &calls, http.StatusCreated)
+
+	for _, path := range []string{"/?dryRun=true", "/?dryRun=false"} {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(`{"x":1}`))
+		req.Header.Set(HeaderIdempotencyKey, "k1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if path == "/?dryRun=false" && w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected to reject reused key with different query, got %v", w.Code)
+		}
+	}
+	if calls != 1 {
+		t.Error("Expected handler to be called once")
+	}
+}
+
+func Test_Manager_Handle_BodyTooLarge_Failure(t *testing.T) {
+	calls := 0
+	r := newTestRouter(NewManager(NewMemoryStore(), DefaultTTL), &calls, http.StatusCreated)
+
+	w := serve(r, "POST", "k1", "a", strings.Repeat("x", maxBodySize+1))
+	if w.Code != http.StatusRequestEntityTooLarge || calls != 0 {
+		t.Errorf("Expected to reject too large body before the handler, got %v", w.Code)
+	}
+}
+
+func Test_Manager_Handle_Clients_Success(t *testing.T) {
+	calls := 0
+	r := newTestRouter(NewManager(NewMemoryStore(), DefaultTTL), &calls, http.StatusCreated)
+
+	serve(r, "POST", "k1", "a", `{"x":1}`)
+	serve(r, "POST", "k1", "b", `{"x":1}`)
+
+	if calls != 2 {
+		t.Error("Expected keys of different clients not to collide")
+	}
+}
+
+func Test_Manager_Handle_NoKey_Success(t *testing.T) {
+	calls := 0
+	r := newTestRouter(NewManager(NewMemoryStore(), DefaultTTL), &calls, http.StatusCreated)
+
+	serve(r, "POST", "", "a", `{"x":1}`)
+	serve(r, "POST", "", "a", `{"x":1}`)
+	serve(r, "GET", "k1", "a", "")
+	serve(r, "GET", "k1", "a", "")
+
+	if calls != 4 {
+		t.Error("Expected requests without key or with safe method to pass through")
+	}
+}
+
+func Test_Manager_Handle_ServerError_Success(t *testing.T) {
+	calls := 0
+	r := newTestRouter(NewManager(NewMemoryStore(), DefaultTTL), &calls, http.StatusInternalServerError)
+
+	serve(r, "POST", "k1", "a", `{"x":1}`)
+	serve(r, "POST", "k1", "a", `{"x":1}`)
+
+	if calls != 2 {
+		t.Error("Expected server errors not to be stored")
+	}
+}
+
+func Test_Manager_Handle_Headers_Success(t *testing.T) {
+	r := gin.New()
+	r.Use(identify, NewManager(NewMemoryStore(), DefaultTTL).Handle())
+	r.POST("/", func(c *gin.Context) {
+		c.Header("ETag", `"1"`)
+		c.Header("Location", "/v1/flights/1")
+		c.Header("X-Request-ID", "r1")
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+
+	serve(r, "POST", "k1", "a", `{"x":1}`)
+	w := serve(r, "POST", "k1", "a", `{"x":1}`)
+
+	if w.Header().Get("ETag") != `"1"` || w.Header().Get("Location") != "/v1/flights/1" ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("Expected to replay stored headers, got %v", w.Header())
+	}
+	if w.Header().Get("X-Request-ID") != "" {
+		t.Error("Expected not to replay headers of the first request")
+	}
+}
+
+func Test_Manager_Handle_Panic_Success(t *testing.T) {
+	calls := 0
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		defer func() {
+			if recover() != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		c.Next()
+	})
+	r.Use(identify, NewManager(NewMemoryStore(), DefaultTTL).Handle())
+	r.POST("/", func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	first := serve(r, "POST", "k1", "a", `{"x":1}`)
+	second := serve(r, "POST", "k1", "a", `{"x":1}`)
+
+	if first.Code != http.StatusInternalServerError || second.Code != http.StatusCreated || calls != 2 {
+		t.Error("Expected to release key of the panicking request for retry")
+	}
+}
+
+func Test_Manager_Handle_InProgress_Failure(t *testing.T) {
+	store := NewMemoryStore()
+	calls := 0
+	r := newTestRouter(NewManager(store, DefaultTTL), &calls, http.StatusCreated)
+
+	_, _, err := store.Reserve("t1"+keySeparator+"a"+keySeparator+"k1", &Record{
+		Fingerprint: fingerprint("POST", "/", "", []byte(`{"x":1}`)),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal("Expected to reserve key successfully")
+	}
+
+	w := serve(r, "POST", "k1", "a", `{"x":1}`)
+	if w.Code != http.StatusConflict {
+		t.Error("Expected to reject request in progress")
+	}
+	if calls != 0 {
+		t.Error("Expected handler not to be called")
+	}
+}
+
+func Test_MemoryStore_Reserve_Expired_Success(t *testing.T) {
+	now := time.Now()
+	store := &MemoryStore{records: map[string]*Record{}, now: func() time.Time { return now }}
+
+	_, reserved, _ := store.Reserve("k", &Record{ExpiresAt: now.Add(time.Minute)})
+	if !reserved {
+		t.Fatal("Expected to reserve free key")
+	}
+
+	_, reserved, _ = store.Reserve("k", &Record{ExpiresAt: now.Add(time.Minute)})
+	if reserved {
+		t.Error("Expected not to reserve taken key")
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	_, reserved, _ = store.Reserve("k", &Record{ExpiresAt: now.Add(time.Minute)})
+	if !reserved {
+		t.Error("Expected to reserve expired key")
+	}
+}
+
+func Test_MemoryStore_Expire_Success(t *testing.T) {
+	now := time.Now()
+	store := &MemoryStore{records: map[string]*Record{}, now: func() time.Time { return now }}
+
+	_, _, _ = store.Reserve("expired", &Record{ExpiresAt: now.Add(time.Minute)})
+	_, _, _ = store.Reserve("kept", &Record{ExpiresAt: now.Add(time.Hour)})
+	now = now.Add(2 * time.Minute)
+
+	err := store.Expire()
+	if err != nil {
+		t.Fatal("Expected to expire records successfully")
+	}
+	if _, ok := store.records["expired"]; ok || len(store.records) != 1 {
+		t.Errorf("Expected only expired record to be removed, got %v records", len(store.records))
+	}
+}
@@ -0,0 +1,95 @@
+package idempotency
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record contains stored response of the idempotent request
+type Record struct {
+	Fingerprint string
+	Completed   bool
+	Status      int
+	Header      http.Header
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// Store is idempotency record store interface
+type Store interface {
+	// Reserve stores in-progress record if key is free, otherwise returns existing record
+	Reserve(key string, record *Record) (*Record, bool, error)
+	// Complete stores completed record
+	Complete(key string, record *Record) error
+	// Release removes record
+	Release(key string) error
+	// Expire removes expired records, it is run periodically by the reaper
+	Expire() error
+}
+
+// MemoryStore is in-memory idempotency record store
+type MemoryStore struct {
+	mutex   sync.Mutex
+	records map[string]*Record
+	now     func() time.Time
+}
+
+// NewMemoryStore is a constructor of in-memory idempotency record store
+func NewMemoryStore() Store {
+	return &MemoryStore{records: map[string]*Record{}, now: time.Now}
+}
+
+// Reserve stores in-progress record if key is free, otherwise returns existing record
+func (store *MemoryStore) Reserve(key string, record *Record) (*Record, bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	existing, ok := store.records[key]
+	if ok && existing.ExpiresAt.After(store.now()) {
+		copied := *existing
+		return &copied, false, nil
+	}
+
+	copied := *record
+	store.records[key] = &copied
+
+	return nil, true, nil
+}
+
+// Complete stores completed record
+func (store *MemoryStore) Complete(key string, record *Record) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	copied := *record
+	copied.Completed = true
+	store.records[key] = &copied
+
+	return nil
+}
+
+// Release removes record
+func (store *MemoryStore) Release(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.records, key)
+
+	return nil
+}
+
+// Expire removes expired records
+func (store *MemoryStore) Expire() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	for key, record := range store.records {
+		if !record.ExpiresAt.After(now) {
+			delete(store.records, key)
+		}
+	}
+
+	return nil
+}
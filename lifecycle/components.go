@@ -0,0 +1,177 @@
+package lifecycle
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/persistence/sqldb"
+)
+
+const (
+	// componentDB is name of the db pool component
+	componentDB = "db"
+	// componentServer is name of the http server component
+	componentServer = "http"
+)
+
+// DB is db pool component checking connection on start and closing connections on stop
+type DB struct {
+	db sqldb.DBInterface
+}
+
+// NewDB is a constructor of db pool component
+func NewDB(db sqldb.DBInterface) Component {
+	return &DB{db: db}
+}
+
+// Name returns component name
+func (component *DB) Name() string {
+	return componentDB
+}
+
+// Start pings db
+func (component *DB) Start(ctx context.Context, fail func(err error)) error {
+	return component.db.Ping(ctx)
+}
+
+// Stop closes db connections, it waits for queries in progress to finish
+func (component *DB) Stop(ctx context.Context) error {
+	return component.db.Close()
+}
+
+// Server is http server component serving requests in the base context cancelled on stop
+// to abort db operations of requests still running
+type Server struct {
+	server     *http.Server
+	drain      func()
+	drainDelay time.Duration
+	cancel     context.CancelFunc
+}
+
+// NewServer is a constructor of http server component, on drain it runs drain and keeps serving for drain delay
+// so that load balancer stops sending traffic before shutdown
+func NewServer(server *http.Server, drain func(), drainDelay time.Duration) Component {
+	return &Server{server: server, drain: drain, drainDelay: drainDelay}
+}
+
+// Name returns component name
+func (component *Server) Name() string {
+	return componentServer
+}
+
+// Start listens to the server address and serves requests in background
+func (component *Server) Start(ctx context.Context, fail func(err error)) error {
+	listener, err := net.Listen("tcp", component.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	base, cancel := context.WithCancel(context.Background())
+	component.cancel = cancel
+	component.server.BaseContext = func(net.Listener) context.Context { return base }
+
+	go func() {
+		err := component.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			fail(err)
+		}
+	}()
+
+	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+		"address": listener.Addr().String(),
+	}).Info("Service is listening")
+
+	return nil
+}
+
+// Drain runs drain and waits for drain delay
+func (component *Server) Drain(ctx context.Context) {
+	if component.drain != nil {
+		component.drain()
+	}
+
+	timer := time.NewTimer(component.drainDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// Stop stops accepting requests and waits for requests in progress, requests still running at the context
+// deadline are cancelled and their connections are closed
+func (component *Server) Stop(ctx context.Context) error {
+	err := component.server.Shutdown(ctx)
+	component.cancel()
+	if err != nil {
+		_ = component.server.Close()
+	}
+
+	return err
+}
+
+// Worker is background component running its job periodically
+type Worker struct {
+	name     string
+	interval time.Duration
+	job      func(ctx context.Context) error
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewWorker is a constructor of background component running the job every interval,
+// job errors are logged and the job is run again on the next tick
+func NewWorker(name string, interval time.Duration, job func(ctx context.Context) error) Component {
+	return &Worker{name: name, interval: interval, job: job}
+}
+
+// Name returns component name
+func (component *Worker) Name() string {
+	return component.name
+}
+
+// Start runs the job in background
+func (component *Worker) Start(ctx context.Context, fail func(err error)) error {
+	ctx, component.cancel = context.WithCancel(context.Background())
+	component.done = make(chan struct{})
+
+	go func() {
+		defer close(component.done)
+
+		ticker := time.NewTicker(component.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				err := component.job(ctx)
+				if err != nil && ctx.Err() == nil {
+					logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+						"error":  err,
+						"worker": component.name,
+					}).Error("Error running worker job")
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop cancels the job in progress and waits for it within the context deadline
+func (component *Worker) Stop(ctx context.Context) error {
+	component.cancel()
+
+	select {
+	case <-component.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
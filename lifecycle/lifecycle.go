@@ -0,0 +1,188 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vsukhin/booking/logging"
+)
+
+const (
+	// DefaultDrainTimeout is default time given to components to stop, components still running after it are
+	// stopped forcibly
+	DefaultDrainTimeout = 30 * time.Second
+	// ExitCodeClean is exit code of the service stopped cleanly
+	ExitCodeClean = 0
+	// ExitCodeFailure is exit code of the service failed to start or stopped by failed component
+	ExitCodeFailure = 1
+	// ExitCodeForced is exit code of the service stopped forcibly after drain timeout or failed stop of component
+	ExitCodeForced = 3
+)
+
+// Component is service part started and stopped by lifecycle manager
+type Component interface {
+	Name() string
+	// Start starts component, errors of the component running in background are reported to fail
+	Start(ctx context.Context, fail func(err error)) error
+	// Stop stops component within the context deadline
+	Stop(ctx context.Context) error
+}
+
+// Drainer is component stopping to take new work before it is stopped on SIGTERM
+type Drainer interface {
+	Drain(ctx context.Context)
+}
+
+// Manager is lifecycle manager starting components in order and stopping them in reverse order
+type Manager struct {
+	components   []Component
+	drainTimeout time.Duration
+	reloads      []func(ctx context.Context) error
+	started      []Component
+	failures     chan error
+	once         sync.Once
+}
+
+// ManagerInterface is lifecycle manager interface
+type ManagerInterface interface {
+	OnReload(reload func(ctx context.Context) error)
+	Start(ctx context.Context) error
+	Reload(ctx context.Context)
+	Stop(drain bool) int
+	Run(signals <-chan os.Signal) int
+}
+
+// NewManager is a constructor of lifecycle manager
+func NewManager(drainTimeout time.Duration, components ...Component) ManagerInterface {
+	return &Manager{components: components, drainTimeout: drainTimeout, failures: make(chan error, 1)}
+}
+
+// OnReload registers reload of the settings run on SIGHUP
+func (manager *Manager) OnReload(reload func(ctx context.Context) error) {
+	manager.reloads = append(manager.reloads, reload)
+}
+
+// fail reports first failure of the running component
+func (manager *Manager) fail(name string) func(err error) {
+	return func(err error) {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error":     err,
+			"component": name,
+		}).Error("Component failed")
+
+		manager.once.Do(func() {
+			manager.failures <- err
+		})
+	}
+}
+
+// Start starts components in order, components started before the failed one are stopped
+func (manager *Manager) Start(ctx context.Context) error {
+	for _, component := range manager.components {
+		err := component.Start(ctx, manager.fail(component.Name()))
+		if err != nil {
+			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+				"error":     err,
+				"component": component.Name(),
+			}).Error("Error starting component")
+
+			manager.Stop(false)
+			return err
+		}
+
+		logging.Log.WithFields(logging.DepthLow, logging.Fields{
+			"component": component.Name(),
+		}).Info("Component is started")
+
+		manager.started = append(manager.started, component)
+	}
+
+	return nil
+}
+
+// Reload runs reloads of the settings, failed reload keeps former settings and the service running
+func (manager *Manager) Reload(ctx context.Context) {
+	for _, reload := range manager.reloads {
+		err := reload(ctx)
+		if err != nil {
+			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+				"error": err,
+			}).Error("Error reloading settings")
+		}
+	}
+
+	logging.Log.Info("Service is reloaded at ", time.Now())
+}
+
+// Stop drains started components if asked and stops them in reverse order within drain timeout,
+// returns exit code of the stop
+func (manager *Manager) Stop(drain bool) int {
+	ctx, cancel := context.WithTimeout(context.Background(), manager.drainTimeout)
+	defer cancel()
+
+	if drain {
+		for i := len(manager.started) - 1; i >= 0; i-- {
+			if drainer, ok := manager.started[i].(Drainer); ok {
+				drainer.Drain(ctx)
+			}
+		}
+	}
+
+	code := ExitCodeClean
+	for i := len(manager.started) - 1; i >= 0; i-- {
+		component := manager.started[i]
+
+		err := component.Stop(ctx)
+		if err != nil {
+			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+				"error":     err,
+				"component": component.Name(),
+				"timeout":   manager.drainTimeout.String(),
+			}).Error("Error stopping component")
+
+			code = ExitCodeForced
+			continue
+		}
+
+		logging.Log.WithFields(logging.DepthLow, logging.Fields{
+			"component": component.Name(),
+		}).Info("Component is stopped")
+	}
+	manager.started = nil
+
+	return code
+}
+
+// Run starts components and serves signals until the service is stopped, returns exit code of the service:
+// SIGHUP reloads settings, SIGTERM drains and stops components, other signals stop them at once,
+// failure of the running component stops the service with failure code unless the stop is forced
+func (manager *Manager) Run(signals <-chan os.Signal) int {
+	err := manager.Start(context.Background())
+	if err != nil {
+		return ExitCodeFailure
+	}
+
+	for {
+		select {
+		case sig := <-signals:
+			switch sig {
+			case syscall.SIGHUP:
+				manager.Reload(context.Background())
+			case syscall.SIGTERM:
+				return manager.Stop(true)
+			default:
+				return manager.Stop(false)
+			}
+		case <-manager.failures:
+			code := manager.Stop(false)
+			if code == ExitCodeClean {
+				code = ExitCodeFailure
+			}
+
+			return code
+		}
+	}
+}
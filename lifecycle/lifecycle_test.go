@@ -0,0 +1,223 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/logging"
+)
+
+func init() {
+	logging.Log = NewFakeLogger()
+}
+
+// FakeLogger is fake logger
+type FakeLogger struct {
+	*logrus.Logger
+}
+
+// NewFakeLogger is a constructor of fake logger
+func NewFakeLogger() logging.LoggerInterface {
+	log := logrus.New()
+
+	return &FakeLogger{log}
+}
+
+// Init initiates logging
+func (logger *FakeLogger) Init(mode string) {
+}
+
+// WithFields logs with fields
+func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// Info logs info
+func (logger *FakeLogger) Info(args ...interface{}) {
+}
+
+// FakeComponent is fake component recording its calls to the shared log
+type FakeComponent struct {
+	name     string
+	calls    *[]string
+	startErr error
+	blocking bool
+	fail     func(err error)
+}
+
+// Name returns component name
+func (component *FakeComponent) Name() string {
+	return component.name
+}
+
+// Start records start
+func (component *FakeComponent) Start(ctx context.Context, fail func(err error)) error {
+	*component.calls = append(*component.calls, "start "+component.name)
+	component.fail = fail
+
+	return component.startErr
+}
+
+// Drain records drain
+func (component *FakeComponent) Drain(ctx context.Context) {
+	*component.calls = append(*component.calls, "drain "+component.name)
+}
+
+// Stop records stop, blocking component waits for the context deadline
+func (component *FakeComponent) Stop(ctx context.Context) error {
+	*component.calls = append(*component.calls, "stop "+component.name)
+	if component.blocking {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func Test_Manager_Run_Success(t *testing.T) {
+	var calls []string
+	manager := NewManager(time.Second, &FakeComponent{name: "db", calls: &calls},
+		&FakeComponent{name: "http", calls: &calls})
+	manager.OnReload(func(ctx context.Context) error {
+		calls = append(calls, "reload")
+		return errors.New("invalid key set")
+	})
+
+	signals := make(chan os.Signal, 2)
+	signals <- syscall.SIGHUP
+	signals <- syscall.SIGTERM
+
+	code := manager.Run(signals)
+	if code != ExitCodeClean {
+		t.Errorf("Expected clean stop, got %v", code)
+	}
+
+	expected := "start db, start http, reload, drain http, drain db, stop http, stop db"
+	if strings.Join(calls, ", ") != expected {
+		t.Errorf("Unexpected lifecycle %v", strings.Join(calls, ", "))
+	}
+}
+
+func Test_Manager_Run_Interrupt_Success(t *testing.T) {
+	var calls []string
+	manager := NewManager(time.Second, &FakeComponent{name: "http", calls: &calls})
+
+	signals := make(chan os.Signal, 1)
+	signals <- os.Interrupt
+
+	code := manager.Run(signals)
+	if code != ExitCodeClean || strings.Join(calls, ", ") != "start http, stop http" {
+		t.Errorf("Expected stop without drain, got %v %v", code, calls)
+	}
+}
+
+func Test_Manager_Run_Start_Failure(t *testing.T) {
+	var calls []string
+	manager := NewManager(time.Second, &FakeComponent{name: "db", calls: &calls},
+		&FakeComponent{name: "http", calls: &calls, startErr: errors.New("address in use")},
+		&FakeComponent{name: "worker", calls: &calls})
+
+	code := manager.Run(make(chan os.Signal))
+	if code != ExitCodeFailure {
+		t.Errorf("Expected failure code, got %v", code)
+	}
+	if strings.Join(calls, ", ") != "start db, start http, stop db" {
+		t.Errorf("Expected started components to be stopped, got %v", strings.Join(calls, ", "))
+	}
+}
+
+func Test_Manager_Run_Component_Failure(t *testing.T) {
+	var calls []string
+	component := &FakeComponent{name: "http", calls: &calls}
+	manager := NewManager(time.Second, component)
+
+	started := make(chan os.Signal)
+	go func() {
+		started <- syscall.SIGHUP
+		component.fail(errors.New("listener is closed"))
+		component.fail(errors.New("listener is closed"))
+	}()
+
+	code := manager.Run(started)
+	if code != ExitCodeFailure {
+		t.Errorf("Expected failure code, got %v", code)
+	}
+}
+
+func Test_Manager_Stop_Forced_Failure(t *testing.T) {
+	var calls []string
+	manager := NewManager(10*time.Millisecond, &FakeComponent{name: "db", calls: &calls},
+		&FakeComponent{name: "worker", calls: &calls, blocking: true})
+
+	err := manager.Start(context.Background())
+	if err != nil {
+		t.Fatal("Expected to start components successfully")
+	}
+
+	code := manager.Stop(false)
+	if code != ExitCodeForced {
+		t.Errorf("Expected forced stop, got %v", code)
+	}
+	if strings.Join(calls, ", ") != "start db, start worker, stop worker, stop db" {
+		t.Errorf("Expected components after the forced one to be stopped, got %v", strings.Join(calls, ", "))
+	}
+}
+
+func Test_Worker_Stop_Success(t *testing.T) {
+	runs := make(chan bool, 1)
+	worker := NewWorker("reaper", time.Millisecond, func(ctx context.Context) error {
+		select {
+		case runs <- true:
+		default:
+		}
+
+		return errors.New("store is unavailable")
+	})
+
+	err := worker.Start(context.Background(), nil)
+	if err != nil {
+		t.Fatal("Expected to start worker successfully")
+	}
+
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("Expected worker to run its job")
+	}
+
+	err = worker.Stop(context.Background())
+	if err != nil {
+		t.Error("Expected to stop worker successfully")
+	}
+}
+
+func Test_Server_Stop_Success(t *testing.T) {
+	drained := false
+	server := NewServer(&http.Server{Addr: "127.0.0.1:0", Handler: http.NotFoundHandler()},
+		func() { drained = true }, time.Millisecond)
+
+	err := server.Start(context.Background(), nil)
+	if err != nil {
+		t.Fatal("Expected to start server successfully")
+	}
+
+	server.(Drainer).Drain(context.Background())
+
+	err = server.Stop(context.Background())
+	if err != nil || !drained {
+		t.Error("Expected to drain and stop server successfully")
+	}
+}
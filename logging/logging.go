@@ -1,11 +1,14 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/tracing"
 )
 
 const (
@@ -42,7 +45,9 @@ type Logger struct {
 // LoggerInterface is logger interface
 type LoggerInterface interface {
 	Init(mode string)
+	SetLevel(level logrus.Level)
 	WithFields(depthLevel int, fields Fields) *logrus.Entry
+	WithContext(ctx context.Context, depthLevel int, fields Fields) *logrus.Entry
 	Info(args ...interface{})
 }
 
@@ -75,6 +80,21 @@ func (logger *Logger) Init(mode string) {
 
 // WithFields logs with fields
 func (logger *Logger) WithFields(depthLevel int, fields Fields) *logrus.Entry {
+	return logger.entry(depthLevel+1, fields)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *Logger) WithContext(ctx context.Context, depthLevel int, fields Fields) *logrus.Entry {
+	span := tracing.FromContext(ctx)
+	if span != nil {
+		fields["traceID"] = span.Context.TraceID
+		fields["spanID"] = span.Context.SpanID
+	}
+
+	return logger.entry(depthLevel+1, fields)
+}
+
+func (logger *Logger) entry(depthLevel int, fields Fields) *logrus.Entry {
 	_, file, line, ok := runtime.Caller(depthLevel)
 	if ok {
 		fields["line"] = fmt.Sprintf("%s:%d", file, line)
@@ -1,7 +1,10 @@
 package logging
 
 import (
+	"context"
 	"testing"
+
+	"github.com/vsukhin/booking/tracing"
 )
 
 func Test_Logger_Init_Dev_Success(t *testing.T) {
@@ -45,6 +48,25 @@ func Test_Logger_WithFields_Success(t *testing.T) {
 	}
 }
 
+func Test_Logger_WithContext_Success(t *testing.T) {
+	logger := NewLogger()
+	tracer := tracing.NewTracer(&tracing.NopExporter{}, nil)
+	ctx, span := tracer.Start(context.Background(), "test", tracing.SpanKindServer, tracing.SpanContext{})
+
+	var fields = Fields{}
+
+	entry := logger.WithContext(ctx, DepthModerate, fields)
+	if entry == nil {
+		t.Error("Expected to log fields successfully")
+	}
+	if fields["traceID"] != span.Context.TraceID || fields["spanID"] != span.Context.SpanID {
+		t.Error("Expected trace of the context")
+	}
+	if fields["line"] == "" {
+		t.Error("Expected not empty line")
+	}
+}
+
 func Test_Logger_Info_Success(t *testing.T) {
 	logger := NewLogger()
 
@@ -10,126 +10,150 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/cache"
+	"github.com/vsukhin/booking/config"
+	"github.com/vsukhin/booking/events"
+	"github.com/vsukhin/booking/health"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/idempotency"
+	"github.com/vsukhin/booking/lifecycle"
 	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/metrics"
+	"github.com/vsukhin/booking/models"
 	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/ratelimit"
 	"github.com/vsukhin/booking/router"
+	"github.com/vsukhin/booking/tracing"
 )
 
 const (
-	// HostAddress is host address
-	HostAddress = ""
-	// ParameterNameHostAddress contains parameter host name
-	ParameterNameHostAddress = "host"
-	// PortHTTP is server port
-	PortHTTP = 3000
-	// ParameterNamePortHTTP contains parameter port name
-	ParameterNamePortHTTP = "port"
-	// Mode is service running mode
-	Mode = logging.ModeDev
-	// ParameterNameMode contains parameter mode name
-	ParameterNameMode = "mode"
-	// DBConnection is db connection string
-	DBConnection = ""
-	// ParameterNameDBConnection contains parameter db connection name
-	ParameterNameDBConnection = "db"
+	// ServiceName is service name of exported spans
+	ServiceName = "booking"
 )
 
-var (
-	host         = flag.String(ParameterNameHostAddress, HostAddress, "HTTP server host address")
-	httpPort     = flag.Int(ParameterNamePortHTTP, PortHTTP, "HTTP server port")
-	mode         = flag.String(ParameterNameMode, Mode, "Service running mode: dev, staging, prod")
-	dbConnection = flag.String(ParameterNameDBConnection, DBConnection, "DB connection string")
-)
-
-func initParameters() []error {
-	var err error
-	var errs []error
+// applySettings applies reloadable settings of the configuration
+func applySettings(settings *config.Config, db sqldb.DBInterface) {
+	logging.Log.SetLevel(settings.LogLevel())
+	sqldb.SetPoolSize(db, settings.DB.MaxIdleConns, settings.DB.MaxOpenConns)
+	models.SetLayoutLimits(models.LayoutLimits{MaxRows: settings.Limits.MaxRows, MaxLines: settings.Limits.MaxLines})
+	helpers.SetDefaultLimit(settings.Limits.PageSize)
+}
 
-	flag.Parse()
+func main() {
+	logging.Log = logging.NewLogger()
 
-	envHost := os.Getenv("BOOKING_API_HTTP_HOST")
-	if envHost != "" {
-		*host = envHost
+	settings, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
 	}
-
-	envPort := os.Getenv("BOOKING_API_HTTP_PORT")
-	if envPort != "" {
-		var value int
-
-		value, err = strconv.Atoi(envPort)
-		if err == nil {
-			*httpPort = value
-		} else {
-			errs = append(errs, err)
-		}
+	if err != nil {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error": err,
+		}).Error("Error loading configuration")
+		os.Exit(1)
 	}
 
-	envMode := os.Getenv("BOOKING_API_MODE")
-	if envMode != "" {
-		*mode = envMode
-	}
+	logging.Log.Info("Service is started at ", time.Now())
+	logging.Log.Init(settings.Mode)
 
-	envDBConnection := os.Getenv("BOOKING_API_DB")
-	if envDBConnection != "" {
-		*dbConnection = envDBConnection
+	db, err := sqldb.NewDB(settings.DB.Connection, nil, settings.IsProduction(), sqldb.NewGorpLogger())
+	if err != nil {
+		os.Exit(1)
 	}
+	applySettings(settings, db)
 
-	return errs
-}
+	registry := metrics.NewRegistry()
+	db = sqldb.NewTracedDB(sqldb.NewInstrumentedDB(db, registry))
 
-func main() {
-	errs := initParameters()
-
-	logging.Log = logging.NewLogger()
-	logging.Log.Info("Service is started at ", time.Now())
-	if len(errs) != 0 {
+	exporter, err := tracing.NewExporter(settings.Trace.Exporter, settings.Trace.File, ServiceName)
+	if err != nil {
 		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"errors": errs,
-		}).Warn("Error reading values from env")
+			"error":    err,
+			"exporter": settings.Trace.Exporter,
+			"file":     settings.Trace.File,
+		}).Error("Error creating trace exporter")
+		os.Exit(1)
 	}
+	tracer := tracing.NewTracer(exporter, func(err error) {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error": err,
+		}).Error("Error exporting span")
+	})
 
-	logging.Log.Init(*mode)
-
-	isProduction := false
-	if *mode == logging.ModeStaging || *mode == logging.ModeProd {
-		isProduction = true
+	if len(args) != 0 && args[0] == CommandImport {
+		code := runImport(db, args[1:])
+		_ = db.Close()
+		os.Exit(code)
 	}
 
-	db, err := sqldb.NewDB(*dbConnection, nil, isProduction, sqldb.NewGorpLogger())
+	keySet, err := auth.LoadKeySet(settings.Auth.KeySetFile)
 	if err != nil {
 		os.Exit(1)
 	}
+	authManager := auth.NewKeySetManager(keySet)
+
+	healthManager := health.NewManager(health.DefaultCheckTimeout, health.NewDBChecker(db),
+		health.NewMigrationChecker(db, sqldb.SchemaVersion))
+
+	idempotencyStore := idempotency.NewMemoryStore()
+	rateLimitStore := ratelimit.NewMemoryStore()
+	rateLimitManager := ratelimit.NewManager(rateLimitStore, settings.RateLimitBudgets(), settings.TrustedProxies())
+	routerManager := router.NewManager(db, authManager,
+		idempotency.NewManager(idempotencyStore, idempotency.DefaultTTL), rateLimitManager, events.NewLogPublisher(),
+		cache.NewManager(cache.NewMemoryStore(cache.DefaultCapacity), cache.DefaultTTL), registry, tracer,
+		healthManager, settings.HTTP.RequestTimeout)
+	r := routerManager.CreateRouter(settings.Mode)
+
+	server := &http.Server{Addr: settings.HTTP.Host + ":" + strconv.Itoa(settings.HTTP.Port), Handler: r}
+
+	// components are started in order and stopped in reverse order, so the server stops taking requests first
+	// and the db is closed last
+	lifecycleManager := lifecycle.NewManager(settings.HTTP.DrainTimeout,
+		lifecycle.NewDB(db),
+		lifecycle.NewWorker("idempotency-reaper", idempotency.ExpireInterval, func(ctx context.Context) error {
+			return idempotencyStore.Expire()
+		}),
+		lifecycle.NewWorker("rate-limit-reaper", ratelimit.ExpireInterval, func(ctx context.Context) error {
+			return rateLimitStore.Expire()
+		}),
+		lifecycle.NewServer(server, healthManager.Drain, settings.HTTP.DrainDelay))
+
+	// reload takes configuration from the same file, env and flags, invalid configuration keeps the former one
+	lifecycleManager.OnReload(func(ctx context.Context) error {
+		next, _, err := config.Load(os.Args[1:], os.Getenv)
+		if err != nil {
+			return err
+		}
 
-	routerManager := router.NewManager(db)
-	r := routerManager.CreateRouter(*mode)
-	server := &http.Server{Addr: *host + ":" + strconv.Itoa(*httpPort), Handler: r}
+		keySet, err := auth.LoadKeySet(next.Auth.KeySetFile)
+		if err != nil {
+			return err
+		}
 
-	go func() {
-		err := server.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
+		reloaded, restart := settings.Reload(next)
+		if len(restart) != 0 {
 			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-				"error": err,
-				"host":  *host,
-				"port":  *httpPort,
-			}).Fatal("Error starting http service")
+				"settings": restart,
+			}).Warn("Changed settings require restart")
 		}
-	}()
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	signal.Notify(c, syscall.SIGTERM)
+		settings = reloaded
+		applySettings(settings, db)
+		authManager.SetKeySet(keySet)
+		rateLimitManager.SetBudgets(settings.RateLimitBudgets())
 
-	<-c
-	close(c)
+		return nil
+	})
 
-	err = server.Shutdown(context.Background())
-	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"error": err,
-			"host":  *host,
-			"port":  *httpPort,
-		}).Error("Error stopping http service")
-	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	code := lifecycleManager.Run(c)
+	signal.Stop(c)
 
-	logging.Log.Info("Service is stopped at ", time.Now())
+	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+		"code": code,
+	}).Info("Service is stopped at ", time.Now())
+	os.Exit(code)
 }
@@ -0,0 +1,230 @@
+package manifest
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/vsukhin/booking/models"
+)
+
+// Format is manifest file format
+type Format string
+
+const (
+	// FormatCSV is comma separated values with header line
+	FormatCSV Format = "csv"
+	// FormatNDJSON is one json object per line
+	FormatNDJSON Format = "ndjson"
+	// FormatText is fixed-width printable text with header line
+	FormatText Format = "text"
+)
+
+const (
+	// statusFree is status of the free seat
+	statusFree = "free"
+	// statusAssigned is status of the assigned seat
+	statusAssigned = "assigned"
+	// statusBlocked is status of the blocked seat
+	statusBlocked = "blocked"
+)
+
+var (
+	// formats contains content types and file extensions of formats
+	formats = map[Format]struct{ contentType, extension string }{
+		FormatCSV:    {"text/csv; charset=utf-8", "csv"},
+		FormatNDJSON: {"application/x-ndjson", "ndjson"},
+		FormatText:   {"text/plain; charset=utf-8", "txt"},
+	}
+	// typeNames contains names of seat types
+	typeNames = map[models.SeatType]string{
+		models.SeatTypeAisle:  "aisle",
+		models.SeatTypeWindow: "window",
+		models.SeatTypeMiddle: "middle",
+	}
+	// columns contains names and fixed widths of manifest columns
+	columns = []struct {
+		name  string
+		width int
+	}{
+		{"index", 5}, {"seat", 5}, {"type", 6}, {"status", 8}, {"owner", 24}, {"first_name", 16},
+		{"last_name", 20}, {"pnr", 7}, {"sequence", 8},
+	}
+)
+
+// Encoder writes manifest rows in the format
+type Encoder interface {
+	Encode(row *models.ManifestRow) error
+	Flush() error
+}
+
+// IsValid checks manifest format
+func (format Format) IsValid() bool {
+	_, ok := formats[format]
+	return ok
+}
+
+// ContentType returns content type of the format
+func (format Format) ContentType() string {
+	return formats[format].contentType
+}
+
+// Extension returns file extension of the format
+func (format Format) Extension() string {
+	return formats[format].extension
+}
+
+// NewEncoder is a constructor of the format encoder, header line is written with the first row or flush
+func NewEncoder(format Format, writer io.Writer) Encoder {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{writer: csv.NewWriter(writer)}
+	case FormatText:
+		return &textEncoder{writer: bufio.NewWriter(writer)}
+	}
+
+	buffered := bufio.NewWriter(writer)
+	return &ndjsonEncoder{writer: buffered, encoder: json.NewEncoder(buffered)}
+}
+
+// values returns column values of the row
+func values(row *models.ManifestRow) []string {
+	status := statusFree
+	if row.Blocked {
+		status = statusBlocked
+	} else if row.Assigned {
+		status = statusAssigned
+	}
+
+	sequence := ""
+	if row.Sequence != 0 {
+		sequence = strconv.Itoa(row.Sequence)
+	}
+
+	return []string{strconv.Itoa(row.Index), fmt.Sprintf("%d%s", row.Row, row.Line), typeNames[row.Type], status,
+		row.Owner, row.FirstName, row.LastName, row.PNR, sequence}
+}
+
+type csvEncoder struct {
+	writer  *csv.Writer
+	started bool
+}
+
+func (encoder *csvEncoder) header() error {
+	if encoder.started {
+		return nil
+	}
+	encoder.started = true
+
+	var names []string
+	for _, column := range columns {
+		names = append(names, column.name)
+	}
+
+	return encoder.writer.Write(names)
+}
+
+// Encode writes csv record of the row
+func (encoder *csvEncoder) Encode(row *models.ManifestRow) error {
+	err := encoder.header()
+	if err != nil {
+		return err
+	}
+
+	return encoder.writer.Write(values(row))
+}
+
+// Flush flushes buffered records
+func (encoder *csvEncoder) Flush() error {
+	err := encoder.header()
+	if err != nil {
+		return err
+	}
+
+	encoder.writer.Flush()
+	return encoder.writer.Error()
+}
+
+type ndjsonEncoder struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// Encode writes json line of the row
+func (encoder *ndjsonEncoder) Encode(row *models.ManifestRow) error {
+	return encoder.encoder.Encode(row)
+}
+
+// Flush flushes buffered lines
+func (encoder *ndjsonEncoder) Flush() error {
+	return encoder.writer.Flush()
+}
+
+type textEncoder struct {
+	writer  *bufio.Writer
+	started bool
+}
+
+func (encoder *textEncoder) line(values []string) error {
+	var builder strings.Builder
+
+	for i, column := range columns {
+		value := []rune(values[i])
+		if len(value) > column.width {
+			value = value[:column.width]
+		}
+
+		if i != 0 {
+			builder.WriteString(" ")
+		}
+		builder.WriteString(string(value))
+		builder.WriteString(strings.Repeat(" ", column.width-len(value)))
+	}
+
+	_, err := encoder.writer.WriteString(strings.TrimRight(builder.String(), " ") + "\n")
+	return err
+}
+
+func (encoder *textEncoder) header() error {
+	if encoder.started {
+		return nil
+	}
+	encoder.started = true
+
+	var names, rules []string
+	for _, column := range columns {
+		names = append(names, strings.ToUpper(column.name))
+		rules = append(rules, strings.Repeat("-", column.width))
+	}
+
+	err := encoder.line(names)
+	if err != nil {
+		return err
+	}
+
+	return encoder.line(rules)
+}
+
+// Encode writes fixed-width line of the row
+func (encoder *textEncoder) Encode(row *models.ManifestRow) error {
+	err := encoder.header()
+	if err != nil {
+		return err
+	}
+
+	return encoder.line(values(row))
+}
+
+// Flush flushes buffered lines
+func (encoder *textEncoder) Flush() error {
+	err := encoder.header()
+	if err != nil {
+		return err
+	}
+
+	return encoder.writer.Flush()
+}
@@ -0,0 +1,73 @@
+package manifest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/vsukhin/booking/models"
+)
+
+func newTestRows() []models.ManifestRow {
+	return []models.ManifestRow{
+		{Index: 1, Row: 1, Line: "A", Type: models.SeatTypeWindow, Assigned: true, Owner: "owner",
+			FirstName: "Luc", LastName: "Desmarais", PNR: "ABC123", Sequence: 1},
+		{Index: 2, Row: 1, Line: "B", Type: models.SeatTypeAisle, Blocked: true},
+	}
+}
+
+func encode(t *testing.T, format Format) string {
+	var buffer bytes.Buffer
+
+	encoder := NewEncoder(format, &buffer)
+	for _, row := range newTestRows() {
+		row := row
+		if err := encoder.Encode(&row); err != nil {
+			t.Fatal("Expected to encode row successfully")
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal("Expected to flush successfully")
+	}
+
+	return buffer.String()
+}
+
+func Test_Encoder_CSV_Success(t *testing.T) {
+	expected := "index,seat,type,status,owner,first_name,last_name,pnr,sequence\n" +
+		"1,1A,window,assigned,owner,Luc,Desmarais,ABC123,1\n" +
+		"2,1B,aisle,blocked,,,,,\n"
+	if value := encode(t, FormatCSV); value != expected {
+		t.Errorf("Expected to encode %q, got %q", expected, value)
+	}
+}
+
+func Test_Encoder_NDJSON_Success(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(encode(t, FormatNDJSON)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"last_name":"Desmarais"`) ||
+		!strings.Contains(lines[1], `"blocked":true`) {
+		t.Errorf("Expected to encode json line per row, got %q", lines)
+	}
+}
+
+func Test_Encoder_Text_Success(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(encode(t, FormatText)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "INDEX SEAT  TYPE   STATUS") ||
+		!strings.HasPrefix(lines[2], "1     1A    window assigned owner") ||
+		strings.Index(lines[2], "Luc") != strings.Index(lines[0], "FIRST_NAME") {
+		t.Errorf("Expected to encode fixed-width lines, got %q", lines)
+	}
+}
+
+func Test_Encoder_Empty_Success(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := NewEncoder(FormatText, &buffer).Flush(); err != nil || strings.Count(buffer.String(), "\n") != 2 {
+		t.Errorf("Expected to write header of empty manifest, got %q", buffer.String())
+	}
+}
+
+func Test_Format_IsValid_Failure(t *testing.T) {
+	if Format("pdf").IsValid() || !FormatText.IsValid() || FormatText.Extension() != "txt" {
+		t.Error("Expected to accept known formats only")
+	}
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	// ContentType is content type of the prometheus text exposition format
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// DefaultBuckets contains upper bounds of latency histogram buckets in seconds
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+)
+
+// Counter is monotonically increasing metric
+type Counter interface {
+	// Add increases counter of the label values
+	Add(value float64, labels ...string)
+}
+
+// Gauge is metric set to the current value
+type Gauge interface {
+	// Set sets gauge of the label values
+	Set(value float64, labels ...string)
+	// Reset removes values of all labels
+	Reset()
+}
+
+// Histogram is metric counting observations by buckets
+type Histogram interface {
+	// Observe adds observation of the label values
+	Observe(value float64, labels ...string)
+}
+
+// Registry creates metrics and writes their values, registering the same metric twice returns the existing one
+type Registry interface {
+	Counter(name string, help string, labels ...string) Counter
+	Gauge(name string, help string, labels ...string) Gauge
+	Histogram(name string, help string, buckets []float64, labels ...string) Histogram
+	// OnCollect adds hook updating metrics right before they are written
+	OnCollect(hook func())
+	// Write writes values of all metrics
+	Write(writer io.Writer) error
+}
+
+// Since returns seconds passed since the start time
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/logging"
+)
+
+func init() {
+	logging.Log = NewFakeLogger()
+}
+
+// FakeLogger is fake logger
+type FakeLogger struct {
+	*logrus.Logger
+}
+
+// NewFakeLogger is a constructor of fake logger
+func NewFakeLogger() logging.LoggerInterface {
+	log := logrus.New()
+
+	return &FakeLogger{log}
+}
+
+// Init initiates logging
+func (logger *FakeLogger) Init(mode string) {
+}
+
+// WithFields logs with fields
+func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// Info logs info
+func (logger *FakeLogger) Info(args ...interface{}) {
+}
+
+func write(t *testing.T, registry Registry) string {
+	var buffer bytes.Buffer
+
+	err := registry.Write(&buffer)
+	if err != nil {
+		t.Fatal("Expected to write metrics successfully")
+	}
+
+	return buffer.String()
+}
+
+func Test_PrometheusRegistry_Write_Counter_Success(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.Counter("requests_total", "Number of requests", "method", "path")
+
+	counter.Add(1, "GET", "/a")
+	counter.Add(2, "GET", "/a")
+	counter.Add(1, "POST", `/"b"`)
+
+	expected := "# HELP requests_total Number of requests\n" +
+		"# TYPE requests_total counter\n" +
+		"requests_total{method=\"GET\",path=\"/a\"} 3\n" +
+		"requests_total{method=\"POST\",path=\"/\\\"b\\\"\"} 1\n"
+	if output := write(t, registry); output != expected {
+		t.Errorf("Unexpected counter output %q", output)
+	}
+}
+
+func Test_PrometheusRegistry_Write_Histogram_Success(t *testing.T) {
+	registry := NewRegistry()
+	histogram := registry.Histogram("latency_seconds", "Latency", []float64{1, 0.5})
+
+	histogram.Observe(0.5)
+	histogram.Observe(0.75)
+	histogram.Observe(3)
+
+	expected := "# HELP latency_seconds Latency\n" +
+		"# TYPE latency_seconds histogram\n" +
+		"latency_seconds_bucket{le=\"0.5\"} 1\n" +
+		"latency_seconds_bucket{le=\"1\"} 2\n" +
+		"latency_seconds_bucket{le=\"+Inf\"} 3\n" +
+		"latency_seconds_sum 4.25\n" +
+		"latency_seconds_count 3\n"
+	if output := write(t, registry); output != expected {
+		t.Errorf("Unexpected histogram output %q", output)
+	}
+}
+
+func Test_PrometheusRegistry_Write_Gauge_Success(t *testing.T) {
+	registry := NewRegistry()
+	gauge := registry.Gauge("load", "Load", "flight")
+
+	gauge.Set(0.5, "1")
+	registry.OnCollect(func() {
+		gauge.Reset()
+		gauge.Set(0.25, "2")
+	})
+
+	output := write(t, registry)
+	if strings.Contains(output, `load{flight="1"}`) || !strings.Contains(output, `load{flight="2"} 0.25`) {
+		t.Errorf("Expected collect hook to replace gauge values, got %q", output)
+	}
+}
+
+func Test_PrometheusRegistry_Counter_Labels_Failure(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.Counter("requests_total", "Number of requests", "method")
+
+	counter.Add(1)
+	counter.Add(1, "GET", "extra")
+
+	if output := write(t, registry); strings.Contains(output, "requests_total{") {
+		t.Errorf("Expected values of mismatched labels to be dropped, got %q", output)
+	}
+}
+
+func Test_PrometheusRegistry_Counter_Registered_Success(t *testing.T) {
+	registry := NewRegistry()
+
+	registry.Counter("requests_total", "Number of requests").Add(1)
+	registry.Counter("requests_total", "Number of requests").Add(1)
+
+	if output := write(t, registry); !strings.Contains(output, "requests_total 2\n") {
+		t.Errorf("Expected registered counter to be reused, got %q", output)
+	}
+}
+
+func Test_PrometheusRegistry_Gauge_Registered_Failure(t *testing.T) {
+	registry := NewRegistry()
+	registry.Counter("requests_total", "Number of requests")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected to panic registering counter as gauge")
+		}
+	}()
+
+	registry.Gauge("requests_total", "Number of requests")
+}
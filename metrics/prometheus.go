@@ -0,0 +1,271 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vsukhin/booking/logging"
+)
+
+const (
+	// kindCounter is type of counter metric
+	kindCounter = "counter"
+	// kindGauge is type of gauge metric
+	kindGauge = "gauge"
+	// kindHistogram is type of histogram metric
+	kindHistogram = "histogram"
+	// labelSeparator is separator of label values in the value key
+	labelSeparator = "\xff"
+)
+
+var (
+	// labelReplacer escapes label values
+	labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// PrometheusRegistry is registry writing metrics in the prometheus text exposition format
+type PrometheusRegistry struct {
+	mutex    sync.Mutex
+	families map[string]*family
+	hooks    []func()
+}
+
+// family contains values of the metric by label values
+type family struct {
+	mutex   sync.Mutex
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	values  map[string]*sample
+}
+
+// sample is value of the metric with label values
+type sample struct {
+	labels []string
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+// NewRegistry is a constructor of prometheus registry
+func NewRegistry() Registry {
+	return &PrometheusRegistry{families: map[string]*family{}}
+}
+
+func (registry *PrometheusRegistry) register(name string, help string, kind string, buckets []float64,
+	labels []string) *family {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	existing, ok := registry.families[name]
+	if ok {
+		if existing.kind != kind || len(existing.labels) != len(labels) {
+			panic(fmt.Sprintf("metric %s is already registered as %s with %v labels", name, existing.kind,
+				existing.labels))
+		}
+		return existing
+	}
+
+	created := &family{name: name, help: help, kind: kind, labels: labels, buckets: buckets,
+		values: map[string]*sample{}}
+	registry.families[name] = created
+
+	return created
+}
+
+// Counter creates counter
+func (registry *PrometheusRegistry) Counter(name string, help string, labels ...string) Counter {
+	return registry.register(name, help, kindCounter, nil, labels)
+}
+
+// Gauge creates gauge
+func (registry *PrometheusRegistry) Gauge(name string, help string, labels ...string) Gauge {
+	return registry.register(name, help, kindGauge, nil, labels)
+}
+
+// Histogram creates histogram with sorted bucket upper bounds
+func (registry *PrometheusRegistry) Histogram(name string, help string, buckets []float64,
+	labels ...string) Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return registry.register(name, help, kindHistogram, sorted, labels)
+}
+
+// OnCollect adds hook updating metrics right before they are written
+func (registry *PrometheusRegistry) OnCollect(hook func()) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.hooks = append(registry.hooks, hook)
+}
+
+// Write runs collect hooks and writes values of all metrics sorted by name and labels
+func (registry *PrometheusRegistry) Write(writer io.Writer) error {
+	registry.mutex.Lock()
+	hooks := append([]func(){}, registry.hooks...)
+	registry.mutex.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	registry.mutex.Lock()
+	var families []*family
+	for _, metric := range registry.families {
+		families = append(families, metric)
+	}
+	registry.mutex.Unlock()
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	buffered := bufio.NewWriter(writer)
+	for _, metric := range families {
+		metric.write(buffered)
+	}
+
+	return buffered.Flush()
+}
+
+// sample returns value of the label values, nil if their number differs from the metric labels
+func (family *family) sample(labels []string) *sample {
+	if len(labels) != len(family.labels) {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"metric":   family.name,
+			"expected": family.labels,
+			"labels":   labels,
+		}).Error("Metric label values don't match its labels")
+		return nil
+	}
+
+	key := strings.Join(labels, labelSeparator)
+
+	current, ok := family.values[key]
+	if !ok {
+		current = &sample{labels: append([]string(nil), labels...)}
+		if family.kind == kindHistogram {
+			current.counts = make([]uint64, len(family.buckets))
+		}
+		family.values[key] = current
+	}
+
+	return current
+}
+
+// Add increases counter of the label values
+func (family *family) Add(value float64, labels ...string) {
+	family.mutex.Lock()
+	defer family.mutex.Unlock()
+
+	current := family.sample(labels)
+	if current != nil {
+		current.value += value
+	}
+}
+
+// Set sets gauge of the label values
+func (family *family) Set(value float64, labels ...string) {
+	family.mutex.Lock()
+	defer family.mutex.Unlock()
+
+	current := family.sample(labels)
+	if current != nil {
+		current.value = value
+	}
+}
+
+// Reset removes values of all labels
+func (family *family) Reset() {
+	family.mutex.Lock()
+	defer family.mutex.Unlock()
+
+	family.values = map[string]*sample{}
+}
+
+// Observe adds observation of the label values to the first bucket holding it, sum and count
+func (family *family) Observe(value float64, labels ...string) {
+	family.mutex.Lock()
+	defer family.mutex.Unlock()
+
+	current := family.sample(labels)
+	if current == nil {
+		return
+	}
+
+	i := sort.SearchFloat64s(family.buckets, value)
+	if i < len(current.counts) {
+		current.counts[i]++
+	}
+	current.value += value
+	current.count++
+}
+
+func (family *family) write(writer *bufio.Writer) {
+	family.mutex.Lock()
+	defer family.mutex.Unlock()
+
+	fmt.Fprintf(writer, "# HELP %s %s\n", family.name, strings.Replace(family.help, "\n", `\n`, -1))
+	fmt.Fprintf(writer, "# TYPE %s %s\n", family.name, family.kind)
+
+	var keys []string
+	for key := range family.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		current := family.values[key]
+		if family.kind != kindHistogram {
+			writeLine(writer, family.name, family.labels, current.labels, "", current.value)
+			continue
+		}
+
+		var cumulative uint64
+		for i, bound := range family.buckets {
+			cumulative += current.counts[i]
+			writeLine(writer, family.name+"_bucket", family.labels, current.labels, formatFloat(bound),
+				float64(cumulative))
+		}
+		writeLine(writer, family.name+"_bucket", family.labels, current.labels, "+Inf", float64(current.count))
+		writeLine(writer, family.name+"_sum", family.labels, current.labels, "", current.value)
+		writeLine(writer, family.name+"_count", family.labels, current.labels, "", float64(current.count))
+	}
+}
+
+// writeLine writes sample line, le label of histogram bucket is added if given
+func writeLine(writer *bufio.Writer, name string, names []string, values []string, le string, value float64) {
+	var pairs []string
+	for i, label := range names {
+		pairs = append(pairs, label+`="`+labelReplacer.Replace(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+
+	writer.WriteString(name)
+	if len(pairs) != 0 {
+		writer.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	writer.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
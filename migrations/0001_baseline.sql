@@ -1,4 +1,6 @@
-CREATE TABLE `flights` (
+-- baseline schema, tables existing before versioning are kept
+
+CREATE TABLE IF NOT EXISTS `flights` (
   `id` INT(11) NOT NULL AUTO_INCREMENT,
   `name` VARCHAR(255) NOT NULL DEFAULT '',
   `created_at` int(11) NOT NULL,
@@ -7,7 +9,7 @@ CREATE TABLE `flights` (
   KEY `created_at` (`created_at`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `blocks` (
+CREATE TABLE IF NOT EXISTS `blocks` (
   `id` INT(11) NOT NULL AUTO_INCREMENT,
   `flight_id` int(11) NOT NULL,
   `rows` int(11) NOT NULL,
@@ -15,7 +17,7 @@ CREATE TABLE `blocks` (
   FOREIGN KEY (`flight_id`) REFERENCES `flights`(`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `seat_numbers` (
+CREATE TABLE IF NOT EXISTS `seat_numbers` (
   `id` INT(11) NOT NULL AUTO_INCREMENT,
   `block_id` int(11) NOT NULL,
   `type` int(11) NOT NULL,
@@ -25,22 +27,30 @@ CREATE TABLE `seat_numbers` (
   KEY `type` (`type`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `seats` (
+CREATE TABLE IF NOT EXISTS `seats` (
   `id` INT(11) NOT NULL AUTO_INCREMENT,
   `flight_id` int(11) NOT NULL,
   `index` int(11) NOT NULL,
   `type` int(11) NOT NULL,
-  `row` int(11) NOT NULL, 
+  `row` int(11) NOT NULL,
   `line` CHAR NOT NULL,
-  `assigned` BOOLEAN NOT NULL DEFAULT FALSE,  
+  `assigned` BOOLEAN NOT NULL DEFAULT FALSE,
   `created_at` int(11) NOT NULL,
   `updated_at` int(11) NOT NULL,
   PRIMARY KEY (`id`),
   FOREIGN KEY (`flight_id`) REFERENCES `flights`(`id`),
   KEY `index` (`index`),
   KEY `type` (`type`),
-  KEY `row` (`row`),  
-  KEY `line` (`line`), 
+  KEY `row` (`row`),
+  KEY `line` (`line`),
   KEY `created_at` (`created_at`),
-  KEY `updated_at` (`updated_at`)    
+  KEY `updated_at` (`updated_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `schema_migrations` (
+  `version` int(11) NOT NULL,
+  `applied_at` int(11) NOT NULL,
+  PRIMARY KEY (`version`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT IGNORE INTO `schema_migrations` (`version`, `applied_at`) VALUES (1, UNIX_TIMESTAMP());
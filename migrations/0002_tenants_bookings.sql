@@ -0,0 +1,90 @@
+-- tenants, versions, flight schedule, seat owners, waitlist, bookings and check-ins,
+-- rows existing before tenants are moved to the tenant `default`
+
+ALTER TABLE `flights`
+  ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default' AFTER `id`,
+  ADD COLUMN `carrier` VARCHAR(3) NOT NULL DEFAULT '' AFTER `name`,
+  ADD COLUMN `number` VARCHAR(5) NOT NULL DEFAULT '' AFTER `carrier`,
+  ADD COLUMN `origin` CHAR(3) NOT NULL DEFAULT '' AFTER `number`,
+  ADD COLUMN `destination` CHAR(3) NOT NULL DEFAULT '' AFTER `origin`,
+  ADD COLUMN `departs_at` int(11) NOT NULL DEFAULT 0 AFTER `destination`,
+  ADD COLUMN `overbooking` int(11) NOT NULL DEFAULT 0 AFTER `departs_at`,
+  ADD COLUMN `status` VARCHAR(16) NOT NULL DEFAULT 'open' AFTER `overbooking`,
+  ADD COLUMN `version` int(11) NOT NULL DEFAULT 1,
+  ADD KEY `tenant_id` (`tenant_id`);
+
+ALTER TABLE `flights` ALTER COLUMN `tenant_id` DROP DEFAULT;
+
+ALTER TABLE `blocks`
+  ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default' AFTER `id`,
+  ADD KEY `tenant_id` (`tenant_id`);
+
+ALTER TABLE `blocks` ALTER COLUMN `tenant_id` DROP DEFAULT;
+
+ALTER TABLE `seats`
+  ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default' AFTER `id`,
+  ADD COLUMN `owner` VARCHAR(255) NOT NULL DEFAULT '' AFTER `assigned`,
+  ADD COLUMN `blocked` BOOLEAN NOT NULL DEFAULT FALSE AFTER `owner`,
+  ADD COLUMN `version` int(11) NOT NULL DEFAULT 1,
+  ADD KEY `tenant_id` (`tenant_id`),
+  ADD KEY `owner` (`owner`);
+
+ALTER TABLE `seats` ALTER COLUMN `tenant_id` DROP DEFAULT;
+
+CREATE TABLE `waitlist` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `flight_id` int(11) NOT NULL,
+  `owner` VARCHAR(255) NOT NULL,
+  `tier` VARCHAR(16) NOT NULL DEFAULT '',
+  `priority` int(11) NOT NULL DEFAULT 0,
+  `status` VARCHAR(16) NOT NULL,
+  `seat_index` int(11) NOT NULL DEFAULT 0,
+  `created_at` int(11) NOT NULL,
+  `updated_at` int(11) NOT NULL,
+  `version` int(11) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`flight_id`) REFERENCES `flights`(`id`) ON DELETE CASCADE,
+  KEY `tenant_id` (`tenant_id`),
+  KEY `queue` (`flight_id`, `status`, `priority`, `created_at`),
+  KEY `owner` (`owner`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `bookings` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `flight_id` int(11) NOT NULL,
+  `owner` VARCHAR(255) NOT NULL,
+  `status` VARCHAR(16) NOT NULL,
+  `seat_index` int(11) NOT NULL DEFAULT 0,
+  `created_at` int(11) NOT NULL,
+  `updated_at` int(11) NOT NULL,
+  `version` int(11) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`flight_id`) REFERENCES `flights`(`id`) ON DELETE CASCADE,
+  KEY `tenant_id` (`tenant_id`),
+  KEY `flight_status` (`flight_id`, `status`),
+  KEY `owner` (`owner`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `checkins` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `flight_id` int(11) NOT NULL,
+  `seat_index` int(11) NOT NULL,
+  `row` int(11) NOT NULL,
+  `line` VARCHAR(8) NOT NULL,
+  `owner` VARCHAR(255) NOT NULL,
+  `first_name` VARCHAR(255) NOT NULL,
+  `last_name` VARCHAR(255) NOT NULL,
+  `pnr` VARCHAR(7) NOT NULL,
+  `sequence` int(11) NOT NULL,
+  `created_at` int(11) NOT NULL,
+  `version` int(11) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`flight_id`) REFERENCES `flights`(`id`) ON DELETE CASCADE,
+  UNIQUE KEY `seat` (`flight_id`, `seat_index`),
+  KEY `tenant_id` (`tenant_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT INTO `schema_migrations` (`version`, `applied_at`) VALUES (2, UNIX_TIMESTAMP());
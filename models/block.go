@@ -14,13 +14,22 @@ const (
 	BlockTypeMiddle
 )
 
+// SeatNumber is number of seats of the block side or middle part
+type SeatNumber struct {
+	ID      int64     `db:"id"`
+	BlockID int64     `db:"block_id"`
+	Type    BlockType `db:"type"`
+	Number  int       `db:"number"`
+}
+
 // Block is seat block
 type Block struct {
-	ID                int64 `json:"-"                   db:"id"`
-	FlightID          int64 `json:"-"                   db:"flight_id"`
-	Rows              int   `json:"rows"                db:"rows"`
-	SideSeatNumbers   []int `json:"side_seat_numbers"   db:"-"`
-	MiddleSeatNumbers []int `json:"middle_seat_numbers" db:"-"`
+	ID                int64  `json:"-"                   db:"id"`
+	TenantID          string `json:"-"                   db:"tenant_id"`
+	FlightID          int64  `json:"-"                   db:"flight_id"`
+	Rows              int    `json:"rows"                db:"rows"`
+	SideSeatNumbers   []int  `json:"side_seat_numbers"   db:"-"`
+	MiddleSeatNumbers []int  `json:"middle_seat_numbers" db:"-"`
 }
 
 // Validate validates block data
@@ -68,6 +77,7 @@ func (block *Block) Validate() []Error {
 		lines += number
 	}
 
+	maxLines := GetLayoutLimits().MaxLines
 	if lines > maxLines {
 		errs = append(errs, Error{
 			Code:    "seat_number.TooLarge",
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"fmt"
+)
+
+// BoardingStrategy is strategy of boarding zone computation
+type BoardingStrategy string
+
+const (
+	// BoardingStrategyBackToFront boards rear rows before front rows in equal row groups
+	BoardingStrategyBackToFront BoardingStrategy = "back-to-front"
+	// BoardingStrategyWilma boards window seats before middle and aisle seats
+	BoardingStrategyWilma BoardingStrategy = "wilma"
+	// BoardingStrategyOutsideIn boards seat types from window to aisle, rear half of the rows first within each type
+	BoardingStrategyOutsideIn BoardingStrategy = "outside-in"
+	// BoardingStrategyBlock boards blocks from the rear block to the front one
+	BoardingStrategyBlock BoardingStrategy = "block"
+)
+
+const (
+	// defaultBoardingZones is default zones number of back-to-front boarding
+	defaultBoardingZones = 4
+	// maxBoardingZones is max zones number of back-to-front boarding
+	maxBoardingZones = 20
+)
+
+var (
+	// seatTypeBoardingRanks contains boarding ranks of seat types from window to aisle
+	seatTypeBoardingRanks = map[SeatType]int{
+		SeatTypeWindow: 1,
+		SeatTypeMiddle: 2,
+		SeatTypeAisle:  3,
+	}
+)
+
+// BoardingQuery contains boarding manifest parameters
+type BoardingQuery struct {
+	Strategy      BoardingStrategy `form:"strategy"`
+	Zones         int              `form:"zones"`
+	PremiumBlocks int              `form:"premium_blocks"`
+}
+
+// BoardingPlace describes position of the seat in the cabin
+type BoardingPlace struct {
+	// Block is block number from the front starting with one
+	Block int
+	// Blocks is number of blocks
+	Blocks int
+	// Row is row number from the front counted through all blocks starting with one
+	Row int
+	// Rows is number of rows counted through all blocks
+	Rows int
+	// FirstRow is the first row after premium blocks counted through all blocks
+	FirstRow int
+	// Type is seat type
+	Type SeatType
+}
+
+// BoardingPassenger contains boarding data of the passenger
+type BoardingPassenger struct {
+	Index     int      `json:"index"`
+	Block     int      `json:"block"`
+	Row       int      `json:"row"`
+	Line      string   `json:"line"`
+	Type      SeatType `json:"type"`
+	Owner     string   `json:"owner"`
+	Passenger string   `json:"passenger,omitempty"`
+	CheckedIn bool     `json:"checked_in"`
+	Sequence  int      `json:"sequence,omitempty"`
+}
+
+// BoardingZone contains passengers of the boarding zone
+type BoardingZone struct {
+	Zone       int                 `json:"zone"`
+	Passengers []BoardingPassenger `json:"passengers"`
+}
+
+// BoardingManifest contains passengers of the flight by boarding zone
+type BoardingManifest struct {
+	FlightID      int64            `json:"flight_id"`
+	Strategy      BoardingStrategy `json:"strategy"`
+	PremiumBlocks int              `json:"premium_blocks"`
+	Passengers    int              `json:"passengers"`
+	Zones         []BoardingZone   `json:"zones"`
+}
+
+// IsValid checks boarding strategy
+func (strategy BoardingStrategy) IsValid() bool {
+	return strategy == BoardingStrategyBackToFront || strategy == BoardingStrategyWilma ||
+		strategy == BoardingStrategyOutsideIn || strategy == BoardingStrategyBlock
+}
+
+// Validate validates boarding manifest parameters setting defaults
+func (query *BoardingQuery) Validate() []Error {
+	var errs []Error
+
+	if query.Strategy == "" {
+		query.Strategy = BoardingStrategyBackToFront
+	}
+	if query.Zones == 0 {
+		query.Zones = defaultBoardingZones
+	}
+
+	if !query.Strategy.IsValid() {
+		errs = append(errs, Error{
+			Code:    "strategy.Invalid",
+			Message: "Strategy must be back-to-front, wilma, outside-in or block",
+			Field:   "strategy",
+		})
+	}
+
+	if query.Zones < 0 || query.Zones > maxBoardingZones {
+		errs = append(errs, Error{
+			Code:    "zones.Invalid",
+			Message: fmt.Sprintf("Zones must be from 1 to %v", maxBoardingZones),
+			Field:   "zones",
+		})
+	}
+
+	if query.PremiumBlocks < 0 {
+		errs = append(errs, Error{
+			Code:    "premium_blocks.TooSmall",
+			Message: "Premium blocks must not be negative",
+			Field:   "premium_blocks",
+		})
+	}
+
+	return errs
+}
+
+// Zone computes boarding zone of the seat place, seats of premium blocks board first in zone one
+func (query *BoardingQuery) Zone(place BoardingPlace) int {
+	if place.Block <= query.PremiumBlocks {
+		return 1
+	}
+
+	zone := 1
+	switch query.Strategy {
+	case BoardingStrategyBackToFront:
+		rows := place.Rows - place.FirstRow + 1
+		zone = (place.Rows-place.Row)*query.Zones/rows + 1
+	case BoardingStrategyWilma:
+		zone = seatTypeBoardingRanks[place.Type]
+	case BoardingStrategyOutsideIn:
+		zone = seatTypeBoardingRanks[place.Type] * 2
+		if place.Row > place.FirstRow+(place.Rows-place.FirstRow)/2 {
+			zone--
+		}
+	case BoardingStrategyBlock:
+		zone = place.Blocks - place.Block + 1
+	}
+
+	if query.PremiumBlocks > 0 {
+		zone++
+	}
+
+	return zone
+}
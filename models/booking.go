@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+)
+
+// BookingStatus is booking status
+type BookingStatus string
+
+const (
+	// BookingStatusConfirmed is status of confirmed booking without seat
+	BookingStatusConfirmed BookingStatus = "confirmed"
+	// BookingStatusSeated is status of booking seated at check-in
+	BookingStatusSeated BookingStatus = "seated"
+	// BookingStatusDenied is status of booking left unseated when flight closed
+	BookingStatusDenied BookingStatus = "denied"
+)
+
+// BookingCreate is data for booking creation
+type BookingCreate struct {
+	Owner string `json:"owner,omitempty"`
+}
+
+// Booking contains confirmed passenger booking which is seated at check-in
+type Booking struct {
+	ID        int64         `json:"id"                   db:"id"`
+	TenantID  string        `json:"-"                    db:"tenant_id"`
+	FlightID  int64         `json:"flight_id"            db:"flight_id"`
+	Owner     string        `json:"owner"                db:"owner"`
+	Status    BookingStatus `json:"status"               db:"status"`
+	SeatIndex int           `json:"seat_index,omitempty" db:"seat_index"`
+	CreatedAt int64         `json:"created_at"           db:"created_at"`
+	UpdatedAt int64         `json:"updated_at"           db:"updated_at"`
+	Version   int64         `json:"version"              db:"version"`
+}
+
+// DeniedBoardingReport lists passengers left unseated on the closed flight
+type DeniedBoardingReport struct {
+	FlightID    int64     `json:"flight_id"`
+	Overbooking int       `json:"overbooking"`
+	Seats       int64     `json:"seats"`
+	Limit       int64     `json:"limit"`
+	Seated      int64     `json:"seated"`
+	Denied      []Booking `json:"denied"`
+}
+
+// Validate validates booking data
+func (booking *BookingCreate) Validate() []Error {
+	var errs []Error
+
+	if len([]rune(booking.Owner)) > maxFieldLength {
+		errs = append(errs, Error{
+			Code:    "owner.TooLarge",
+			Message: fmt.Sprintf("Owner must be less than %v characters", maxFieldLength),
+			Field:   "owner",
+		})
+	}
+
+	return errs
+}
@@ -0,0 +1,9 @@
+package models
+
+// CacheStats contains cache hit and miss counters
+type CacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Errors        int64 `json:"errors"`
+	Invalidations int64 `json:"invalidations"`
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// maxNameLength is max passenger name length
+	maxNameLength = 64
+)
+
+var (
+	// pnrExp is booking reference expression
+	pnrExp = regexp.MustCompile(`^[A-Z0-9]{5,7}$`)
+)
+
+// CheckinCreate is data for passenger check-in by assigned seat or by confirmed booking
+type CheckinCreate struct {
+	Index     int    `json:"index,omitempty"`
+	BookingID int64  `json:"booking_id,omitempty"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	PNR       string `json:"pnr,omitempty"`
+}
+
+// Checkin contains checked in passenger data
+type Checkin struct {
+	ID        int64  `json:"id"         db:"id"`
+	TenantID  string `json:"-"          db:"tenant_id"`
+	FlightID  int64  `json:"flight_id"  db:"flight_id"`
+	SeatIndex int    `json:"seat_index" db:"seat_index"`
+	Row       int    `json:"row"        db:"row"`
+	Line      string `json:"line"       db:"line"`
+	Owner     string `json:"owner"      db:"owner"`
+	FirstName string `json:"first_name" db:"first_name"`
+	LastName  string `json:"last_name"  db:"last_name"`
+	PNR       string `json:"pnr"        db:"pnr"`
+	Sequence  int    `json:"sequence"   db:"sequence"`
+	CreatedAt int64  `json:"created_at" db:"created_at"`
+	Version   int64  `json:"version"    db:"version"`
+}
+
+// BoardingPass contains boarding pass of checked in passenger
+type BoardingPass struct {
+	CheckinID    int64  `json:"checkin_id"`
+	FlightID     int64  `json:"flight_id"`
+	Passenger    string `json:"passenger"`
+	PNR          string `json:"pnr"`
+	Carrier      string `json:"carrier"`
+	FlightNumber string `json:"flight_number"`
+	Origin       string `json:"origin"`
+	Destination  string `json:"destination"`
+	DepartsAt    int64  `json:"departs_at"`
+	Seat         string `json:"seat"`
+	Sequence     int    `json:"sequence"`
+	BCBP         string `json:"bcbp"`
+}
+
+// Validate validates check-in data
+func (checkin *CheckinCreate) Validate() []Error {
+	var errs []Error
+
+	if (checkin.Index == 0) == (checkin.BookingID == 0) {
+		errs = append(errs, Error{
+			Code:    "seat.Invalid",
+			Message: "Either index or booking id must be provided",
+			Field:   "index,booking_id",
+		})
+	}
+
+	if checkin.Index < 0 || checkin.BookingID < 0 {
+		errs = append(errs, Error{
+			Code:    "seat.TooSmall",
+			Message: "Index and booking id must be more than zero",
+			Field:   "index,booking_id",
+		})
+	}
+
+	if checkin.LastName == "" {
+		errs = append(errs, Error{
+			Code:    "last_name.Missing",
+			Message: "Last name must be provided",
+			Field:   "last_name",
+		})
+	}
+
+	names := []struct{ field, value string }{{"first_name", checkin.FirstName}, {"last_name", checkin.LastName}}
+	for _, name := range names {
+		if len([]rune(name.value)) > maxNameLength {
+			errs = append(errs, Error{
+				Code:    name.field + ".TooLarge",
+				Message: fmt.Sprintf("Name must be less than %v characters", maxNameLength),
+				Field:   name.field,
+			})
+		}
+	}
+
+	if checkin.PNR != "" && !pnrExp.MatchString(checkin.PNR) {
+		errs = append(errs, Error{
+			Code:    "pnr.Invalid",
+			Message: "Booking reference must match " + pnrExp.String(),
+			Field:   "pnr",
+		})
+	}
+
+	return errs
+}
+
+// ManifestRow contains seat of the flight manifest with passenger data when checked in
+type ManifestRow struct {
+	Index     int      `json:"index"`
+	Row       int      `json:"row"`
+	Line      string   `json:"line"`
+	Type      SeatType `json:"type"`
+	Assigned  bool     `json:"assigned"`
+	Blocked   bool     `json:"blocked"`
+	Owner     string   `json:"owner,omitempty"`
+	FirstName string   `json:"first_name,omitempty"`
+	LastName  string   `json:"last_name,omitempty"`
+	PNR       string   `json:"pnr,omitempty"`
+	Sequence  int      `json:"sequence,omitempty"`
+}
@@ -7,7 +7,9 @@ const (
 
 // Error contains error details
 type Error struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Field   string `json:"field"`
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Field     string                 `json:"field"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
 }
@@ -2,23 +2,108 @@ package models
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/vsukhin/booking/logging"
 )
 
 const (
-	// maxLines is max line number
-	maxLines = 20
-	// maxRows is max rows number
-	maxRows = 200
+	// DefaultMaxLines is default max line number
+	DefaultMaxLines = 20
+	// DefaultMaxRows is default max rows number
+	DefaultMaxRows = 200
+	// MaxLinesLimit is upper bound of max line number, lines are named by letters
+	MaxLinesLimit = 26
+	// maxOverbooking is max percent of passengers sold beyond capacity
+	maxOverbooking = 100
+	// defaultImportChunkSize is default number of flights created in one import transaction
+	defaultImportChunkSize = 100
+	// maxImportChunkSize is max number of flights created in one import transaction
+	maxImportChunkSize = 1000
+)
+
+// LayoutLimits contains limits of the flight seat layout, they are reloaded without restart
+type LayoutLimits struct {
+	MaxRows  int
+	MaxLines int
+}
+
+var (
+	// layoutLimits contains current layout limits
+	layoutLimits atomic.Value
+	// carrierExp is airline designator expression
+	carrierExp = regexp.MustCompile(`^[A-Z0-9]{2,3}$`)
+	// flightNumberExp is flight number expression with optional operational suffix
+	flightNumberExp = regexp.MustCompile(`^[0-9]{1,4}[A-Z]?$`)
+	// airportExp is airport code expression
+	airportExp = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+func init() {
+	SetLayoutLimits(LayoutLimits{MaxRows: DefaultMaxRows, MaxLines: DefaultMaxLines})
+}
+
+// SetLayoutLimits sets layout limits checked by validation of flights created afterwards
+func SetLayoutLimits(limits LayoutLimits) {
+	layoutLimits.Store(limits)
+}
+
+// GetLayoutLimits gets current layout limits
+func GetLayoutLimits() LayoutLimits {
+	return layoutLimits.Load().(LayoutLimits)
+}
+
+// FlightStatus is flight status
+type FlightStatus string
+
+const (
+	// FlightStatusOpen is status of flight open for booking
+	FlightStatusOpen FlightStatus = "open"
+	// FlightStatusClosed is status of flight closed for booking and boarding
+	FlightStatusClosed FlightStatus = "closed"
 )
 
 // FlightCreate is data for flight creation
 type FlightCreate struct {
-	Name   string  `json:"name"`
+	Name        string  `json:"name"`
+	Carrier     string  `json:"carrier,omitempty"`
+	Number      string  `json:"number,omitempty"`
+	Origin      string  `json:"origin,omitempty"`
+	Destination string  `json:"destination,omitempty"`
+	DepartsAt   int64   `json:"departs_at,omitempty"`
+	Overbooking int     `json:"overbooking,omitempty"`
+	Blocks      []Block `json:"blocks"`
+}
+
+// FlightUpdate is data for flight updating
+type FlightUpdate struct {
+	Overbooking int `json:"overbooking"`
+}
+
+// FlightLayout is data for flight cabin re-layout
+type FlightLayout struct {
 	Blocks []Block `json:"blocks"`
+	DryRun bool    `json:"dry_run,omitempty"`
+}
+
+// SeatReassignment describes occupant moved to the seat of the new layout
+type SeatReassignment struct {
+	Owner string  `json:"owner"`
+	From  SeatRef `json:"from"`
+	To    SeatRef `json:"to"`
+	Exact bool    `json:"exact"`
+}
+
+// FlightLayoutResult contains outcome of flight cabin re-layout
+type FlightLayoutResult struct {
+	DryRun         bool               `json:"dry_run"`
+	Seats          int                `json:"seats"`
+	Reassigned     []SeatReassignment `json:"reassigned"`
+	Unaccommodated []Seat             `json:"unaccommodated"`
 }
 
 // FlightMeta is metadata for flight list
@@ -28,10 +113,19 @@ type FlightMeta struct {
 
 // Flight contains flight data
 type Flight struct {
-	ID        int64   `json:"id"               db:"id"         query:"id"         search:"id"`
-	Name      string  `json:"name"             db:"name"       query:"name"       search:"name"`
-	CreatedAt int64   `json:"created_at"       db:"created_at" query:"created_at" search:"created_at"`
-	Blocks    []Block `json:"blocks,omitempty" db:"-"`
+	ID          int64        `json:"id"               db:"id"          query:"id"         search:"id"`
+	TenantID    string       `json:"-"                db:"tenant_id"   query:"-"          search:"-"`
+	Name        string       `json:"name"             db:"name"        query:"name"       search:"name"`
+	Carrier     string       `json:"carrier"          db:"carrier"     query:"-"          search:"-"`
+	Number      string       `json:"number"           db:"number"      query:"-"          search:"-"`
+	Origin      string       `json:"origin"           db:"origin"      query:"-"          search:"-"`
+	Destination string       `json:"destination"      db:"destination" query:"-"          search:"-"`
+	DepartsAt   int64        `json:"departs_at"       db:"departs_at"  query:"-"          search:"-"`
+	Overbooking int          `json:"overbooking"      db:"overbooking" query:"-"          search:"-"`
+	Status      FlightStatus `json:"status"           db:"status"      query:"-"          search:"-"`
+	CreatedAt   int64        `json:"created_at"       db:"created_at"  query:"created_at" search:"created_at"`
+	Version     int64        `json:"version"          db:"version"     query:"-"          search:"-"`
+	Blocks      []Block      `json:"blocks,omitempty" db:"-"`
 }
 
 // Validate validates flight data
@@ -46,13 +140,108 @@ func (flight *FlightCreate) Validate() []Error {
 		})
 	}
 
+	errs = append(errs, validateCode(flight.Carrier, carrierExp, "carrier")...)
+	errs = append(errs, validateCode(flight.Number, flightNumberExp, "number")...)
+	errs = append(errs, validateCode(flight.Origin, airportExp, "origin")...)
+	errs = append(errs, validateCode(flight.Destination, airportExp, "destination")...)
+
+	if flight.DepartsAt < 0 {
+		errs = append(errs, Error{
+			Code:    "departs_at.Invalid",
+			Message: "Departure time must be unix time",
+			Field:   "departs_at",
+		})
+	}
+
+	errs = append(errs, validateOverbooking(flight.Overbooking)...)
+	errs = append(errs, validateBlocks(flight.Blocks)...)
+
+	return errs
+}
+
+// NewFlight makes open flight of the tenant from flight creation data
+func (flight *FlightCreate) NewFlight(tenantID string) *Flight {
+	return &Flight{
+		TenantID:    tenantID,
+		Name:        flight.Name,
+		Carrier:     flight.Carrier,
+		Number:      flight.Number,
+		Origin:      flight.Origin,
+		Destination: flight.Destination,
+		DepartsAt:   flight.DepartsAt,
+		Overbooking: flight.Overbooking,
+		Status:      FlightStatusOpen,
+		Blocks:      flight.Blocks,
+		CreatedAt:   time.Now().Unix(),
+	}
+}
+
+// Validate validates flight update data
+func (flight *FlightUpdate) Validate() []Error {
+	return validateOverbooking(flight.Overbooking)
+}
+
+func validateCode(value string, exp *regexp.Regexp, field string) []Error {
+	var errs []Error
+
+	if value != "" && !exp.MatchString(value) {
+		errs = append(errs, Error{
+			Code:    field + ".Invalid",
+			Message: field + " must match " + exp.String(),
+			Field:   field,
+		})
+	}
+
+	return errs
+}
+
+func validateOverbooking(overbooking int) []Error {
+	var errs []Error
+
+	if overbooking < 0 || overbooking > maxOverbooking {
+		errs = append(errs, Error{
+			Code:    "overbooking.Invalid",
+			Message: fmt.Sprintf("Overbooking must be from 0 to %v percent", maxOverbooking),
+			Field:   "overbooking",
+		})
+	}
+
+	return errs
+}
+
+// Limit returns max number of passengers sold for the seats including overbooking
+func (flight *Flight) Limit(seats int64) int64 {
+	return seats + seats*int64(flight.Overbooking)/100
+}
+
+// Validate validates flight layout data
+func (layout *FlightLayout) Validate() []Error {
+	var errs []Error
+
+	if len(layout.Blocks) == 0 {
+		errs = append(errs, Error{
+			Code:    "blocks.Missing",
+			Message: "Blocks must be provided",
+			Field:   "blocks",
+		})
+	}
+
+	errs = append(errs, validateBlocks(layout.Blocks)...)
+
+	return errs
+}
+
+func validateBlocks(blocks []Block) []Error {
+	var errs []Error
+
 	rows := 0
-	for _, block := range flight.Blocks {
+	for _, block := range blocks {
 		valerrs := block.Validate()
 		errs = append(errs, valerrs...)
 		rows += block.Rows
 	}
 
+	maxRows := GetLayoutLimits().MaxRows
 	if rows > maxRows {
 		errs = append(errs, Error{
 			Code:    "rows.TooLarge",
@@ -134,3 +323,63 @@ func (flight *Flight) ValidateAll(value string) string {
 	searchValue = "'" + value + "'"
 	return searchValue
 }
+
+// ScheduleFlight is flight of the schedule file with inline blocks or blocks of the template flight
+type ScheduleFlight struct {
+	FlightCreate
+	Template int64 `json:"template,omitempty"`
+}
+
+// ScheduleRow is schedule file row with flight data or errors reading it
+type ScheduleRow struct {
+	Row    int
+	Flight ScheduleFlight
+	Errors []Error
+}
+
+// ImportOptions contains flight import parameters
+type ImportOptions struct {
+	Format    string `form:"format"`
+	DryRun    bool   `form:"dry_run"`
+	ChunkSize int    `form:"chunk_size"`
+}
+
+// ImportRowError contains errors of the schedule row
+type ImportRowError struct {
+	Row    int     `json:"row"`
+	Errors []Error `json:"errors"`
+}
+
+// ImportProgress contains flight import progress
+type ImportProgress struct {
+	Rows    int `json:"rows"`
+	Valid   int `json:"valid"`
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+}
+
+// ImportReport contains outcome of flight import
+type ImportReport struct {
+	ImportProgress
+	DryRun bool             `json:"dry_run"`
+	Errors []ImportRowError `json:"errors"`
+}
+
+// Validate validates flight import parameters setting defaults
+func (options *ImportOptions) Validate() []Error {
+	var errs []Error
+
+	if options.ChunkSize == 0 {
+		options.ChunkSize = defaultImportChunkSize
+	}
+
+	if options.ChunkSize < 0 || options.ChunkSize > maxImportChunkSize {
+		errs = append(errs, Error{
+			Code:    "chunk_size.Invalid",
+			Message: fmt.Sprintf("Chunk size must be from 1 to %v", maxImportChunkSize),
+			Field:   "chunk_size",
+		})
+	}
+
+	return errs
+}
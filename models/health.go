@@ -0,0 +1,24 @@
+package models
+
+const (
+	// HealthStatusOK is status of the healthy service or component
+	HealthStatusOK = "ok"
+	// HealthStatusDegraded is status of the service with failed components or of the failed component
+	HealthStatusDegraded = "degraded"
+	// HealthStatusDraining is status of the service stopping to take traffic before shutdown
+	HealthStatusDraining = "draining"
+)
+
+// HealthComponent contains status of the service dependency
+type HealthComponent struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// HealthReport contains status of the service and of its dependencies
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Components []HealthComponent `json:"components,omitempty"`
+}
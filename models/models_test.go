@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -33,6 +34,11 @@ func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *log
 	return logrus.NewEntry(logger.Logger)
 }
 
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
 // Info logs info
 func (logger *FakeLogger) Info(args ...interface{}) {
 }
@@ -87,3 +93,186 @@ func Test_GetAllSearchTags_Success(t *testing.T) {
 		}
 	}
 }
+
+func Test_SeatUpdate_Validate_Success(t *testing.T) {
+	seatUpdate := &SeatUpdate{Assigned: true, Owner: "customer"}
+
+	errs := seatUpdate.Validate()
+	if len(errs) != 0 {
+		t.Error("Expected to validate seat update successfully")
+	}
+}
+
+func Test_SeatUpdate_Validate_Failure(t *testing.T) {
+	seatUpdate := &SeatUpdate{Assigned: false, Owner: "customer"}
+
+	errs := seatUpdate.Validate()
+	if len(errs) != 1 || errs[0].Code != "owner.Unexpected" {
+		t.Error("Expected to have error validating owner of released seat")
+	}
+}
+
+func Test_SeatBatch_Validate_Success(t *testing.T) {
+	seatBatch := &SeatBatch{Action: SeatActionRelease, Seats: []SeatRef{{Index: 1}, {Row: 2, Line: "C"}}}
+
+	errs := seatBatch.Validate()
+	if len(errs) != 0 {
+		t.Error("Expected to validate seat batch successfully")
+	}
+}
+
+func Test_SeatBatch_Validate_Failure(t *testing.T) {
+	seatBatch := &SeatBatch{Action: "move", Seats: []SeatRef{{Index: 1, Row: 2, Line: "C"}}}
+
+	errs := seatBatch.Validate()
+	if len(errs) != 2 || errs[0].Field != "action" || errs[1].Field != "seats[0]" {
+		t.Error("Expected to have errors validating action and seat reference")
+	}
+}
+
+func Test_WaitlistCreate_Validate_Success(t *testing.T) {
+	waitlistCreate := &WaitlistCreate{Owner: "customer", Tier: TierGold}
+
+	errs := waitlistCreate.Validate()
+	if len(errs) != 0 {
+		t.Error("Expected to validate waitlist entry successfully")
+	}
+}
+
+func Test_WaitlistCreate_Validate_Failure(t *testing.T) {
+	waitlistCreate := &WaitlistCreate{Owner: "customer", Tier: "diamond"}
+
+	errs := waitlistCreate.Validate()
+	if len(errs) != 1 || errs[0].Code != "tier.Invalid" {
+		t.Error("Expected to have error validating unknown tier")
+	}
+}
+
+func Test_Tier_Rank_Success(t *testing.T) {
+	if Tier("").Rank() != TierStandard.Rank() || TierPlatinum.Rank() <= TierGold.Rank() ||
+		TierGold.Rank() <= TierSilver.Rank() || TierSilver.Rank() <= TierStandard.Rank() {
+		t.Error("Expected to rank tiers from platinum to standard")
+	}
+}
+
+func Test_FlightUpdate_Validate_Failure(t *testing.T) {
+	flightUpdate := &FlightUpdate{Overbooking: -5}
+
+	errs := flightUpdate.Validate()
+	if len(errs) != 1 || errs[0].Code != "overbooking.Invalid" {
+		t.Error("Expected to have error validating negative overbooking")
+	}
+}
+
+func Test_Flight_Limit_Success(t *testing.T) {
+	flight := &Flight{Overbooking: 10}
+
+	if flight.Limit(180) != 198 {
+		t.Error("Expected to add overbooking percent to capacity")
+	}
+}
+
+func Test_FlightCreate_Validate_Codes_Failure(t *testing.T) {
+	flightCreate := &FlightCreate{
+		Name:        "Flight",
+		Blocks:      []Block{{Rows: 1, SideSeatNumbers: []int{1, 1}}},
+		Carrier:     "a",
+		Number:      "12345",
+		Origin:      "YUL",
+		Destination: "FR",
+	}
+
+	errs := flightCreate.Validate()
+	if len(errs) != 3 || errs[0].Code != "carrier.Invalid" || errs[1].Code != "number.Invalid" ||
+		errs[2].Code != "destination.Invalid" {
+		t.Errorf("Expected to have errors validating flight codes, got %+v", errs)
+	}
+}
+
+func Test_FlightCreate_Validate_LayoutLimits_Failure(t *testing.T) {
+	SetLayoutLimits(LayoutLimits{MaxRows: 10, MaxLines: 4})
+	defer SetLayoutLimits(LayoutLimits{MaxRows: DefaultMaxRows, MaxLines: DefaultMaxLines})
+
+	flightCreate := &FlightCreate{
+		Name:   "Flight",
+		Blocks: []Block{{Rows: 11, SideSeatNumbers: []int{2, 2}, MiddleSeatNumbers: []int{1}}},
+	}
+
+	errs := flightCreate.Validate()
+	if len(errs) != 2 || errs[0].Code != "seat_number.TooLarge" || errs[1].Code != "rows.TooLarge" {
+		t.Errorf("Expected to validate flight against reloaded limits, got %+v", errs)
+	}
+}
+
+func Test_CheckinCreate_Validate_Success(t *testing.T) {
+	checkinCreate := &CheckinCreate{Index: 3, LastName: "Desmarais", PNR: "ABC123"}
+
+	errs := checkinCreate.Validate()
+	if len(errs) != 0 {
+		t.Error("Expected to validate check-in successfully")
+	}
+}
+
+func Test_CheckinCreate_Validate_Failure(t *testing.T) {
+	checkinCreate := &CheckinCreate{Index: 3, BookingID: 1, PNR: "abc"}
+
+	errs := checkinCreate.Validate()
+	if len(errs) != 3 || errs[0].Code != "seat.Invalid" || errs[1].Code != "last_name.Missing" ||
+		errs[2].Code != "pnr.Invalid" {
+		t.Errorf("Expected to have errors validating check-in, got %+v", errs)
+	}
+}
+
+func Test_BoardingQuery_Validate_Success(t *testing.T) {
+	query := &BoardingQuery{}
+
+	errs := query.Validate()
+	if len(errs) != 0 || query.Strategy != BoardingStrategyBackToFront || query.Zones != defaultBoardingZones {
+		t.Error("Expected to validate boarding parameters with defaults")
+	}
+}
+
+func Test_BoardingQuery_Validate_Failure(t *testing.T) {
+	query := &BoardingQuery{Strategy: "random", Zones: maxBoardingZones + 1, PremiumBlocks: -1}
+
+	errs := query.Validate()
+	if len(errs) != 3 {
+		t.Errorf("Expected to have errors validating boarding parameters, got %+v", errs)
+	}
+}
+
+func Test_BoardingQuery_Zone_Success(t *testing.T) {
+	place := BoardingPlace{Block: 2, Blocks: 3, Row: 8, Rows: 20, FirstRow: 5, Type: SeatTypeMiddle}
+
+	cases := []struct {
+		query BoardingQuery
+		zone  int
+	}{
+		{BoardingQuery{Strategy: BoardingStrategyBackToFront, Zones: 4}, 4},
+		{BoardingQuery{Strategy: BoardingStrategyWilma}, 2},
+		{BoardingQuery{Strategy: BoardingStrategyOutsideIn}, 4},
+		{BoardingQuery{Strategy: BoardingStrategyBlock}, 2},
+		{BoardingQuery{Strategy: BoardingStrategyBlock, PremiumBlocks: 1}, 3},
+		{BoardingQuery{Strategy: BoardingStrategyWilma, PremiumBlocks: 2}, 1},
+	}
+
+	for _, item := range cases {
+		if zone := item.query.Zone(place); zone != item.zone {
+			t.Errorf("Expected zone %v for %+v, got %v", item.zone, item.query, zone)
+		}
+	}
+}
+
+func Test_ImportOptions_Validate_Success(t *testing.T) {
+	options := &ImportOptions{}
+
+	errs := options.Validate()
+	if len(errs) != 0 || options.ChunkSize != defaultImportChunkSize {
+		t.Error("Expected to validate import options with default chunk size")
+	}
+
+	options.ChunkSize = maxImportChunkSize + 1
+	if errs = options.Validate(); len(errs) != 1 || errs[0].Code != "chunk_size.Invalid" {
+		t.Error("Expected to reject too large chunk size")
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+// Role is principal role
+type Role string
+
+const (
+	// RoleAdmin is administrator role managing flights
+	RoleAdmin Role = "admin"
+	// RoleAgent is agent role managing seats of any flight
+	RoleAgent Role = "agent"
+	// RoleCustomer is customer role managing own seats
+	RoleCustomer Role = "customer"
+)
+
+// Tier is loyalty tier of the principal
+type Tier string
+
+const (
+	// TierStandard is tier without privileges, used when tier is empty
+	TierStandard Tier = "standard"
+	// TierSilver is silver loyalty tier
+	TierSilver Tier = "silver"
+	// TierGold is gold loyalty tier
+	TierGold Tier = "gold"
+	// TierPlatinum is platinum loyalty tier
+	TierPlatinum Tier = "platinum"
+)
+
+var (
+	// tierRanks contains priorities of tiers, higher rank is served first
+	tierRanks = map[Tier]int{"": 0, TierStandard: 0, TierSilver: 1, TierGold: 2, TierPlatinum: 3}
+)
+
+// Principal contains authenticated client data
+type Principal struct {
+	Subject string `json:"sub"              yaml:"subject"`
+	Role    Role   `json:"role"             yaml:"role"`
+	Tenant  string `json:"tenant,omitempty" yaml:"tenant"`
+	Tier    Tier   `json:"tier,omitempty"   yaml:"tier"`
+}
+
+// IsValid checks if role is known
+func (role Role) IsValid() bool {
+	switch role {
+	case RoleAdmin, RoleAgent, RoleCustomer:
+		return true
+	}
+
+	return false
+}
+
+// IsValid checks if tier is known, empty tier is standard
+func (tier Tier) IsValid() bool {
+	_, ok := tierRanks[tier]
+	return ok
+}
+
+// Rank returns priority of the tier
+func (tier Tier) Rank() int {
+	return tierRanks[tier]
+}
+
+// HasRole checks if principal has one of roles
+func (principal *Principal) HasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if principal.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanManage checks if principal can manage seat of the owner
+func (principal *Principal) CanManage(owner string) bool {
+	if principal.Role != RoleCustomer {
+		return true
+	}
+
+	return owner == principal.Subject
+}
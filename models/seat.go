@@ -22,7 +22,60 @@ const (
 
 // SeatUpdate is data for seat updating
 type SeatUpdate struct {
-	Assigned bool `json:"assigned"`
+	Assigned bool   `json:"assigned"`
+	Owner    string `json:"owner"`
+}
+
+// SeatTarget refers target seat of move or swap
+type SeatTarget struct {
+	Index int `json:"index"`
+}
+
+// SeatAction is batch seat action
+type SeatAction string
+
+const (
+	// SeatActionAssign assigns seat to the owner
+	SeatActionAssign SeatAction = "assign"
+	// SeatActionRelease releases assigned or blocked seat
+	SeatActionRelease SeatAction = "release"
+	// SeatActionBlock blocks free seat from assignment
+	SeatActionBlock SeatAction = "block"
+)
+
+const (
+	// maxBatchSeats is max seats number in the batch
+	maxBatchSeats = 500
+)
+
+// SeatRef refers seat by index or by row and line
+type SeatRef struct {
+	Index int    `json:"index,omitempty"`
+	Row   int    `json:"row,omitempty"`
+	Line  string `json:"line,omitempty"`
+}
+
+// SeatBatch is data for batch seat operation
+type SeatBatch struct {
+	Action     SeatAction `json:"action"`
+	Owner      string     `json:"owner,omitempty"`
+	BestEffort bool       `json:"best_effort,omitempty"`
+	Seats      []SeatRef  `json:"seats"`
+}
+
+// SeatBatchResult is result of batch seat operation item
+type SeatBatchResult struct {
+	SeatRef
+	Succeeded bool   `json:"succeeded"`
+	Seat      *Seat  `json:"seat,omitempty"`
+	Error     *Error `json:"error,omitempty"`
+}
+
+// SeatBatchResponse contains results of batch seat operation
+type SeatBatchResponse struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []SeatBatchResult `json:"results"`
 }
 
 // SeatMeta is metadata for seat list
@@ -30,23 +83,171 @@ type SeatMeta struct {
 	TotalRecords int64 `json:"total_records"`
 }
 
+// SeatSummary contains seat availability counts of the flight
+type SeatSummary struct {
+	FlightID int64 `json:"flight_id"`
+	Total    int64 `json:"total"`
+	Free     int64 `json:"free"`
+	Assigned int64 `json:"assigned"`
+	Blocked  int64 `json:"blocked"`
+}
+
 // Seat contains seat data
 type Seat struct {
 	ID        int64    `json:"id"         db:"id"         query:"id"         search:"id"`
+	TenantID  string   `json:"-"          db:"tenant_id"  query:"-"          search:"-"`
 	FlightID  int64    `json:"flight_id"  db:"flight_id"  query:"-"          search:"-"`
 	Index     int      `json:"index"      db:"index"      query:"index"      search:"index"`
 	Type      SeatType `json:"type"       db:"type"       query:"type"       search:"type"`
 	Row       int      `json:"row"        db:"row"        query:"row"        search:"row"`
 	Line      string   `json:"line"       db:"line"       query:"line"       search:"line"`
 	Assigned  bool     `json:"assigned"   db:"assigned"   query:"assigned"   search:"assigned"`
+	Owner     string   `json:"owner"      db:"owner"      query:"-"          search:"-"`
+	Blocked   bool     `json:"blocked"    db:"blocked"    query:"-"          search:"-"`
 	CreatedAt int64    `json:"created_at" db:"created_at" query:"created_at" search:"created_at"`
 	UpdatedAt int64    `json:"updated_at" db:"updated_at" query:"updated_at" search:"updated_at"`
+	Version   int64    `json:"version"    db:"version"    query:"-"          search:"-"`
 }
 
 // Validate validates seat data
 func (seat *SeatUpdate) Validate() []Error {
+	var errs []Error
+
+	if len([]rune(seat.Owner)) > maxFieldLength {
+		errs = append(errs, Error{
+			Code:    "owner.TooLarge",
+			Message: fmt.Sprintf("Owner must be less than %v characters", maxFieldLength),
+			Field:   "owner",
+		})
+	}
+
+	if !seat.Assigned && seat.Owner != "" {
+		errs = append(errs, Error{
+			Code:    "owner.Unexpected",
+			Message: "Owner must be empty for released seat",
+			Field:   "owner",
+		})
+	}
+
+	return errs
+}
+
+// Validate validates target seat data
+func (target *SeatTarget) Validate() []Error {
+	var errs []Error
+
+	if target.Index <= 0 {
+		errs = append(errs, Error{
+			Code:    "index.TooSmall",
+			Message: "Index must be more than zero",
+			Field:   "index",
+		})
+	}
+
+	return errs
+}
+
+// MoveOccupant moves occupant data to the other seat and frees the seat
+func (seat *Seat) MoveOccupant(other *Seat) {
+	other.Assigned, other.Owner = seat.Assigned, seat.Owner
+	seat.Assigned, seat.Owner = false, ""
+}
+
+// SwapOccupant swaps occupant data with the other seat
+func (seat *Seat) SwapOccupant(other *Seat) {
+	seat.Assigned, other.Assigned = other.Assigned, seat.Assigned
+	seat.Owner, other.Owner = other.Owner, seat.Owner
+}
+
+// IsValid checks seat action
+func (action SeatAction) IsValid() bool {
+	return action == SeatActionAssign || action == SeatActionRelease || action == SeatActionBlock
+}
+
+// Validate validates seat reference
+func (ref *SeatRef) Validate(field string) []Error {
+	var errs []Error
+
+	byIndex := ref.Index != 0
+	byPlace := ref.Row != 0 || ref.Line != ""
+
+	if byIndex == byPlace {
+		errs = append(errs, Error{
+			Code:    "seat.Invalid",
+			Message: "Seat must be referred either by index or by row and line",
+			Field:   field,
+		})
+		return errs
+	}
+
+	if byIndex && ref.Index < 0 {
+		errs = append(errs, Error{
+			Code:    "index.TooSmall",
+			Message: "Index must be more than zero",
+			Field:   field + ".index",
+		})
+	}
+
+	if byPlace && ref.Row <= 0 {
+		errs = append(errs, Error{
+			Code:    "row.TooSmall",
+			Message: "Row must be more than zero",
+			Field:   field + ".row",
+		})
+	}
+
+	if byPlace && len(ref.Line) != 1 {
+		errs = append(errs, Error{
+			Code:    "line.Invalid",
+			Message: "Line is not one character",
+			Field:   field + ".line",
+		})
+	}
+
+	return errs
+}
+
+// Validate validates batch seat data
+func (batch *SeatBatch) Validate() []Error {
+	var errs []Error
+
+	if !batch.Action.IsValid() {
+		errs = append(errs, Error{
+			Code:    "action.Invalid",
+			Message: fmt.Sprintf("Action must be one of %v, %v, %v", SeatActionAssign, SeatActionRelease, SeatActionBlock),
+			Field:   "action",
+		})
+	}
+
+	if batch.Owner != "" && batch.Action != SeatActionAssign {
+		errs = append(errs, Error{
+			Code:    "owner.Unexpected",
+			Message: "Owner is allowed for assign action only",
+			Field:   "owner",
+		})
+	}
+
+	if len([]rune(batch.Owner)) > maxFieldLength {
+		errs = append(errs, Error{
+			Code:    "owner.TooLarge",
+			Message: fmt.Sprintf("Owner must be less than %v characters", maxFieldLength),
+			Field:   "owner",
+		})
+	}
+
+	if len(batch.Seats) == 0 || len(batch.Seats) > maxBatchSeats {
+		errs = append(errs, Error{
+			Code:    "seats.Invalid",
+			Message: fmt.Sprintf("Seats number must be from 1 to %v", maxBatchSeats),
+			Field:   "seats",
+		})
+	}
+
+	for i := range batch.Seats {
+		errs = append(errs, batch.Seats[i].Validate(fmt.Sprintf("seats[%v]", i))...)
+	}
 
-	return []Error{}
+	return errs
 }
 
 // Verify verifies sort field
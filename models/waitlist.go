@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+)
+
+// WaitlistStatus is waitlist entry status
+type WaitlistStatus string
+
+const (
+	// WaitlistStatusWaiting is status of entry waiting for free seat
+	WaitlistStatusWaiting WaitlistStatus = "waiting"
+	// WaitlistStatusGranted is status of entry granted with seat
+	WaitlistStatusGranted WaitlistStatus = "granted"
+	// WaitlistStatusCancelled is status of entry cancelled by the owner
+	WaitlistStatusCancelled WaitlistStatus = "cancelled"
+)
+
+// WaitlistCreate is data for joining waitlist
+type WaitlistCreate struct {
+	Owner string `json:"owner,omitempty"`
+	Tier  Tier   `json:"tier,omitempty"`
+}
+
+// WaitlistEntry contains waitlist entry data
+type WaitlistEntry struct {
+	ID        int64          `json:"id"                   db:"id"`
+	TenantID  string         `json:"-"                    db:"tenant_id"`
+	FlightID  int64          `json:"flight_id"            db:"flight_id"`
+	Owner     string         `json:"owner"                db:"owner"`
+	Tier      Tier           `json:"tier"                 db:"tier"`
+	Priority  int            `json:"-"                    db:"priority"`
+	Status    WaitlistStatus `json:"status"               db:"status"`
+	SeatIndex int            `json:"seat_index,omitempty" db:"seat_index"`
+	Position  int            `json:"position,omitempty"   db:"-"`
+	CreatedAt int64          `json:"created_at"           db:"created_at"`
+	UpdatedAt int64          `json:"updated_at"           db:"updated_at"`
+	Version   int64          `json:"version"              db:"version"`
+}
+
+// Validate validates waitlist data
+func (waitlist *WaitlistCreate) Validate() []Error {
+	var errs []Error
+
+	if len([]rune(waitlist.Owner)) > maxFieldLength {
+		errs = append(errs, Error{
+			Code:    "owner.TooLarge",
+			Message: fmt.Sprintf("Owner must be less than %v characters", maxFieldLength),
+			Field:   "owner",
+		})
+	}
+
+	if !waitlist.Tier.IsValid() {
+		errs = append(errs, Error{
+			Code:    "tier.Invalid",
+			Message: "Tier is unknown",
+			Field:   "tier",
+		})
+	}
+
+	return errs
+}
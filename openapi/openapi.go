@@ -0,0 +1,407 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/idempotency"
+	"github.com/vsukhin/booking/models"
+)
+
+const (
+	// Version is OpenAPI version
+	Version = "3.0.3"
+	// contentTypeJSON is json content type
+	contentTypeJSON = "application/json"
+	// schemaPrefix is component schema reference prefix
+	schemaPrefix = "#/components/schemas/"
+	// securityAPIKey is api key security scheme name
+	securityAPIKey = "ApiKey"
+	// securityBearer is bearer token security scheme name
+	securityBearer = "Bearer"
+)
+
+var (
+	// pathParamExp is gin path parameter expression
+	pathParamExp = regexp.MustCompile(`:(\w+)`)
+)
+
+// Route describes operation of the registered route
+type Route struct {
+	Summary string
+	Tag     string
+	// Request is request body model
+	Request interface{}
+	// Response is success response body model
+	Response interface{}
+	// Status is success status, http.StatusOK if empty
+	Status int
+	// Sort is model with query tags of sortable fields
+	Sort models.SortFieldChecker
+	// Filter is model with query tags of filterable fields
+	Filter models.SearchFieldChecker
+	// Paging adds offset and limit parameters
+	Paging bool
+	// Query contains descriptions of additional string query parameters by name
+	Query map[string]string
+	// Produces contains additional content types of success response
+	Produces []string
+	// Consumes contains content types of request body given as text
+	Consumes []string
+	// ETag marks responses versioned by entity tag
+	ETag bool
+	// Precondition marks operation honouring If-Match, implied by ETag for PATCH and DELETE
+	Precondition bool
+	// Public marks operation without authentication
+	Public bool
+	// Streamed marks operation streaming its request body, it takes no idempotency key
+	Streamed bool
+	// PathTypes overrides types of path parameters, integer by default
+	PathTypes map[string]string
+	// Errors contains additional error statuses
+	Errors []int
+}
+
+// Key makes route key from method and gin path
+func Key(method string, path string) string {
+	return method + " " + path
+}
+
+// builder builds document
+type builder struct {
+	document *Document
+}
+
+// Build builds document from registered routes and their descriptions
+func Build(info Info, routes gin.RoutesInfo, descriptions map[string]Route) (*Document, error) {
+	b := &builder{document: &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				securityAPIKey: {Type: "apiKey", Name: auth.HeaderAPIKey, In: "header"},
+				securityBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		Security: []map[string][]string{
+			{securityAPIKey: {}},
+			{securityBearer: {}},
+		},
+	}}
+
+	b.schema(reflect.TypeOf(models.Error{}))
+
+	described := map[string]bool{}
+	for _, route := range routes {
+		key := Key(route.Method, route.Path)
+
+		description, ok := descriptions[key]
+		if !ok {
+			return nil, fmt.Errorf("route %s has no openapi description", key)
+		}
+		described[key] = true
+
+		path := pathParamExp.ReplaceAllString(route.Path, "{$1}")
+		if b.document.Paths[path] == nil {
+			b.document.Paths[path] = PathItem{}
+		}
+		b.document.Paths[path][strings.ToLower(route.Method)] = b.operation(route.Method, route.Path, description)
+	}
+
+	var missing []string
+	for key := range descriptions {
+		if !described[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("openapi descriptions %v have no routes", missing)
+	}
+
+	return b.document, nil
+}
+
+func (b *builder) operation(method string, path string, route Route) *Operation {
+	operation := &Operation{
+		OperationID: operationID(method, path),
+		Summary:     route.Summary,
+		Responses:   map[string]Response{},
+	}
+
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+
+	errors := map[int]bool{}
+	for _, status := range route.Errors {
+		errors[status] = true
+	}
+
+	for _, match := range pathParamExp.FindAllStringSubmatch(path, -1) {
+		paramType := "integer"
+		if value, ok := route.PathTypes[match[1]]; ok {
+			paramType = value
+		}
+
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: paramType},
+		})
+		errors[http.StatusBadRequest] = true
+		errors[http.StatusNotFound] = true
+	}
+
+	if route.Paging {
+		operation.Parameters = append(operation.Parameters,
+			Parameter{Name: "offset", In: "query", Description: "Number of records to skip",
+				Schema: &Schema{Type: "integer"}},
+			Parameter{Name: "limit", In: "query", Description: "Number of records to return, 100 by default",
+				Schema: &Schema{Type: "integer"}})
+		errors[http.StatusBadRequest] = true
+	}
+
+	var names []string
+	for name := range route.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: name, In: "query", Description: route.Query[name], Schema: &Schema{Type: "string"},
+		})
+	}
+
+	if route.Sort != nil {
+		fields := queryFields(route.Sort)
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: "sort",
+			In:   "query",
+			Description: "Sort expression field:order, may be repeated; fields: " + strings.Join(fields, ", ") +
+				"; orders: " + strings.Join(helpers.SortOrders, ", "),
+			Schema: &Schema{
+				Type: "array",
+				Items: &Schema{Type: "string", Pattern: "^(" + strings.Join(fields, "|") + "):(" +
+					strings.Join(helpers.SortOrders, "|") + ")$"},
+			},
+		})
+		errors[http.StatusBadRequest] = true
+	}
+
+	if route.Filter != nil {
+		fields := queryFields(route.Filter)
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: "filter",
+			In:   "query",
+			Description: "Filter expression field:operation:value in csv format, may be repeated, " +
+				"* matches any field, * in lk value matches any characters; fields: " + strings.Join(fields, ", ") +
+				"; operations: " + strings.Join(helpers.FilterOperations, ", "),
+			Schema: &Schema{
+				Type: "array",
+				Items: &Schema{Type: "string", Pattern: "^(\\*|" + strings.Join(fields, "|") + "):(" +
+					strings.Join(helpers.FilterOperations, "|") + "):.*$"},
+			},
+		})
+		errors[http.StatusBadRequest] = true
+	}
+
+	if route.ETag && method == http.MethodGet {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: "If-None-Match", In: "header", Description: "Entity tag of cached representation",
+			Schema: &Schema{Type: "string"},
+		})
+	}
+
+	if route.Precondition || (route.ETag && (method == http.MethodPatch || method == http.MethodDelete)) {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: "If-Match", In: "header", Description: "Entity tag of modified representation",
+			Schema: &Schema{Type: "string"},
+		})
+		errors[http.StatusPreconditionFailed] = true
+	}
+
+	if !route.Streamed && (method == http.MethodPost || method == http.MethodPatch || method == http.MethodDelete) {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: idempotency.HeaderIdempotencyKey, In: "header",
+			Description: "Key to replay the first response on retry",
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+
+	if route.Public {
+		operation.Security = &[]map[string][]string{}
+	} else {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: auth.HeaderTenant, In: "header", Description: "Tenant for admin credentials not bound to tenant",
+			Schema: &Schema{Type: "string"},
+		})
+		errors[http.StatusUnauthorized] = true
+		errors[http.StatusForbidden] = true
+		errors[http.StatusTooManyRequests] = true
+		errors[http.StatusInternalServerError] = true
+		errors[http.StatusServiceUnavailable] = true
+	}
+
+	operation.Parameters = append(operation.Parameters, Parameter{
+		Name: helpers.HeaderRequestID, In: "header", Description: "Request id echoed in response and errors",
+		Schema: &Schema{Type: "string"},
+	})
+
+	if route.Request != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{contentTypeJSON: {Schema: b.schema(reflect.TypeOf(route.Request))}},
+		}
+		errors[http.StatusBadRequest] = true
+	}
+	for _, contentType := range route.Consumes {
+		if operation.RequestBody == nil {
+			operation.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+		}
+		operation.RequestBody.Content[contentType] = MediaType{Schema: &Schema{Type: "string"}}
+		errors[http.StatusBadRequest] = true
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	response := Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		response.Content = map[string]MediaType{contentTypeJSON: {Schema: b.schema(reflect.TypeOf(route.Response))}}
+	}
+	for _, contentType := range route.Produces {
+		if response.Content == nil {
+			response.Content = map[string]MediaType{}
+		}
+		response.Content[contentType] = MediaType{Schema: &Schema{Type: "string"}}
+	}
+	if route.ETag && method != http.MethodDelete {
+		response.Headers = map[string]Header{"ETag": {Description: "Entity tag of the version",
+			Schema: &Schema{Type: "string"}}}
+	}
+	operation.Responses[strconv.Itoa(status)] = response
+
+	if route.ETag && method == http.MethodGet {
+		operation.Responses[strconv.Itoa(http.StatusNotModified)] = Response{
+			Description: http.StatusText(http.StatusNotModified),
+		}
+	}
+
+	for status := range errors {
+		operation.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status),
+			Content: map[string]MediaType{contentTypeJSON: {Schema: &Schema{
+				Type:  "array",
+				Items: &Schema{Ref: schemaPrefix + "Error"},
+			}}},
+		}
+	}
+
+	return operation
+}
+
+func (b *builder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		if _, ok := b.document.Components.Schemas[t.Name()]; !ok {
+			schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			b.document.Components.Schemas[t.Name()] = schema
+			b.properties(t, schema)
+		}
+
+		return &Schema{Ref: schemaPrefix + t.Name()}
+	}
+
+	return &Schema{}
+}
+
+func (b *builder) properties(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			b.properties(field.Type, schema)
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = b.schema(field.Type)
+		if !strings.Contains(tag, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func queryFields(object interface{}) []string {
+	var fields []string
+
+	t := reflect.TypeOf(object).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get(models.QueryTag)
+		if tag != "" && tag != "-" {
+			fields = append(fields, tag)
+		}
+	}
+
+	return fields
+}
+
+func operationID(method string, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.Split(path, "/") {
+		part = strings.TrimPrefix(part, ":")
+		if part == "" {
+			continue
+		}
+
+		part = strings.Replace(part, ".", "_", -1)
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+
+	return id
+}
@@ -0,0 +1,85 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/models"
+)
+
+func Test_Build_Success(t *testing.T) {
+	routes := gin.RoutesInfo{
+		{Method: http.MethodGet, Path: "/v1/flights/:flightId/seats/row/:row/line/:line"},
+		{Method: http.MethodGet, Path: "/v1/flights"},
+	}
+	descriptions := map[string]Route{
+		Key(http.MethodGet, "/v1/flights/:flightId/seats/row/:row/line/:line"): {
+			Response:  models.Seat{},
+			PathTypes: map[string]string{"line": "string"},
+		},
+		Key(http.MethodGet, "/v1/flights"): {
+			Response: []models.Flight{},
+			Sort:     &models.Flight{},
+			Public:   true,
+		},
+	}
+
+	document, err := Build(Info{Title: "test", Version: "v1"}, routes, descriptions)
+	if err != nil {
+		t.Fatal("Expected to build document successfully")
+	}
+
+	operation := document.Paths["/v1/flights/{flightId}/seats/row/{row}/line/{line}"]["get"]
+	if operation == nil {
+		t.Fatal("Expected to convert path parameters")
+	}
+	if operation.OperationID != "getV1FlightsFlightIdSeatsRowRowLineLine" {
+		t.Error("Expected to make operation id from path")
+	}
+	if operation.Parameters[2].Schema.Type != "string" || operation.Parameters[1].Schema.Type != "integer" {
+		t.Error("Expected to apply path parameter types")
+	}
+	if _, ok := operation.Responses["404"]; !ok {
+		t.Error("Expected to describe not found response")
+	}
+
+	seat := document.Components.Schemas["Seat"]
+	if seat == nil || seat.Properties["tenant_id"] != nil || seat.Properties["TenantID"] != nil {
+		t.Error("Expected to describe seat without hidden fields")
+	}
+
+	flight := document.Components.Schemas["Flight"]
+	for _, name := range flight.Required {
+		if name == "blocks" {
+			t.Error("Expected omitted fields not to be required")
+		}
+	}
+
+	list := document.Paths["/v1/flights"]["get"]
+	if list.Security == nil || len(*list.Security) != 0 {
+		t.Error("Expected public operation to override security")
+	}
+	if list.Parameters[0].Schema.Items.Pattern != "^(id|name|created_at):(asc|desc)$" {
+		t.Error("Expected sort pattern from query tags")
+	}
+}
+
+func Test_Build_Undescribed_Failure(t *testing.T) {
+	routes := gin.RoutesInfo{{Method: http.MethodGet, Path: "/v1/flights"}}
+
+	_, err := Build(Info{}, routes, map[string]Route{})
+	if err == nil {
+		t.Error("Expected to fail on route without description")
+	}
+}
+
+func Test_Build_Unrouted_Failure(t *testing.T) {
+	descriptions := map[string]Route{Key(http.MethodGet, "/v1/flights"): {}}
+
+	_, err := Build(Info{}, gin.RoutesInfo{}, descriptions)
+	if err == nil {
+		t.Error("Expected to fail on description without route")
+	}
+}
@@ -0,0 +1,186 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	gorp "github.com/go-gorp/gorp/v3"
+
+	"github.com/vsukhin/booking/metrics"
+)
+
+// InstrumentedDB is db management decorator measuring latencies and errors of db operations,
+// transaction rollbacks and connection pool stats
+type InstrumentedDB struct {
+	DBInterface
+	duration  metrics.Histogram
+	errors    metrics.Counter
+	rollbacks metrics.Counter
+}
+
+// NewInstrumentedDB is a constructor of db management decorator registering its metrics
+func NewInstrumentedDB(db DBInterface, registry metrics.Registry) DBInterface {
+	instrumented := &InstrumentedDB{
+		DBInterface: db,
+		duration: registry.Histogram("db_operation_duration_seconds", "Latency of db operations in seconds",
+			metrics.DefaultBuckets, "operation"),
+		errors:    registry.Counter("db_operation_errors_total", "Number of failed db operations", "operation"),
+		rollbacks: registry.Counter("db_rollbacks_total", "Number of rollbacked transactions"),
+	}
+
+	open := registry.Gauge("db_open_connections", "Number of established db connections")
+	inUse := registry.Gauge("db_in_use_connections", "Number of db connections in use")
+	idle := registry.Gauge("db_idle_connections", "Number of idle db connections")
+	waitCount := registry.Gauge("db_wait_count", "Total number of waits for db connection")
+	waitDuration := registry.Gauge("db_wait_duration_seconds", "Total time blocked waiting for db connection")
+
+	registry.OnCollect(func() {
+		dbMap := db.GetDBMap()
+		if dbMap == nil || dbMap.Db == nil {
+			return
+		}
+
+		stats := dbMap.Db.Stats()
+		open.Set(float64(stats.OpenConnections))
+		inUse.Set(float64(stats.InUse))
+		idle.Set(float64(stats.Idle))
+		waitCount.Set(float64(stats.WaitCount))
+		waitDuration.Set(stats.WaitDuration.Seconds())
+	})
+
+	return instrumented
+}
+
+// observe records latency of the operation and counts it failed if error is not expected
+func (db *InstrumentedDB) observe(operation string, start time.Time, err error) {
+	db.duration.Observe(metrics.Since(start), operation)
+	if err != nil && err != sql.ErrNoRows {
+		db.errors.Add(1, operation)
+	}
+}
+
+// Insert inserts data to the db table
+func (db *InstrumentedDB) Insert(ctx context.Context, trans *gorp.Transaction, list ...interface{}) error {
+	start := time.Now()
+	err := db.DBInterface.Insert(ctx, trans, list...)
+	db.observe("insert", start, err)
+
+	return err
+}
+
+// Update updates data in the db table
+func (db *InstrumentedDB) Update(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	start := time.Now()
+	count, err := db.DBInterface.Update(ctx, trans, list...)
+	db.observe("update", start, err)
+
+	return count, err
+}
+
+// Delete deletes data from the db table
+func (db *InstrumentedDB) Delete(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	start := time.Now()
+	count, err := db.DBInterface.Delete(ctx, trans, list...)
+	db.observe("delete", start, err)
+
+	return count, err
+}
+
+// Get gets data from the db table
+func (db *InstrumentedDB) Get(ctx context.Context, trans *gorp.Transaction, i interface{},
+	keys ...interface{}) (interface{}, error) {
+	start := time.Now()
+	result, err := db.DBInterface.Get(ctx, trans, i, keys...)
+	db.observe("get", start, err)
+
+	return result, err
+}
+
+// Select selects data from the db table
+func (db *InstrumentedDB) Select(ctx context.Context, trans *gorp.Transaction, i interface{}, query string,
+	args ...interface{}) ([]interface{}, error) {
+	start := time.Now()
+	result, err := db.DBInterface.Select(ctx, trans, i, query, args...)
+	db.observe("select", start, err)
+
+	return result, err
+}
+
+// SelectInt selects int from the db table
+func (db *InstrumentedDB) SelectInt(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (int64, error) {
+	start := time.Now()
+	result, err := db.DBInterface.SelectInt(ctx, trans, query, args...)
+	db.observe("select_int", start, err)
+
+	return result, err
+}
+
+// SelectStr selects string from the db table
+func (db *InstrumentedDB) SelectStr(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (string, error) {
+	start := time.Now()
+	result, err := db.DBInterface.SelectStr(ctx, trans, query, args...)
+	db.observe("select_str", start, err)
+
+	return result, err
+}
+
+// SelectOne selects one row from the db table
+func (db *InstrumentedDB) SelectOne(ctx context.Context, trans *gorp.Transaction, holder interface{}, query string,
+	args ...interface{}) error {
+	start := time.Now()
+	err := db.DBInterface.SelectOne(ctx, trans, holder, query, args...)
+	db.observe("select_one", start, err)
+
+	return err
+}
+
+// Query opens cursor, only its opening is measured
+func (db *InstrumentedDB) Query(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (RowsInterface, error) {
+	start := time.Now()
+	rows, err := db.DBInterface.Query(ctx, trans, query, args...)
+	db.observe("query", start, err)
+
+	return rows, err
+}
+
+// Exec executes query
+func (db *InstrumentedDB) Exec(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := db.DBInterface.Exec(ctx, trans, query, args...)
+	db.observe("exec", start, err)
+
+	return result, err
+}
+
+// Begin begins transaction
+func (db *InstrumentedDB) Begin(ctx context.Context) (*gorp.Transaction, error) {
+	start := time.Now()
+	trans, err := db.DBInterface.Begin(ctx)
+	db.observe("begin", start, err)
+
+	return trans, err
+}
+
+// Rollback rollbacks transaction and counts it
+func (db *InstrumentedDB) Rollback(ctx context.Context, trans *gorp.Transaction) error {
+	start := time.Now()
+	err := db.DBInterface.Rollback(ctx, trans)
+	db.observe("rollback", start, err)
+	db.rollbacks.Add(1)
+
+	return err
+}
+
+// Commit commits transaction
+func (db *InstrumentedDB) Commit(ctx context.Context, trans *gorp.Transaction) error {
+	start := time.Now()
+	err := db.DBInterface.Commit(ctx, trans)
+	db.observe("commit", start, err)
+
+	return err
+}
@@ -1,7 +1,7 @@
 package sqldb
 
 import (
-	gorp "gopkg.in/gorp.v2"
+	gorp "github.com/go-gorp/gorp/v3"
 
 	"github.com/vsukhin/booking/logging"
 )
@@ -1,26 +1,30 @@
 package sqldb
 
 import (
+	"context"
 	"database/sql"
 
+	gorp "github.com/go-gorp/gorp/v3"
 	// importing mysql driver
 	_ "github.com/go-sql-driver/mysql"
-	gorp "gopkg.in/gorp.v2"
 
 	"github.com/vsukhin/booking/logging"
 )
 
 const (
-	// maxIdleConns is maximum idle db connections
-	maxIdleConns = 50
-	// maxOpenConns is maximum open db connections
-	maxOpenConns = 100
+	// DefaultMaxIdleConns is default maximum idle db connections
+	DefaultMaxIdleConns = 50
+	// DefaultMaxOpenConns is default maximum open db connections
+	DefaultMaxOpenConns = 100
 	// dbDriver is db driver
 	dbDriver = "mysql"
 	// dbEngine is db engine
 	dbEngine = "InnoDB"
 	// dbEncoding is db encoding
 	dbEncoding = "UTF8"
+	// SchemaVersion is version of the latest migration expected by the service, every migration records
+	// its version in schema_migrations
+	SchemaVersion = 2
 )
 
 // DB is db management structure
@@ -28,21 +32,33 @@ type DB struct {
 	dbMap *gorp.DbMap
 }
 
+// RowsInterface is db cursor interface implemented by sql rows
+type RowsInterface interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
 // DBInterface is db management interface
 type DBInterface interface {
 	AddTableWithName(i interface{}, name string) *gorp.TableMap
-	Insert(trans *gorp.Transaction, list ...interface{}) error
-	Update(trans *gorp.Transaction, list ...interface{}) (int64, error)
-	Delete(trans *gorp.Transaction, list ...interface{}) (int64, error)
-	Get(trans *gorp.Transaction, i interface{}, keys ...interface{}) (interface{}, error)
-	Select(i interface{}, query string, args ...interface{}) ([]interface{}, error)
-	SelectInt(query string, args ...interface{}) (int64, error)
-	SelectStr(query string, args ...interface{}) (string, error)
-	SelectOne(trans *gorp.Transaction, holder interface{}, query string, args ...interface{}) error
-	Exec(trans *gorp.Transaction, query string, args ...interface{}) (sql.Result, error)
-	Begin() (*gorp.Transaction, error)
-	Rollback(trans *gorp.Transaction) error
-	Commit(trans *gorp.Transaction) error
+	Insert(ctx context.Context, trans *gorp.Transaction, list ...interface{}) error
+	Update(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error)
+	Delete(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error)
+	Get(ctx context.Context, trans *gorp.Transaction, i interface{}, keys ...interface{}) (interface{}, error)
+	Select(ctx context.Context, trans *gorp.Transaction, i interface{}, query string,
+		args ...interface{}) ([]interface{}, error)
+	SelectInt(ctx context.Context, trans *gorp.Transaction, query string, args ...interface{}) (int64, error)
+	SelectStr(ctx context.Context, trans *gorp.Transaction, query string, args ...interface{}) (string, error)
+	SelectOne(ctx context.Context, trans *gorp.Transaction, holder interface{}, query string, args ...interface{}) error
+	Query(ctx context.Context, trans *gorp.Transaction, query string, args ...interface{}) (RowsInterface, error)
+	Exec(ctx context.Context, trans *gorp.Transaction, query string, args ...interface{}) (sql.Result, error)
+	Begin(ctx context.Context) (*gorp.Transaction, error)
+	Rollback(ctx context.Context, trans *gorp.Transaction) error
+	Commit(ctx context.Context, trans *gorp.Transaction) error
+	Ping(ctx context.Context) error
+	Close() error
 	GetDBMap() *gorp.DbMap
 }
 
@@ -62,10 +78,8 @@ func NewDB(connString string, db *sql.DB, isProduction bool,
 			return nil, err
 		}
 
-		if isProduction {
-			db.SetMaxIdleConns(maxIdleConns)
-			db.SetMaxOpenConns(maxOpenConns)
-		}
+		db.SetMaxIdleConns(DefaultMaxIdleConns)
+		db.SetMaxOpenConns(DefaultMaxOpenConns)
 	}
 
 	err = db.Ping()
@@ -96,116 +110,111 @@ func (db *DB) AddTableWithName(i interface{}, name string) *gorp.TableMap {
 	return db.dbMap.AddTableWithName(i, name)
 }
 
-// Insert inserts data to the db table
-func (db *DB) Insert(trans *gorp.Transaction, list ...interface{}) error {
-	var err error
-
+// executor returns executor of the transaction or of the db map without one, its statements are bound to the context
+func (db *DB) executor(ctx context.Context, trans *gorp.Transaction) gorp.SqlExecutor {
 	if trans != nil {
-		err = trans.Insert(list...)
-	} else {
-		err = db.dbMap.Insert(list...)
+		return trans.WithContext(ctx)
 	}
 
-	return err
+	return db.dbMap.WithContext(ctx)
 }
 
-// Update updates data in the db table
-func (db *DB) Update(trans *gorp.Transaction, list ...interface{}) (int64, error) {
-	var err error
-	var count int64
-
-	if trans != nil {
-		count, err = trans.Update(list...)
-	} else {
-		count, err = db.dbMap.Update(list...)
-	}
+// Insert inserts data to the db table
+func (db *DB) Insert(ctx context.Context, trans *gorp.Transaction, list ...interface{}) error {
+	return db.executor(ctx, trans).Insert(list...)
+}
 
-	return count, err
+// Update updates data in the db table
+func (db *DB) Update(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	return db.executor(ctx, trans).Update(list...)
 }
 
 // Delete deletes data from the db table
-func (db *DB) Delete(trans *gorp.Transaction, list ...interface{}) (int64, error) {
-	var err error
-	var count int64
-
-	if trans != nil {
-		count, err = trans.Delete(list...)
-	} else {
-		count, err = db.dbMap.Delete(list...)
-	}
-
-	return count, err
+func (db *DB) Delete(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	return db.executor(ctx, trans).Delete(list...)
 }
 
 // Get gets data from the db table
-func (db *DB) Get(trans *gorp.Transaction, i interface{}, keys ...interface{}) (interface{}, error) {
-	var err error
-	var object interface{}
-
-	if trans != nil {
-		object, err = trans.Get(i, keys...)
-	} else {
-		object, err = db.dbMap.Get(i, keys...)
-	}
-
-	return object, err
+func (db *DB) Get(ctx context.Context, trans *gorp.Transaction, i interface{},
+	keys ...interface{}) (interface{}, error) {
+	return db.executor(ctx, trans).Get(i, keys...)
 }
 
 // Select selects data from the db table
-func (db *DB) Select(i interface{}, query string, args ...interface{}) ([]interface{}, error) {
-	return db.dbMap.Select(i, query, args...)
+func (db *DB) Select(ctx context.Context, trans *gorp.Transaction, i interface{}, query string,
+	args ...interface{}) ([]interface{}, error) {
+	return db.executor(ctx, trans).Select(i, query, args...)
 }
 
 // SelectInt selects int from the db table
-func (db *DB) SelectInt(query string, args ...interface{}) (int64, error) {
-	return db.dbMap.SelectInt(query, args...)
+func (db *DB) SelectInt(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (int64, error) {
+	return db.executor(ctx, trans).SelectInt(query, args...)
 }
 
 // SelectStr selects string from the db table
-func (db *DB) SelectStr(query string, args ...interface{}) (string, error) {
-	return db.dbMap.SelectStr(query, args...)
+func (db *DB) SelectStr(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (string, error) {
+	return db.executor(ctx, trans).SelectStr(query, args...)
 }
 
 // SelectOne selects one row from the db table
-func (db *DB) SelectOne(trans *gorp.Transaction, holder interface{}, query string, args ...interface{}) error {
-	var err error
+func (db *DB) SelectOne(ctx context.Context, trans *gorp.Transaction, holder interface{}, query string,
+	args ...interface{}) error {
+	return db.executor(ctx, trans).SelectOne(holder, query, args...)
+}
 
-	if trans != nil {
-		err = trans.SelectOne(holder, query, args...)
-	} else {
-		err = db.dbMap.SelectOne(holder, query, args...)
+// Query opens cursor over the query rows, cursor is closed when the context is done
+func (db *DB) Query(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (RowsInterface, error) {
+	rows, err := db.executor(ctx, trans).Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
 
-	return err
+	return rows, nil
 }
 
 // Exec executes statement
-func (db *DB) Exec(trans *gorp.Transaction, query string, args ...interface{}) (sql.Result, error) {
-	var result sql.Result
-	var err error
+func (db *DB) Exec(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (sql.Result, error) {
+	return db.executor(ctx, trans).Exec(query, args...)
+}
 
-	if trans != nil {
-		result, err = trans.Exec(query, args...)
-	} else {
-		result, err = db.dbMap.Exec(query, args...)
+// Begin begins transaction of the context, it is rolled back when the context is done before commit
+func (db *DB) Begin(ctx context.Context) (*gorp.Transaction, error) {
+	return db.dbMap.WithContext(ctx).(*gorp.DbMap).Begin()
+}
+
+// Rollback rollbacks transaction, transaction already rolled back by its done context is not an error
+func (db *DB) Rollback(ctx context.Context, trans *gorp.Transaction) error {
+	err := trans.Rollback()
+	if err == sql.ErrTxDone && ctx.Err() != nil {
+		return nil
 	}
 
-	return result, err
+	return err
+}
+
+// Commit commits transaction
+func (db *DB) Commit(ctx context.Context, trans *gorp.Transaction) error {
+	return trans.Commit()
 }
 
-// Begin begins transaction
-func (db *DB) Begin() (*gorp.Transaction, error) {
-	return db.dbMap.Begin()
+// Ping checks db connection
+func (db *DB) Ping(ctx context.Context) error {
+	return db.dbMap.Db.PingContext(ctx)
 }
 
-// Rollback rollbacks transaction
-func (db *DB) Rollback(trans *gorp.Transaction) error {
-	return trans.Rollback()
+// Close closes db connections
+func (db *DB) Close() error {
+	return db.dbMap.Db.Close()
 }
 
-// Commit commits transaction
-func (db *DB) Commit(trans *gorp.Transaction) error {
-	return trans.Commit()
+// SetPoolSize sets limits of the db connection pool, they are changed while queries run
+func SetPoolSize(db DBInterface, maxIdleConns int, maxOpenConns int) {
+	db.GetDBMap().Db.SetMaxIdleConns(maxIdleConns)
+	db.GetDBMap().Db.SetMaxOpenConns(maxOpenConns)
 }
 
 // GetDBMap returns dbmap
@@ -1,11 +1,25 @@
 package sqldb
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	gorp "github.com/go-gorp/gorp/v3"
 	"github.com/sirupsen/logrus"
 
 	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/metrics"
+	"github.com/vsukhin/booking/tracing"
 )
 
 func init() {
@@ -33,6 +47,11 @@ func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *log
 	return logrus.NewEntry(logger.Logger)
 }
 
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
 // Info logs info
 func (logger *FakeLogger) Info(args ...interface{}) {
 }
@@ -46,3 +65,243 @@ func Test_NewDB_Failure(t *testing.T) {
 		t.Error("Expected to have nil db")
 	}
 }
+
+// FakeConn is fake driver connection counting executed statements and ends of transactions
+type FakeConn struct {
+	mutex     sync.Mutex
+	executed  int
+	commits   int
+	rollbacks int
+}
+
+// Connect returns connection
+func (conn *FakeConn) Connect(ctx context.Context) (driver.Conn, error) {
+	return conn, nil
+}
+
+// Driver returns no driver
+func (conn *FakeConn) Driver() driver.Driver {
+	return nil
+}
+
+// Prepare is not supported
+func (conn *FakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+// Close closes nothing
+func (conn *FakeConn) Close() error {
+	return nil
+}
+
+// Begin begins transaction
+func (conn *FakeConn) Begin() (driver.Tx, error) {
+	return &FakeTx{conn: conn}, nil
+}
+
+// BeginTx begins transaction
+func (conn *FakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return &FakeTx{conn: conn}, nil
+}
+
+// ExecContext counts statement
+func (conn *FakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.executed++
+	return driver.RowsAffected(1), nil
+}
+
+// ends returns numbers of committed and rolled back transactions
+func (conn *FakeConn) ends() (int, int) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	return conn.commits, conn.rollbacks
+}
+
+// FakeTx is fake driver transaction
+type FakeTx struct {
+	conn *FakeConn
+}
+
+// Commit counts commit
+func (tx *FakeTx) Commit() error {
+	tx.conn.mutex.Lock()
+	defer tx.conn.mutex.Unlock()
+
+	tx.conn.commits++
+	return nil
+}
+
+// Rollback counts rollback
+func (tx *FakeTx) Rollback() error {
+	tx.conn.mutex.Lock()
+	defer tx.conn.mutex.Unlock()
+
+	tx.conn.rollbacks++
+	return nil
+}
+
+func Test_DB_Begin_Cancelled_Failure(t *testing.T) {
+	conn := &FakeConn{}
+	db, err := NewDB("", sql.OpenDB(conn), true, nil)
+	if err != nil {
+		t.Fatal("Expected to connect fake db")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	trans, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal("Expected to begin transaction")
+	}
+
+	_, err = db.Exec(ctx, trans, "UPDATE seats SET owner = ? WHERE id = ?", "owner", 1)
+	if err != nil {
+		t.Fatal("Expected to execute statement before cancellation")
+	}
+
+	cancel()
+
+	_, err = db.Exec(ctx, trans, "UPDATE seats SET owner = ? WHERE id = ?", "owner", 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected statement of cancelled request to fail, got %v", err)
+	}
+	if err = db.Commit(ctx, trans); err == nil {
+		t.Error("Expected not to commit transaction of cancelled request")
+	}
+	if err = db.Rollback(ctx, trans); err != nil {
+		t.Errorf("Expected rollback of aborted transaction to succeed, got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	commits, rollbacks := conn.ends()
+	for rollbacks == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		commits, rollbacks = conn.ends()
+	}
+
+	if commits != 0 || rollbacks != 1 || conn.executed != 1 {
+		t.Errorf("Expected transaction to be rolled back, got %v commits, %v rollbacks, %v statements", commits,
+			rollbacks, conn.executed)
+	}
+}
+
+// FakeDB is fake db failing to select rows
+type FakeDB struct {
+	DBInterface
+}
+
+// SelectOne returns no rows
+func (db *FakeDB) SelectOne(ctx context.Context, trans *gorp.Transaction, holder interface{}, query string,
+	args ...interface{}) error {
+	return sql.ErrNoRows
+}
+
+// Exec fails
+func (db *FakeDB) Exec(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (sql.Result, error) {
+	return nil, errors.New("db is unavailable")
+}
+
+// Rollback rollbacks nothing
+func (db *FakeDB) Rollback(ctx context.Context, trans *gorp.Transaction) error {
+	return nil
+}
+
+// FakeExporter collects exported spans
+type FakeExporter struct {
+	spans []*tracing.Span
+}
+
+// Export collects span
+func (exporter *FakeExporter) Export(span *tracing.Span) error {
+	exporter.spans = append(exporter.spans, span)
+	return nil
+}
+
+// GetDBMap returns no dbmap
+func (db *FakeDB) GetDBMap() *gorp.DbMap {
+	return nil
+}
+
+func Test_InstrumentedDB_Exec_Failure(t *testing.T) {
+	registry := metrics.NewRegistry()
+	db := NewInstrumentedDB(&FakeDB{}, registry)
+
+	_ = db.SelectOne(context.Background(), nil, nil, "SELECT 1")
+	_, _ = db.Exec(context.Background(), nil, "DELETE FROM seats")
+	_ = db.Rollback(context.Background(), nil)
+
+	var buffer bytes.Buffer
+	err := registry.Write(&buffer)
+	if err != nil {
+		t.Fatal("Expected to write metrics successfully")
+	}
+
+	output := buffer.String()
+	for _, line := range []string{
+		`db_operation_duration_seconds_count{operation="select_one"} 1`,
+		`db_operation_duration_seconds_count{operation="exec"} 1`,
+		`db_operation_errors_total{operation="exec"} 1`,
+		"db_rollbacks_total 1",
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected metrics to contain %v", line)
+		}
+	}
+	if strings.Contains(output, `db_operation_errors_total{operation="select_one"}`) {
+		t.Error("Expected no rows not to be counted as error")
+	}
+}
+
+func Test_TracedDB_Exec_Failure(t *testing.T) {
+	exporter := &FakeExporter{}
+	ctx, parent := tracing.NewTracer(exporter, nil).Start(context.Background(), "request",
+		tracing.SpanKindServer, tracing.SpanContext{})
+	db := NewTracedDB(&FakeDB{})
+
+	_ = db.SelectOne(ctx, nil, nil, "SELECT 1")
+	_, _ = db.Exec(ctx, nil, "DELETE FROM seats")
+
+	if len(exporter.spans) != 2 {
+		t.Fatalf("Expected span per statement, got %v", len(exporter.spans))
+	}
+	for _, span := range exporter.spans {
+		if span.Context.TraceID != parent.Context.TraceID || span.ParentID != parent.Context.SpanID {
+			t.Errorf("Expected span %v to be child of the context span", span.Name)
+		}
+		if span.Kind != tracing.SpanKindClient {
+			t.Errorf("Expected span %v to be client span", span.Name)
+		}
+	}
+	if exporter.spans[0].Name != "db.select_one" || exporter.spans[0].Error != "" {
+		t.Error("Expected no rows not to fail span")
+	}
+	if exporter.spans[1].Attributes["db.statement"] != "DELETE FROM seats" || exporter.spans[1].Error == "" {
+		t.Error("Expected failed span of the statement")
+	}
+}
+
+func Test_SchemaVersion_Migrations_Success(t *testing.T) {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil || len(files) != SchemaVersion {
+		t.Fatalf("Expected %v migrations, got %v, %v", SchemaVersion, len(files), err)
+	}
+
+	for i, file := range files {
+		prefix := fmt.Sprintf("%04d_", i+1)
+		if !strings.HasPrefix(filepath.Base(file), prefix) {
+			t.Errorf("Expected migration %v to have version prefix %v", file, prefix)
+		}
+
+		script, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Expected to read migration %v, got %v", file, err)
+		}
+		if !strings.Contains(string(script), fmt.Sprintf("VALUES (%v, UNIX_TIMESTAMP())", i+1)) {
+			t.Errorf("Expected migration %v to record its version", file)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+
+	gorp "github.com/go-gorp/gorp/v3"
+
+	"github.com/vsukhin/booking/tracing"
+)
+
+// TracedDB is db management decorator starting client span of the context trace per db operation
+type TracedDB struct {
+	DBInterface
+}
+
+// NewTracedDB is a constructor of db management decorator tracing db operations
+func NewTracedDB(db DBInterface) DBInterface {
+	return &TracedDB{DBInterface: db}
+}
+
+// start starts span of the operation and its statement if the statement is known
+func (db *TracedDB) start(ctx context.Context, operation string, query string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.StartKind(ctx, "db."+operation, tracing.SpanKindClient)
+	span.SetAttribute("db.system", dbDriver)
+	span.SetAttribute("db.operation", operation)
+	if query != "" {
+		span.SetAttribute("db.statement", query)
+	}
+
+	return ctx, span
+}
+
+// end ends span of the operation, no rows is not a failure
+func (db *TracedDB) end(span *tracing.Span, err error) {
+	if err != sql.ErrNoRows {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+// Insert inserts data to the db table
+func (db *TracedDB) Insert(ctx context.Context, trans *gorp.Transaction, list ...interface{}) error {
+	ctx, span := db.start(ctx, "insert", "")
+	err := db.DBInterface.Insert(ctx, trans, list...)
+	db.end(span, err)
+
+	return err
+}
+
+// Update updates data in the db table
+func (db *TracedDB) Update(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	ctx, span := db.start(ctx, "update", "")
+	count, err := db.DBInterface.Update(ctx, trans, list...)
+	db.end(span, err)
+
+	return count, err
+}
+
+// Delete deletes data from the db table
+func (db *TracedDB) Delete(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	ctx, span := db.start(ctx, "delete", "")
+	count, err := db.DBInterface.Delete(ctx, trans, list...)
+	db.end(span, err)
+
+	return count, err
+}
+
+// Get gets data from the db table
+func (db *TracedDB) Get(ctx context.Context, trans *gorp.Transaction, i interface{},
+	keys ...interface{}) (interface{}, error) {
+	ctx, span := db.start(ctx, "get", "")
+	result, err := db.DBInterface.Get(ctx, trans, i, keys...)
+	db.end(span, err)
+
+	return result, err
+}
+
+// Select selects data from the db table
+func (db *TracedDB) Select(ctx context.Context, trans *gorp.Transaction, i interface{}, query string,
+	args ...interface{}) ([]interface{}, error) {
+	ctx, span := db.start(ctx, "select", query)
+	result, err := db.DBInterface.Select(ctx, trans, i, query, args...)
+	db.end(span, err)
+
+	return result, err
+}
+
+// SelectInt selects int from the db table
+func (db *TracedDB) SelectInt(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (int64, error) {
+	ctx, span := db.start(ctx, "select_int", query)
+	result, err := db.DBInterface.SelectInt(ctx, trans, query, args...)
+	db.end(span, err)
+
+	return result, err
+}
+
+// SelectStr selects string from the db table
+func (db *TracedDB) SelectStr(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (string, error) {
+	ctx, span := db.start(ctx, "select_str", query)
+	result, err := db.DBInterface.SelectStr(ctx, trans, query, args...)
+	db.end(span, err)
+
+	return result, err
+}
+
+// SelectOne selects one row from the db table
+func (db *TracedDB) SelectOne(ctx context.Context, trans *gorp.Transaction, holder interface{}, query string,
+	args ...interface{}) error {
+	ctx, span := db.start(ctx, "select_one", query)
+	err := db.DBInterface.SelectOne(ctx, trans, holder, query, args...)
+	db.end(span, err)
+
+	return err
+}
+
+// Query opens cursor, only its opening is traced
+func (db *TracedDB) Query(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (RowsInterface, error) {
+	ctx, span := db.start(ctx, "query", query)
+	rows, err := db.DBInterface.Query(ctx, trans, query, args...)
+	db.end(span, err)
+
+	return rows, err
+}
+
+// Exec executes query
+func (db *TracedDB) Exec(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (sql.Result, error) {
+	ctx, span := db.start(ctx, "exec", query)
+	result, err := db.DBInterface.Exec(ctx, trans, query, args...)
+	db.end(span, err)
+
+	return result, err
+}
+
+// Begin begins transaction
+func (db *TracedDB) Begin(ctx context.Context) (*gorp.Transaction, error) {
+	ctx, span := db.start(ctx, "begin", "")
+	trans, err := db.DBInterface.Begin(ctx)
+	db.end(span, err)
+
+	return trans, err
+}
+
+// Rollback rollbacks transaction
+func (db *TracedDB) Rollback(ctx context.Context, trans *gorp.Transaction) error {
+	ctx, span := db.start(ctx, "rollback", "")
+	err := db.DBInterface.Rollback(ctx, trans)
+	db.end(span, err)
+
+	return err
+}
+
+// Commit commits transaction
+func (db *TracedDB) Commit(ctx context.Context, trans *gorp.Transaction) error {
+	ctx, span := db.start(ctx, "commit", "")
+	err := db.DBInterface.Commit(ctx, trans)
+	db.end(span, err)
+
+	return err
+}
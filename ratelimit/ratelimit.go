@@ -0,0 +1,290 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+const (
+	// HeaderRateLimitLimit is header of the budget burst
+	HeaderRateLimitLimit = "RateLimit-Limit"
+	// HeaderRateLimitRemaining is header of the tokens left in the budget
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	// HeaderRateLimitReset is header of the seconds until the budget is full
+	HeaderRateLimitReset = "RateLimit-Reset"
+	// HeaderRetryAfter is header of the seconds until the next request is allowed
+	HeaderRetryAfter = "Retry-After"
+	// BudgetRead is budget of the reads and other requests not mutating seats
+	BudgetRead = "read"
+	// BudgetMutation is budget of the seat mutations
+	BudgetMutation = "mutation"
+	// BudgetAddress is budget of the client ip taken before authentication
+	BudgetAddress = "address"
+	// DefaultReadPerMinute is default reads per minute of the client
+	DefaultReadPerMinute = 600
+	// DefaultReadBurst is default reads of the client allowed at once
+	DefaultReadBurst = 100
+	// DefaultMutationPerMinute is default mutations per minute of the client
+	DefaultMutationPerMinute = 60
+	// DefaultMutationBurst is default mutations of the client allowed at once
+	DefaultMutationBurst = 20
+	// DefaultAddressPerMinute is default requests per minute of the client ip, clients behind one ip share it
+	DefaultAddressPerMinute = 6000
+	// DefaultAddressBurst is default requests of the client ip allowed at once
+	DefaultAddressBurst = 1000
+	// ExpireInterval is interval of removing full buckets from the store
+	ExpireInterval = time.Minute
+	// keySeparator is separator of store key parts
+	keySeparator = "\x00"
+)
+
+// Budgets contains budgets of the request classes
+type Budgets struct {
+	Read     Budget
+	Mutation Budget
+	Address  Budget
+}
+
+// Classifier tells whether the request mutates seats
+type Classifier func(c *gin.Context) bool
+
+// Manager is rate limit manager taking token of the client budget per request, budgets are replaced on reload
+type Manager struct {
+	store   Store
+	proxies []*net.IPNet
+	mutex   sync.RWMutex
+	budgets Budgets
+}
+
+// ManagerInterface is rate limit manager interface
+type ManagerInterface interface {
+	Handle(mutation Classifier) gin.HandlerFunc
+	HandleAddress() gin.HandlerFunc
+	SetBudgets(budgets Budgets)
+}
+
+// NewManager is a constructor of rate limit manager, X-Forwarded-For is taken from the trusted proxies only
+func NewManager(store Store, budgets Budgets, proxies []*net.IPNet) ManagerInterface {
+	return &Manager{store: store, budgets: budgets, proxies: proxies}
+}
+
+// ParseProxies parses comma separated ips and cidrs of the trusted proxies
+func ParseProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy %v", entry)
+			}
+			bits := net.IPv6len * 8
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), net.IPv4len*8
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %v", entry)
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
+
+// NewBudgets makes budgets of the requests per minute and bursts, zero requests per minute sets no limit
+func NewBudgets(readPerMinute int, readBurst int, mutationPerMinute int, mutationBurst int, addressPerMinute int,
+	addressBurst int) Budgets {
+	return Budgets{
+		Read:     Budget{Name: BudgetRead, Rate: float64(readPerMinute) / 60, Burst: readBurst},
+		Mutation: Budget{Name: BudgetMutation, Rate: float64(mutationPerMinute) / 60, Burst: mutationBurst},
+		Address:  Budget{Name: BudgetAddress, Rate: float64(addressPerMinute) / 60, Burst: addressBurst},
+	}
+}
+
+// SetBudgets replaces budgets, buckets keep their tokens
+func (manager *Manager) SetBudgets(budgets Budgets) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.budgets = budgets
+}
+
+// budget returns budget of the request class
+func (manager *Manager) budget(name string) Budget {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	switch name {
+	case BudgetMutation:
+		return manager.budgets.Mutation
+	case BudgetAddress:
+		return manager.budgets.Address
+	}
+
+	return manager.budgets.Read
+}
+
+// trusted checks whether the address is of the trusted proxy
+func (manager *Manager) trusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, proxy := range manager.proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP returns peer address or, when the peer is the trusted proxy, the last X-Forwarded-For entry
+// not set by the trusted proxies, entries before it are set by the client and ignored
+func (manager *Manager) clientIP(c *gin.Context) (string, error) {
+	ip, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return c.Request.RemoteAddr, err
+	}
+	if !manager.trusted(ip) {
+		return ip, nil
+	}
+
+	forwarded := strings.Split(c.Request.Header.Get(helpers.HeaderForwardedFor), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+
+		ip = hop
+		if !manager.trusted(hop) {
+			break
+		}
+	}
+
+	return ip, nil
+}
+
+// addressKey returns key of the client ip
+func (manager *Manager) addressKey(c *gin.Context) (string, error) {
+	ip, err := manager.clientIP(c)
+	return "ip:" + ip, err
+}
+
+// principalKey returns key of the authenticated tenant and subject, so every credential of the principal
+// shares the budget, requests without principal are keyed by the client ip
+func (manager *Manager) principalKey(c *gin.Context) (string, error) {
+	principal := auth.GetPrincipal(c)
+	if principal == nil {
+		return manager.addressKey(c)
+	}
+
+	return "principal:" + principal.Tenant + keySeparator + principal.Subject, nil
+}
+
+// ceilSeconds formats duration in whole seconds rounded up
+func ceilSeconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
+
+// Handle is rate limit middleware of the authenticated principal mounted after authentication, seat mutations
+// take tokens of the mutation budget and other requests of the read budget, RateLimit-* headers are of this budget
+func (manager *Manager) Handle(mutation Classifier) gin.HandlerFunc {
+	return manager.limit(manager.principalKey, true, func(c *gin.Context) string {
+		if mutation(c) {
+			return BudgetMutation
+		}
+
+		return BudgetRead
+	})
+}
+
+// HandleAddress is rate limit middleware of the client ip mounted before authentication to limit requests
+// with invalid credentials too, its own budget is shared by clients behind one ip and sets no RateLimit-* headers
+func (manager *Manager) HandleAddress() gin.HandlerFunc {
+	return manager.limit(manager.addressKey, false, func(c *gin.Context) string {
+		return BudgetAddress
+	})
+}
+
+// limit returns middleware rejecting requests of the client over its budget with too many requests,
+// store errors are logged and the request is let through
+func (manager *Manager) limit(clientKey func(c *gin.Context) (string, error), headers bool,
+	class func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		budget := manager.budget(class(c))
+		if budget.Rate <= 0 {
+			c.Next()
+			return
+		}
+
+		key, err := clientKey(c)
+		if err != nil {
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"error":   err,
+				"address": c.Request.RemoteAddr,
+			}).Error("Error detecting ip")
+		}
+
+		result, err := manager.store.Take(budget.Name+keySeparator+key, budget)
+		if err != nil {
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"error":  err,
+				"budget": budget.Name,
+			}).Error("Error taking rate limit token")
+
+			c.Next()
+			return
+		}
+
+		if headers {
+			c.Header(HeaderRateLimitLimit, strconv.Itoa(budget.Burst))
+			c.Header(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			c.Header(HeaderRateLimitReset, ceilSeconds(result.Reset))
+		}
+
+		if !result.Allowed {
+			c.Header(HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+
+			errs := []models.Error{models.Error{
+				Code:    "requests.TooMany",
+				Message: "Too many requests, retry later",
+			}}
+
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"errors": errs,
+				"budget": budget.Name,
+				"path":   c.Request.URL.Path,
+			}).Warn("Rate limit is exceeded")
+
+			helpers.AbortWithErrors(c, http.StatusTooManyRequests, errs)
+			return
+		}
+
+		c.Next()
+	}
+}
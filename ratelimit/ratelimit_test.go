@@ -0,0 +1,294 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+)
+
+func init() {
+	logging.Log = NewFakeLogger()
+	gin.SetMode(gin.TestMode)
+}
+
+// FakeLogger is fake logger
+type FakeLogger struct {
+	*logrus.Logger
+}
+
+// NewFakeLogger is a constructor of fake logger
+func NewFakeLogger() logging.LoggerInterface {
+	log := logrus.New()
+
+	return &FakeLogger{log}
+}
+
+// Init initiates logging
+func (logger *FakeLogger) Init(mode string) {
+}
+
+// WithFields logs with fields
+func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// Info logs info
+func (logger *FakeLogger) Info(args ...interface{}) {
+}
+
+// FakeStore is store failing to take tokens
+type FakeStore struct {
+}
+
+// Take fails
+func (store *FakeStore) Take(key string, budget Budget) (Result, error) {
+	return Result{}, errors.New("store is unavailable")
+}
+
+// Expire expires nothing
+func (store *FakeStore) Expire() error {
+	return nil
+}
+
+func newTestRouter(manager ManagerInterface) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		switch c.Request.Header.Get(auth.HeaderAPIKey) {
+		case "agent-key", "agent-token":
+			auth.SetPrincipal(c, &models.Principal{Subject: "agent", Role: models.RoleAgent, Tenant: "acme"})
+		case "other-tenant-key":
+			auth.SetPrincipal(c, &models.Principal{Subject: "agent", Role: models.RoleAgent, Tenant: "globex"})
+		}
+	})
+	r.Use(manager.HandleAddress())
+	r.Use(manager.Handle(func(c *gin.Context) bool {
+		return c.Request.Method == http.MethodPost && c.Request.URL.Path == "/flights/1/seats"
+	}))
+	r.GET("/flights/:flightId/seats", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.POST("/flights/:flightId/seats", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	r.POST("/flights/:flightId/checkins", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	return r
+}
+
+func serve(r *gin.Engine, method string, key string, ip string) *httptest.ResponseRecorder {
+	return servePath(r, method, "/flights/1/seats", key, ip)
+}
+
+func servePath(r *gin.Engine, method string, path string, key string, ip string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	if key != "" {
+		req.Header.Set(auth.HeaderAPIKey, key)
+	}
+	if ip != "" {
+		req.Header.Set(helpers.HeaderForwardedFor, ip)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func Test_Manager_Handle_Success(t *testing.T) {
+	r := newTestRouter(NewManager(NewMemoryStore(), NewBudgets(60, 5, 60, 2, 60, 10), nil))
+
+	w := serve(r, "GET", "agent-key", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected request within budget to be served, got %v", w.Code)
+	}
+	if w.Header().Get(HeaderRateLimitLimit) != "5" || w.Header().Get(HeaderRateLimitRemaining) != "4" ||
+		w.Header().Get(HeaderRateLimitReset) != "1" || w.Header().Get(HeaderRetryAfter) != "" {
+		t.Errorf("Expected rate limit headers of the principal budget, got %v", w.Header())
+	}
+}
+
+func Test_Manager_Handle_Exceeded_Failure(t *testing.T) {
+	r := newTestRouter(NewManager(NewMemoryStore(), NewBudgets(60, 5, 60, 2, 0, 0), nil))
+
+	for i := 0; i < 2; i++ {
+		if w := serve(r, "POST", "agent-key", ""); w.Code != http.StatusCreated {
+			t.Fatalf("Expected mutation %v within budget to be served, got %v", i, w.Code)
+		}
+	}
+
+	w := serve(r, "POST", "agent-key", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected mutation over budget to be rejected, got %v", w.Code)
+	}
+	if w.Header().Get(HeaderRetryAfter) != "1" || w.Header().Get(HeaderRateLimitRemaining) != "0" {
+		t.Errorf("Unexpected rate limit headers %v", w.Header())
+	}
+
+	if w := serve(r, "GET", "agent-key", ""); w.Code != http.StatusOK {
+		t.Error("Expected reads to have separate budget")
+	}
+	if w := servePath(r, "POST", "/flights/1/checkins", "agent-key", ""); w.Code != http.StatusCreated ||
+		w.Header().Get(HeaderRateLimitLimit) != "5" {
+		t.Error("Expected requests not changing seats to take tokens of the read budget")
+	}
+	if w := serve(r, "POST", "agent-token", ""); w.Code != http.StatusTooManyRequests {
+		t.Error("Expected other credential of the principal to share its budget")
+	}
+	if w := serve(r, "POST", "other-tenant-key", ""); w.Code != http.StatusCreated {
+		t.Error("Expected subject of other tenant to have own budget")
+	}
+	if w := serve(r, "POST", "", ""); w.Code != http.StatusCreated {
+		t.Error("Expected client without principal to have own budget")
+	}
+}
+
+func Test_Manager_HandleAddress_Exceeded_Failure(t *testing.T) {
+	r := gin.New()
+	r.Use(NewManager(NewMemoryStore(), NewBudgets(60, 5, 60, 5, 60, 1), nil).HandleAddress())
+	r.POST("/flights/:flightId/seats", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+	})
+
+	w := serve(r, "POST", "invalid-key", "")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected request within budget to be served, got %v", w.Code)
+	}
+	if w.Header().Get(HeaderRateLimitLimit) != "" {
+		t.Errorf("Expected ip budget to set no rate limit headers, got %v", w.Header())
+	}
+
+	w = serve(r, "POST", "other-invalid-key", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get(HeaderRetryAfter) != "1" {
+		t.Errorf("Expected requests of the ip with invalid credentials to be limited, got %v", w.Code)
+	}
+}
+
+func Test_Manager_ClientIP_Proxies_Success(t *testing.T) {
+	proxies, err := ParseProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf("Expected proxies to be parsed, got %v", err)
+	}
+	manager := NewManager(NewMemoryStore(), NewBudgets(60, 5, 60, 1, 60, 1), proxies).(*Manager)
+
+	for _, test := range []struct {
+		forwarded string
+		ip        string
+	}{
+		{"", "10.0.0.1"},
+		{"1.2.3.4", "1.2.3.4"},
+		{"5.6.7.8, 1.2.3.4", "1.2.3.4"},
+		{"1.2.3.4, 192.168.1.1", "1.2.3.4"},
+		{"10.0.0.2, 192.168.1.1", "10.0.0.2"},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", "/flights/1/seats", nil)
+		c.Request.RemoteAddr = "10.0.0.1:5000"
+		c.Request.Header.Set(helpers.HeaderForwardedFor, test.forwarded)
+
+		ip, err := manager.clientIP(c)
+		if err != nil || ip != test.ip {
+			t.Errorf("Expected ip %v of %q, got %v, %v", test.ip, test.forwarded, ip, err)
+		}
+	}
+}
+
+func Test_Manager_ClientIP_Untrusted_Success(t *testing.T) {
+	manager := NewManager(NewMemoryStore(), NewBudgets(60, 5, 60, 1, 60, 1), nil).(*Manager)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/flights/1/seats", nil)
+	c.Request.RemoteAddr = "10.0.0.1:5000"
+	c.Request.Header.Set(helpers.HeaderForwardedFor, "1.2.3.4")
+
+	ip, err := manager.clientIP(c)
+	if err != nil || ip != "10.0.0.1" {
+		t.Errorf("Expected X-Forwarded-For of untrusted peer to be ignored, got %v, %v", ip, err)
+	}
+}
+
+func Test_ParseProxies_Failure(t *testing.T) {
+	_, err := ParseProxies("10.0.0.0/8, proxy")
+	if err == nil {
+		t.Error("Expected invalid proxy to fail")
+	}
+}
+
+func Test_Manager_SetBudgets_Success(t *testing.T) {
+	manager := NewManager(NewMemoryStore(), NewBudgets(60, 1, 60, 1, 0, 0), nil)
+	r := newTestRouter(manager)
+
+	serve(r, "POST", "", "")
+	manager.SetBudgets(NewBudgets(60, 1, 0, 0, 0, 0))
+
+	w := serve(r, "POST", "", "")
+	if w.Code != http.StatusCreated || w.Header().Get(HeaderRateLimitLimit) != "" {
+		t.Errorf("Expected zero rate to set no limit, got %v", w.Code)
+	}
+}
+
+func Test_Manager_Handle_Store_Failure(t *testing.T) {
+	r := newTestRouter(NewManager(&FakeStore{}, NewBudgets(60, 1, 60, 1, 0, 0), nil))
+
+	w := serve(r, "GET", "", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected request to be served when store fails, got %v", w.Code)
+	}
+}
+
+func Test_MemoryStore_Take_Refill_Success(t *testing.T) {
+	now := time.Now()
+	store := &MemoryStore{buckets: map[string]*bucket{}, now: func() time.Time { return now }}
+	budget := Budget{Name: BudgetMutation, Rate: 2, Burst: 2}
+
+	_, _ = store.Take("k", budget)
+	_, _ = store.Take("k", budget)
+
+	result, _ := store.Take("k", budget)
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond || result.Reset != time.Second {
+		t.Errorf("Expected empty bucket to reject take, got %+v", result)
+	}
+
+	now = now.Add(750 * time.Millisecond)
+
+	result, _ = store.Take("k", budget)
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Expected refilled bucket to allow take, got %+v", result)
+	}
+}
+
+func Test_MemoryStore_Expire_Success(t *testing.T) {
+	now := time.Now()
+	store := &MemoryStore{buckets: map[string]*bucket{}, now: func() time.Time { return now }}
+
+	_, _ = store.Take("idle", Budget{Rate: 1, Burst: 10})
+	for i := 0; i < 5; i++ {
+		_, _ = store.Take("busy", Budget{Rate: 1, Burst: 10})
+	}
+	now = now.Add(2 * time.Second)
+
+	err := store.Expire()
+	if err != nil {
+		t.Fatal("Expected to expire buckets successfully")
+	}
+	if _, ok := store.buckets["idle"]; ok || len(store.buckets) != 1 {
+		t.Errorf("Expected only full bucket to be removed, got %v buckets", len(store.buckets))
+	}
+}
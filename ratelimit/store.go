@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Budget is token bucket of the request class refilled at rate tokens per second up to burst tokens,
+// zero rate sets no limit
+type Budget struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+// Result contains state of the bucket after the take
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store is token bucket store interface, a shared store limits clients across service instances
+type Store interface {
+	// Take takes token from the bucket of the key refilling it by the time passed since the last take
+	Take(key string, budget Budget) (Result, error)
+	// Expire removes buckets refilled to full, it is run periodically by the reaper
+	Expire() error
+}
+
+// bucket contains tokens of the key
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// MemoryStore is in-memory token bucket store
+type MemoryStore struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryStore is a constructor of in-memory token bucket store
+func NewMemoryStore() Store {
+	return &MemoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Take takes token from the bucket of the key refilling it by the time passed since the last take
+func (store *MemoryStore) Take(key string, budget Budget) (Result, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	b, ok := store.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(budget.Burst), updated: now}
+		store.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(budget.Burst), b.tokens+now.Sub(b.updated).Seconds()*budget.Rate)
+	b.updated = now
+
+	result := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / budget.Rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((float64(budget.Burst) - b.tokens) / budget.Rate)
+	b.full = now.Add(result.Reset)
+
+	return result, nil
+}
+
+// Expire removes buckets refilled to full
+func (store *MemoryStore) Expire() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	for key, b := range store.buckets {
+		if !b.full.After(now) {
+			delete(store.buckets, key)
+		}
+	}
+
+	return nil
+}
+
+// seconds converts seconds to duration
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
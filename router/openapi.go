@@ -0,0 +1,308 @@
+package router
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/openapi"
+)
+
+const (
+	// pathOpenAPI is path of openapi document
+	pathOpenAPI = "/" + APIVersion + "/openapi.json"
+	// tagFlights is flights operation tag
+	tagFlights = "flights"
+	// tagSeats is seats operation tag
+	tagSeats = "seats"
+	// tagWaitlist is waitlist operation tag
+	tagWaitlist = "waitlist"
+	// tagBookings is bookings operation tag
+	tagBookings = "bookings"
+	// tagCheckins is check-in operation tag
+	tagCheckins = "checkins"
+)
+
+// apiInfo is api description
+var apiInfo = openapi.Info{
+	Title:       "Booking API",
+	Description: "Flight seat booking service",
+	Version:     APIVersion,
+}
+
+// apiRoutes contains descriptions of all registered routes
+var apiRoutes = map[string]openapi.Route{
+	openapi.Key(http.MethodGet, pathOpenAPI): {
+		Summary: "Get openapi document",
+		Public:  true,
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId"): {
+		Summary:  "Get flight",
+		Tag:      tagFlights,
+		Response: models.Flight{},
+		ETag:     true,
+	},
+	openapi.Key(http.MethodGet, "/v1/flights"): {
+		Summary:  "List flights",
+		Tag:      tagFlights,
+		Response: []models.Flight{},
+		Sort:     &models.Flight{},
+		Filter:   &models.Flight{},
+		Paging:   true,
+	},
+	openapi.Key(http.MethodOptions, "/v1/flights"): {
+		Summary:  "Get flight list metadata",
+		Tag:      tagFlights,
+		Response: models.FlightMeta{},
+		Filter:   &models.Flight{},
+	},
+	openapi.Key(http.MethodPost, "/v1/flights"): {
+		Summary:  "Create flight",
+		Tag:      tagFlights,
+		Request:  models.FlightCreate{},
+		Response: models.Flight{},
+		Status:   http.StatusCreated,
+		ETag:     true,
+	},
+	openapi.Key(http.MethodPatch, "/v1/flights/:flightId"): {
+		Summary:  "Update flight overbooking limit",
+		Tag:      tagFlights,
+		Request:  models.FlightUpdate{},
+		Response: models.Flight{},
+		ETag:     true,
+	},
+	openapi.Key(http.MethodPost, "/v1/flights/:flightId/close"): {
+		Summary:      "Close flight denying boarding to unseated bookings",
+		Tag:          tagFlights,
+		Response:     models.DeniedBoardingReport{},
+		ETag:         true,
+		Precondition: true,
+		Errors:       []int{http.StatusConflict},
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/denied-boarding"): {
+		Summary:  "Get denied boarding report of closed flight",
+		Tag:      tagFlights,
+		Response: models.DeniedBoardingReport{},
+		Errors:   []int{http.StatusConflict},
+	},
+	openapi.Key(http.MethodDelete, "/v1/flights/:flightId"): {
+		Summary: "Delete flight",
+		Tag:     tagFlights,
+		Status:  http.StatusNoContent,
+		ETag:    true,
+	},
+	openapi.Key(http.MethodPut, "/v1/flights/:flightId/blocks"): {
+		Summary:      "Replace cabin layout moving seat occupants to the new seats",
+		Tag:          tagFlights,
+		Request:      models.FlightLayout{},
+		Response:     models.FlightLayoutResult{},
+		ETag:         true,
+		Precondition: true,
+		Errors:       []int{http.StatusConflict},
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/seats/index/:index"): {
+		Summary:  "Get seat by index",
+		Tag:      tagSeats,
+		Response: models.Seat{},
+		ETag:     true,
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/seats/row/:row/line/:line"): {
+		Summary:   "Get seat by row and line",
+		Tag:       tagSeats,
+		Response:  models.Seat{},
+		ETag:      true,
+		PathTypes: map[string]string{"line": "string"},
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/seats"): {
+		Summary:  "List seats",
+		Tag:      tagSeats,
+		Response: []models.Seat{},
+		Sort:     &models.Seat{},
+		Filter:   &models.Seat{},
+		Paging:   true,
+	},
+	openapi.Key(http.MethodOptions, "/v1/flights/:flightId/seats"): {
+		Summary:  "Get seat list metadata",
+		Tag:      tagSeats,
+		Response: models.SeatMeta{},
+		Filter:   &models.Seat{},
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/availability"): {
+		Summary:  "Get seat availability counts of the flight",
+		Tag:      tagSeats,
+		Response: models.SeatSummary{},
+	},
+	openapi.Key(http.MethodPost, "/v1/flights/:flightId/seats"): {
+		Summary:  "Assign first free seat",
+		Tag:      tagSeats,
+		Errors:   []int{http.StatusConflict},
+		Response: models.Seat{},
+		Status:   http.StatusCreated,
+		ETag:     true,
+	},
+	openapi.Key(http.MethodPatch, "/v1/flights/:flightId/seats/:index"): {
+		Summary:  "Update seat",
+		Tag:      tagSeats,
+		Errors:   []int{http.StatusConflict},
+		Request:  models.SeatUpdate{},
+		Response: models.Seat{},
+		ETag:     true,
+	},
+	openapi.Key(http.MethodPost, "/v1/flights/:flightId/seats/:index/move"): {
+		Summary:      "Move occupant to the free seat",
+		Tag:          tagSeats,
+		Request:      models.SeatTarget{},
+		Response:     []models.Seat{},
+		Precondition: true,
+		Errors:       []int{http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	openapi.Key(http.MethodPost, "/v1/flights/:flightId/seats/:index/swap"): {
+		Summary:      "Swap occupants of two seats",
+		Tag:          tagSeats,
+		Request:      models.SeatTarget{},
+		Response:     []models.Seat{},
+		Precondition: true,
+		Errors:       []int{http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	openapi.Key(http.MethodPatch, "/v1/flights/:flightId/seats"): {
+		Summary:  "Assign, release or block seats in one transaction",
+		Tag:      tagSeats,
+		Request:  models.SeatBatch{},
+		Response: models.SeatBatchResponse{},
+		Errors:   []int{http.StatusConflict},
+	},
+	openapi.Key(http.MethodDelete, "/v1/flights/:flightId/seats/:index"): {
+		Summary: "Release seat",
+		Tag:     tagSeats,
+		Status:  http.StatusNoContent,
+		ETag:    true,
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/waitlist"): {
+		Summary:  "List waiting entries in order of promotion",
+		Tag:      tagWaitlist,
+		Response: []models.WaitlistEntry{},
+	},
+	openapi.Key(http.MethodPost, "/v1/flights/:flightId/waitlist"): {
+		Summary:  "Join waitlist, seat is granted at once if flight has free seats",
+		Tag:      tagWaitlist,
+		Request:  models.WaitlistCreate{},
+		Response: models.WaitlistEntry{},
+		Status:   http.StatusCreated,
+		ETag:     true,
+		Errors:   []int{http.StatusConflict},
+	},
+	openapi.Key(http.MethodDelete, "/v1/flights/:flightId/waitlist/:entryId"): {
+		Summary: "Cancel waiting entry",
+		Tag:     tagWaitlist,
+		Status:  http.StatusNoContent,
+		ETag:    true,
+		Errors:  []int{http.StatusConflict},
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/bookings"): {
+		Summary:  "List bookings",
+		Tag:      tagBookings,
+		Response: []models.Booking{},
+	},
+	openapi.Key(http.MethodPost, "/v1/flights/:flightId/bookings"): {
+		Summary:  "Create confirmed booking seated at check-in within overbooking limit",
+		Tag:      tagBookings,
+		Request:  models.BookingCreate{},
+		Response: models.Booking{},
+		Status:   http.StatusCreated,
+		ETag:     true,
+		Errors:   []int{http.StatusConflict},
+	},
+	openapi.Key(http.MethodPost, "/v1/flights/:flightId/checkins"): {
+		Summary:  "Check in passenger by assigned seat or by confirmed booking and issue boarding pass",
+		Tag:      tagCheckins,
+		Request:  models.CheckinCreate{},
+		Response: models.BoardingPass{},
+		Status:   http.StatusCreated,
+		Errors:   []int{http.StatusConflict},
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/checkins/:checkinId/boarding-pass"): {
+		Summary:  "Get boarding pass",
+		Tag:      tagCheckins,
+		Response: models.BoardingPass{},
+		Query:    map[string]string{"format": "bcbp returns IATA bar coded boarding pass string as text/plain"},
+		Produces: []string{"text/plain"},
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/boarding-manifest"): {
+		Summary:  "List passengers of assigned seats by boarding zone",
+		Tag:      tagCheckins,
+		Response: models.BoardingManifest{},
+		Query: map[string]string{
+			"strategy":       "back-to-front (default), wilma, outside-in or block",
+			"zones":          "Number of back-to-front zones, 4 by default",
+			"premium_blocks": "Number of front blocks boarding first in zone 1",
+		},
+	},
+	openapi.Key(http.MethodGet, "/v1/flights/:flightId/manifest"): {
+		Summary:  "Export all seats of the flight with passenger data as file streamed without paging",
+		Tag:      tagCheckins,
+		Sort:     &models.Seat{},
+		Filter:   &models.Seat{},
+		Query:    map[string]string{"format": "csv (default), ndjson or text"},
+		Produces: []string{"text/csv", "application/x-ndjson", "text/plain"},
+	},
+	openapi.Key(http.MethodPost, "/v1/flight-imports"): {
+		Summary:  "Import flights of csv or ndjson schedule in chunked transactions reporting row errors",
+		Tag:      tagFlights,
+		Response: models.ImportReport{},
+		Status:   http.StatusCreated,
+		Query: map[string]string{
+			"format":     "csv or ndjson, taken from Content-Type by default",
+			"dry_run":    "true validates rows without creating flights",
+			"chunk_size": "Number of flights created in one transaction, 100 by default",
+		},
+		Consumes: []string{"text/csv", "application/x-ndjson"},
+		Streamed: true,
+	},
+	openapi.Key(http.MethodGet, "/v1/cache/stats"): {
+		Summary:  "Get cache hit and miss counters of all tenants, admin credentials without tenant only",
+		Tag:      tagFlights,
+		Response: models.CacheStats{},
+	},
+	openapi.Key(http.MethodGet, pathMetrics): {
+		Summary:  "Get metrics of all tenants in prometheus text format, admin credentials without tenant only",
+		Produces: []string{"text/plain"},
+	},
+	openapi.Key(http.MethodGet, pathHealthz): {
+		Summary:  "Get liveness of the service",
+		Response: models.HealthReport{},
+		Public:   true,
+	},
+	openapi.Key(http.MethodGet, pathReadyz): {
+		Summary:  "Get readiness of the service checking db connection and schema version",
+		Response: models.HealthReport{},
+		Public:   true,
+	},
+}
+
+// OpenAPI serves openapi document of the registered routes
+func (router *Manager) OpenAPI(r *gin.Engine) gin.HandlerFunc {
+	var once sync.Once
+	var document *openapi.Document
+	var err error
+
+	return func(c *gin.Context) {
+		once.Do(func() {
+			document, err = openapi.Build(apiInfo, r.Routes(), apiRoutes)
+		})
+
+		if err != nil {
+			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+				"error": err,
+			}).Error("Error building openapi document")
+
+			helpers.AbortWithStatus(c, http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, document)
+	}
+}
@@ -1,21 +1,35 @@
 package router
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/cache"
 	"github.com/vsukhin/booking/controllers"
+	"github.com/vsukhin/booking/events"
+	"github.com/vsukhin/booking/health"
 	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/idempotency"
 	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/metrics"
 	"github.com/vsukhin/booking/models"
 	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/ratelimit"
 	"github.com/vsukhin/booking/services"
+	"github.com/vsukhin/booking/tracing"
 )
 
 const (
@@ -23,17 +37,53 @@ const (
 	APIVersion = "v1"
 	// version is version regexp
 	version = `^\/(v\d\/)?`
-	// proxyHeader is proxy header
-	proxyHeader = "X-FORWARDED-FOR"
-	// ip4Local is ip 4 local
-	ip4local = "127.0.0.1"
-	// ip6Local is ip 6 local
-	ip6local = "::1"
+	// pathMetrics is path of metrics of all tenants served to prometheus with admin credentials without tenant
+	pathMetrics = "/metrics"
+	// pathHealthz is path of liveness probe served without authentication
+	pathHealthz = "/healthz"
+	// pathReadyz is path of readiness probe served without authentication
+	pathReadyz = "/readyz"
+	// routeUnknown is route label of requests not matching any route
+	routeUnknown = "unknown"
+	// maxRequestIDLength is max length of request id accepted from the client
+	maxRequestIDLength = 128
+	// routeFlightImports is route of schedule import streaming its request body
+	routeFlightImports = "/" + APIVersion + "/flight-imports"
+	// importTimeout is deadline of schedule import instead of the request timeout
+	importTimeout = 30 * time.Minute
+)
+
+var (
+	// requestIDExp is request id accepted from the client
+	requestIDExp = regexp.MustCompile(`^[\w.\-]+$`)
+	// routeTimeouts contains own deadlines of the routes streaming request bodies longer than the request timeout
+	routeTimeouts = map[string]time.Duration{routeFlightImports: importTimeout}
+	// seatMutations contains methods and routes changing seats, they take tokens of the seat mutation budget
+	seatMutations = map[string]bool{
+		http.MethodPost + " /" + APIVersion + "/flights/:flightId/seats":             true,
+		http.MethodPatch + " /" + APIVersion + "/flights/:flightId/seats":            true,
+		http.MethodPatch + " /" + APIVersion + "/flights/:flightId/seats/:index":     true,
+		http.MethodDelete + " /" + APIVersion + "/flights/:flightId/seats/:index":    true,
+		http.MethodPost + " /" + APIVersion + "/flights/:flightId/seats/:index/move": true,
+		http.MethodPost + " /" + APIVersion + "/flights/:flightId/seats/:index/swap": true,
+		http.MethodPut + " /" + APIVersion + "/flights/:flightId/blocks":             true,
+	}
 )
 
 // Manager is router manager
 type Manager struct {
-	db sqldb.DBInterface
+	db                 sqldb.DBInterface
+	authManager        auth.ManagerInterface
+	idempotencyManager idempotency.ManagerInterface
+	rateLimitManager   ratelimit.ManagerInterface
+	publisher          events.Publisher
+	cacheManager       cache.ManagerInterface
+	registry           metrics.Registry
+	tracer             tracing.TracerInterface
+	healthManager      health.ManagerInterface
+	requestTimeout     time.Duration
+	requests           metrics.Counter
+	duration           metrics.Histogram
 }
 
 // ManagerInterface is router manager interface
@@ -45,8 +95,19 @@ type ManagerInterface interface {
 }
 
 // NewManager is a constructor of router manager
-func NewManager(db sqldb.DBInterface) ManagerInterface {
-	return &Manager{db: db}
+func NewManager(db sqldb.DBInterface, authManager auth.ManagerInterface,
+	idempotencyManager idempotency.ManagerInterface, rateLimitManager ratelimit.ManagerInterface,
+	publisher events.Publisher,
+	cacheManager cache.ManagerInterface, registry metrics.Registry, tracer tracing.TracerInterface,
+	healthManager health.ManagerInterface, requestTimeout time.Duration) ManagerInterface {
+	return &Manager{db: db, authManager: authManager, idempotencyManager: idempotencyManager,
+		rateLimitManager: rateLimitManager, publisher: publisher,
+		cacheManager: cacheManager, registry: registry, tracer: tracer, healthManager: healthManager,
+		requestTimeout: requestTimeout, requests: registry.Counter("http_requests_total", "Number of http requests",
+			"method", "route", "status"),
+		duration: registry.Histogram("http_request_duration_seconds", "Latency of http requests in seconds",
+			metrics.DefaultBuckets, "method", "route"),
+	}
 }
 
 func (router *Manager) stackMap(skip int) models.OrderedMap {
@@ -96,7 +157,7 @@ func (router *Manager) GinLogger() gin.HandlerFunc {
 
 		r, err := regexp.Compile(version)
 		if err != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
 				"error":  err,
 				"path":   path,
 				"regexp": version,
@@ -112,16 +173,13 @@ func (router *Manager) GinLogger() gin.HandlerFunc {
 
 		stop := time.Since(start)
 
-		var ip = c.Request.Header.Get(proxyHeader)
-		if ip == ip6local || ip == ip4local || ip == "" {
-			ip, _, err = net.SplitHostPort(c.Request.RemoteAddr)
-			if err != nil {
-				logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-					"error":   err,
-					"path":    path,
-					"address": c.Request.RemoteAddr,
-				}).Error("Error detecting ip")
-			}
+		ip, err := helpers.GetClientIP(c)
+		if err != nil {
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, logging.Fields{
+				"error":   err,
+				"path":    path,
+				"address": c.Request.RemoteAddr,
+			}).Error("Error detecting ip")
 		}
 
 		m := logging.Fields{
@@ -133,6 +191,7 @@ func (router *Manager) GinLogger() gin.HandlerFunc {
 			"request_path":  path,
 			"query_string":  c.Request.URL.Query(),
 			"user_agent":    c.Request.UserAgent(),
+			"request_id":    helpers.GetRequestID(c),
 			"created_at":    time.Now().Unix(),
 		}
 
@@ -140,9 +199,9 @@ func (router *Manager) GinLogger() gin.HandlerFunc {
 			m["headers"] = c.Request.Header
 			m["stack"] = router.stackMap(logging.DepthLow)
 			m["errors"] = c.Errors.String()
-			logging.Log.WithFields(logging.DepthModerate, m).Error("request")
+			logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, m).Error("request")
 		} else {
-			logging.Log.WithFields(logging.DepthLow, m).Info("request")
+			logging.Log.WithContext(c.Request.Context(), logging.DepthLow, m).Info("request")
 		}
 	}
 }
@@ -160,8 +219,8 @@ func (router *Manager) PanicRecovery() gin.HandlerFunc {
 					"path":       c.Request.URL.Path,
 					"user_agent": c.Request.UserAgent(),
 				}
-				logging.Log.WithFields(logging.DepthModerate, m).Error("Panic recovered")
-				c.AbortWithStatus(http.StatusInternalServerError)
+				logging.Log.WithContext(c.Request.Context(), logging.DepthModerate, m).Error("Panic recovered")
+				helpers.AbortWithStatus(c, http.StatusInternalServerError)
 			}
 		}()
 
@@ -169,6 +228,174 @@ func (router *Manager) PanicRecovery() gin.HandlerFunc {
 	}
 }
 
+// RequestID is request id middleware keeping client request id or generating new one
+func (router *Manager) RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.Request.Header.Get(helpers.HeaderRequestID)
+		if len(requestID) > maxRequestIDLength || !requestIDExp.MatchString(requestID) {
+			data := make([]byte, 16)
+			_, err := rand.Read(data)
+			if err != nil {
+				logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+					"error": err,
+				}).Error("Error generating request id")
+			}
+			requestID = hex.EncodeToString(data)
+		}
+
+		helpers.SetRequestID(c, requestID)
+		c.Header(helpers.HeaderRequestID, requestID)
+
+		c.Next()
+	}
+}
+
+// Deadline is middleware setting deadline of the request context, db operations of the request are cancelled
+// when it passes or the client disconnects; zero timeout sets no deadline, routes with own timeout keep it
+func (router *Manager) Deadline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := routeTimeouts[routeTemplate(c.Request.URL.Path, c.Params)]
+		if !ok {
+			timeout = router.requestTimeout
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// Trace is middleware continuing trace of the traceparent header or starting new one with server span of the request,
+// the span is passed to handlers in the request context and its traceparent is returned to the client
+func (router *Manager) Trace(r *gin.Engine) gin.HandlerFunc {
+	resolve := routeResolver(r)
+
+	return func(c *gin.Context) {
+		route := resolve(c)
+		parent, _ := tracing.ParseTraceparent(c.Request.Header.Get(tracing.HeaderTraceparent))
+
+		ctx, span := router.tracer.Start(c.Request.Context(), c.Request.Method+" "+route, tracing.SpanKindServer,
+			parent)
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", c.Request.URL.Path)
+		span.SetAttribute("http.request_id", helpers.GetRequestID(c))
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(tracing.HeaderTraceparent, span.Context.Traceparent())
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetError(errors.New(http.StatusText(status)))
+		}
+		span.End()
+	}
+}
+
+// Instrument is middleware counting requests and measuring their latencies by route template,
+// unknown routes are counted together
+func (router *Manager) Instrument(r *gin.Engine) gin.HandlerFunc {
+	resolve := routeResolver(r)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := resolve(c)
+		router.requests.Add(1, c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		router.duration.Observe(metrics.Since(start), c.Request.Method, route)
+	}
+}
+
+// routeResolver makes resolver of the request route template, routes of the engine are collected on first use
+// and requests not matching any of them are resolved to unknown route
+func routeResolver(r *gin.Engine) func(c *gin.Context) string {
+	var once sync.Once
+	routes := map[string]bool{}
+
+	return func(c *gin.Context) string {
+		once.Do(func() {
+			for _, route := range r.Routes() {
+				routes[route.Method+" "+route.Path] = true
+			}
+		})
+
+		route := routeTemplate(c.Request.URL.Path, c.Params)
+		if !routes[c.Request.Method+" "+route] {
+			return routeUnknown
+		}
+
+		return route
+	}
+}
+
+// routeTemplate restores gin route of the path replacing segments of the params with param names in order
+func routeTemplate(path string, params gin.Params) string {
+	segments := strings.Split(path, "/")
+
+	next := 0
+	for _, param := range params {
+		for i := next; i < len(segments); i++ {
+			if segments[i] == param.Value {
+				segments[i] = ":" + param.Key
+				next = i + 1
+				break
+			}
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// seatMutation tells whether the request route changes seats
+func seatMutation(c *gin.Context) bool {
+	return seatMutations[c.Request.Method+" "+routeTemplate(c.Request.URL.Path, c.Params)]
+}
+
+// Metrics serves values of all metrics in the prometheus text format
+func (router *Manager) Metrics(c *gin.Context) {
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+
+	err := router.registry.Write(c.Writer)
+	if err != nil {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error": err,
+		}).Error("Error writing metrics")
+	}
+}
+
+// Healthz serves liveness of the service
+func (router *Manager) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, router.healthManager.Live())
+}
+
+// Readyz serves readiness of the service, degraded or draining service is answered with service unavailable
+func (router *Manager) Readyz(c *gin.Context) {
+	report := router.healthManager.Ready(c.Request.Context())
+	if report.Status != models.HealthStatusOK {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// NotFound answers unknown routes with error envelope
+func (router *Manager) NotFound(c *gin.Context) {
+	helpers.AbortWithStatus(c, http.StatusNotFound)
+}
+
 // CreateRouter creates router
 func (router *Manager) CreateRouter(mode string) *gin.Engine {
 	router.InitGin(mode)
@@ -176,32 +403,111 @@ func (router *Manager) CreateRouter(mode string) *gin.Engine {
 	r := gin.New()
 
 	blockService := services.NewBlockService(router.db)
-	seatService := services.NewSeatService(router.db)
-	flightService := services.NewFlightService(router.db, blockService, seatService)
+	seatService := services.NewSeatService(router.db, router.cacheManager)
+	flightService := services.NewFlightService(router.db, blockService, seatService, router.cacheManager)
+	waitlistService := services.NewWaitlistService(router.db, router.publisher, router.cacheManager)
+	bookingService := services.NewBookingService(router.db, router.cacheManager)
+	checkinService := services.NewCheckinService(router.db)
+	boardingService := services.NewBoardingService(router.db)
+	manifestService := services.NewManifestService(router.db)
+	scheduleService := services.NewScheduleService(flightService)
+	kpiService := services.NewKPIService(router.db, router.registry)
+	router.registry.OnCollect(func() {
+		err := kpiService.Collect()
+		if err != nil {
+			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+				"error": err,
+			}).Error("Error collecting booking KPIs")
+		}
+	})
 
 	queryManager := helpers.NewQueryManager()
 
 	flightController := controllers.NewFlightController(flightService, queryManager)
-	seatController := controllers.NewSeatController(seatService, flightService, queryManager)
+	seatController := controllers.NewSeatController(seatService, flightService, waitlistService, queryManager)
+	waitlistController := controllers.NewWaitlistController(waitlistService, flightService)
+	bookingController := controllers.NewBookingController(bookingService, flightService)
+	checkinController := controllers.NewCheckinController(checkinService, bookingService, seatService, flightService)
+	boardingController := controllers.NewBoardingController(boardingService, flightService)
+	manifestController := controllers.NewManifestController(manifestService, flightService, queryManager)
+	scheduleController := controllers.NewScheduleController(scheduleService)
+	cacheController := controllers.NewCacheController(router.cacheManager)
 
+	r.Use(router.RequestID())
+	r.Use(router.Trace(r))
+	r.Use(router.Instrument(r))
 	r.Use(router.GinLogger())
 	r.Use(router.PanicRecovery())
+	r.Use(router.Deadline())
+	r.NoRoute(router.NotFound)
+
+	r.GET(pathOpenAPI, router.OpenAPI(r))
+	r.GET(pathMetrics, router.authManager.Authenticate(), router.authManager.AuthorizeOperator(), router.Metrics)
+	r.GET(pathHealthz, router.Healthz)
+	r.GET(pathReadyz, router.Readyz)
+
+	// operators read state of all tenants, so they aren't bound to tenant
+	operators := r.Group("/"+APIVersion, router.rateLimitManager.HandleAddress(), router.authManager.Authenticate(),
+		router.authManager.AuthorizeOperator(), router.rateLimitManager.Handle(seatMutation))
+	{
+		operators.GET("/cache/stats", cacheController.Stats)
+	}
 
-	v := r.Group("/" + APIVersion)
+	v := r.Group("/"+APIVersion, router.rateLimitManager.HandleAddress(), router.authManager.Authenticate(),
+		router.authManager.ResolveTenant(), router.rateLimitManager.Handle(seatMutation))
 	{
-		v.GET("/flights/:flightId", flightController.Retrieve)
-		v.GET("/flights", flightController.ListAll)
-		v.OPTIONS("/flights", flightController.GetMeta)
-		v.POST("/flights", flightController.Create)
-		v.DELETE("/flights/:flightId", flightController.Delete)
-
-		v.GET("/flights/:flightId/seats/index/:index", seatController.Retrieve)
-		v.GET("/flights/:flightId/seats/row/:row/line/:line", seatController.Find)
-		v.GET("/flights/:flightId/seats", seatController.ListAll)
-		v.OPTIONS("/flights/:flightId/seats", seatController.GetMeta)
-		v.POST("/flights/:flightId/seats", seatController.Create)
-		v.PATCH("/flights/:flightId/seats/:index", seatController.Update)
-		v.DELETE("/flights/:flightId/seats/:index", seatController.Delete)
+		// schedule import streams its request body, so it isn't buffered for idempotency keys
+		imports := v.Group("", router.authManager.Authorize(models.RoleAdmin))
+		{
+			imports.POST("/flight-imports", scheduleController.Import)
+		}
+
+		idempotent := v.Group("", router.idempotencyManager.Handle())
+
+		readers := idempotent.Group("", router.authManager.Authorize(models.RoleAdmin, models.RoleAgent, models.RoleCustomer))
+		{
+			readers.GET("/flights/:flightId", flightController.Retrieve)
+			readers.GET("/flights", flightController.ListAll)
+			readers.OPTIONS("/flights", flightController.GetMeta)
+		}
+
+		admins := idempotent.Group("", router.authManager.Authorize(models.RoleAdmin))
+		{
+			admins.POST("/flights", flightController.Create)
+			admins.PATCH("/flights/:flightId", flightController.Update)
+			admins.DELETE("/flights/:flightId", flightController.Delete)
+			admins.PUT("/flights/:flightId/blocks", flightController.Relayout)
+			admins.POST("/flights/:flightId/close", bookingController.Close)
+		}
+
+		seats := idempotent.Group("", router.authManager.Authorize(models.RoleAdmin, models.RoleAgent, models.RoleCustomer))
+		{
+			seats.GET("/flights/:flightId/seats/index/:index", seatController.Retrieve)
+			seats.GET("/flights/:flightId/seats/row/:row/line/:line", seatController.Find)
+			seats.GET("/flights/:flightId/seats", seatController.ListAll)
+			seats.OPTIONS("/flights/:flightId/seats", seatController.GetMeta)
+			seats.GET("/flights/:flightId/availability", seatController.Summary)
+			seats.POST("/flights/:flightId/seats", seatController.Create)
+			seats.PATCH("/flights/:flightId/seats/:index", seatController.Update)
+			seats.DELETE("/flights/:flightId/seats/:index", seatController.Delete)
+			seats.POST("/flights/:flightId/seats/:index/move", seatController.Move)
+			seats.POST("/flights/:flightId/seats/:index/swap", seatController.Swap)
+			seats.GET("/flights/:flightId/waitlist", waitlistController.ListAll)
+			seats.POST("/flights/:flightId/waitlist", waitlistController.Create)
+			seats.DELETE("/flights/:flightId/waitlist/:entryId", waitlistController.Delete)
+			seats.GET("/flights/:flightId/bookings", bookingController.ListAll)
+			seats.POST("/flights/:flightId/bookings", bookingController.Create)
+			seats.POST("/flights/:flightId/checkins", checkinController.Create)
+			seats.GET("/flights/:flightId/checkins/:checkinId/boarding-pass", checkinController.BoardingPass)
+		}
+
+		agents := idempotent.Group("", router.authManager.Authorize(models.RoleAdmin, models.RoleAgent))
+		{
+			agents.PATCH("/flights/:flightId/seats", seatController.Batch)
+			agents.GET("/flights/:flightId/denied-boarding", bookingController.DeniedBoarding)
+			agents.GET("/flights/:flightId/boarding-manifest", boardingController.Manifest)
+			agents.GET("/flights/:flightId/manifest", manifestController.Export)
+		}
 	}
 
 	return r
@@ -1,19 +1,43 @@
 package router
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	gorp "github.com/go-gorp/gorp/v3"
 	"github.com/sirupsen/logrus"
-	gorp "gopkg.in/gorp.v2"
 
+	"github.com/vsukhin/booking/auth"
+	"github.com/vsukhin/booking/cache"
+	"github.com/vsukhin/booking/events"
+	"github.com/vsukhin/booking/health"
+	"github.com/vsukhin/booking/idempotency"
 	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/metrics"
+	"github.com/vsukhin/booking/models"
 	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/ratelimit"
+	"github.com/vsukhin/booking/tracing"
 )
 
+// openAPIFile is committed openapi document
+const openAPIFile = "../docs/openapi.json"
+
+// update rewrites committed openapi document
+var update = flag.Bool("update", false, "update committed openapi document")
+
 func init() {
 	logging.Log = NewFakeLogger()
 }
@@ -39,6 +63,11 @@ func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *log
 	return logrus.NewEntry(logger.Logger)
 }
 
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
 // Info logs info
 func (logger *FakeLogger) Info(args ...interface{}) {
 }
@@ -59,70 +88,92 @@ func (db *FakeDB) AddTableWithName(i interface{}, name string) *gorp.TableMap {
 }
 
 // Insert inserts data to the db table
-func (db *FakeDB) Insert(trans *gorp.Transaction, list ...interface{}) error {
+func (db *FakeDB) Insert(ctx context.Context, trans *gorp.Transaction, list ...interface{}) error {
 
 	return nil
 }
 
 // Update updates data in the db table
-func (db *FakeDB) Update(trans *gorp.Transaction, list ...interface{}) (int64, error) {
+func (db *FakeDB) Update(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
 
 	return 0, nil
 }
 
 // Delete deletes data from the db table
-func (db *FakeDB) Delete(trans *gorp.Transaction, list ...interface{}) (int64, error) {
+func (db *FakeDB) Delete(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
 
 	return 0, nil
 }
 
 // Get gets data from the db table
-func (db *FakeDB) Get(trans *gorp.Transaction, i interface{}, keys ...interface{}) (interface{}, error) {
+func (db *FakeDB) Get(ctx context.Context, trans *gorp.Transaction, i interface{},
+	keys ...interface{}) (interface{}, error) {
 
 	return nil, nil
 }
 
 // Select selects data from the db table
-func (db *FakeDB) Select(i interface{}, query string, args ...interface{}) ([]interface{}, error) {
+func (db *FakeDB) Select(ctx context.Context, trans *gorp.Transaction, i interface{}, query string,
+	args ...interface{}) ([]interface{}, error) {
 	var list []interface{}
 	return list, nil
 }
 
 // SelectInt selects int from the db table
-func (db *FakeDB) SelectInt(query string, args ...interface{}) (int64, error) {
+func (db *FakeDB) SelectInt(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (int64, error) {
 
 	return 0, nil
 }
 
 // SelectStr selects string from the db table
-func (db *FakeDB) SelectStr(query string, args ...interface{}) (string, error) {
+func (db *FakeDB) SelectStr(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (string, error) {
 
 	return "", nil
 }
 
 // SelectOne selects one row from the db table
-func (db *FakeDB) SelectOne(trans *gorp.Transaction, holder interface{}, query string, args ...interface{}) error {
+func (db *FakeDB) SelectOne(ctx context.Context, trans *gorp.Transaction, holder interface{}, query string,
+	args ...interface{}) error {
 
 	return nil
 }
 
+// Query opens cursor over the query rows
+func (db *FakeDB) Query(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (sqldb.RowsInterface, error) {
+	return nil, nil
+}
+
 // Exec executes statement
-func (db *FakeDB) Exec(trans *gorp.Transaction, query string, args ...interface{}) (sql.Result, error) {
+func (db *FakeDB) Exec(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (sql.Result, error) {
 	return nil, nil
 }
 
 // Begin begins transaction
-func (db *FakeDB) Begin() (*gorp.Transaction, error) {
+func (db *FakeDB) Begin(ctx context.Context) (*gorp.Transaction, error) {
 	return nil, nil
 }
 
 // Rollback rollbacks transaction
-func (db *FakeDB) Rollback(trans *gorp.Transaction) error {
+func (db *FakeDB) Rollback(ctx context.Context, trans *gorp.Transaction) error {
 	return nil
 }
 
 // Commit commits transaction
-func (db *FakeDB) Commit(trans *gorp.Transaction) error {
+func (db *FakeDB) Commit(ctx context.Context, trans *gorp.Transaction) error {
+	return nil
+}
+
+// Ping pings nothing
+func (db *FakeDB) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close closes nothing
+func (db *FakeDB) Close() error {
 	return nil
 }
 
@@ -132,7 +183,9 @@ func (db *FakeDB) GetDBMap() *gorp.DbMap {
 }
 
 func Test_Router_InitGin_Dev_Success(t *testing.T) {
-	router := NewManager(NewFakeDB())
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
 
 	router.InitGin(logging.ModeDev)
 	if gin.Mode() != "debug" {
@@ -141,7 +194,9 @@ func Test_Router_InitGin_Dev_Success(t *testing.T) {
 }
 
 func Test_Router_InitGin_Staging_Success(t *testing.T) {
-	router := NewManager(NewFakeDB())
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
 
 	router.InitGin(logging.ModeStaging)
 	if gin.Mode() != "release" {
@@ -150,7 +205,9 @@ func Test_Router_InitGin_Staging_Success(t *testing.T) {
 }
 
 func Test_Router_InitGin_Prod_Success(t *testing.T) {
-	router := NewManager(NewFakeDB())
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
 
 	router.InitGin(logging.ModeProd)
 	if gin.Mode() != "release" {
@@ -159,7 +216,9 @@ func Test_Router_InitGin_Prod_Success(t *testing.T) {
 }
 
 func Test_Router_InitGin_Unknown_Success(t *testing.T) {
-	router := NewManager(NewFakeDB())
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
 
 	router.InitGin("Unknown")
 	if gin.Mode() != "debug" {
@@ -171,7 +230,9 @@ func Test_Router_GinLogger_Success(t *testing.T) {
 	req, _ := http.NewRequest("POST", "/", nil)
 	w := httptest.NewRecorder()
 
-	router := NewManager(NewFakeDB())
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
 
 	r := gin.New()
 	r.Use(router.GinLogger())
@@ -190,7 +251,9 @@ func Test_Router_GinLogger_GinError_Failure(t *testing.T) {
 	req, _ := http.NewRequest("POST", "/", nil)
 	w := httptest.NewRecorder()
 
-	router := NewManager(NewFakeDB())
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
 
 	r := gin.New()
 	r.Use(router.GinLogger())
@@ -210,7 +273,9 @@ func Test_Router_PanicRecovery_Success(t *testing.T) {
 	req, _ := http.NewRequest("POST", "/", nil)
 	w := httptest.NewRecorder()
 
-	router := NewManager(NewFakeDB())
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
 
 	r := gin.New()
 	r.Use(router.PanicRecovery())
@@ -226,11 +291,573 @@ func Test_Router_PanicRecovery_Success(t *testing.T) {
 	}
 }
 
+func Test_Router_Deadline_Success(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(),
+		time.Minute).(*Manager)
+
+	var deadline time.Time
+	var ok bool
+
+	r := gin.New()
+	r.Use(router.Deadline())
+	r.GET("/", func(c *gin.Context) {
+		deadline, ok = c.Request.Context().Deadline()
+	})
+
+	start := time.Now()
+	r.ServeHTTP(w, req)
+
+	if !ok || deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Error("Expected request context to have deadline of the request timeout")
+	}
+}
+
+func Test_Router_Deadline_Import_Success(t *testing.T) {
+	req, _ := http.NewRequest("POST", routeFlightImports, nil)
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(),
+		time.Minute).(*Manager)
+
+	var deadline time.Time
+	var ok bool
+
+	r := gin.New()
+	r.Use(router.Deadline())
+	r.POST(routeFlightImports, func(c *gin.Context) {
+		deadline, ok = c.Request.Context().Deadline()
+	})
+
+	start := time.Now()
+	r.ServeHTTP(w, req)
+
+	if !ok || deadline.Before(start.Add(importTimeout)) || deadline.After(time.Now().Add(importTimeout)) {
+		t.Error("Expected schedule import to have deadline of its own timeout")
+	}
+}
+
 func Test_Router_CreateRouter_Success(t *testing.T) {
-	router := NewManager(NewFakeDB())
+	router := NewManager(NewFakeDB(), auth.NewManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
 
 	r := router.CreateRouter(logging.ModeDev)
 	if r == nil {
 		t.Error("Expected router successfully created")
 	}
 }
+
+func newTestIdempotencyManager() idempotency.ManagerInterface {
+	return idempotency.NewManager(idempotency.NewMemoryStore(), idempotency.DefaultTTL)
+}
+
+func newTestRateLimitManager() ratelimit.ManagerInterface {
+	return ratelimit.NewManager(ratelimit.NewMemoryStore(), ratelimit.NewBudgets(0, 0, 0, 0, 0, 0), nil)
+}
+
+func newTestCacheManager() cache.ManagerInterface {
+	return cache.NewManager(cache.NewMemoryStore(cache.DefaultCapacity), cache.DefaultTTL)
+}
+
+func newTestTracer() tracing.TracerInterface {
+	return tracing.NewTracer(&tracing.NopExporter{}, nil)
+}
+
+func newTestHealthManager() health.ManagerInterface {
+	db := NewFakeDB()
+	return health.NewManager(health.DefaultCheckTimeout, health.NewDBChecker(db),
+		health.NewMigrationChecker(db, sqldb.SchemaVersion))
+}
+
+func newTestKeySetManager() auth.ManagerInterface {
+	return auth.NewKeySetManager(&auth.KeySet{
+		APIKeys: []auth.APIKey{
+			{Key: "admin-key", Principal: models.Principal{Subject: "admin", Role: models.RoleAdmin}},
+			{Key: "tenant-admin-key", Principal: models.Principal{Subject: "admin", Role: models.RoleAdmin,
+				Tenant: "t1"}},
+			{Key: "agent-key", Principal: models.Principal{Subject: "agent", Role: models.RoleAgent}},
+			{Key: "customer-key", Principal: models.Principal{Subject: "customer", Role: models.RoleCustomer,
+				Tenant: "t1"}},
+		},
+	})
+}
+
+func Test_Router_CreateRouter_Unauthenticated_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/flights", nil)
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Error("Expected to return unauthorized")
+	}
+}
+
+func Test_Router_CreateRouter_Authenticated_Success(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/flights", nil)
+	req.Header.Set(auth.HeaderAPIKey, "customer-key")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Error("Expected to return success")
+	}
+}
+
+func Test_Router_CreateRouter_TenantHeader_Success(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/flights", nil)
+	req.Header.Set(auth.HeaderAPIKey, "admin-key")
+	req.Header.Set(auth.HeaderTenant, "t2")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Error("Expected to return success")
+	}
+}
+
+func Test_Router_CreateRouter_TenantMissing_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/flights", nil)
+	req.Header.Set(auth.HeaderAPIKey, "admin-key")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Error("Expected to return bad request")
+	}
+}
+
+func Test_Router_CreateRouter_TenantMismatch_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/flights", nil)
+	req.Header.Set(auth.HeaderAPIKey, "customer-key")
+	req.Header.Set(auth.HeaderTenant, "t2")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Error("Expected to return forbidden")
+	}
+}
+
+func Test_Router_CreateRouter_TenantHeader_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/flights", nil)
+	req.Header.Set(auth.HeaderAPIKey, "agent-key")
+	req.Header.Set(auth.HeaderTenant, "t2")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Error("Expected to forbid tenant selection by credentials other than admin ones")
+	}
+}
+
+func Test_Router_CreateRouter_Forbidden_Failure(t *testing.T) {
+	req, _ := http.NewRequest("DELETE", "/v1/flights/1", nil)
+	req.Header.Set(auth.HeaderAPIKey, "customer-key")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Error("Expected to return forbidden")
+	}
+}
+
+func Test_Router_CreateRouter_NotModified_Success(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/flights/1", nil)
+	req.Header.Set(auth.HeaderAPIKey, "customer-key")
+	req.Header.Set("If-None-Match", `"0"`)
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Error("Expected to return not modified")
+	}
+	if w.Header().Get("ETag") != `"0"` {
+		t.Error("Expected to return matching etag")
+	}
+}
+
+func Test_Router_CreateRouter_PreconditionFailed_Failure(t *testing.T) {
+	req, _ := http.NewRequest("DELETE", "/v1/flights/1", nil)
+	req.Header.Set(auth.HeaderAPIKey, "admin-key")
+	req.Header.Set(auth.HeaderTenant, "t1")
+	req.Header.Set("If-Match", `"5"`)
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Error("Expected to return precondition failed")
+	}
+}
+
+func Test_Router_CreateRouter_OpenAPI_Success(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatal("Expected to return openapi document without credentials")
+	}
+
+	var served interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &served)
+	if err != nil {
+		t.Fatal("Expected to return valid json")
+	}
+
+	if *update {
+		data, err := json.MarshalIndent(served, "", "  ")
+		if err != nil {
+			t.Fatal("Expected to marshal openapi document")
+		}
+
+		err = ioutil.WriteFile(openAPIFile, append(data, '\n'), 0644)
+		if err != nil {
+			t.Fatal("Expected to write openapi document")
+		}
+	}
+
+	data, err := ioutil.ReadFile(openAPIFile)
+	if err != nil {
+		t.Fatal("Expected to read openapi document, run go test ./router -update")
+	}
+
+	var committed interface{}
+	err = json.Unmarshal(data, &committed)
+	if err != nil {
+		t.Fatal("Expected committed openapi document to be valid json")
+	}
+
+	if !reflect.DeepEqual(served, committed) {
+		t.Error("Expected committed openapi document to match routes and models, run go test ./router -update")
+	}
+}
+
+func Test_Router_CreateRouter_NotFound_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/unknown", nil)
+	req.Header.Set("X-Request-ID", "r1")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	var errs []models.Error
+	err := json.Unmarshal(w.Body.Bytes(), &errs)
+	if err != nil || w.Code != http.StatusNotFound || len(errs) != 1 || errs[0].RequestID != "r1" {
+		t.Error("Expected to return not found error envelope with request id")
+	}
+	if w.Header().Get("X-Request-ID") != "r1" {
+		t.Error("Expected to echo request id")
+	}
+}
+
+func Test_Router_CreateRouter_Unauthenticated_RequestID_Success(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/flights", nil)
+	req.Header.Set("X-Request-ID", "bad id")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	var errs []models.Error
+	err := json.Unmarshal(w.Body.Bytes(), &errs)
+	if err != nil || len(errs) != 1 || errs[0].RequestID == "" || errs[0].RequestID == "bad id" {
+		t.Error("Expected to generate request id for invalid one")
+	}
+}
+
+func Test_Router_CreateRouter_CustomerBatch_Failure(t *testing.T) {
+	req, _ := http.NewRequest("PATCH", "/v1/flights/1/seats", nil)
+	req.Header.Set(auth.HeaderAPIKey, "customer-key")
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Error("Expected to forbid seat batch for customer")
+	}
+}
+
+func Test_Router_CreateRouter_Metrics_Success(t *testing.T) {
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+
+	for _, path := range []string{"/v1/flights/12", "/v1/flights/34", "/unknown/12"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	for key, status := range map[string]int{"": http.StatusUnauthorized, "customer-key": http.StatusForbidden} {
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		if key != "" {
+			req.Header.Set(auth.HeaderAPIKey, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected metrics of all tenants to be denied for %q, got %v", key, w.Code)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set(auth.HeaderAPIKey, "admin-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != metrics.ContentType {
+		t.Fatal("Expected to serve metrics to admin without tenant")
+	}
+
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/v1/flights/:flightId",status="401"} 2`,
+		`http_requests_total{method="GET",route="unknown",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/v1/flights/:flightId"} 2`,
+		"booking_open_flights 0",
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("Expected metrics to contain %v", line)
+		}
+	}
+}
+
+func Test_Router_CreateRouter_Health_Success(t *testing.T) {
+	healthManager := health.NewManager(health.DefaultCheckTimeout, health.NewDBChecker(NewFakeDB()))
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), healthManager, 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"ok"`) {
+			t.Errorf("Expected %v to report healthy service without authentication, got %v", path, w.Code)
+		}
+	}
+
+	healthManager.Drain()
+
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), models.HealthStatusDraining) {
+		t.Errorf("Expected draining service not to be ready, got %v", w.Code)
+	}
+}
+
+func Test_Router_CreateRouter_Readyz_Failure(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	var report models.HealthReport
+	err := json.Unmarshal(w.Body.Bytes(), &report)
+	if err != nil || w.Code != http.StatusServiceUnavailable || report.Status != models.HealthStatusDegraded {
+		t.Fatalf("Expected service with outdated schema not to be ready, got %v", w.Code)
+	}
+	if report.Components[0].Status != models.HealthStatusOK || report.Components[1].Status == models.HealthStatusOK {
+		t.Errorf("Expected only migrations component to fail, got %+v", report.Components)
+	}
+}
+
+func Test_Router_CreateRouter_Trace_Success(t *testing.T) {
+	parent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	req, _ := http.NewRequest("GET", "/v1/flights", nil)
+	req.Header.Set(auth.HeaderAPIKey, "customer-key")
+	req.Header.Set(tracing.HeaderTraceparent, parent)
+	w := httptest.NewRecorder()
+
+	var buffer bytes.Buffer
+	router := NewManager(sqldb.NewTracedDB(NewFakeDB()), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		tracing.NewTracer(tracing.NewOTLPExporter(&buffer, "booking"), nil), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+	r.ServeHTTP(w, req)
+
+	spanContext, ok := tracing.ParseTraceparent(w.Header().Get(tracing.HeaderTraceparent))
+	if !ok || spanContext.TraceID != "0af7651916cd43dd8448eb211c80319c" {
+		t.Fatal("Expected to continue trace of the traceparent")
+	}
+
+	spans := map[string]string{}
+	scanner := bufio.NewScanner(&buffer)
+	for scanner.Scan() {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						TraceID      string `json:"traceId"`
+						ParentSpanID string `json:"parentSpanId"`
+						Name         string `json:"name"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+
+		err := json.Unmarshal(scanner.Bytes(), &request)
+		if err != nil {
+			t.Fatal("Expected to export otlp json")
+		}
+
+		span := request.ResourceSpans[0].ScopeSpans[0].Spans[0]
+		if span.TraceID != spanContext.TraceID {
+			t.Errorf("Expected span %v to belong to the trace", span.Name)
+		}
+		spans[span.Name] = span.ParentSpanID
+	}
+
+	if spans["GET /v1/flights"] != "b7ad6b7169203331" {
+		t.Error("Expected server span to be child of the traceparent span")
+	}
+	if _, ok := spans["FlightService.ListAll"]; !ok {
+		t.Error("Expected service span")
+	}
+	if _, ok := spans["db.select"]; !ok {
+		t.Error("Expected span of sql statement")
+	}
+}
+
+func Test_RouteTemplate_Success(t *testing.T) {
+	route := routeTemplate("/v1/flights/7/seats/7/swap", gin.Params{{Key: "flightId", Value: "7"},
+		{Key: "index", Value: "7"}})
+	if route != "/v1/flights/:flightId/seats/:index/swap" {
+		t.Errorf("Unexpected route template %v", route)
+	}
+}
+
+func Test_SeatMutation_Success(t *testing.T) {
+	for _, test := range []struct {
+		method   string
+		path     string
+		params   gin.Params
+		mutation bool
+	}{
+		{"POST", "/v1/flights/3/seats", gin.Params{{Key: "flightId", Value: "3"}}, true},
+		{"PATCH", "/v1/flights/3/seats/4", gin.Params{{Key: "flightId", Value: "3"}, {Key: "index", Value: "4"}}, true},
+		{"GET", "/v1/flights/3/seats", gin.Params{{Key: "flightId", Value: "3"}}, false},
+		{"POST", "/v1/flights/3/checkins", gin.Params{{Key: "flightId", Value: "3"}}, false},
+		{"POST", "/v1/flight-imports", nil, false},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest(test.method, test.path, nil)
+		c.Params = test.params
+
+		if seatMutation(c) != test.mutation {
+			t.Errorf("Expected seat mutation of %v %v to be %v", test.method, test.path, test.mutation)
+		}
+	}
+}
+
+func Test_Router_CreateRouter_CacheStats_Failure(t *testing.T) {
+	router := NewManager(NewFakeDB(), newTestKeySetManager(), newTestIdempotencyManager(),
+		newTestRateLimitManager(), events.NewLogPublisher(), newTestCacheManager(), metrics.NewRegistry(),
+		newTestTracer(), newTestHealthManager(), 0)
+
+	r := router.CreateRouter(logging.ModeDev)
+
+	for key, status := range map[string]int{"admin-key": http.StatusOK, "tenant-admin-key": http.StatusForbidden,
+		"agent-key": http.StatusForbidden} {
+		req, _ := http.NewRequest("GET", "/v1/cache/stats", nil)
+		req.Header.Set(auth.HeaderAPIKey, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected cache stats of all tenants to answer %v to %v, got %v", status, key, w.Code)
+		}
+	}
+}
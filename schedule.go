@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/vsukhin/booking/cache"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/schedule"
+	"github.com/vsukhin/booking/services"
+)
+
+const (
+	// CommandImport is subcommand importing flight schedule file
+	CommandImport = "import"
+	// ExitCodeFailure is exit code of the failed command
+	ExitCodeFailure = 1
+	// ExitCodeRowErrors is exit code of the import with rows not imported
+	ExitCodeRowErrors = 2
+)
+
+// runImport imports flights of the schedule file printing progress to stderr and report to stdout
+func runImport(db sqldb.DBInterface, args []string) int {
+	flags := flag.NewFlagSet(CommandImport, flag.ContinueOnError)
+	file := flags.String("file", "", "Schedule file, standard input by default")
+	tenant := flags.String("tenant", "", "Tenant of the imported flights")
+	format := flags.String("format", "", "Schedule format: csv, ndjson; by file extension by default")
+	dryRun := flags.Bool("dry-run", false, "Validate rows without creating flights")
+	chunkSize := flags.Int("chunk-size", 0, "Number of flights created in one transaction")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return ExitCodeFailure
+	}
+	if *tenant == "" {
+		fmt.Fprintln(os.Stderr, "tenant must be provided")
+		return ExitCodeFailure
+	}
+
+	options := &models.ImportOptions{Format: *format, DryRun: *dryRun, ChunkSize: *chunkSize}
+	if errs := options.Validate(); len(errs) != 0 {
+		fmt.Fprintln(os.Stderr, errs[0].Message)
+		return ExitCodeFailure
+	}
+
+	var input io.Reader = os.Stdin
+	if *file != "" {
+		opened, err := os.Open(*file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return ExitCodeFailure
+		}
+		defer opened.Close()
+
+		input = opened
+		if options.Format == "" {
+			options.Format = strings.TrimPrefix(filepath.Ext(*file), ".")
+		}
+	}
+
+	scheduleFormat := schedule.FormatOf(options.Format)
+	reader, err := schedule.NewReader(scheduleFormat, input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitCodeFailure
+	}
+
+	cacheManager := cache.NewManager(cache.NewMemoryStore(cache.DefaultCapacity), cache.DefaultTTL)
+	flightService := services.NewFlightService(db, services.NewBlockService(db), services.NewSeatService(db, cacheManager),
+		cacheManager)
+
+	// interrupted import rolls back the chunk in progress, chunks created before stay
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := services.NewScheduleService(flightService).Import(ctx, *tenant, reader, options,
+		func(progress models.ImportProgress) {
+			fmt.Fprintf(os.Stderr, "rows=%d valid=%d created=%d failed=%d\n", progress.Rows, progress.Valid,
+				progress.Created, progress.Failed)
+		})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encErr := encoder.Encode(report); encErr != nil {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error": encErr,
+		}).Error("Error printing import report")
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitCodeFailure
+	}
+	if report.Failed != 0 {
+		return ExitCodeRowErrors
+	}
+
+	return 0
+}
@@ -0,0 +1,225 @@
+package schedule
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vsukhin/booking/models"
+)
+
+// Format is schedule file format
+type Format string
+
+const (
+	// FormatCSV is comma separated values with header line naming columns
+	FormatCSV Format = "csv"
+	// FormatNDJSON is one json flight object per line
+	FormatNDJSON Format = "ndjson"
+)
+
+const (
+	// maxLineSize is max size of the ndjson line
+	maxLineSize = 1 << 20
+)
+
+var (
+	// ErrUnknownColumn is error of the csv header column not known
+	ErrUnknownColumn = errors.New("unknown schedule column")
+	// ErrMissingColumn is error of the csv header without flight name column
+	ErrMissingColumn = errors.New("schedule has no name column")
+	// setters contains csv column parsers setting flight fields
+	setters = map[string]func(flight *models.ScheduleFlight, value string) error{
+		"name":        func(flight *models.ScheduleFlight, value string) error { flight.Name = value; return nil },
+		"carrier":     func(flight *models.ScheduleFlight, value string) error { flight.Carrier = value; return nil },
+		"number":      func(flight *models.ScheduleFlight, value string) error { flight.Number = value; return nil },
+		"origin":      func(flight *models.ScheduleFlight, value string) error { flight.Origin = value; return nil },
+		"destination": func(flight *models.ScheduleFlight, value string) error { flight.Destination = value; return nil },
+		"departs_at":  setDepartsAt,
+		"overbooking": func(flight *models.ScheduleFlight, value string) error {
+			return setInt(value, func(number int64) { flight.Overbooking = int(number) })
+		},
+		"template": func(flight *models.ScheduleFlight, value string) error {
+			return setInt(value, func(number int64) { flight.Template = number })
+		},
+		"blocks": func(flight *models.ScheduleFlight, value string) error {
+			if value == "" {
+				return nil
+			}
+			return json.Unmarshal([]byte(value), &flight.Blocks)
+		},
+	}
+)
+
+// Reader reads flights of the schedule row by row
+type Reader interface {
+	Next() (*models.ScheduleRow, error)
+}
+
+// FormatOf returns schedule format of the content type, csv for unknown one
+func FormatOf(contentType string) Format {
+	if strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonl") {
+		return FormatNDJSON
+	}
+
+	return FormatCSV
+}
+
+// IsValid checks schedule format
+func (format Format) IsValid() bool {
+	return format == FormatCSV || format == FormatNDJSON
+}
+
+// NewReader is a constructor of the format reader, csv header is read at once
+func NewReader(format Format, reader io.Reader) (Reader, error) {
+	if format == FormatNDJSON {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+
+		return &ndjsonReader{scanner: scanner}, nil
+	}
+
+	records := csv.NewReader(reader)
+	records.TrimLeadingSpace = true
+
+	header, err := records.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	named := false
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := setters[header[i]]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, column)
+		}
+		named = named || header[i] == "name"
+	}
+	if !named {
+		return nil, ErrMissingColumn
+	}
+
+	return &csvReader{reader: records, header: header}, nil
+}
+
+// rowError makes error of the schedule row value
+func rowError(field string, message string) models.Error {
+	return models.Error{
+		Code:    field + ".Invalid",
+		Message: message,
+		Field:   field,
+	}
+}
+
+func setInt(value string, set func(number int64)) error {
+	if value == "" {
+		return nil
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	set(number)
+	return nil
+}
+
+// setDepartsAt sets departure time given as unix time or RFC 3339 time
+func setDepartsAt(flight *models.ScheduleFlight, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		flight.DepartsAt = number
+		return nil
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return err
+	}
+
+	flight.DepartsAt = date.Unix()
+	return nil
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+// Next reads flight of the next csv record, malformed records are reported as row errors with line number
+func (reader *csvReader) Next() (*models.ScheduleRow, error) {
+	record, err := reader.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+
+	row := &models.ScheduleRow{}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		row.Row = parseErr.StartLine
+		row.Errors = append(row.Errors, rowError("row", parseErr.Err.Error()))
+		return row, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	row.Row, _ = reader.reader.FieldPos(0)
+	for i, value := range record {
+		err = setters[reader.header[i]](&row.Flight, strings.TrimSpace(value))
+		if err != nil {
+			row.Errors = append(row.Errors, rowError(reader.header[i], "Value is malformed: "+err.Error()))
+		}
+	}
+
+	return row, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	row     int
+}
+
+// Next reads flight of the next non-empty line, malformed lines are reported as row errors
+func (reader *ndjsonReader) Next() (*models.ScheduleRow, error) {
+	for reader.scanner.Scan() {
+		reader.row++
+
+		line := bytes.TrimSpace(reader.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		row := &models.ScheduleRow{Row: reader.row}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+
+		err := decoder.Decode(&row.Flight)
+		if err != nil {
+			row.Errors = append(row.Errors, rowError("row", "Line is malformed: "+err.Error()))
+		}
+
+		return row, nil
+	}
+
+	err := reader.scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
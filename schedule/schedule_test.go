@@ -0,0 +1,102 @@
+package schedule
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vsukhin/booking/models"
+)
+
+func readAll(t *testing.T, reader Reader) []models.ScheduleRow {
+	var rows []models.ScheduleRow
+
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatal("Expected to read schedule successfully")
+		}
+		rows = append(rows, *row)
+	}
+}
+
+func Test_Reader_CSV_Success(t *testing.T) {
+	input := "name,carrier,number,origin,destination,departs_at,overbooking,template,blocks\n" +
+		`Morning,AC,834,YUL,FRA,2026-11-22T08:30:00Z,5,,"[{""rows"":2,""side_seat_numbers"":[3,3]}]"` + "\n" +
+		"Evening,AC,835,FRA,YUL,1795000000,,7,\n" +
+		"Broken,AC,836,YUL,FRA,tomorrow,x,,\n"
+
+	reader, err := NewReader(FormatCSV, strings.NewReader(input))
+	if err != nil {
+		t.Fatal("Expected to read csv header successfully")
+	}
+
+	rows := readAll(t, reader)
+	if len(rows) != 3 {
+		t.Fatalf("Expected to read three rows, got %+v", rows)
+	}
+
+	first := rows[0]
+	if first.Row != 2 || len(first.Errors) != 0 || first.Flight.DepartsAt != 1795336200 ||
+		first.Flight.Overbooking != 5 || len(first.Flight.Blocks) != 1 || first.Flight.Blocks[0].Rows != 2 {
+		t.Errorf("Expected to parse flight with inline blocks, got %+v", first)
+	}
+	if second := rows[1]; second.Row != 3 || second.Flight.Template != 7 || second.Flight.DepartsAt != 1795000000 {
+		t.Errorf("Expected to parse flight with template, got %+v", second)
+	}
+	if third := rows[2]; third.Row != 4 || len(third.Errors) != 2 || third.Errors[0].Code != "departs_at.Invalid" ||
+		third.Errors[1].Code != "overbooking.Invalid" {
+		t.Errorf("Expected to report malformed values, got %+v", third)
+	}
+}
+
+func Test_Reader_CSV_FieldCount_Failure(t *testing.T) {
+	reader, err := NewReader(FormatCSV, strings.NewReader("name,carrier\nA,AC,extra\nB,AC\n"))
+	if err != nil {
+		t.Fatal("Expected to read csv header successfully")
+	}
+
+	rows := readAll(t, reader)
+	if len(rows) != 2 || rows[0].Row != 2 || len(rows[0].Errors) != 1 || rows[0].Errors[0].Code != "row.Invalid" ||
+		len(rows[1].Errors) != 0 || rows[1].Flight.Name != "B" {
+		t.Errorf("Expected to report malformed record and keep reading, got %+v", rows)
+	}
+}
+
+func Test_Reader_CSV_Header_Failure(t *testing.T) {
+	_, err := NewReader(FormatCSV, strings.NewReader("name,gate\n"))
+	if !errors.Is(err, ErrUnknownColumn) {
+		t.Error("Expected to reject unknown column")
+	}
+
+	_, err = NewReader(FormatCSV, strings.NewReader("carrier\n"))
+	if !errors.Is(err, ErrMissingColumn) {
+		t.Error("Expected to reject header without name")
+	}
+}
+
+func Test_Reader_NDJSON_Success(t *testing.T) {
+	input := `{"name":"Morning","template":7}` + "\n\n" + `{"name":"Broken","gate":"B1"}` + "\n" + `{"name":`
+
+	reader, err := NewReader(FormatNDJSON, strings.NewReader(input))
+	if err != nil {
+		t.Fatal("Expected to make ndjson reader successfully")
+	}
+
+	rows := readAll(t, reader)
+	if len(rows) != 3 || rows[0].Flight.Template != 7 || len(rows[0].Errors) != 0 || rows[1].Row != 3 ||
+		len(rows[1].Errors) != 1 || rows[2].Row != 4 || len(rows[2].Errors) != 1 {
+		t.Errorf("Expected to read lines reporting malformed ones, got %+v", rows)
+	}
+}
+
+func Test_FormatOf_Success(t *testing.T) {
+	if FormatOf("application/x-ndjson") != FormatNDJSON || FormatOf("jsonl") != FormatNDJSON ||
+		FormatOf("text/csv") != FormatCSV || FormatOf("") != FormatCSV {
+		t.Error("Expected to detect schedule format")
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	gorp "github.com/go-gorp/gorp/v3"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/persistence/sqldb"
+)
+
+const (
+	// maxInsertRows is max rows inserted by one multi-row statement
+	maxInsertRows = 500
+)
+
+// insertRows inserts rows of column values into the table by multi-row statements of at most maxInsertRows rows
+func insertRows(ctx context.Context, db sqldb.DBInterface, trans *gorp.Transaction, table string, columns []string,
+	rows [][]interface{}) error {
+	placeholders := "(?" + strings.Repeat(", ?", len(columns)-1) + ")"
+	prefix := "INSERT INTO " + table + " (`" + strings.Join(columns, "`, `") + "`) VALUES "
+
+	for start := 0; start < len(rows); start += maxInsertRows {
+		end := start + maxInsertRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*len(columns))
+		for _, row := range rows[start:end] {
+			values = append(values, placeholders)
+			args = append(args, row...)
+		}
+
+		_, err := db.Exec(ctx, trans, prefix+strings.Join(values, ", "), args...)
+		if err != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error": err,
+				"table": table,
+				"rows":  end - start,
+			}).Error("Error inserting rows")
+			return err
+		}
+	}
+
+	return nil
+}
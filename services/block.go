@@ -1,13 +1,20 @@
 package services
 
 import (
-	"fmt"
+	"context"
+	"strings"
 
-	gorp "gopkg.in/gorp.v2"
+	gorp "github.com/go-gorp/gorp/v3"
 
 	"github.com/vsukhin/booking/logging"
 	"github.com/vsukhin/booking/models"
 	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/tracing"
+)
+
+var (
+	// seatNumberColumns contains seat number columns set by multi-row inserts
+	seatNumberColumns = []string{"block_id", "type", "number"}
 )
 
 // BlockService is a block service
@@ -17,9 +24,9 @@ type BlockService struct {
 
 // BlockServiceInterface is an interface for block service methods
 type BlockServiceInterface interface {
-	Create(trans *gorp.Transaction, block *models.Block) error
-	Delete(trans *gorp.Transaction, block *models.Block) error
-	ListAll(flightID int64) ([]models.Block, error)
+	Create(ctx context.Context, trans *gorp.Transaction, block *models.Block) error
+	Delete(ctx context.Context, trans *gorp.Transaction, block *models.Block) error
+	ListAll(ctx context.Context, tenantID string, flightID int64) ([]models.Block, error)
 }
 
 // NewBlockService is a constructor for block service
@@ -30,119 +37,130 @@ func NewBlockService(db sqldb.DBInterface) BlockServiceInterface {
 }
 
 // Create creates block
-func (blockService *BlockService) Create(trans *gorp.Transaction, block *models.Block) error {
-	err := blockService.db.Insert(trans, block)
+func (blockService *BlockService) Create(ctx context.Context, trans *gorp.Transaction, block *models.Block) error {
+	ctx, span := tracing.Start(ctx, "BlockService.Create")
+	defer span.End()
+
+	err := blockService.db.Insert(ctx, trans, block)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error": err,
 			"block": *block,
 		}).Error("Error creating block")
 		return err
 	}
 
-	var seatNumbers = []struct {
-		numbers   []int
-		blockType models.BlockType
-	}{
-		{
-			block.SideSeatNumbers,
-			models.BlockTypeSide,
-		},
-		{
-			block.MiddleSeatNumbers,
-			models.BlockTypeMiddle,
-		},
+	var rows [][]interface{}
+	for _, number := range block.SideSeatNumbers {
+		rows = append(rows, []interface{}{block.ID, models.BlockTypeSide, number})
+	}
+	for _, number := range block.MiddleSeatNumbers {
+		rows = append(rows, []interface{}{block.ID, models.BlockTypeMiddle, number})
 	}
 
-	for _, seats := range seatNumbers {
-		if len(seats.numbers) > 0 {
-			statement := ""
-			for _, number := range seats.numbers {
-				if statement != "" {
-					statement += " UNION ALL"
-				}
-				statement += fmt.Sprintf(" SELECT %v, %v, %v", block.ID, seats.blockType, number)
-			}
-
-			_, err = blockService.db.Exec(trans, "INSERT INTO seat_numbers (block_id, type, number)"+statement)
-			if err != nil {
-				logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-					"error": err,
-					"block": *block,
-				}).Error("Error inserting seat numbers")
-				return err
-			}
-		}
+	err = insertRows(ctx, blockService.db, trans, "seat_numbers", seatNumberColumns, rows)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"block": *block,
+		}).Error("Error inserting seat numbers")
+		return err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
 		"block": block,
 	}).Debug("Block successfully created")
 	return nil
 }
 
 // Delete deletes block
-func (blockService *BlockService) Delete(trans *gorp.Transaction, block *models.Block) error {
-	_, err := blockService.db.Exec(trans, "DELETE FROM seat_numbers WHERE block_id = ?", block.ID)
+func (blockService *BlockService) Delete(ctx context.Context, trans *gorp.Transaction, block *models.Block) error {
+	ctx, span := tracing.Start(ctx, "BlockService.Delete")
+	defer span.End()
+
+	_, err := blockService.db.Exec(ctx, trans, "DELETE FROM seat_numbers WHERE block_id = ?", block.ID)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error": err,
 			"block": *block,
 		}).Error("Error deleting seat numbers")
 		return err
 	}
 
-	_, err = blockService.db.Delete(trans, block)
+	_, err = blockService.db.Delete(ctx, trans, block)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error": err,
 			"block": *block,
 		}).Error("Error deleting block")
 		return err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
 		"block": *block,
 	}).Debug("Block successfully deleted")
 	return nil
 }
 
-// ListAll list all blocks according filtering, sorting, limitation parameters
-func (blockService *BlockService) ListAll(flightID int64) ([]models.Block, error) {
+// ListAll list all blocks of the flight with their seat numbers
+func (blockService *BlockService) ListAll(ctx context.Context, tenantID string,
+	flightID int64) ([]models.Block, error) {
+	ctx, span := tracing.Start(ctx, "BlockService.ListAll")
+	defer span.End()
+
 	var blocks []models.Block
 
-	_, err := blockService.db.Select(&blocks, "SELECT * FROM blocks WHERE flight_id = ? ORDER BY id", flightID)
+	_, err := blockService.db.Select(ctx, nil, &blocks,
+		"SELECT * FROM blocks WHERE tenant_id = ? AND flight_id = ? ORDER BY id",
+		tenantID, flightID)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":    err,
+			"tenantID": tenantID,
 			"flightID": flightID,
 		}).Error("Error returning blocks")
 		return nil, err
 	}
 
+	if len(blocks) == 0 {
+		return blocks, nil
+	}
+
+	ids := make([]interface{}, len(blocks))
+	positions := make(map[int64]int, len(blocks))
 	for i := range blocks {
-		_, err = blockService.db.Select(&blocks[i].SideSeatNumbers, "SELECT number FROM seat_numbers "+
-			"WHERE block_id = ? AND type = ? ORDER BY id", blocks[i].ID, models.BlockTypeSide)
-		if err != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-				"error":    err,
-				"flightID": flightID,
-			}).Error("Error returning side seat number")
-			return nil, err
+		ids[i] = blocks[i].ID
+		positions[blocks[i].ID] = i
+		blocks[i].SideSeatNumbers, blocks[i].MiddleSeatNumbers = nil, nil
+	}
+
+	var numbers []models.SeatNumber
+
+	_, err = blockService.db.Select(ctx, nil, &numbers, "SELECT * FROM seat_numbers WHERE block_id IN (?"+
+		strings.Repeat(", ?", len(ids)-1)+") ORDER BY id", ids...)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"flightID": flightID,
+		}).Error("Error returning seat numbers")
+		return nil, err
+	}
+
+	for _, number := range numbers {
+		i, ok := positions[number.BlockID]
+		if !ok {
+			continue
 		}
 
-		_, err = blockService.db.Select(&blocks[i].MiddleSeatNumbers, "SELECT number FROM seat_numbers "+
-			"WHERE block_id = ? AND type = ? ORDER BY id", blocks[i].ID, models.BlockTypeMiddle)
-		if err != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-				"error":    err,
-				"flightID": flightID,
-			}).Error("Error returning middle seat number")
-			return nil, err
+		if number.Type == models.BlockTypeSide {
+			blocks[i].SideSeatNumbers = append(blocks[i].SideSeatNumbers, number.Number)
+		} else {
+			blocks[i].MiddleSeatNumbers = append(blocks[i].MiddleSeatNumbers, number.Number)
 		}
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
 		"flightID": flightID,
 		"blocks":   blocks,
 	}).Debug("Blocks successfully returned")
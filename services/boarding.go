@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/tracing"
+)
+
+// BoardingService is a boarding service
+type BoardingService struct {
+	db sqldb.DBInterface
+}
+
+// BoardingServiceInterface is an interface for boarding service methods
+type BoardingServiceInterface interface {
+	Manifest(ctx context.Context, flight *models.Flight, query *models.BoardingQuery) (*models.BoardingManifest, error)
+}
+
+// NewBoardingService is a constructor for boarding service
+func NewBoardingService(db sqldb.DBInterface) BoardingServiceInterface {
+	return &BoardingService{db: db}
+}
+
+// Manifest lists passengers of the assigned seats by boarding zone of the strategy,
+// blocks of the flight own consecutive seat indexes in the order of the blocks
+func (boardingService *BoardingService) Manifest(ctx context.Context, flight *models.Flight,
+	query *models.BoardingQuery) (*models.BoardingManifest, error) {
+	ctx, span := tracing.Start(ctx, "BoardingService.Manifest")
+	defer span.End()
+
+	var seats []models.Seat
+	var checkins []models.Checkin
+
+	_, err := boardingService.db.Select(ctx, nil, &seats, "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+		"ORDER BY `index` ASC", flight.TenantID, flight.ID)
+	if err == nil {
+		_, err = boardingService.db.Select(ctx, nil, &checkins, "SELECT * FROM checkins WHERE tenant_id = ? "+
+			"AND flight_id = ?", flight.TenantID, flight.ID)
+	}
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+			"query":  *query,
+		}).Error("Error returning seats for boarding manifest")
+		return nil, err
+	}
+
+	checked := make(map[int]models.Checkin, len(checkins))
+	for _, checkin := range checkins {
+		checked[checkin.SeatIndex] = checkin
+	}
+
+	// last contains the last seat index of each block and offsets contain rows of the blocks ahead of it
+	last := make([]int, len(flight.Blocks))
+	offsets := make([]int, len(flight.Blocks))
+	index, rows, firstRow := 0, 0, 1
+	for i, layout := range flight.Blocks {
+		lines := 0
+		for _, number := range layout.SideSeatNumbers {
+			lines += number
+		}
+		for _, number := range layout.MiddleSeatNumbers {
+			lines += number
+		}
+		if i == query.PremiumBlocks {
+			firstRow = rows + 1
+		}
+
+		offsets[i] = rows
+		rows += layout.Rows
+		index += layout.Rows * lines
+		last[i] = index
+	}
+
+	places := make([]models.BoardingPlace, len(seats))
+	for i, seat := range seats {
+		current := sort.SearchInts(last, seat.Index)
+		if current == len(last) {
+			current = len(last) - 1
+		}
+
+		places[i] = models.BoardingPlace{Block: current + 1, Row: offsets[current] + seat.Row, Type: seat.Type}
+	}
+
+	zones := map[int][]models.BoardingPassenger{}
+	manifest := &models.BoardingManifest{FlightID: flight.ID, Strategy: query.Strategy,
+		PremiumBlocks: query.PremiumBlocks, Zones: []models.BoardingZone{}}
+
+	for i, seat := range seats {
+		if !seat.Assigned {
+			continue
+		}
+
+		place := places[i]
+		place.Blocks, place.Rows, place.FirstRow = len(flight.Blocks), rows, firstRow
+
+		passenger := models.BoardingPassenger{Index: seat.Index, Block: place.Block, Row: seat.Row, Line: seat.Line,
+			Type: seat.Type, Owner: seat.Owner}
+		if checkin, ok := checked[seat.Index]; ok {
+			passenger.Passenger = strings.TrimSpace(checkin.FirstName + " " + checkin.LastName)
+			passenger.CheckedIn = true
+			passenger.Sequence = checkin.Sequence
+		}
+
+		zone := query.Zone(place)
+		zones[zone] = append(zones[zone], passenger)
+		manifest.Passengers++
+	}
+
+	for zone, passengers := range zones {
+		sort.SliceStable(passengers, func(i, j int) bool {
+			if passengers[i].Block != passengers[j].Block {
+				return passengers[i].Block > passengers[j].Block
+			}
+			if passengers[i].Row != passengers[j].Row {
+				return passengers[i].Row > passengers[j].Row
+			}
+			return passengers[i].Line < passengers[j].Line
+		})
+		manifest.Zones = append(manifest.Zones, models.BoardingZone{Zone: zone, Passengers: passengers})
+	}
+	sort.Slice(manifest.Zones, func(i, j int) bool { return manifest.Zones[i].Zone < manifest.Zones[j].Zone })
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"flightID":   flight.ID,
+		"query":      *query,
+		"passengers": manifest.Passengers,
+	}).Debug("Boarding manifest successfully made")
+	return manifest, nil
+}
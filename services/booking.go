@@ -0,0 +1,503 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	gorp "github.com/go-gorp/gorp/v3"
+
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/cache"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/tracing"
+)
+
+// flightCapacity contains passenger counts of the flight
+type flightCapacity struct {
+	flight   models.Flight
+	seats    int64
+	assigned int64
+	unseated int64
+}
+
+// loadCapacity counts passengers of the flight, in the transaction it locks the flight serializing
+// seat assignments and bookings of the flight
+func loadCapacity(ctx context.Context, db sqldb.DBInterface, trans *gorp.Transaction, tenantID string,
+	flightID int64) (*flightCapacity, error) {
+	var result flightCapacity
+
+	query := "SELECT * FROM flights WHERE tenant_id = ? AND id = ?"
+	if trans != nil {
+		query += " FOR UPDATE"
+	}
+
+	err := db.SelectOne(ctx, trans, &result.flight, query, tenantID, flightID)
+	if err == nil {
+		result.seats, err = db.SelectInt(ctx, trans, "SELECT COUNT(*) FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+			"AND blocked = false", tenantID, flightID)
+	}
+	if err == nil {
+		result.assigned, err = db.SelectInt(ctx, trans, "SELECT COUNT(*) FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+			"AND assigned = true", tenantID, flightID)
+	}
+	if err == nil {
+		result.unseated, err = db.SelectInt(ctx, trans, "SELECT COUNT(*) FROM bookings WHERE tenant_id = ? "+
+			"AND flight_id = ? AND status = ?", tenantID, flightID, models.BookingStatusConfirmed)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrFlightNotFound
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error counting flight passengers")
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// admit checks that one more passenger fits the flight, seated passenger also needs free seat
+func (capacity *flightCapacity) admit(seated bool) error {
+	if capacity.flight.Status == models.FlightStatusClosed {
+		return apperrors.ErrFlightClosed
+	}
+
+	if seated && capacity.assigned >= capacity.seats {
+		return apperrors.ErrFlightFull
+	}
+
+	if capacity.assigned+capacity.unseated >= capacity.flight.Limit(capacity.seats) {
+		return apperrors.ErrOverbookingLimit
+	}
+
+	return nil
+}
+
+// change admits seat newly assigned by the change of the seat and counts the change,
+// owner of the assigned seat can't be changed on the closed flight either
+func (capacity *flightCapacity) change(seat *models.Seat, changed *models.Seat) error {
+	if changed.Assigned && !seat.Assigned {
+		err := capacity.admit(true)
+		if err != nil {
+			return err
+		}
+	}
+	if changed.Assigned && seat.Assigned && changed.Owner != seat.Owner &&
+		capacity.flight.Status == models.FlightStatusClosed {
+		return apperrors.ErrFlightClosed
+	}
+
+	if changed.Assigned != seat.Assigned {
+		if changed.Assigned {
+			capacity.assigned++
+		} else {
+			capacity.assigned--
+		}
+	}
+	if changed.Blocked != seat.Blocked {
+		if changed.Blocked {
+			capacity.seats--
+		} else {
+			capacity.seats++
+		}
+	}
+
+	return nil
+}
+
+// BookingService is a booking service
+type BookingService struct {
+	db           sqldb.DBInterface
+	cacheManager cache.ManagerInterface
+}
+
+// BookingServiceInterface is an interface for booking service methods
+type BookingServiceInterface interface {
+	Create(ctx context.Context, booking *models.Booking) error
+	Seat(ctx context.Context, booking *models.Booking) (*models.Seat, error)
+	Retrieve(ctx context.Context, tenantID string, flightID int64, id int64) (*models.Booking, error)
+	ListAll(ctx context.Context, tenantID string, flightID int64) ([]models.Booking, error)
+	Close(ctx context.Context, flight *models.Flight) (*models.DeniedBoardingReport, error)
+	DeniedBoarding(ctx context.Context, flight *models.Flight) (*models.DeniedBoardingReport, error)
+}
+
+// NewBookingService is a constructor for booking service
+func NewBookingService(db sqldb.DBInterface, cacheManager cache.ManagerInterface) BookingServiceInterface {
+	db.AddTableWithName(models.Booking{}, "bookings").SetKeys(true, "ID").SetVersionCol("Version")
+
+	return &BookingService{db: db, cacheManager: cacheManager}
+}
+
+// Create creates confirmed booking without seat if it fits the overbooking limit of the flight
+func (bookingService *BookingService) Create(ctx context.Context, booking *models.Booking) error {
+	ctx, span := tracing.Start(ctx, "BookingService.Create")
+	defer span.End()
+
+	trans, err := bookingService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"booking": *booking,
+		}).Error("Error creating transaction")
+		return err
+	}
+
+	capacity, err := loadCapacity(ctx, bookingService.db, trans, booking.TenantID, booking.FlightID)
+	if err == nil {
+		err = capacity.admit(false)
+	}
+	if err != nil {
+		bookingService.rollback(ctx, trans, booking.TenantID, booking.FlightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"booking": *booking,
+		}).Error("Booking is not admitted")
+		return err
+	}
+
+	now := time.Now().Unix()
+	booking.Status = models.BookingStatusConfirmed
+	booking.CreatedAt = now
+	booking.UpdatedAt = now
+
+	err = bookingService.db.Insert(ctx, trans, booking)
+	if err != nil {
+		bookingService.rollback(ctx, trans, booking.TenantID, booking.FlightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"booking": *booking,
+		}).Error("Error creating booking")
+		return err
+	}
+
+	err = bookingService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"booking": *booking,
+		}).Error("Error committing transaction")
+		return err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"booking": *booking,
+	}).Debug("Booking successfully created")
+	return nil
+}
+
+// Seat assigns first free seat to the confirmed booking
+func (bookingService *BookingService) Seat(ctx context.Context, booking *models.Booking) (*models.Seat, error) {
+	ctx, span := tracing.Start(ctx, "BookingService.Seat")
+	defer span.End()
+
+	var locked models.Booking
+	var seat models.Seat
+
+	if booking.Status != models.BookingStatusConfirmed {
+		return nil, apperrors.ErrBookingNotConfirmed
+	}
+
+	trans, err := bookingService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"booking": *booking,
+		}).Error("Error creating transaction")
+		return nil, err
+	}
+
+	capacity, err := loadCapacity(ctx, bookingService.db, trans, booking.TenantID, booking.FlightID)
+	if err == nil && capacity.flight.Status == models.FlightStatusClosed {
+		err = apperrors.ErrFlightClosed
+	}
+	if err == nil {
+		err = bookingService.db.SelectOne(ctx, trans, &locked, "SELECT * FROM bookings WHERE tenant_id = ? "+
+			"AND flight_id = ? AND id = ? FOR UPDATE", booking.TenantID, booking.FlightID, booking.ID)
+		if err == nil && (locked.Version != booking.Version || locked.Status != models.BookingStatusConfirmed) {
+			err = gorp.OptimisticLockError{TableName: "bookings", Keys: []interface{}{booking.ID}, RowExists: true,
+				LocalVersion: booking.Version}
+		}
+	}
+	if err == nil {
+		err = bookingService.db.SelectOne(ctx, trans, &seat, freeSeatQuery+" FOR UPDATE", booking.TenantID,
+			booking.FlightID)
+		if err == sql.ErrNoRows {
+			err = apperrors.ErrFlightFull
+		}
+	}
+	if err != nil {
+		bookingService.rollback(ctx, trans, booking.TenantID, booking.FlightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"booking": *booking,
+		}).Error("Error seating booking")
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+
+	seat.Assigned = true
+	seat.Owner = booking.Owner
+	seat.UpdatedAt = now
+	_, err = bookingService.db.Update(ctx, trans, &seat)
+	if err == nil {
+		locked.Status = models.BookingStatusSeated
+		locked.SeatIndex = seat.Index
+		locked.UpdatedAt = now
+		_, err = bookingService.db.Update(ctx, trans, &locked)
+	}
+	if err != nil {
+		bookingService.rollback(ctx, trans, booking.TenantID, booking.FlightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"booking": *booking,
+			"seat":    seat,
+		}).Error("Error seating booking")
+		return nil, err
+	}
+
+	err = bookingService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"booking": *booking,
+		}).Error("Error committing transaction")
+		return nil, err
+	}
+
+	*booking = locked
+	bookingService.cacheManager.Delete(cache.SeatSummaryKey(booking.TenantID, booking.FlightID))
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"booking": *booking,
+		"seat":    seat,
+	}).Debug("Booking successfully seated")
+	return &seat, nil
+}
+
+// Retrieve retrieves booking
+func (bookingService *BookingService) Retrieve(ctx context.Context, tenantID string, flightID int64,
+	id int64) (*models.Booking, error) {
+	ctx, span := tracing.Start(ctx, "BookingService.Retrieve")
+	defer span.End()
+
+	var booking models.Booking
+
+	err := bookingService.db.SelectOne(ctx, nil, &booking, "SELECT * FROM bookings WHERE tenant_id = ? AND flight_id = ? "+
+		"AND id = ?", tenantID, flightID, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"tenantID": tenantID,
+				"flightID": flightID,
+				"id":       id,
+			}).Error("Booking not found")
+			return nil, apperrors.ErrBookingNotFound
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+			"id":       id,
+		}).Error("Error returning booking")
+		return nil, err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"booking": booking,
+	}).Debug("Booking successfully retrieved")
+	return &booking, nil
+}
+
+// ListAll lists bookings of the flight
+func (bookingService *BookingService) ListAll(ctx context.Context, tenantID string,
+	flightID int64) ([]models.Booking, error) {
+	ctx, span := tracing.Start(ctx, "BookingService.ListAll")
+	defer span.End()
+
+	return bookingService.list(ctx, nil, tenantID, flightID, "")
+}
+
+// list lists bookings of the flight with the status or all of them, in the transaction of the locked flight
+func (bookingService *BookingService) list(ctx context.Context, trans *gorp.Transaction, tenantID string,
+	flightID int64, status models.BookingStatus) ([]models.Booking, error) {
+	var bookings []models.Booking
+	var err error
+
+	if status == "" {
+		_, err = bookingService.db.Select(ctx, trans, &bookings, "SELECT * FROM bookings WHERE tenant_id = ? "+
+			"AND flight_id = ? ORDER BY id ASC", tenantID, flightID)
+	} else {
+		_, err = bookingService.db.Select(ctx, trans, &bookings, "SELECT * FROM bookings WHERE tenant_id = ? "+
+			"AND flight_id = ? AND status = ? ORDER BY id ASC", tenantID, flightID, status)
+	}
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+			"status":   status,
+		}).Error("Error returning bookings")
+		return nil, err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"flightID": flightID,
+		"status":   status,
+		"bookings": bookings,
+	}).Debug("Bookings successfully listed")
+	return bookings, nil
+}
+
+// Close closes the flight denying boarding to the bookings left unseated
+func (bookingService *BookingService) Close(ctx context.Context,
+	flight *models.Flight) (*models.DeniedBoardingReport, error) {
+	ctx, span := tracing.Start(ctx, "BookingService.Close")
+	defer span.End()
+
+	if flight.Status == models.FlightStatusClosed {
+		return nil, apperrors.ErrFlightClosed
+	}
+
+	trans, err := bookingService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error creating transaction")
+		return nil, err
+	}
+
+	capacity, err := loadCapacity(ctx, bookingService.db, trans, flight.TenantID, flight.ID)
+	if err == nil && capacity.flight.Version != flight.Version {
+		err = gorp.OptimisticLockError{TableName: "flights", Keys: []interface{}{flight.ID}, RowExists: true,
+			LocalVersion: flight.Version}
+	}
+	if err != nil {
+		bookingService.rollback(ctx, trans, flight.TenantID, flight.ID)
+		return nil, err
+	}
+
+	unseated, err := bookingService.list(ctx, trans, flight.TenantID, flight.ID, models.BookingStatusConfirmed)
+	if err != nil {
+		bookingService.rollback(ctx, trans, flight.TenantID, flight.ID)
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	for i := range unseated {
+		unseated[i].Status = models.BookingStatusDenied
+		unseated[i].UpdatedAt = now
+
+		_, err = bookingService.db.Update(ctx, trans, &unseated[i])
+		if err != nil {
+			bookingService.rollback(ctx, trans, flight.TenantID, flight.ID)
+
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":   err,
+				"booking": unseated[i],
+			}).Error("Error denying boarding")
+			return nil, err
+		}
+	}
+
+	locked := capacity.flight
+	locked.Status = models.FlightStatusClosed
+	_, err = bookingService.db.Update(ctx, trans, &locked)
+	if err != nil {
+		bookingService.rollback(ctx, trans, flight.TenantID, flight.ID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error closing flight")
+		return nil, err
+	}
+
+	err = bookingService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error committing transaction")
+		return nil, err
+	}
+
+	flight.Status = locked.Status
+	flight.Version = locked.Version
+	bookingService.cacheManager.Delete(cache.FlightKey(flight.TenantID, flight.ID))
+
+	report := bookingService.report(capacity, unseated)
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"flight": *flight,
+		"report": *report,
+	}).Debug("Flight successfully closed")
+	return report, nil
+}
+
+// DeniedBoarding reports bookings left unseated on the closed flight
+func (bookingService *BookingService) DeniedBoarding(ctx context.Context,
+	flight *models.Flight) (*models.DeniedBoardingReport, error) {
+	ctx, span := tracing.Start(ctx, "BookingService.DeniedBoarding")
+	defer span.End()
+
+	if flight.Status != models.FlightStatusClosed {
+		return nil, apperrors.ErrFlightNotClosed
+	}
+
+	capacity, err := loadCapacity(ctx, bookingService.db, nil, flight.TenantID, flight.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	denied, err := bookingService.list(ctx, nil, flight.TenantID, flight.ID, models.BookingStatusDenied)
+	if err != nil {
+		return nil, err
+	}
+
+	return bookingService.report(capacity, denied), nil
+}
+
+func (bookingService *BookingService) report(capacity *flightCapacity,
+	denied []models.Booking) *models.DeniedBoardingReport {
+	report := &models.DeniedBoardingReport{
+		FlightID:    capacity.flight.ID,
+		Overbooking: capacity.flight.Overbooking,
+		Seats:       capacity.seats,
+		Limit:       capacity.flight.Limit(capacity.seats),
+		Seated:      capacity.assigned,
+		Denied:      denied,
+	}
+
+	if report.Denied == nil {
+		report.Denied = []models.Booking{}
+	}
+
+	return report
+}
+
+func (bookingService *BookingService) rollback(ctx context.Context, trans *gorp.Transaction, tenantID string,
+	flightID int64) {
+	err := bookingService.db.Rollback(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error rollbacking transaction")
+	}
+}
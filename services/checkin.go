@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	gorp "github.com/go-gorp/gorp/v3"
+
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/bcbp"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/tracing"
+)
+
+const (
+	// compartmentEconomy is compartment code of the boarding pass
+	compartmentEconomy = "Y"
+	// referenceLength is length of booking reference generated for check-in without one
+	referenceLength = 6
+)
+
+// CheckinService is a check-in service
+type CheckinService struct {
+	db sqldb.DBInterface
+}
+
+// CheckinServiceInterface is an interface for check-in service methods
+type CheckinServiceInterface interface {
+	Create(ctx context.Context, checkin *models.Checkin) error
+	Retrieve(ctx context.Context, tenantID string, flightID int64, id int64) (*models.Checkin, error)
+	BoardingPass(flight *models.Flight, checkin *models.Checkin) *models.BoardingPass
+}
+
+// NewCheckinService is a constructor for check-in service
+func NewCheckinService(db sqldb.DBInterface) CheckinServiceInterface {
+	db.AddTableWithName(models.Checkin{}, "checkins").SetKeys(true, "ID").SetVersionCol("Version")
+
+	return &CheckinService{db: db}
+}
+
+// Create checks in the owner of the assigned seat of the open flight issuing next boarding sequence number
+func (checkinService *CheckinService) Create(ctx context.Context, checkin *models.Checkin) error {
+	ctx, span := tracing.Start(ctx, "CheckinService.Create")
+	defer span.End()
+
+	var flight models.Flight
+	var seat models.Seat
+
+	trans, err := checkinService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"checkin": *checkin,
+		}).Error("Error creating transaction")
+		return err
+	}
+
+	err = checkinService.db.SelectOne(ctx, trans, &flight,
+		"SELECT * FROM flights WHERE tenant_id = ? AND id = ? FOR UPDATE",
+		checkin.TenantID, checkin.FlightID)
+	if err == sql.ErrNoRows {
+		err = apperrors.ErrFlightNotFound
+	}
+	if err == nil && flight.Status == models.FlightStatusClosed {
+		err = apperrors.ErrFlightClosed
+	}
+	if err == nil {
+		err = checkinService.db.SelectOne(ctx, trans, &seat, "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+			"AND `index` = ? FOR UPDATE", checkin.TenantID, checkin.FlightID, checkin.SeatIndex)
+		if err == sql.ErrNoRows {
+			err = apperrors.ErrSeatNotFound
+		}
+	}
+	if err == nil && !seat.Assigned {
+		err = apperrors.ErrSeatNotAssigned
+	}
+	if err == nil && seat.Owner != checkin.Owner {
+		err = apperrors.ErrSeatTaken
+	}
+	if err != nil {
+		checkinService.rollback(ctx, trans, checkin)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"checkin": *checkin,
+		}).Error("Error checking seat for check-in")
+		return err
+	}
+
+	checked, err := checkinService.db.SelectInt(ctx, trans,
+		"SELECT COUNT(*) FROM checkins WHERE tenant_id = ? AND flight_id = ? "+
+			"AND seat_index = ?", checkin.TenantID, checkin.FlightID, checkin.SeatIndex)
+	if err == nil && checked != 0 {
+		err = apperrors.ErrCheckinExists
+	}
+	if err != nil {
+		checkinService.rollback(ctx, trans, checkin)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"checkin": *checkin,
+		}).Error("Error checking existing check-in")
+		return err
+	}
+
+	sequence, err := checkinService.db.SelectInt(ctx, trans, "SELECT COUNT(*) FROM checkins WHERE tenant_id = ? "+
+		"AND flight_id = ?", checkin.TenantID, checkin.FlightID)
+	if err != nil {
+		checkinService.rollback(ctx, trans, checkin)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"checkin": *checkin,
+		}).Error("Error counting check-ins")
+		return err
+	}
+
+	checkin.Row = seat.Row
+	checkin.Line = seat.Line
+	checkin.Sequence = int(sequence) + 1
+	checkin.CreatedAt = time.Now().Unix()
+
+	err = checkinService.db.Insert(ctx, trans, checkin)
+	if err != nil {
+		checkinService.rollback(ctx, trans, checkin)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"checkin": *checkin,
+		}).Error("Error creating check-in")
+		return err
+	}
+
+	err = checkinService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"checkin": *checkin,
+		}).Error("Error committing transaction")
+		return err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"checkin": *checkin,
+	}).Debug("Passenger successfully checked in")
+	return nil
+}
+
+func (checkinService *CheckinService) rollback(ctx context.Context, trans *gorp.Transaction, checkin *models.Checkin) {
+	err := checkinService.db.Rollback(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"checkin": *checkin,
+		}).Error("Error rollbacking transaction")
+	}
+}
+
+// Retrieve retrieves check-in
+func (checkinService *CheckinService) Retrieve(ctx context.Context, tenantID string, flightID int64,
+	id int64) (*models.Checkin, error) {
+	ctx, span := tracing.Start(ctx, "CheckinService.Retrieve")
+	defer span.End()
+
+	var checkin models.Checkin
+
+	err := checkinService.db.SelectOne(ctx, nil, &checkin, "SELECT * FROM checkins WHERE tenant_id = ? AND flight_id = ? "+
+		"AND id = ?", tenantID, flightID, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"tenantID": tenantID,
+				"flightID": flightID,
+				"id":       id,
+			}).Error("Check-in not found")
+			return nil, apperrors.ErrCheckinNotFound
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+			"id":       id,
+		}).Error("Error returning check-in")
+		return nil, err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"checkin": checkin,
+	}).Debug("Check-in successfully retrieved")
+	return &checkin, nil
+}
+
+// BoardingPass makes boarding pass of the check-in, check-in without booking reference gets one from its id
+func (checkinService *CheckinService) BoardingPass(flight *models.Flight,
+	checkin *models.Checkin) *models.BoardingPass {
+	reference := checkin.PNR
+	if reference == "" {
+		reference = strings.ToUpper(strconv.FormatInt(checkin.ID, 36))
+		if len(reference) < referenceLength {
+			reference = strings.Repeat("0", referenceLength-len(reference)) + reference
+		}
+	}
+
+	var date time.Time
+	if flight.DepartsAt != 0 {
+		date = time.Unix(flight.DepartsAt, 0).UTC()
+	}
+
+	return &models.BoardingPass{
+		CheckinID:    checkin.ID,
+		FlightID:     flight.ID,
+		Passenger:    strings.TrimSpace(checkin.FirstName + " " + checkin.LastName),
+		PNR:          reference,
+		Carrier:      flight.Carrier,
+		FlightNumber: flight.Number,
+		Origin:       flight.Origin,
+		Destination:  flight.Destination,
+		DepartsAt:    flight.DepartsAt,
+		Seat:         fmt.Sprintf("%d%s", checkin.Row, checkin.Line),
+		Sequence:     checkin.Sequence,
+		BCBP: bcbp.Encode(bcbp.Pass{
+			LastName:     checkin.LastName,
+			FirstName:    checkin.FirstName,
+			PNR:          reference,
+			Origin:       flight.Origin,
+			Destination:  flight.Destination,
+			Carrier:      flight.Carrier,
+			FlightNumber: flight.Number,
+			Date:         date,
+			Compartment:  compartmentEconomy,
+			Row:          checkin.Row,
+			Line:         checkin.Line,
+			Sequence:     checkin.Sequence,
+		}),
+	}
+}
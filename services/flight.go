@@ -1,15 +1,19 @@
 package services
 
 import (
-	"errors"
-	"strings"
+	"context"
+	"database/sql"
+	"sort"
 	"time"
 
-	gorp "gopkg.in/gorp.v2"
+	gorp "github.com/go-gorp/gorp/v3"
 
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/cache"
 	"github.com/vsukhin/booking/logging"
 	"github.com/vsukhin/booking/models"
 	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/tracing"
 )
 
 // FlightService is a flight service
@@ -17,68 +21,114 @@ type FlightService struct {
 	db           sqldb.DBInterface
 	blockService BlockServiceInterface
 	seatService  SeatServiceInterface
+	cacheManager cache.ManagerInterface
 }
 
 // FlightServiceInterface is an interface for flight service methods
 type FlightServiceInterface interface {
-	Create(flight *models.Flight) error
-	Retrieve(id int64) (*models.Flight, error)
-	Delete(flight *models.Flight) error
-	ListAll(filtering string, sorting string, limitation string) ([]models.Flight, error)
-	GetMeta(filtering string) (*models.FlightMeta, error)
-	SetBlocks(trans *gorp.Transaction, id int64, blocks []models.Block) error
+	Create(ctx context.Context, flight *models.Flight) error
+	CreateAll(ctx context.Context, flights []*models.Flight) error
+	Retrieve(ctx context.Context, tenantID string, id int64) (*models.Flight, error)
+	Update(ctx context.Context, flight *models.Flight) error
+	Delete(ctx context.Context, flight *models.Flight) error
+	ListAll(ctx context.Context, tenantID string, filtering string, sorting string,
+		limitation string) ([]models.Flight, error)
+	GetMeta(ctx context.Context, tenantID string, filtering string) (*models.FlightMeta, error)
+	SetBlocks(ctx context.Context, trans *gorp.Transaction, tenantID string, id int64, blocks []models.Block) error
+	Relayout(ctx context.Context, flight *models.Flight, layout *models.FlightLayout) (*models.FlightLayoutResult, error)
 }
 
 // NewFlightService is a constructor for flight service
-func NewFlightService(db sqldb.DBInterface, blockService BlockServiceInterface,
-	seatService SeatServiceInterface) FlightServiceInterface {
-	db.AddTableWithName(models.Flight{}, "flights").SetKeys(true, "ID")
+func NewFlightService(db sqldb.DBInterface, blockService BlockServiceInterface, seatService SeatServiceInterface,
+	cacheManager cache.ManagerInterface) FlightServiceInterface {
+	db.AddTableWithName(models.Flight{}, "flights").SetKeys(true, "ID").SetVersionCol("Version")
 
-	return &FlightService{db: db, blockService: blockService, seatService: seatService}
+	return &FlightService{db: db, blockService: blockService, seatService: seatService, cacheManager: cacheManager}
 }
 
 // Create creates flight
-func (flightService *FlightService) Create(flight *models.Flight) error {
-	trans, err := flightService.db.Begin()
+func (flightService *FlightService) Create(ctx context.Context, flight *models.Flight) error {
+	ctx, span := tracing.Start(ctx, "FlightService.Create")
+	defer span.End()
+
+	return flightService.CreateAll(ctx, []*models.Flight{flight})
+}
+
+// CreateAll creates flights in one transaction
+func (flightService *FlightService) CreateAll(ctx context.Context, flights []*models.Flight) error {
+	ctx, span := tracing.Start(ctx, "FlightService.CreateAll")
+	defer span.End()
+
+	trans, err := flightService.db.Begin(ctx)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"error":  err,
-			"flight": *flight,
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"flights": len(flights),
 		}).Error("Error creating transaction")
 		return err
 	}
 
-	err = flightService.db.Insert(trans, flight)
-	if err != nil {
-		trErr := flightService.db.Rollback(trans)
-		if trErr != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-				"error":  trErr,
-				"flight": *flight,
-			}).Error("Error rollbacking transaction")
+	for _, flight := range flights {
+		err = flightService.create(ctx, trans, flight)
+		if err != nil {
+			trErr := flightService.db.Rollback(ctx, trans)
+			if trErr != nil {
+				logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+					"error":  trErr,
+					"flight": *flight,
+				}).Error("Error rollbacking transaction")
+			}
+			return err
 		}
+	}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+	err = flightService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":   err,
+			"flights": len(flights),
+		}).Error("Error committing transaction")
+		return err
+	}
+
+	for _, flight := range flights {
+		logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+			"flight": *flight,
+		}).Debug("Flight successfully created")
+	}
+	return nil
+}
+
+// create creates flight with its blocks and seats in the transaction, seats share creation time of the flight
+func (flightService *FlightService) create(ctx context.Context, trans *gorp.Transaction, flight *models.Flight) error {
+	if flight.CreatedAt == 0 {
+		flight.CreatedAt = time.Now().Unix()
+	}
+
+	err := flightService.db.Insert(ctx, trans, flight)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":  err,
 			"flight": *flight,
 		}).Error("Error creating flight")
 		return err
 	}
 
-	err = flightService.SetBlocks(trans, flight.ID, flight.Blocks)
+	err = flightService.SetBlocks(ctx, trans, flight.TenantID, flight.ID, flight.Blocks)
 	if err != nil {
-		trErr := flightService.db.Rollback(trans)
-		if trErr != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-				"error":  trErr,
-				"flight": *flight,
-			}).Error("Error rollbacking transaction")
-		}
 		return err
 	}
 
+	return flightService.seatService.CreateAll(ctx, trans, layoutSeats(flight.TenantID, flight.ID, flight.Blocks,
+		flight.CreatedAt))
+}
+
+// layoutSeats generates seats of the blocks created at the same time
+func layoutSeats(tenantID string, flightID int64, blocks []models.Block, createdAt int64) []models.Seat {
+	var all []models.Seat
+
 	index := 0
-	for _, block := range flight.Blocks {
+	for _, block := range blocks {
 		for i := 0; i < block.Rows; i++ {
 			var seats []models.Seat
 
@@ -95,12 +145,13 @@ func (flightService *FlightService) Create(flight *models.Flight) error {
 				}
 
 				seats = append(seats, models.Seat{
-					FlightID:  flight.ID,
+					TenantID:  tenantID,
+					FlightID:  flightID,
 					Index:     index + 1,
 					Type:      seatType,
 					Row:       i + 1,
-					Line:      string(line),
-					CreatedAt: time.Now().Unix(),
+					Line:      string(rune(line)),
+					CreatedAt: createdAt,
 				})
 
 				line++
@@ -118,12 +169,13 @@ func (flightService *FlightService) Create(flight *models.Flight) error {
 					}
 
 					seats = append(seats, models.Seat{
-						FlightID:  flight.ID,
+						TenantID:  tenantID,
+						FlightID:  flightID,
 						Index:     index + 1,
 						Type:      seatType,
 						Row:       i + 1,
-						Line:      string(line),
-						CreatedAt: time.Now().Unix(),
+						Line:      string(rune(line)),
+						CreatedAt: createdAt,
 					})
 
 					line++
@@ -143,104 +195,117 @@ func (flightService *FlightService) Create(flight *models.Flight) error {
 				}
 
 				seats = append(seats, models.Seat{
-					FlightID:  flight.ID,
+					TenantID:  tenantID,
+					FlightID:  flightID,
 					Index:     index + 1,
 					Type:      seatType,
 					Row:       i + 1,
-					Line:      string(line),
-					CreatedAt: time.Now().Unix(),
+					Line:      string(rune(line)),
+					CreatedAt: createdAt,
 				})
 
 				line++
 				index++
 			}
 
-			for j := range seats {
-				err = flightService.seatService.Create(trans, &seats[j])
-				if err != nil {
-					trErr := flightService.db.Rollback(trans)
-					if trErr != nil {
-						logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-							"error":  trErr,
-							"flight": *flight,
-						}).Error("Error rollbacking transaction")
-					}
-					return err
-				}
-			}
+			all = append(all, seats...)
 		}
 	}
 
-	err = flightService.db.Commit(trans)
-	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"error":  err,
-			"flight": *flight,
-		}).Error("Error committing transaction")
-		return err
-	}
-
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
-		"flight": *flight,
-	}).Debug("Flight successfully created")
-	return nil
+	return all
 }
 
-// Retrieve retrieves flight
-func (flightService *FlightService) Retrieve(id int64) (*models.Flight, error) {
-	obj, err := flightService.db.Get(nil, models.Flight{}, id)
+// Retrieve retrieves flight with its blocks, cached flight is returned if any
+func (flightService *FlightService) Retrieve(ctx context.Context, tenantID string, id int64) (*models.Flight, error) {
+	ctx, span := tracing.Start(ctx, "FlightService.Retrieve")
+	defer span.End()
+
+	var flight models.Flight
+
+	key := cache.FlightKey(tenantID, id)
+	if flightService.cacheManager.Get(key, &flight) {
+		logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+			"tenantID": tenantID,
+			"id":       id,
+			"flight":   flight,
+		}).Debug("Flight successfully retrieved from cache")
+		return &flight, nil
+	}
+
+	err := flightService.db.SelectOne(ctx, nil, &flight, "SELECT * FROM flights WHERE tenant_id = ? AND id = ?",
+		tenantID, id)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"error": err,
-			"id":    id,
+		if err == sql.ErrNoRows {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"tenantID": tenantID,
+				"id":       id,
+			}).Error("Flight not found")
+			return nil, apperrors.ErrFlightNotFound
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"id":       id,
 		}).Error("Error retrieving flight")
 		return nil, err
 	}
 
-	if obj == nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"id": id,
-		}).Error("Flight not found")
-		return nil, nil
+	flight.Blocks, err = flightService.blockService.ListAll(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
 	}
 
-	flight, ok := obj.(*models.Flight)
-	if !ok {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
-			"id":  id,
-			"obj": obj,
-		}).Error("Error returning flight")
-		return nil, errors.New("Flight not valid")
-	}
+	flightService.cacheManager.Set(key, flight)
 
-	flight.Blocks, err = flightService.blockService.ListAll(id)
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"id":       id,
+		"flight":   flight,
+	}).Debug("Flight successfully retrieved")
+	return &flight, nil
+}
+
+// Update updates flight, cached flight is dropped even if update fails as it may be stale
+func (flightService *FlightService) Update(ctx context.Context, flight *models.Flight) error {
+	ctx, span := tracing.Start(ctx, "FlightService.Update")
+	defer span.End()
+
+	_, err := flightService.db.Update(ctx, nil, flight)
+	flightService.cacheManager.Delete(cache.FlightKey(flight.TenantID, flight.ID))
 	if err != nil {
-		return nil, err
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error updating flight")
+		return err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
-		"id":     id,
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
 		"flight": *flight,
-	}).Debug("Flight successfully retrieved")
-	return flight, nil
+	}).Debug("Flight successfully updated")
+	return nil
 }
 
 // Delete deletes flight
-func (flightService *FlightService) Delete(flight *models.Flight) error {
-	trans, err := flightService.db.Begin()
+func (flightService *FlightService) Delete(ctx context.Context, flight *models.Flight) error {
+	ctx, span := tracing.Start(ctx, "FlightService.Delete")
+	defer span.End()
+
+	trans, err := flightService.db.Begin(ctx)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":  err,
 			"flight": *flight,
 		}).Error("Error creating transaction")
 		return err
 	}
 
-	err = flightService.seatService.DeleteAll(trans, flight.ID)
+	err = flightService.seatService.DeleteAll(ctx, trans, flight.TenantID, flight.ID)
 	if err != nil {
-		trErr := flightService.db.Rollback(trans)
+		trErr := flightService.db.Rollback(ctx, trans)
 		if trErr != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 				"error":  trErr,
 				"flight": *flight,
 			}).Error("Error rollbacking transaction")
@@ -249,11 +314,11 @@ func (flightService *FlightService) Delete(flight *models.Flight) error {
 	}
 
 	for i := range flight.Blocks {
-		err = flightService.blockService.Delete(trans, &flight.Blocks[i])
+		err = flightService.blockService.Delete(ctx, trans, &flight.Blocks[i])
 		if err != nil {
-			trErr := flightService.db.Rollback(trans)
+			trErr := flightService.db.Rollback(ctx, trans)
 			if trErr != nil {
-				logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+				logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 					"error":  trErr,
 					"flight": *flight,
 				}).Error("Error rollbacking transaction")
@@ -262,51 +327,54 @@ func (flightService *FlightService) Delete(flight *models.Flight) error {
 		}
 	}
 
-	_, err = flightService.db.Delete(trans, flight)
+	_, err = flightService.db.Delete(ctx, trans, flight)
 	if err != nil {
-		trErr := flightService.db.Rollback(trans)
+		trErr := flightService.db.Rollback(ctx, trans)
 		if trErr != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 				"error":  trErr,
 				"flight": *flight,
 			}).Error("Error rollbacking transaction")
 		}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":  err,
 			"flight": *flight,
 		}).Error("Error deleting flight")
 		return err
 	}
 
-	err = flightService.db.Commit(trans)
+	err = flightService.db.Commit(ctx, trans)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":  err,
 			"flight": *flight,
 		}).Error("Error committing transaction")
 		return err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	flightService.invalidate(flight)
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
 		"flight": *flight,
 	}).Debug("Flight successfully deleted")
 	return nil
 }
 
 // ListAll list all flights according filtering, sorting, limitation parameters
-func (flightService *FlightService) ListAll(filtering string, sorting string,
+func (flightService *FlightService) ListAll(ctx context.Context, tenantID string, filtering string, sorting string,
 	limitation string) ([]models.Flight, error) {
-	var flights []models.Flight
+	ctx, span := tracing.Start(ctx, "FlightService.ListAll")
+	defer span.End()
 
-	if filtering != "" {
-		filtering = " WHERE " + strings.TrimPrefix(filtering, " AND ")
-	}
+	var flights []models.Flight
 
-	_, err := flightService.db.Select(&flights, "SELECT * FROM flights"+filtering+sorting+limitation)
+	_, err := flightService.db.Select(ctx, nil, &flights, "SELECT * FROM flights WHERE tenant_id = ?"+
+		filtering+sorting+limitation, tenantID)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":      err,
+			"tenantID":   tenantID,
 			"filtering":  filtering,
 			"sorting":    sorting,
 			"limitation": limitation,
@@ -314,7 +382,8 @@ func (flightService *FlightService) ListAll(filtering string, sorting string,
 		return nil, err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID":   tenantID,
 		"filtering":  filtering,
 		"sorting":    sorting,
 		"limitation": limitation,
@@ -324,21 +393,24 @@ func (flightService *FlightService) ListAll(filtering string, sorting string,
 }
 
 // GetMeta gets metadata about flight list according filtering parameters
-func (flightService *FlightService) GetMeta(filtering string) (*models.FlightMeta, error) {
-	if filtering != "" {
-		filtering = " WHERE " + strings.TrimPrefix(filtering, " AND ")
-	}
+func (flightService *FlightService) GetMeta(ctx context.Context, tenantID string,
+	filtering string) (*models.FlightMeta, error) {
+	ctx, span := tracing.Start(ctx, "FlightService.GetMeta")
+	defer span.End()
 
-	count, err := flightService.db.SelectInt("SELECT COUNT(*) FROM flights" + filtering)
+	count, err := flightService.db.SelectInt(ctx, nil, "SELECT COUNT(*) FROM flights WHERE tenant_id = ?"+filtering,
+		tenantID)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":     err,
+			"tenantID":  tenantID,
 			"filtering": filtering,
 		}).Error("Error returning flight metadata")
 		return nil, err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID":  tenantID,
 		"filtering": filtering,
 		"count":     count,
 	}).Debug("Flight metadata successfully returned")
@@ -348,18 +420,308 @@ func (flightService *FlightService) GetMeta(filtering string) (*models.FlightMet
 }
 
 // SetBlocks sets flight blocks
-func (flightService *FlightService) SetBlocks(trans *gorp.Transaction, id int64, blocks []models.Block) error {
+func (flightService *FlightService) SetBlocks(ctx context.Context, trans *gorp.Transaction, tenantID string, id int64,
+	blocks []models.Block) error {
+	ctx, span := tracing.Start(ctx, "FlightService.SetBlocks")
+	defer span.End()
+
 	for i := range blocks {
+		blocks[i].TenantID = tenantID
 		blocks[i].FlightID = id
-		err := flightService.blockService.Create(trans, &blocks[i])
+		err := flightService.blockService.Create(ctx, trans, &blocks[i])
 		if err != nil {
 			return err
 		}
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
-		"id":     id,
-		"blocks": blocks,
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"id":       id,
+		"blocks":   blocks,
 	}).Debug("Flight blocks successfully inserted")
 	return nil
 }
+
+// Relayout replaces flight blocks and seats, moving occupants with their links onto the new seats
+func (flightService *FlightService) Relayout(ctx context.Context, flight *models.Flight,
+	layout *models.FlightLayout) (*models.FlightLayoutResult, error) {
+	ctx, span := tracing.Start(ctx, "FlightService.Relayout")
+	defer span.End()
+
+	var trans *gorp.Transaction
+	var err error
+
+	if flight.Status != models.FlightStatusOpen {
+		return nil, apperrors.ErrFlightClosed
+	}
+
+	if !layout.DryRun {
+		trans, err = flightService.lock(ctx, flight)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var old []models.Seat
+	_, err = flightService.db.Select(ctx, trans, &old, "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ?",
+		flight.TenantID, flight.ID)
+	if err != nil {
+		flightService.rollback(ctx, trans, flight)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error returning seats")
+		return nil, err
+	}
+
+	seats := layoutSeats(flight.TenantID, flight.ID, layout.Blocks, time.Now().Unix())
+	result := reseat(old, seats)
+	result.DryRun = layout.DryRun
+
+	if layout.DryRun {
+		logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+			"flight": *flight,
+			"result": *result,
+		}).Debug("Flight re-layout successfully previewed")
+		return result, nil
+	}
+
+	err = flightService.seatService.DeleteAll(ctx, trans, flight.TenantID, flight.ID)
+	if err != nil {
+		flightService.rollback(ctx, trans, flight)
+		return nil, err
+	}
+
+	for i := range flight.Blocks {
+		err = flightService.blockService.Delete(ctx, trans, &flight.Blocks[i])
+		if err != nil {
+			flightService.rollback(ctx, trans, flight)
+			return nil, err
+		}
+	}
+
+	err = flightService.SetBlocks(ctx, trans, flight.TenantID, flight.ID, layout.Blocks)
+	if err != nil {
+		flightService.rollback(ctx, trans, flight)
+		return nil, err
+	}
+
+	err = flightService.seatService.CreateAll(ctx, trans, seats)
+	if err != nil {
+		flightService.rollback(ctx, trans, flight)
+		return nil, err
+	}
+
+	moves := map[int]models.Seat{}
+	for _, reassigned := range result.Reassigned {
+		moves[reassigned.From.Index] = models.Seat{Index: reassigned.To.Index, Row: reassigned.To.Row,
+			Line: reassigned.To.Line}
+	}
+	for _, unaccommodated := range result.Unaccommodated {
+		moves[unaccommodated.Index] = models.Seat{}
+	}
+
+	err = relink(ctx, flightService.db, trans, flight.TenantID, flight.ID, moves)
+	if err != nil {
+		flightService.rollback(ctx, trans, flight)
+		return nil, err
+	}
+
+	flight.Blocks = layout.Blocks
+	_, err = flightService.db.Update(ctx, trans, flight)
+	if err != nil {
+		flightService.rollback(ctx, trans, flight)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error updating flight")
+		return nil, err
+	}
+
+	err = flightService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error committing transaction")
+		return nil, err
+	}
+
+	flightService.invalidate(flight)
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"flight": *flight,
+		"result": *result,
+	}).Debug("Flight successfully re-laid out")
+	return result, nil
+}
+
+// lock begins transaction locking the flight of the known version and its seats
+func (flightService *FlightService) lock(ctx context.Context, flight *models.Flight) (*gorp.Transaction, error) {
+	var locked models.Flight
+
+	trans, err := flightService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error creating transaction")
+		return nil, err
+	}
+
+	err = flightService.db.SelectOne(ctx, trans, &locked,
+		"SELECT * FROM flights WHERE tenant_id = ? AND id = ? FOR UPDATE",
+		flight.TenantID, flight.ID)
+	if err != nil {
+		flightService.rollback(ctx, trans, flight)
+
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrFlightNotFound
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error locking flight")
+		return nil, err
+	}
+
+	if locked.Version != flight.Version {
+		flightService.rollback(ctx, trans, flight)
+		return nil, gorp.OptimisticLockError{TableName: "flights", Keys: []interface{}{flight.ID}, RowExists: true,
+			LocalVersion: flight.Version}
+	}
+
+	_, err = flightService.db.Exec(ctx, trans, "SELECT id FROM seats WHERE tenant_id = ? AND flight_id = ? FOR UPDATE",
+		flight.TenantID, flight.ID)
+	if err != nil {
+		flightService.rollback(ctx, trans, flight)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error locking seats")
+		return nil, err
+	}
+
+	return trans, nil
+}
+
+// invalidate drops cached flight and its seat summary
+func (flightService *FlightService) invalidate(flight *models.Flight) {
+	flightService.cacheManager.Delete(cache.FlightKey(flight.TenantID, flight.ID),
+		cache.SeatSummaryKey(flight.TenantID, flight.ID))
+}
+
+func (flightService *FlightService) rollback(ctx context.Context, trans *gorp.Transaction, flight *models.Flight) {
+	if trans == nil {
+		return
+	}
+
+	err := flightService.db.Rollback(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":  err,
+			"flight": *flight,
+		}).Error("Error rollbacking transaction")
+	}
+}
+
+// reseat blocks new seats of the blocked row and line and moves occupants of the old seats onto the new seats
+// keeping row and line where possible, otherwise taking the closest free seat of the same type
+func reseat(old []models.Seat, seats []models.Seat) *models.FlightLayoutResult {
+	result := &models.FlightLayoutResult{
+		Seats:          len(seats),
+		Reassigned:     []models.SeatReassignment{},
+		Unaccommodated: []models.Seat{},
+	}
+
+	var occupied []models.Seat
+	for _, seat := range old {
+		if seat.Assigned {
+			occupied = append(occupied, seat)
+		}
+		if !seat.Blocked {
+			continue
+		}
+		for j := range seats {
+			if seats[j].Row == seat.Row && seats[j].Line == seat.Line {
+				seats[j].Blocked = true
+			}
+		}
+	}
+	sort.Slice(occupied, func(i, j int) bool { return occupied[i].Index < occupied[j].Index })
+
+	move := func(occupant models.Seat, seat *models.Seat, exact bool) {
+		result.Reassigned = append(result.Reassigned, models.SeatReassignment{
+			Owner: occupant.Owner,
+			From:  models.SeatRef{Index: occupant.Index, Row: occupant.Row, Line: occupant.Line},
+			To:    models.SeatRef{Index: seat.Index, Row: seat.Row, Line: seat.Line},
+			Exact: exact,
+		})
+		occupant.MoveOccupant(seat)
+	}
+
+	var unplaced []models.Seat
+	for _, occupant := range occupied {
+		placed := false
+		for j := range seats {
+			if !seats[j].Assigned && !seats[j].Blocked && seats[j].Row == occupant.Row &&
+				seats[j].Line == occupant.Line {
+				move(occupant, &seats[j], true)
+				placed = true
+				break
+			}
+		}
+
+		if !placed {
+			unplaced = append(unplaced, occupant)
+		}
+	}
+
+	for _, occupant := range unplaced {
+		closest := -1
+		for j := range seats {
+			if seats[j].Assigned || seats[j].Blocked || seats[j].Type != occupant.Type {
+				continue
+			}
+
+			if closest < 0 || closer(occupant, seats[j], seats[closest]) {
+				closest = j
+			}
+		}
+
+		if closest < 0 {
+			result.Unaccommodated = append(result.Unaccommodated, occupant)
+			continue
+		}
+
+		move(occupant, &seats[closest], false)
+	}
+
+	return result
+}
+
+// closer checks whether the seat is closer to the occupant than the other seat by row, then by line,
+// then by index
+func closer(occupant models.Seat, seat models.Seat, other models.Seat) bool {
+	distance := func(a int, b int) int {
+		if a > b {
+			return a - b
+		}
+		return b - a
+	}
+
+	if d, o := distance(occupant.Row, seat.Row), distance(occupant.Row, other.Row); d != o {
+		return d < o
+	}
+
+	line := int(occupant.Line[0])
+	if d, o := distance(line, int(seat.Line[0])), distance(line, int(other.Line[0])); d != o {
+		return d < o
+	}
+
+	return distance(occupant.Index, seat.Index) < distance(occupant.Index, other.Index)
+}
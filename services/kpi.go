@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/metrics"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+)
+
+const (
+	// flightLoadQuery counts seats and assigned seats of every open flight
+	flightLoadQuery = "SELECT s.tenant_id, s.flight_id, COUNT(*) AS seats, SUM(s.assigned) AS assigned FROM seats s " +
+		"JOIN flights f ON f.tenant_id = s.tenant_id AND f.id = s.flight_id WHERE f.status = ? " +
+		"GROUP BY s.tenant_id, s.flight_id"
+)
+
+// flightLoad contains seat counts of the flight
+type flightLoad struct {
+	TenantID string `db:"tenant_id"`
+	FlightID int64  `db:"flight_id"`
+	Seats    int64  `db:"seats"`
+	Assigned int64  `db:"assigned"`
+}
+
+// KPIService is a booking KPI service updating business gauges, its owner collects them with the registry
+type KPIService struct {
+	db         sqldb.DBInterface
+	flights    metrics.Gauge
+	seats      metrics.Gauge
+	assigned   metrics.Gauge
+	loadFactor metrics.Gauge
+}
+
+// KPIServiceInterface is an interface for booking KPI service methods
+type KPIServiceInterface interface {
+	Collect() error
+}
+
+// NewKPIService is a constructor for booking KPI service registering its gauges
+func NewKPIService(db sqldb.DBInterface, registry metrics.Registry) KPIServiceInterface {
+	kpiService := &KPIService{db: db}
+
+	kpiService.flights = registry.Gauge("booking_open_flights", "Number of flights open for booking")
+	kpiService.seats = registry.Gauge("booking_flight_seats", "Number of seats of the open flight", "tenant", "flight")
+	kpiService.assigned = registry.Gauge("booking_flight_seats_assigned", "Number of assigned seats of the open flight",
+		"tenant", "flight")
+	kpiService.loadFactor = registry.Gauge("booking_flight_load_factor", "Share of assigned seats of the open flight",
+		"tenant", "flight")
+
+	return kpiService
+}
+
+// Collect sets gauges of the open flights, gauges of closed and deleted flights are removed
+func (kpiService *KPIService) Collect() error {
+	var loads []flightLoad
+
+	_, err := kpiService.db.Select(context.Background(), nil, &loads, flightLoadQuery, models.FlightStatusOpen)
+	if err != nil {
+		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			"error": err,
+		}).Error("Error counting flight loads")
+		return err
+	}
+
+	kpiService.seats.Reset()
+	kpiService.assigned.Reset()
+	kpiService.loadFactor.Reset()
+
+	for _, load := range loads {
+		flightID := strconv.FormatInt(load.FlightID, 10)
+
+		kpiService.seats.Set(float64(load.Seats), load.TenantID, flightID)
+		kpiService.assigned.Set(float64(load.Assigned), load.TenantID, flightID)
+		if load.Seats != 0 {
+			kpiService.loadFactor.Set(float64(load.Assigned)/float64(load.Seats), load.TenantID, flightID)
+		}
+	}
+	kpiService.flights.Set(float64(len(loads)))
+
+	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+		"flights": len(loads),
+	}).Debug("Flight loads successfully collected")
+	return nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"io"
+
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/tracing"
+)
+
+const (
+	// manifestQuery selects seats with check-in passenger data, seat filtering and sorting apply to its columns
+	manifestQuery = "SELECT `index`, row, line, type, assigned, blocked, owner, first_name, last_name, pnr, sequence " +
+		"FROM (SELECT s.*, COALESCE(c.first_name, '') AS first_name, COALESCE(c.last_name, '') AS last_name, " +
+		"COALESCE(c.pnr, '') AS pnr, COALESCE(c.sequence, 0) AS sequence FROM seats s LEFT JOIN checkins c " +
+		"ON c.flight_id = s.flight_id AND c.seat_index = s.`index`) AS seats WHERE tenant_id = ? AND flight_id = ?"
+	// manifestDefaultSorting is manifest sorting without sort parameters
+	manifestDefaultSorting = " ORDER BY `index` ASC"
+)
+
+// ManifestService is a flight manifest service
+type ManifestService struct {
+	db sqldb.DBInterface
+}
+
+// ManifestServiceInterface is an interface for flight manifest service methods
+type ManifestServiceInterface interface {
+	Export(ctx context.Context, flight *models.Flight, filtering string, sorting string) (*ManifestCursor, error)
+}
+
+// ManifestCursor iterates manifest rows straight from the db cursor, errors are logged with the export context
+type ManifestCursor struct {
+	ctx  context.Context
+	rows sqldb.RowsInterface
+}
+
+// NewManifestService is a constructor for flight manifest service
+func NewManifestService(db sqldb.DBInterface) ManifestServiceInterface {
+	return &ManifestService{db: db}
+}
+
+// Export opens cursor over all seats of the flight according filtering and sorting parameters without limitation
+func (manifestService *ManifestService) Export(ctx context.Context, flight *models.Flight, filtering string,
+	sorting string) (*ManifestCursor, error) {
+	ctx, span := tracing.Start(ctx, "ManifestService.Export")
+	defer span.End()
+
+	if sorting == "" {
+		sorting = manifestDefaultSorting
+	}
+
+	rows, err := manifestService.db.Query(ctx, nil, manifestQuery+filtering+sorting, flight.TenantID, flight.ID)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":     err,
+			"flightID":  flight.ID,
+			"filtering": filtering,
+			"sorting":   sorting,
+		}).Error("Error exporting manifest")
+		return nil, err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"flightID":  flight.ID,
+		"filtering": filtering,
+		"sorting":   sorting,
+	}).Debug("Manifest export successfully started")
+	return &ManifestCursor{ctx: ctx, rows: rows}, nil
+}
+
+// Next returns next manifest row or io.EOF after the last one
+func (cursor *ManifestCursor) Next() (*models.ManifestRow, error) {
+	if !cursor.rows.Next() {
+		err := cursor.rows.Err()
+		if err != nil {
+			logging.Log.WithContext(cursor.ctx, logging.DepthModerate, logging.Fields{
+				"error": err,
+			}).Error("Error iterating manifest")
+			return nil, err
+		}
+
+		return nil, io.EOF
+	}
+
+	var row models.ManifestRow
+
+	err := cursor.rows.Scan(&row.Index, &row.Row, &row.Line, &row.Type, &row.Assigned, &row.Blocked, &row.Owner,
+		&row.FirstName, &row.LastName, &row.PNR, &row.Sequence)
+	if err != nil {
+		logging.Log.WithContext(cursor.ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+		}).Error("Error scanning manifest row")
+		return nil, err
+	}
+
+	return &row, nil
+}
+
+// Close closes the db cursor
+func (cursor *ManifestCursor) Close() error {
+	return cursor.rows.Close()
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/schedule"
+	"github.com/vsukhin/booking/tracing"
+)
+
+// ScheduleService is a flight schedule import service
+type ScheduleService struct {
+	flightService FlightServiceInterface
+}
+
+// ScheduleServiceInterface is an interface for flight schedule import service methods
+type ScheduleServiceInterface interface {
+	Import(ctx context.Context, tenantID string, reader schedule.Reader, options *models.ImportOptions,
+		progress func(progress models.ImportProgress)) (*models.ImportReport, error)
+}
+
+// scheduleChunk contains valid flights of the schedule created in one transaction
+type scheduleChunk struct {
+	flights []*models.Flight
+	rows    []int
+}
+
+// NewScheduleService is a constructor for flight schedule import service
+func NewScheduleService(flightService FlightServiceInterface) ScheduleServiceInterface {
+	return &ScheduleService{flightService: flightService}
+}
+
+// Import validates every schedule row and creates valid flights in chunked transactions,
+// progress is reported after every chunk, dry run only validates rows
+func (scheduleService *ScheduleService) Import(ctx context.Context, tenantID string, reader schedule.Reader,
+	options *models.ImportOptions, progress func(progress models.ImportProgress)) (*models.ImportReport, error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.Import")
+	defer span.End()
+
+	report := &models.ImportReport{DryRun: options.DryRun, Errors: []models.ImportRowError{}}
+	templates := map[int64][]models.Block{}
+
+	var chunk scheduleChunk
+	for {
+		if ctx.Err() != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":    ctx.Err(),
+				"tenantID": tenantID,
+				"progress": report.ImportProgress,
+			}).Error("Schedule import cancelled")
+			return report, ctx.Err()
+		}
+
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":    err,
+				"tenantID": tenantID,
+				"progress": report.ImportProgress,
+			}).Error("Error reading schedule")
+			return report, err
+		}
+
+		report.Rows++
+
+		errs := row.Errors
+		if len(errs) == 0 {
+			errs = scheduleService.resolve(ctx, tenantID, &row.Flight, templates)
+		}
+		if len(errs) == 0 {
+			errs = row.Flight.Validate()
+		}
+		if len(errs) != 0 {
+			report.Failed++
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row.Row, Errors: errs})
+			continue
+		}
+
+		report.Valid++
+		chunk.flights = append(chunk.flights, row.Flight.NewFlight(tenantID))
+		chunk.rows = append(chunk.rows, row.Row)
+
+		if len(chunk.flights) == options.ChunkSize {
+			scheduleService.create(ctx, &chunk, report, progress)
+		}
+	}
+
+	scheduleService.create(ctx, &chunk, report, progress)
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"dryRun":   options.DryRun,
+		"progress": report.ImportProgress,
+	}).Info("Schedule successfully imported")
+	return report, nil
+}
+
+// resolve sets blocks of the template flight
+func (scheduleService *ScheduleService) resolve(ctx context.Context, tenantID string, flight *models.ScheduleFlight,
+	templates map[int64][]models.Block) []models.Error {
+	if flight.Template == 0 {
+		return nil
+	}
+
+	if len(flight.Blocks) != 0 {
+		return []models.Error{{
+			Code:    "template.Conflict",
+			Message: "Either template or blocks must be provided",
+			Field:   "template",
+		}}
+	}
+
+	blocks, ok := templates[flight.Template]
+	if !ok {
+		template, err := scheduleService.flightService.Retrieve(ctx, tenantID, flight.Template)
+		if err != nil {
+			if !errors.Is(err, apperrors.ErrFlightNotFound) {
+				return []models.Error{{
+					Code:    "template.Unavailable",
+					Message: "Template flight can't be retrieved",
+					Field:   "template",
+				}}
+			}
+
+			return []models.Error{{
+				Code:    "template.NotFound",
+				Message: "Template flight is not found",
+				Field:   "template",
+			}}
+		}
+
+		blocks = template.Blocks
+		templates[flight.Template] = blocks
+	}
+
+	for _, block := range blocks {
+		flight.Blocks = append(flight.Blocks, models.Block{Rows: block.Rows, SideSeatNumbers: block.SideSeatNumbers,
+			MiddleSeatNumbers: block.MiddleSeatNumbers})
+	}
+
+	return nil
+}
+
+// create creates flights of the chunk in one transaction, all rows of the failed chunk are reported as failed
+// instead of valid
+func (scheduleService *ScheduleService) create(ctx context.Context, chunk *scheduleChunk, report *models.ImportReport,
+	progress func(progress models.ImportProgress)) {
+	if len(chunk.flights) == 0 {
+		return
+	}
+
+	if !report.DryRun {
+		err := scheduleService.flightService.CreateAll(ctx, chunk.flights)
+		if err != nil {
+			for _, row := range chunk.rows {
+				report.Valid--
+				report.Failed++
+				report.Errors = append(report.Errors, models.ImportRowError{Row: row, Errors: []models.Error{{
+					Code:    "flight.NotCreated",
+					Message: "Flight chunk with the row is not created",
+					Field:   "row",
+				}}})
+			}
+		} else {
+			report.Created += len(chunk.flights)
+		}
+	}
+
+	chunk.flights, chunk.rows = nil, nil
+
+	if progress != nil {
+		progress(report.ImportProgress)
+	}
+}
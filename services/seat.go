@@ -1,188 +1,810 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
-	gorp "gopkg.in/gorp.v2"
+	gorp "github.com/go-gorp/gorp/v3"
 
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/cache"
 	"github.com/vsukhin/booking/logging"
 	"github.com/vsukhin/booking/models"
 	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/tracing"
+)
+
+const (
+	// maxAssignAttempts is max attempts to assign seat concurrently taken by another request
+	maxAssignAttempts = 3
+	// freeSeatQuery selects first free seat of the flight
+	freeSeatQuery = "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ? AND assigned = false " +
+		"AND blocked = false ORDER BY row ASC, type ASC, line ASC LIMIT 1"
+)
+
+var (
+	// seatColumns contains seat columns set by multi-row inserts
+	seatColumns = []string{"tenant_id", "flight_id", "index", "type", "row", "line", "assigned", "owner", "blocked",
+		"created_at", "updated_at", "version"}
 )
 
 // SeatService is a seat service
 type SeatService struct {
-	db sqldb.DBInterface
+	db           sqldb.DBInterface
+	cacheManager cache.ManagerInterface
 }
 
 // SeatServiceInterface is an interface for seat service methods
 type SeatServiceInterface interface {
-	Create(trans *gorp.Transaction, seat *models.Seat) error
-	Assign(flightID int64) (*models.Seat, error)
-	Update(seat *models.Seat) error
-	DeleteAll(trans *gorp.Transaction, flightID int64) error
-	Retrieve(flightID int64, index int64) (*models.Seat, error)
-	Find(flightID int64, row int, line string) (*models.Seat, error)
-	ListAll(flightID int64, filtering string, sorting string, limitation string) ([]models.Seat, error)
-	GetMeta(flightID int64, filtering string) (*models.SeatMeta, error)
+	Create(ctx context.Context, trans *gorp.Transaction, seat *models.Seat) error
+	CreateAll(ctx context.Context, trans *gorp.Transaction, seats []models.Seat) error
+	Assign(ctx context.Context, tenantID string, flightID int64, owner string) (*models.Seat, error)
+	Update(ctx context.Context, seat *models.Seat) error
+	Batch(ctx context.Context, tenantID string, flightID int64, batch *models.SeatBatch) ([]models.SeatBatchResult, error)
+	Move(ctx context.Context, seat *models.Seat, index int64) ([]models.Seat, error)
+	Swap(ctx context.Context, seat *models.Seat, other *models.Seat) ([]models.Seat, error)
+	DeleteAll(ctx context.Context, trans *gorp.Transaction, tenantID string, flightID int64) error
+	Retrieve(ctx context.Context, tenantID string, flightID int64, index int64) (*models.Seat, error)
+	Find(ctx context.Context, tenantID string, flightID int64, row int, line string) (*models.Seat, error)
+	ListAll(ctx context.Context, tenantID string, flightID int64, filtering string, sorting string,
+		limitation string) ([]models.Seat, error)
+	GetMeta(ctx context.Context, tenantID string, flightID int64, filtering string) (*models.SeatMeta, error)
+	Summary(ctx context.Context, tenantID string, flightID int64) (*models.SeatSummary, error)
 }
 
 // NewSeatService is a constructor for seat service
-func NewSeatService(db sqldb.DBInterface) SeatServiceInterface {
-	db.AddTableWithName(models.Seat{}, "seats").SetKeys(true, "ID")
+func NewSeatService(db sqldb.DBInterface, cacheManager cache.ManagerInterface) SeatServiceInterface {
+	db.AddTableWithName(models.Seat{}, "seats").SetKeys(true, "ID").SetVersionCol("Version")
 
-	return &SeatService{db: db}
+	return &SeatService{db: db, cacheManager: cacheManager}
 }
 
 // Create creates seat
-func (seatService *SeatService) Create(trans *gorp.Transaction, seat *models.Seat) error {
-	err := seatService.db.Insert(trans, seat)
+func (seatService *SeatService) Create(ctx context.Context, trans *gorp.Transaction, seat *models.Seat) error {
+	ctx, span := tracing.Start(ctx, "SeatService.Create")
+	defer span.End()
+
+	err := seatService.db.Insert(ctx, trans, seat)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error": err,
 			"seat":  *seat,
 		}).Error("Error creating seat")
 		return err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
 		"seat": *seat,
 	}).Debug("Seat successfully created")
 	return nil
 }
 
-// Assign assignes seat
-func (seatService *SeatService) Assign(flightID int64) (*models.Seat, error) {
+// CreateAll creates seats by multi-row inserts, created seats get no ids,
+// the caller drops cached seat summary after commit
+func (seatService *SeatService) CreateAll(ctx context.Context, trans *gorp.Transaction, seats []models.Seat) error {
+	ctx, span := tracing.Start(ctx, "SeatService.CreateAll")
+	defer span.End()
+
+	rows := make([][]interface{}, len(seats))
+	for i, seat := range seats {
+		rows[i] = []interface{}{seat.TenantID, seat.FlightID, seat.Index, seat.Type, seat.Row, seat.Line,
+			seat.Assigned, seat.Owner, seat.Blocked, seat.CreatedAt, seat.UpdatedAt, 1}
+	}
+
+	err := insertRows(ctx, seatService.db, trans, "seats", seatColumns, rows)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"seats": len(seats),
+		}).Error("Error creating seats")
+		return err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"seats": len(seats),
+	}).Debug("Seats successfully created")
+	return nil
+}
+
+// Assign assignes seat to the owner, retrying when the picked seat is concurrently taken
+func (seatService *SeatService) Assign(ctx context.Context, tenantID string, flightID int64,
+	owner string) (*models.Seat, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.Assign")
+	defer span.End()
+
+	for attempt := 1; ; attempt++ {
+		seat, err := seatService.assign(ctx, tenantID, flightID, owner)
+		if _, ok := err.(gorp.OptimisticLockError); ok {
+			if attempt < maxAssignAttempts {
+				logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+					"tenantID": tenantID,
+					"flightID": flightID,
+					"attempt":  attempt,
+				}).Debug("Seat concurrently assigned, retrying")
+				continue
+			}
+
+			// the request has no precondition of the client, so exhausted attempts are conflict to retry later
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"tenantID": tenantID,
+				"flightID": flightID,
+				"attempts": attempt,
+			}).Error("Seats concurrently assigned on every attempt")
+			return nil, apperrors.ErrSeatContention
+		}
+
+		return seat, err
+	}
+}
+
+func (seatService *SeatService) assign(ctx context.Context, tenantID string, flightID int64,
+	owner string) (*models.Seat, error) {
 	var seat models.Seat
 
-	trans, err := seatService.db.Begin()
+	trans, err := seatService.db.Begin(ctx)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":    err,
+			"tenantID": tenantID,
 			"flightID": flightID,
 		}).Error("Error creating transaction")
 		return nil, err
 	}
 
-	err = seatService.db.SelectOne(nil, &seat, "SELECT * FROM seats WHERE flight_id = ? AND assigned = false "+
-		"ORDER BY row ASC, type ASC, line ASC LIMIT 1", flightID)
+	capacity, err := loadCapacity(ctx, seatService.db, trans, tenantID, flightID)
+	if err == nil {
+		err = capacity.admit(true)
+	}
+	if err != nil {
+		seatService.rollback(ctx, trans, tenantID, flightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Seat is not admitted")
+		return nil, err
+	}
+
+	err = seatService.db.SelectOne(ctx, trans, &seat, freeSeatQuery, tenantID, flightID)
 	if err != nil {
-		trErr := seatService.db.Rollback(trans)
+		trErr := seatService.db.Rollback(ctx, trans)
 		if trErr != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 				"error":    trErr,
+				"tenantID": tenantID,
 				"flightID": flightID,
 			}).Error("Error rollbacking transaction")
 		}
 
 		if err == sql.ErrNoRows {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"tenantID": tenantID,
 				"flightID": flightID,
-			}).Error("Seat not found")
-			return nil, nil
+			}).Error("Flight has no free seats")
+			return nil, apperrors.ErrFlightFull
 		}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":    err,
+			"tenantID": tenantID,
 			"flightID": flightID,
 		}).Error("Error returning seat")
 		return nil, err
 	}
 
 	seat.Assigned = true
+	seat.Owner = owner
 	seat.UpdatedAt = time.Now().Unix()
-	_, err = seatService.db.Update(trans, &seat)
+	_, err = seatService.db.Update(ctx, trans, &seat)
 	if err != nil {
-		trErr := seatService.db.Rollback(trans)
+		trErr := seatService.db.Rollback(ctx, trans)
 		if trErr != nil {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 				"error":    trErr,
+				"tenantID": tenantID,
 				"flightID": flightID,
 			}).Error("Error rollbacking transaction")
 		}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":    err,
+			"tenantID": tenantID,
 			"flightID": flightID,
 		}).Error("Error reating seat")
 		return nil, err
 	}
 
-	err = seatService.db.Commit(trans)
+	err = seatService.db.Commit(ctx, trans)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":    err,
+			"tenantID": tenantID,
 			"flightID": flightID,
 		}).Error("Error committing transaction")
 		return nil, err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	seatService.cacheManager.Delete(cache.SeatSummaryKey(tenantID, flightID))
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
 		"flightID": flightID,
 		"seat":     seat,
 	}).Debug("Seat successfully assigned")
 	return &seat, nil
 }
 
-// Update updates seat
-func (seatService *SeatService) Update(seat *models.Seat) error {
-	_, err := seatService.db.Update(nil, seat)
+// Update updates seat of the known version, seat newly assigned is admitted by the locked flight capacity
+// and links of the occupant leaving the seat are released
+func (seatService *SeatService) Update(ctx context.Context, seat *models.Seat) error {
+	ctx, span := tracing.Start(ctx, "SeatService.Update")
+	defer span.End()
+
+	var current models.Seat
+
+	trans, err := seatService.db.Begin(ctx)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"seat":  *seat,
+		}).Error("Error creating transaction")
+		return err
+	}
+
+	capacity, err := loadCapacity(ctx, seatService.db, trans, seat.TenantID, seat.FlightID)
+	if err == nil {
+		err = seatService.db.SelectOne(ctx, trans, &current, "SELECT * FROM seats WHERE tenant_id = ? "+
+			"AND flight_id = ? AND `index` = ? FOR UPDATE", seat.TenantID, seat.FlightID, seat.Index)
+		if err == sql.ErrNoRows {
+			err = apperrors.ErrSeatNotFound
+		}
+	}
+	if err == nil && current.Version != seat.Version {
+		err = gorp.OptimisticLockError{TableName: "seats", Keys: []interface{}{seat.ID}, RowExists: true,
+			LocalVersion: seat.Version}
+	}
+	if err == nil && current.Assigned && seat.Assigned && current.Owner != seat.Owner {
+		err = apperrors.ErrSeatTaken
+	}
+	if err == nil {
+		err = capacity.change(&current, seat)
+	}
+	if err == nil && vacated(&current, seat) {
+		err = relink(ctx, seatService.db, trans, seat.TenantID, seat.FlightID, map[int]models.Seat{current.Index: {}})
+	}
+	if err == nil {
+		_, err = seatService.db.Update(ctx, trans, seat)
+	}
+	if err != nil {
+		seatService.rollback(ctx, trans, seat.TenantID, seat.FlightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error": err,
 			"seat":  *seat,
 		}).Error("Error updating seat")
 		return err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	err = seatService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"seat":  *seat,
+		}).Error("Error committing transaction")
+		return err
+	}
+
+	seatService.cacheManager.Delete(cache.SeatSummaryKey(seat.TenantID, seat.FlightID))
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
 		"seat": *seat,
 	}).Debug("Seat successfully updated")
 	return nil
 }
 
-// DeleteAll deletes all seats
-func (seatService *SeatService) DeleteAll(trans *gorp.Transaction, flightID int64) error {
-	_, err := seatService.db.Exec(trans, "DELETE FROM seats WHERE flight_id = ?", flightID)
+// Batch applies the action to the seats in one transaction, failed item rollbacks the whole batch
+// unless it is best effort
+func (seatService *SeatService) Batch(ctx context.Context, tenantID string, flightID int64,
+	batch *models.SeatBatch) ([]models.SeatBatchResult, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.Batch")
+	defer span.End()
+
+	results := make([]models.SeatBatchResult, len(batch.Seats))
+
+	trans, err := seatService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error creating transaction")
+		return nil, err
+	}
+
+	capacity, err := loadCapacity(ctx, seatService.db, trans, tenantID, flightID)
+	if err != nil {
+		seatService.rollback(ctx, trans, tenantID, flightID)
+		return nil, err
+	}
+
+	var failed *apperrors.Error
+	for i, ref := range batch.Seats {
+		results[i].SeatRef = ref
+
+		seat, err := seatService.apply(ctx, trans, capacity, ref, batch.Action, batch.Owner)
+		if err != nil {
+			var itemErr *apperrors.Error
+			if !errors.As(err, &itemErr) {
+				seatService.rollback(ctx, trans, tenantID, flightID)
+				return nil, err
+			}
+
+			results[i].Error = &models.Error{Code: itemErr.Code, Message: itemErr.Message,
+				Field: fmt.Sprintf("seats[%v]", i)}
+			if failed == nil {
+				failed = itemErr
+			}
+			continue
+		}
+
+		results[i].Succeeded = true
+		results[i].Seat = seat
+	}
+
+	if failed != nil && !batch.BestEffort {
+		seatService.rollback(ctx, trans, tenantID, flightID)
+
+		for i := range results {
+			results[i].Succeeded = false
+			results[i].Seat = nil
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"tenantID": tenantID,
+			"flightID": flightID,
+			"results":  results,
+		}).Error("Seat batch failed")
+		return results, &apperrors.Error{
+			Kind:    failed.Kind,
+			Code:    "seats.BatchFailed",
+			Message: "Seat batch failed, no seats were changed",
+			Field:   "seats",
+			Details: map[string]interface{}{"results": results},
+			Err:     failed,
+		}
+	}
+
+	err = seatService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error committing transaction")
+		return nil, err
+	}
+
+	seatService.cacheManager.Delete(cache.SeatSummaryKey(tenantID, flightID))
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"flightID": flightID,
+		"action":   batch.Action,
+		"results":  results,
+	}).Debug("Seat batch successfully applied")
+	return results, nil
+}
+
+// apply applies the action to the seat in the transaction of the locked flight, seat newly assigned is admitted
+// by the flight capacity and links of the occupant leaving the seat are released
+func (seatService *SeatService) apply(ctx context.Context, trans *gorp.Transaction, capacity *flightCapacity,
+	ref models.SeatRef, action models.SeatAction, owner string) (*models.Seat, error) {
+	var seat models.Seat
+	var err error
+
+	tenantID, flightID := capacity.flight.TenantID, capacity.flight.ID
+
+	if ref.Index != 0 {
+		err = seatService.db.SelectOne(ctx, trans, &seat, "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+			"AND `index` = ? FOR UPDATE", tenantID, flightID, ref.Index)
+	} else {
+		err = seatService.db.SelectOne(ctx, trans, &seat, "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+			"AND row = ? AND line = ? FOR UPDATE", tenantID, flightID, ref.Row, ref.Line)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrSeatNotFound
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+			"seat":     ref,
+		}).Error("Error returning seat")
+		return nil, err
+	}
+
+	current := seat
+
+	switch action {
+	case models.SeatActionAssign:
+		if seat.Blocked {
+			return nil, apperrors.ErrSeatBlocked
+		}
+		if seat.Assigned && seat.Owner != owner {
+			return nil, apperrors.ErrSeatTaken
+		}
+		seat.Assigned = true
+		seat.Owner = owner
+	case models.SeatActionRelease:
+		seat.Assigned = false
+		seat.Owner = ""
+		seat.Blocked = false
+	case models.SeatActionBlock:
+		if seat.Assigned {
+			return nil, apperrors.ErrSeatTaken
+		}
+		seat.Blocked = true
+	}
+	seat.UpdatedAt = time.Now().Unix()
+
+	err = capacity.change(&current, &seat)
+	if err == nil && vacated(&current, &seat) {
+		err = relink(ctx, seatService.db, trans, tenantID, flightID, map[int]models.Seat{current.Index: {}})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = seatService.db.Update(ctx, trans, &seat)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+			"seat":     seat,
+		}).Error("Error updating seat")
+		return nil, err
+	}
+
+	return &seat, nil
+}
+
+// Move moves occupant of the seat to the free seat with the index in one transaction
+func (seatService *SeatService) Move(ctx context.Context, seat *models.Seat, index int64) ([]models.Seat, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.Move")
+	defer span.End()
+
+	return seatService.exchange(ctx, seat, nil, index, func(source *models.Seat, target *models.Seat) error {
+		if !source.Assigned {
+			return apperrors.ErrSeatNotAssigned
+		}
+		if target.Blocked {
+			return apperrors.ErrSeatBlocked
+		}
+		if target.Assigned {
+			return apperrors.ErrSeatTaken
+		}
+
+		source.MoveOccupant(target)
+		return nil
+	})
+}
+
+// Swap swaps occupants of two assigned seats in one transaction
+func (seatService *SeatService) Swap(ctx context.Context, seat *models.Seat,
+	other *models.Seat) ([]models.Seat, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.Swap")
+	defer span.End()
+
+	return seatService.exchange(ctx, seat, other, int64(other.Index), func(source *models.Seat,
+		target *models.Seat) error {
+		if !source.Assigned || !target.Assigned {
+			return apperrors.ErrSeatNotAssigned
+		}
+
+		source.SwapOccupant(target)
+		return nil
+	})
+}
+
+// exchange locks both seats in index order, checks versions of the seats known to the caller,
+// updates both seats changed by the change function and moves links of the occupants with them
+func (seatService *SeatService) exchange(ctx context.Context, seat *models.Seat, other *models.Seat, index int64,
+	change func(source *models.Seat, target *models.Seat) error) ([]models.Seat, error) {
+	tenantID, flightID := seat.TenantID, seat.FlightID
+
+	if int64(seat.Index) == index {
+		return nil, apperrors.New(apperrors.KindValidation, "index.Same", "Seats must differ", "index")
+	}
+
+	trans, err := seatService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error creating transaction")
+		return nil, err
+	}
+
+	seats := make([]models.Seat, 2)
+	known := []*models.Seat{seat, other}
+	indexes := []int64{int64(seat.Index), index}
+
+	order := []int{0, 1}
+	if indexes[1] < indexes[0] {
+		order = []int{1, 0}
+	}
+
+	for _, i := range order {
+		err = seatService.db.SelectOne(ctx, trans, &seats[i], "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+			"AND `index` = ? FOR UPDATE", tenantID, flightID, indexes[i])
+		if err != nil {
+			seatService.rollback(ctx, trans, tenantID, flightID)
+
+			if err == sql.ErrNoRows {
+				return nil, apperrors.ErrSeatNotFound
+			}
+
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":    err,
+				"tenantID": tenantID,
+				"flightID": flightID,
+				"index":    indexes[i],
+			}).Error("Error returning seat")
+			return nil, err
+		}
+
+		if known[i] != nil && known[i].Version != seats[i].Version {
+			seatService.rollback(ctx, trans, tenantID, flightID)
+			return nil, gorp.OptimisticLockError{TableName: "seats", Keys: []interface{}{seats[i].ID},
+				RowExists: true, LocalVersion: known[i].Version}
+		}
+	}
+
+	original := []models.Seat{seats[0], seats[1]}
+
+	err = change(&seats[0], &seats[1])
+	if err != nil {
+		seatService.rollback(ctx, trans, tenantID, flightID)
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	for i := range seats {
+		seats[i].UpdatedAt = now
+
+		_, err = seatService.db.Update(ctx, trans, &seats[i])
+		if err != nil {
+			seatService.rollback(ctx, trans, tenantID, flightID)
+
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":    err,
+				"tenantID": tenantID,
+				"flightID": flightID,
+				"seat":     seats[i],
+			}).Error("Error updating seat")
+			return nil, err
+		}
+	}
+
+	moves := map[int]models.Seat{}
+	for i := range seats {
+		if original[i].Assigned {
+			moves[original[i].Index] = seats[1-i]
+		}
+	}
+
+	err = relink(ctx, seatService.db, trans, tenantID, flightID, moves)
+	if err != nil {
+		seatService.rollback(ctx, trans, tenantID, flightID)
+		return nil, err
+	}
+
+	err = seatService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error committing transaction")
+		return nil, err
+	}
+
+	seatService.cacheManager.Delete(cache.SeatSummaryKey(tenantID, flightID))
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"flightID": flightID,
+		"seats":    seats,
+	}).Debug("Seat occupants successfully exchanged")
+	return seats, nil
+}
+
+// vacated tells if the occupant of the seat left it by the change
+func vacated(seat *models.Seat, changed *models.Seat) bool {
+	return seat.Assigned && (!changed.Assigned || changed.Owner != seat.Owner)
+}
+
+// relink moves bookings, check-ins and waitlist grants of the occupants of the seats with old indexes to the seats
+// their occupants take now in the transaction, occupant left without seat gets booking confirmed again and waitlist
+// grant cancelled unless checked in, check-ins are parked at negative indexes first to let occupants of the swapped
+// seats pass each other on the unique seat of the check-in
+func relink(ctx context.Context, db sqldb.DBInterface, trans *gorp.Transaction, tenantID string, flightID int64,
+	moves map[int]models.Seat) error {
+	var bookings []models.Booking
+	var checkins []models.Checkin
+	var entries []models.WaitlistEntry
+
+	indexes := make([]int, 0, len(moves))
+	for index := range moves {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		_, err := db.Select(ctx, trans, &bookings, "SELECT * FROM bookings WHERE tenant_id = ? AND flight_id = ? "+
+			"AND status = ? AND seat_index = ? FOR UPDATE", tenantID, flightID, models.BookingStatusSeated, index)
+		if err == nil {
+			_, err = db.Select(ctx, trans, &checkins, "SELECT * FROM checkins WHERE tenant_id = ? AND flight_id = ? "+
+				"AND seat_index = ? FOR UPDATE", tenantID, flightID, index)
+		}
+		if err == nil {
+			_, err = db.Select(ctx, trans, &entries, "SELECT * FROM waitlist WHERE tenant_id = ? AND flight_id = ? "+
+				"AND status = ? AND seat_index = ? FOR UPDATE", tenantID, flightID, models.WaitlistStatusGranted, index)
+		}
+		if err != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":    err,
+				"tenantID": tenantID,
+				"flightID": flightID,
+				"index":    index,
+			}).Error("Error returning seat links")
+			return err
+		}
+	}
+
+	for _, checkin := range checkins {
+		if moves[checkin.SeatIndex].Index == 0 {
+			return apperrors.ErrSeatCheckedIn
+		}
+	}
+
+	for i := range checkins {
+		_, err := db.Exec(ctx, trans, "UPDATE checkins SET seat_index = ? WHERE id = ?", -checkins[i].ID,
+			checkins[i].ID)
+		if err != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":   err,
+				"checkin": checkins[i],
+			}).Error("Error parking check-in")
+			return err
+		}
+	}
+
+	now := time.Now().Unix()
+	for i := range bookings {
+		bookings[i].SeatIndex = moves[bookings[i].SeatIndex].Index
+		if bookings[i].SeatIndex == 0 {
+			bookings[i].Status = models.BookingStatusConfirmed
+		}
+		bookings[i].UpdatedAt = now
+		_, err := db.Update(ctx, trans, &bookings[i])
+		if err != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":   err,
+				"booking": bookings[i],
+			}).Error("Error relinking booking")
+			return err
+		}
+	}
+	for i := range checkins {
+		seat := moves[checkins[i].SeatIndex]
+		checkins[i].SeatIndex = seat.Index
+		checkins[i].Row = seat.Row
+		checkins[i].Line = seat.Line
+		_, err := db.Update(ctx, trans, &checkins[i])
+		if err != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error":   err,
+				"checkin": checkins[i],
+			}).Error("Error relinking check-in")
+			return err
+		}
+	}
+	for i := range entries {
+		entries[i].SeatIndex = moves[entries[i].SeatIndex].Index
+		if entries[i].SeatIndex == 0 {
+			entries[i].Status = models.WaitlistStatusCancelled
+		}
+		entries[i].UpdatedAt = now
+		_, err := db.Update(ctx, trans, &entries[i])
+		if err != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error": err,
+				"entry": entries[i],
+			}).Error("Error relinking waitlist entry")
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (seatService *SeatService) rollback(ctx context.Context, trans *gorp.Transaction, tenantID string,
+	flightID int64) {
+	err := seatService.db.Rollback(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error rollbacking transaction")
+	}
+}
+
+// DeleteAll deletes all seats, the caller drops cached seat summary after commit
+func (seatService *SeatService) DeleteAll(ctx context.Context, trans *gorp.Transaction, tenantID string,
+	flightID int64) error {
+	ctx, span := tracing.Start(ctx, "SeatService.DeleteAll")
+	defer span.End()
+
+	_, err := seatService.db.Exec(ctx, trans, "DELETE FROM seats WHERE tenant_id = ? AND flight_id = ?", tenantID,
+		flightID)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":    err,
+			"tenantID": tenantID,
 			"flightID": flightID,
 		}).Error("Error deleting all seats")
 		return err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
 		"flightID": flightID,
 	}).Debug("All seats successfully deleted")
 	return nil
 }
 
 // Retrieve retrieves seat
-func (seatService *SeatService) Retrieve(flightID int64, index int64) (*models.Seat, error) {
+func (seatService *SeatService) Retrieve(ctx context.Context, tenantID string, flightID int64,
+	index int64) (*models.Seat, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.Retrieve")
+	defer span.End()
+
 	var seat models.Seat
 
-	err := seatService.db.SelectOne(nil, &seat, "SELECT * FROM seats WHERE flight_id = ? AND `index` = ?",
-		flightID, index)
+	err := seatService.db.SelectOne(ctx, nil, &seat, "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+		"AND `index` = ?", tenantID, flightID, index)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"tenantID": tenantID,
 				"flightID": flightID,
 				"index":    index,
 			}).Error("Seat not found")
-			return nil, nil
+			return nil, apperrors.ErrSeatNotFound
 		}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":    err,
+			"tenantID": tenantID,
 			"flightID": flightID,
 			"index":    index,
 		}).Error("Error returning seat")
 		return nil, err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
 		"flightID": flightID,
 		"index":    index,
 		"seat":     seat,
@@ -191,23 +813,29 @@ func (seatService *SeatService) Retrieve(flightID int64, index int64) (*models.S
 }
 
 // Find finds seat
-func (seatService *SeatService) Find(flightID int64, row int, line string) (*models.Seat, error) {
+func (seatService *SeatService) Find(ctx context.Context, tenantID string, flightID int64, row int,
+	line string) (*models.Seat, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.Find")
+	defer span.End()
+
 	var seat models.Seat
 
-	err := seatService.db.SelectOne(nil, &seat, "SELECT * FROM seats WHERE flight_id = ? AND row = ? AND line = ?",
-		flightID, row, line)
+	err := seatService.db.SelectOne(ctx, nil, &seat, "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ? "+
+		"AND row = ? AND line = ?", tenantID, flightID, row, line)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"tenantID": tenantID,
 				"flightID": flightID,
 				"row":      row,
 				"line":     line,
 			}).Error("Seat not found")
-			return nil, nil
+			return nil, apperrors.ErrSeatNotFound
 		}
 
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":    err,
+			"tenantID": tenantID,
 			"flightID": flightID,
 			"row":      row,
 			"line":     line,
@@ -215,7 +843,8 @@ func (seatService *SeatService) Find(flightID int64, row int, line string) (*mod
 		return nil, err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
 		"flightID": flightID,
 		"row":      row,
 		"line":     line,
@@ -225,15 +854,20 @@ func (seatService *SeatService) Find(flightID int64, row int, line string) (*mod
 }
 
 // ListAll list all seats according filtering, sorting, limitation parameters
-func (seatService *SeatService) ListAll(flightID int64, filtering string, sorting string,
+func (seatService *SeatService) ListAll(ctx context.Context, tenantID string, flightID int64, filtering string,
+	sorting string,
 	limitation string) ([]models.Seat, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.ListAll")
+	defer span.End()
+
 	var seats []models.Seat
 
-	_, err := seatService.db.Select(&seats, "SELECT * FROM seats WHERE flight_id = ?"+filtering+sorting+limitation,
-		flightID)
+	_, err := seatService.db.Select(ctx, nil, &seats, "SELECT * FROM seats WHERE tenant_id = ? AND flight_id = ?"+
+		filtering+sorting+limitation, tenantID, flightID)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":      err,
+			"tenantID":   tenantID,
 			"flightID":   flightID,
 			"filtering":  filtering,
 			"sorting":    sorting,
@@ -242,7 +876,8 @@ func (seatService *SeatService) ListAll(flightID int64, filtering string, sortin
 		return nil, err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID":   tenantID,
 		"flightID":   flightID,
 		"filtering":  filtering,
 		"sorting":    sorting,
@@ -253,18 +888,25 @@ func (seatService *SeatService) ListAll(flightID int64, filtering string, sortin
 }
 
 // GetMeta gets metadata about seat list according filtering parameters
-func (seatService *SeatService) GetMeta(flightID int64, filtering string) (*models.SeatMeta, error) {
-	count, err := seatService.db.SelectInt("SELECT COUNT(*) FROM seats WHERE flight_id = ?"+filtering, flightID)
+func (seatService *SeatService) GetMeta(ctx context.Context, tenantID string, flightID int64,
+	filtering string) (*models.SeatMeta, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.GetMeta")
+	defer span.End()
+
+	count, err := seatService.db.SelectInt(ctx, nil, "SELECT COUNT(*) FROM seats WHERE tenant_id = ? AND flight_id = ?"+
+		filtering, tenantID, flightID)
 	if err != nil {
-		logging.Log.WithFields(logging.DepthModerate, logging.Fields{
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
 			"error":     err,
+			"tenantID":  tenantID,
 			"flightID":  flightID,
 			"filtering": filtering,
 		}).Error("Error returning seat metadata")
 		return nil, err
 	}
 
-	logging.Log.WithFields(logging.DepthLow, logging.Fields{
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID":  tenantID,
 		"flightID":  flightID,
 		"filtering": filtering,
 		"count":     count,
@@ -273,3 +915,48 @@ func (seatService *SeatService) GetMeta(flightID int64, filtering string) (*mode
 		TotalRecords: count,
 	}, nil
 }
+
+// Summary counts free, assigned and blocked seats of the flight, cached summary is returned if any
+func (seatService *SeatService) Summary(ctx context.Context, tenantID string,
+	flightID int64) (*models.SeatSummary, error) {
+	ctx, span := tracing.Start(ctx, "SeatService.Summary")
+	defer span.End()
+
+	summary := models.SeatSummary{FlightID: flightID}
+
+	key := cache.SeatSummaryKey(tenantID, flightID)
+	if seatService.cacheManager.Get(key, &summary) {
+		return &summary, nil
+	}
+
+	var err error
+
+	summary.Total, err = seatService.db.SelectInt(ctx, nil, "SELECT COUNT(*) FROM seats WHERE tenant_id = ? "+
+		"AND flight_id = ?", tenantID, flightID)
+	if err == nil {
+		summary.Assigned, err = seatService.db.SelectInt(ctx, nil, "SELECT COUNT(*) FROM seats WHERE tenant_id = ? "+
+			"AND flight_id = ? AND assigned = true", tenantID, flightID)
+	}
+	if err == nil {
+		summary.Blocked, err = seatService.db.SelectInt(ctx, nil, "SELECT COUNT(*) FROM seats WHERE tenant_id = ? "+
+			"AND flight_id = ? AND blocked = true", tenantID, flightID)
+	}
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error counting seats")
+		return nil, err
+	}
+
+	summary.Free = summary.Total - summary.Assigned - summary.Blocked
+	seatService.cacheManager.Set(key, summary)
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"flightID": flightID,
+		"summary":  summary,
+	}).Debug("Seat summary successfully counted")
+	return &summary, nil
+}
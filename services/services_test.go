@@ -0,0 +1,2329 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	gorp "github.com/go-gorp/gorp/v3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/cache"
+	"github.com/vsukhin/booking/events"
+	"github.com/vsukhin/booking/helpers"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/metrics"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/schedule"
+)
+
+func init() {
+	logging.Log = NewFakeLogger()
+}
+
+// FakeLogger is fake logger
+type FakeLogger struct {
+	*logrus.Logger
+}
+
+// NewFakeLogger is a constructor of fake logger
+func NewFakeLogger() logging.LoggerInterface {
+	log := logrus.New()
+
+	return &FakeLogger{log}
+}
+
+// Init initiates logging
+func (logger *FakeLogger) Init(mode string) {
+}
+
+// WithFields logs with fields
+func (logger *FakeLogger) WithFields(depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// WithContext logs with fields and trace of the context
+func (logger *FakeLogger) WithContext(ctx context.Context, depthLevel int, fields logging.Fields) *logrus.Entry {
+	return logrus.NewEntry(logger.Logger)
+}
+
+// Info logs info
+func (logger *FakeLogger) Info(args ...interface{}) {
+}
+
+var (
+	// tableExp is table name expression
+	tableExp = regexp.MustCompile(`(?i)(?:FROM|UPDATE|INTO)\s+(\w+)`)
+	// conditionExp is equality condition expression with placeholder or literal of the filter
+	conditionExp = regexp.MustCompile("`?(\\w+)`?\\s*=\\s*(\\?|true|false|-?\\d+|'[^']*')")
+	// orderExp is order clause expression
+	orderExp = regexp.MustCompile(`(?i)ORDER BY\s+(.+?)(?:\s+LIMIT|\s+FOR|$)`)
+	// insertExp is multi-row insert columns expression
+	insertExp = regexp.MustCompile(`\(([^)]*)\)\s+VALUES`)
+	// rowTypes contains row types of tables filled by multi-row inserts
+	rowTypes = map[string]reflect.Type{
+		"seats":        reflect.TypeOf(models.Seat{}),
+		"seat_numbers": reflect.TypeOf(models.SeatNumber{}),
+	}
+)
+
+// fakeResult is fake sql result
+type fakeResult struct {
+	rows int64
+}
+
+// LastInsertId returns last insert id
+func (result fakeResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+// RowsAffected returns affected rows
+func (result fakeResult) RowsAffected() (int64, error) {
+	return result.rows, nil
+}
+
+// FakeDB is in-memory fake db management structure matching equality conditions of the queries
+type FakeDB struct {
+	dbMap      *gorp.DbMap
+	tables     map[string][]interface{}
+	snapshot   map[string][]interface{}
+	nextID     int64
+	statements int
+	selects    int
+	trans      *gorp.Transaction
+	outside    int
+	query      string
+}
+
+// NewFakeDB creates new fake db management structure
+func NewFakeDB() *FakeDB {
+	return &FakeDB{dbMap: &gorp.DbMap{}, tables: map[string][]interface{}{}}
+}
+
+func (db *FakeDB) tableName(i interface{}) string {
+	switch reflect.Indirect(reflect.ValueOf(i)).Interface().(type) {
+	case models.Flight:
+		return "flights"
+	case models.Block:
+		return "blocks"
+	case models.Seat:
+		return "seats"
+	case models.WaitlistEntry:
+		return "waitlist"
+	case models.Booking:
+		return "bookings"
+	case models.Checkin:
+		return "checkins"
+	case models.SeatNumber:
+		return "seat_numbers"
+	}
+
+	return ""
+}
+
+func (db *FakeDB) filter(query string, args []interface{}) (string, []int) {
+	var indexes []int
+
+	table := ""
+	if match := tableExp.FindStringSubmatch(query); match != nil {
+		table = match[1]
+	}
+
+	where := query
+	if position := strings.Index(strings.ToUpper(query), "WHERE"); position >= 0 {
+		where = query[position:]
+	} else {
+		where = ""
+	}
+
+	conditions := conditionExp.FindAllStringSubmatch(where, -1)
+
+	for index, row := range db.tables[table] {
+		value := reflect.ValueOf(row).Elem()
+		matched := true
+		arg := 0
+
+		for _, condition := range conditions {
+			var expected interface{}
+
+			switch condition[2] {
+			case "?":
+				expected = args[arg]
+				arg++
+			case "true":
+				expected = true
+			case "false":
+				expected = false
+			default:
+				if strings.HasPrefix(condition[2], "'") {
+					expected = strings.Trim(condition[2], "'")
+				} else {
+					expected, _ = strconv.ParseInt(condition[2], 10, 64)
+				}
+			}
+
+			if !fieldEquals(value, condition[1], expected) {
+				matched = false
+			}
+		}
+
+		if matched {
+			indexes = append(indexes, index)
+		}
+	}
+
+	if match := orderExp.FindStringSubmatch(query); match != nil {
+		rows := db.tables[table]
+		sort.SliceStable(indexes, func(i, j int) bool {
+			for _, order := range strings.Split(match[1], ",") {
+				parts := strings.Fields(strings.Replace(order, "`", "", -1))
+				left := fieldValue(reflect.ValueOf(rows[indexes[i]]).Elem(), parts[0])
+				right := fieldValue(reflect.ValueOf(rows[indexes[j]]).Elem(), parts[0])
+				if left == right {
+					continue
+				}
+
+				less := left < right
+				if len(parts) > 1 && strings.EqualFold(parts[1], "DESC") {
+					return !less
+				}
+				return less
+			}
+
+			return false
+		})
+	}
+
+	return table, indexes
+}
+
+func fieldValue(value reflect.Value, column string) string {
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Tag.Get("db") == column {
+			field := value.Field(i)
+			switch field.Kind() {
+			case reflect.Int, reflect.Int64:
+				return fmt.Sprintf("%020d", field.Int())
+			}
+
+			return fmt.Sprint(field.Interface())
+		}
+	}
+
+	return ""
+}
+
+func fieldEquals(value reflect.Value, column string, expected interface{}) bool {
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Tag.Get("db") == column {
+			field := value.Field(i)
+			if field.Kind() == reflect.Bool {
+				return field.Bool() == expected
+			}
+
+			return reflect.DeepEqual(reflect.ValueOf(expected).Convert(field.Type()).Interface(), field.Interface())
+		}
+	}
+
+	return false
+}
+
+// read counts reads running outside of the open transaction
+func (db *FakeDB) read(trans *gorp.Transaction) {
+	if db.trans != nil && trans != db.trans {
+		db.outside++
+	}
+}
+
+// AddTableWithName adds table with name to db map
+func (db *FakeDB) AddTableWithName(i interface{}, name string) *gorp.TableMap {
+	return db.dbMap.AddTableWithName(i, name)
+}
+
+// Insert inserts data to the db table
+func (db *FakeDB) Insert(ctx context.Context, trans *gorp.Transaction, list ...interface{}) error {
+	for _, item := range list {
+		db.statements++
+		db.nextID++
+		reflect.ValueOf(item).Elem().FieldByName("ID").SetInt(db.nextID)
+
+		table := db.tableName(item)
+		db.tables[table] = append(db.tables[table], item)
+	}
+
+	return nil
+}
+
+// Update updates data in the db table
+func (db *FakeDB) Update(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	var count int64
+
+	for _, item := range list {
+		id := reflect.ValueOf(item).Elem().FieldByName("ID").Int()
+		table := db.tableName(item)
+
+		for index, row := range db.tables[table] {
+			if reflect.ValueOf(row).Elem().FieldByName("ID").Int() == id {
+				copied := reflect.New(reflect.TypeOf(item).Elem())
+				copied.Elem().Set(reflect.ValueOf(item).Elem())
+				db.tables[table][index] = copied.Interface()
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// Delete deletes data from the db table
+func (db *FakeDB) Delete(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	var count int64
+
+	for _, item := range list {
+		id := reflect.ValueOf(item).Elem().FieldByName("ID").Int()
+		table := db.tableName(item)
+
+		var rows []interface{}
+		for _, row := range db.tables[table] {
+			if reflect.ValueOf(row).Elem().FieldByName("ID").Int() == id {
+				count++
+				continue
+			}
+			rows = append(rows, row)
+		}
+		db.tables[table] = rows
+	}
+
+	return count, nil
+}
+
+// Get gets data from the db table
+func (db *FakeDB) Get(ctx context.Context, trans *gorp.Transaction, i interface{},
+	keys ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+// Select selects data from the db table
+func (db *FakeDB) Select(ctx context.Context, trans *gorp.Transaction, i interface{}, query string,
+	args ...interface{}) ([]interface{}, error) {
+	db.selects++
+	db.read(trans)
+
+	if loads, ok := i.(*[]flightLoad); ok {
+		*loads = db.loads(args[0].(models.FlightStatus))
+		return nil, nil
+	}
+
+	table, indexes := db.filter(query, args)
+
+	holder := reflect.ValueOf(i).Elem()
+	if holder.Type().Elem().Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	for _, index := range indexes {
+		holder.Set(reflect.Append(holder, reflect.ValueOf(db.tables[table][index]).Elem()))
+	}
+
+	return nil, nil
+}
+
+// loads groups seats of the flights of the status as flight load query does
+func (db *FakeDB) loads(status models.FlightStatus) []flightLoad {
+	var loads []flightLoad
+
+	for _, row := range db.tables["flights"] {
+		flight := row.(*models.Flight)
+		if flight.Status != status {
+			continue
+		}
+
+		load := flightLoad{TenantID: flight.TenantID, FlightID: flight.ID}
+		for _, row := range db.tables["seats"] {
+			seat := row.(*models.Seat)
+			if seat.TenantID == flight.TenantID && seat.FlightID == flight.ID {
+				load.Seats++
+				if seat.Assigned {
+					load.Assigned++
+				}
+			}
+		}
+
+		if load.Seats != 0 {
+			loads = append(loads, load)
+		}
+	}
+
+	return loads
+}
+
+// SelectInt selects int from the db table
+func (db *FakeDB) SelectInt(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (int64, error) {
+	db.read(trans)
+	_, indexes := db.filter(query, args)
+
+	return int64(len(indexes)), nil
+}
+
+// SelectStr selects string from the db table
+func (db *FakeDB) SelectStr(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (string, error) {
+	return "", nil
+}
+
+// SelectOne selects one row from the db table
+func (db *FakeDB) SelectOne(ctx context.Context, trans *gorp.Transaction, holder interface{}, query string,
+	args ...interface{}) error {
+	db.read(trans)
+	table, indexes := db.filter(query, args)
+	if len(indexes) == 0 {
+		return sql.ErrNoRows
+	}
+
+	reflect.ValueOf(holder).Elem().Set(reflect.ValueOf(db.tables[table][indexes[0]]).Elem())
+	return nil
+}
+
+// Query opens cursor over seat rows joined with check-ins as manifest query does
+func (db *FakeDB) Query(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (sqldb.RowsInterface, error) {
+	db.read(trans)
+	db.query = query
+	table, indexes := db.filter(query, args)
+
+	rows := &fakeRows{}
+	for _, index := range indexes {
+		seat, ok := db.tables[table][index].(*models.Seat)
+		if !ok {
+			continue
+		}
+
+		var checkin models.Checkin
+		for _, row := range db.tables["checkins"] {
+			if item := row.(*models.Checkin); item.FlightID == seat.FlightID && item.SeatIndex == seat.Index {
+				checkin = *item
+			}
+		}
+
+		rows.values = append(rows.values, []interface{}{seat.Index, seat.Row, seat.Line, seat.Type, seat.Assigned,
+			seat.Blocked, seat.Owner, checkin.FirstName, checkin.LastName, checkin.PNR, checkin.Sequence})
+	}
+
+	return rows, nil
+}
+
+type fakeRows struct {
+	values  [][]interface{}
+	current int
+	closed  bool
+}
+
+// Next moves to the next row
+func (rows *fakeRows) Next() bool {
+	rows.current++
+	return rows.current <= len(rows.values)
+}
+
+// Scan copies the current row values
+func (rows *fakeRows) Scan(dest ...interface{}) error {
+	for i, value := range rows.values[rows.current-1] {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+
+	return nil
+}
+
+// Err returns iteration error
+func (rows *fakeRows) Err() error {
+	return nil
+}
+
+// Close closes the cursor
+func (rows *fakeRows) Close() error {
+	rows.closed = true
+	return nil
+}
+
+// Exec executes statement
+func (db *FakeDB) Exec(ctx context.Context, trans *gorp.Transaction, query string,
+	args ...interface{}) (sql.Result, error) {
+	db.statements++
+
+	if strings.HasPrefix(query, "INSERT") {
+		return db.insert(query, args)
+	}
+	if !strings.HasPrefix(query, "DELETE") {
+		return fakeResult{}, nil
+	}
+
+	table, indexes := db.filter(query, args)
+
+	var rows []interface{}
+	for index, row := range db.tables[table] {
+		deleted := false
+		for _, i := range indexes {
+			if i == index {
+				deleted = true
+			}
+		}
+		if !deleted {
+			rows = append(rows, row)
+		}
+	}
+	db.tables[table] = rows
+
+	return fakeResult{rows: int64(len(indexes))}, nil
+}
+
+// insert inserts rows of multi-row statement setting fields by column names
+func (db *FakeDB) insert(query string, args []interface{}) (sql.Result, error) {
+	table := tableExp.FindStringSubmatch(query)[1]
+
+	var columns []string
+	for _, column := range strings.Split(insertExp.FindStringSubmatch(query)[1], ",") {
+		columns = append(columns, strings.Trim(strings.TrimSpace(column), "`"))
+	}
+
+	rowType := rowTypes[table]
+	fields := make([]int, len(columns))
+	for i, column := range columns {
+		for j := 0; j < rowType.NumField(); j++ {
+			if rowType.Field(j).Tag.Get("db") == column {
+				fields[i] = j
+			}
+		}
+	}
+
+	var count int64
+	for start := 0; start < len(args); start += len(columns) {
+		row := reflect.New(rowType)
+		db.nextID++
+		row.Elem().FieldByName("ID").SetInt(db.nextID)
+
+		for i, field := range fields {
+			value := row.Elem().Field(field)
+			value.Set(reflect.ValueOf(args[start+i]).Convert(value.Type()))
+		}
+
+		db.tables[table] = append(db.tables[table], row.Interface())
+		count++
+	}
+
+	return fakeResult{rows: count}, nil
+}
+
+// Begin begins transaction
+func (db *FakeDB) Begin(ctx context.Context) (*gorp.Transaction, error) {
+	db.snapshot = map[string][]interface{}{}
+	for table, rows := range db.tables {
+		db.snapshot[table] = append([]interface{}{}, rows...)
+	}
+	db.trans = &gorp.Transaction{}
+
+	return db.trans, nil
+}
+
+// Rollback rollbacks transaction
+func (db *FakeDB) Rollback(ctx context.Context, trans *gorp.Transaction) error {
+	db.trans = nil
+	if db.snapshot != nil {
+		db.tables = db.snapshot
+		db.snapshot = nil
+	}
+
+	return nil
+}
+
+// Commit commits transaction
+func (db *FakeDB) Commit(ctx context.Context, trans *gorp.Transaction) error {
+	db.trans = nil
+	return nil
+}
+
+// Ping pings nothing
+func (db *FakeDB) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close closes nothing
+func (db *FakeDB) Close() error {
+	return nil
+}
+
+// GetDBMap returns dbmap
+func (db *FakeDB) GetDBMap() *gorp.DbMap {
+	return db.dbMap
+}
+
+func newTestCacheManager() cache.ManagerInterface {
+	return cache.NewManager(cache.NewMemoryStore(cache.DefaultCapacity), cache.DefaultTTL)
+}
+
+func newTestServices(db sqldb.DBInterface) (FlightServiceInterface, SeatServiceInterface) {
+	cacheManager := newTestCacheManager()
+	blockService := NewBlockService(db)
+	seatService := NewSeatService(db, cacheManager)
+	flightService := NewFlightService(db, blockService, seatService, cacheManager)
+
+	return flightService, seatService
+}
+
+func newTestFlight(t *testing.T, flightService FlightServiceInterface, tenantID string) *models.Flight {
+	flight := &models.Flight{
+		TenantID: tenantID,
+		Name:     "Flight " + tenantID,
+		Status:   models.FlightStatusOpen,
+		Blocks: []models.Block{
+			{Rows: 2, SideSeatNumbers: []int{2, 2}},
+		},
+	}
+
+	err := flightService.Create(context.Background(), flight)
+	if err != nil {
+		t.Fatal("Expected to create flight successfully")
+	}
+
+	return flight
+}
+
+func Test_FlightService_Create_Tenant_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+
+	newTestFlight(t, flightService, "a")
+
+	if len(db.tables["seats"]) != 8 || len(db.tables["blocks"]) != 1 {
+		t.Fatal("Expected to create blocks and seats successfully")
+	}
+	for _, row := range db.tables["seats"] {
+		if row.(*models.Seat).TenantID != "a" {
+			t.Error("Expected seats to belong to flight tenant")
+		}
+	}
+	if db.tables["blocks"][0].(*models.Block).TenantID != "a" {
+		t.Error("Expected blocks to belong to flight tenant")
+	}
+}
+
+func Test_FlightService_Retrieve_Tenant_Success(t *testing.T) {
+	flightService, _ := newTestServices(NewFakeDB())
+
+	created := newTestFlight(t, flightService, "a")
+
+	flight, err := flightService.Retrieve(context.Background(), "a", created.ID)
+	if err != nil || flight == nil {
+		t.Fatal("Expected to retrieve flight of own tenant")
+	}
+	if len(flight.Blocks) != 1 {
+		t.Error("Expected to retrieve flight blocks of own tenant")
+	}
+}
+
+func Test_FlightService_Retrieve_OtherTenant_Failure(t *testing.T) {
+	flightService, _ := newTestServices(NewFakeDB())
+
+	created := newTestFlight(t, flightService, "a")
+
+	flight, err := flightService.Retrieve(context.Background(), "b", created.ID)
+	if !errors.Is(err, apperrors.ErrFlightNotFound) {
+		t.Error("Expected to have not found error retrieving flight of other tenant")
+	}
+	if flight != nil {
+		t.Error("Expected not to retrieve flight of other tenant")
+	}
+}
+
+func Test_FlightService_ListAll_OtherTenant_Failure(t *testing.T) {
+	flightService, _ := newTestServices(NewFakeDB())
+
+	newTestFlight(t, flightService, "a")
+	own := newTestFlight(t, flightService, "b")
+
+	flights, err := flightService.ListAll(context.Background(), "b", "", "", "")
+	if err != nil {
+		t.Error("Expected to list flights successfully")
+	}
+	if len(flights) != 1 || flights[0].ID != own.ID {
+		t.Error("Expected to list flights of own tenant only")
+	}
+}
+
+func Test_FlightService_GetMeta_OtherTenant_Failure(t *testing.T) {
+	flightService, _ := newTestServices(NewFakeDB())
+
+	newTestFlight(t, flightService, "a")
+	newTestFlight(t, flightService, "a")
+
+	meta, err := flightService.GetMeta(context.Background(), "b", "")
+	if err != nil {
+		t.Error("Expected to get flight metadata successfully")
+	}
+	if meta == nil || meta.TotalRecords != 0 {
+		t.Error("Expected not to count flights of other tenant")
+	}
+}
+
+func Test_SeatService_Retrieve_OtherTenant_Failure(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	if err != nil || seat == nil {
+		t.Fatal("Expected to retrieve seat of own tenant")
+	}
+
+	seat, err = seatService.Retrieve(context.Background(), "b", flight.ID, 1)
+	if !errors.Is(err, apperrors.ErrSeatNotFound) {
+		t.Error("Expected to have not found error retrieving seat of other tenant")
+	}
+	if seat != nil {
+		t.Error("Expected not to retrieve seat of other tenant")
+	}
+}
+
+func Test_SeatService_Find_OtherTenant_Failure(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Find(context.Background(), "b", flight.ID, 1, "A")
+	if !errors.Is(err, apperrors.ErrNotFound) {
+		t.Error("Expected to have not found error finding seat of other tenant")
+	}
+	if seat != nil {
+		t.Error("Expected not to find seat of other tenant")
+	}
+}
+
+func Test_SeatService_ListAll_OtherTenant_Failure(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seats, err := seatService.ListAll(context.Background(), "b", flight.ID, "", "", "")
+	if err != nil {
+		t.Error("Expected to list seats successfully")
+	}
+	if len(seats) != 0 {
+		t.Error("Expected not to list seats of other tenant")
+	}
+
+	meta, err := seatService.GetMeta(context.Background(), "b", flight.ID, "")
+	if err != nil {
+		t.Error("Expected to get seat metadata successfully")
+	}
+	if meta == nil || meta.TotalRecords != 0 {
+		t.Error("Expected not to count seats of other tenant")
+	}
+}
+
+// ContendedDB is fake db where every seat update loses to concurrent update
+type ContendedDB struct {
+	*FakeDB
+	updates int
+}
+
+// Update fails with optimistic lock error
+func (db *ContendedDB) Update(ctx context.Context, trans *gorp.Transaction, list ...interface{}) (int64, error) {
+	db.updates++
+	return 0, gorp.OptimisticLockError{TableName: "seats", RowExists: true}
+}
+
+func Test_SeatService_Assign_Contention_Failure(t *testing.T) {
+	db := &ContendedDB{FakeDB: NewFakeDB()}
+	flightService, seatService := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := seatService.Assign(context.Background(), "a", flight.ID, "p1")
+	if !errors.Is(err, apperrors.ErrSeatContention) {
+		t.Errorf("Expected exhausted attempts to be contention conflict, got %v", err)
+	}
+	if _, ok := err.(gorp.OptimisticLockError); ok || apperrors.ErrSeatContention.RetryAfter <= 0 {
+		t.Error("Expected contention not to be precondition failure and to have retry hint")
+	}
+	if db.updates != maxAssignAttempts {
+		t.Errorf("Expected %v assign attempts, got %v", maxAssignAttempts, db.updates)
+	}
+}
+
+func Test_SeatService_Assign_OtherTenant_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Assign(context.Background(), "b", flight.ID, "intruder")
+	if !errors.Is(err, apperrors.ErrFlightNotFound) {
+		t.Error("Expected to have flight not found error assigning seat of other tenant")
+	}
+	if seat != nil {
+		t.Error("Expected not to assign seat of other tenant")
+	}
+	for _, row := range db.tables["seats"] {
+		if row.(*models.Seat).Assigned {
+			t.Error("Expected seats of other tenant to stay unassigned")
+		}
+	}
+}
+
+func Test_SeatService_DeleteAll_OtherTenant_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	err := seatService.DeleteAll(context.Background(), nil, "b", flight.ID)
+	if err != nil {
+		t.Error("Expected to have no error deleting seats of other tenant")
+	}
+	if len(db.tables["seats"]) != 8 {
+		t.Error("Expected seats of other tenant to stay intact")
+	}
+}
+
+func Test_SeatService_Batch_Success(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	results, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action: models.SeatActionAssign,
+		Owner:  "group",
+		Seats:  []models.SeatRef{{Index: 1}, {Row: 1, Line: "B"}},
+	})
+	if err != nil || len(results) != 2 {
+		t.Fatal("Expected to apply seat batch successfully")
+	}
+	for _, result := range results {
+		if !result.Succeeded || result.Seat == nil || result.Seat.Owner != "group" {
+			t.Error("Expected to assign all seats of the batch")
+		}
+	}
+
+	seat, err := seatService.Find(context.Background(), "a", flight.ID, 1, "B")
+	if err != nil || !seat.Assigned {
+		t.Error("Expected to store assigned seat")
+	}
+}
+
+func Test_SeatService_Batch_Atomic_Failure(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	results, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action: models.SeatActionBlock,
+		Seats:  []models.SeatRef{{Index: 1}, {Index: 100}},
+	})
+	if !errors.Is(err, apperrors.ErrNotFound) {
+		t.Error("Expected to fail batch with missing seat")
+	}
+	if len(results) != 2 || results[0].Succeeded || results[1].Error == nil {
+		t.Error("Expected to report item results of failed batch")
+	}
+
+	seat, err := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	if err != nil || seat.Blocked {
+		t.Error("Expected to rollback blocked seat")
+	}
+}
+
+func Test_SeatService_Batch_BestEffort_Success(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action: models.SeatActionAssign,
+		Owner:  "first",
+		Seats:  []models.SeatRef{{Index: 2}},
+	})
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	results, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action:     models.SeatActionBlock,
+		BestEffort: true,
+		Seats:      []models.SeatRef{{Index: 1}, {Index: 2}},
+	})
+	if err != nil || len(results) != 2 {
+		t.Fatal("Expected to apply best effort batch successfully")
+	}
+	if !results[0].Succeeded || results[1].Succeeded || results[1].Error.Code != "seat.Taken" {
+		t.Error("Expected to block free seat and report taken seat")
+	}
+
+	seat, err := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	if err != nil || !seat.Blocked {
+		t.Error("Expected to store blocked seat")
+	}
+
+	_, err = seatService.Assign(context.Background(), "a", flight.ID, "second")
+	if err != nil {
+		t.Fatal("Expected to assign free seat successfully")
+	}
+	seat, _ = seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	if seat.Assigned {
+		t.Error("Expected not to assign blocked seat")
+	}
+}
+
+func Test_SeatService_Move_Success(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action: models.SeatActionAssign, Owner: "p1", Seats: []models.SeatRef{{Index: 1}}})
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	seat, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+
+	seats, err := seatService.Move(context.Background(), seat, 3)
+	if err != nil || len(seats) != 2 {
+		t.Fatal("Expected to move occupant successfully")
+	}
+
+	source, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	target, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 3)
+	if source.Assigned || source.Owner != "" || !target.Assigned || target.Owner != "p1" {
+		t.Error("Expected occupant to follow to the target seat")
+	}
+}
+
+func Test_SeatService_Swap_Links_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+	waitlistService := NewWaitlistService(db, &FakePublisher{}, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+	booking := newTestBookings(t, bookingService, flight, "p1")[0]
+	entry := &models.WaitlistEntry{TenantID: "a", FlightID: flight.ID, Owner: "p2"}
+
+	seat, err := bookingService.Seat(context.Background(), booking)
+	if err == nil {
+		err = waitlistService.Join(context.Background(), entry)
+	}
+	if err != nil || entry.Status != models.WaitlistStatusGranted {
+		t.Fatal("Expected to seat booking and grant waitlist entry successfully")
+	}
+
+	other, _ := seatService.Retrieve(context.Background(), "a", flight.ID, int64(entry.SeatIndex))
+
+	_, err = seatService.Swap(context.Background(), seat, other)
+	if err != nil {
+		t.Fatal("Expected to swap occupants successfully")
+	}
+
+	booked := db.tables["bookings"][0].(*models.Booking)
+	granted := db.tables["waitlist"][0].(*models.WaitlistEntry)
+	if booked.SeatIndex != other.Index || granted.SeatIndex != seat.Index {
+		t.Errorf("Expected booking and waitlist grant to follow occupants, got %v and %v", booked.SeatIndex,
+			granted.SeatIndex)
+	}
+}
+
+func Test_SeatService_Move_Taken_Failure(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action: models.SeatActionAssign, Owner: "p1", Seats: []models.SeatRef{{Index: 1}, {Index: 2}}})
+	if err != nil {
+		t.Fatal("Expected to assign seats successfully")
+	}
+
+	seat, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+
+	_, err = seatService.Move(context.Background(), seat, 2)
+	if !errors.Is(err, apperrors.ErrSeatTaken) {
+		t.Error("Expected not to move occupant to taken seat")
+	}
+}
+
+func Test_SeatService_Move_Stale_Failure(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	stale := *seat
+	stale.Version--
+
+	_, err := seatService.Move(context.Background(), &stale, 2)
+	if _, ok := err.(gorp.OptimisticLockError); !ok {
+		t.Error("Expected to reject stale seat version")
+	}
+}
+
+func Test_SeatService_Swap_Success(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action: models.SeatActionAssign, Owner: "p1", Seats: []models.SeatRef{{Index: 1}}})
+	if err == nil {
+		_, err = seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+			Action: models.SeatActionAssign, Owner: "p2", Seats: []models.SeatRef{{Index: 4}}})
+	}
+	if err != nil {
+		t.Fatal("Expected to assign seats successfully")
+	}
+
+	seat, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 4)
+	other, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+
+	_, err = seatService.Swap(context.Background(), seat, other)
+	if err != nil {
+		t.Fatal("Expected to swap occupants successfully")
+	}
+
+	seat, _ = seatService.Retrieve(context.Background(), "a", flight.ID, 4)
+	other, _ = seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	if seat.Owner != "p1" || other.Owner != "p2" {
+		t.Error("Expected occupants to be swapped")
+	}
+}
+
+func newTestOccupants(t *testing.T, seatService SeatServiceInterface, flight *models.Flight) {
+	_, err := seatService.Batch(context.Background(), flight.TenantID, flight.ID, &models.SeatBatch{
+		Action: models.SeatActionAssign, Owner: "p1", Seats: []models.SeatRef{{Row: 1, Line: "A"}}})
+	if err == nil {
+		_, err = seatService.Batch(context.Background(), flight.TenantID, flight.ID, &models.SeatBatch{
+			Action: models.SeatActionAssign, Owner: "p2", Seats: []models.SeatRef{{Row: 2, Line: "D"}}})
+	}
+	if err == nil {
+		_, err = seatService.Batch(context.Background(), flight.TenantID, flight.ID, &models.SeatBatch{
+			Action: models.SeatActionAssign, Owner: "p3", Seats: []models.SeatRef{{Row: 2, Line: "C"}}})
+	}
+	if err != nil {
+		t.Fatal("Expected to assign seats successfully")
+	}
+}
+
+func Test_FlightService_Relayout_Success(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+	newTestOccupants(t, seatService, flight)
+
+	result, err := flightService.Relayout(context.Background(), flight, &models.FlightLayout{
+		Blocks: []models.Block{{Rows: 1, SideSeatNumbers: []int{2, 2}}},
+	})
+	if err != nil || result.Seats != 4 || len(result.Reassigned) != 3 || len(result.Unaccommodated) != 0 {
+		t.Fatal("Expected to re-layout flight successfully")
+	}
+
+	owners := map[string]string{}
+	seats, _ := seatService.ListAll(context.Background(), "a", flight.ID, "", "", "")
+	for _, seat := range seats {
+		owners[seat.Line] = seat.Owner
+	}
+	if len(seats) != 4 || owners["A"] != "p1" || owners["D"] != "p2" || owners["C"] != "p3" {
+		t.Error("Expected to keep same line or move to the closest seat of same type")
+	}
+	if !result.Reassigned[0].Exact || result.Reassigned[1].Exact {
+		t.Error("Expected to report exact and moved reassignments")
+	}
+}
+
+func Test_FlightService_Relayout_Bookings_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+	for _, booking := range newTestBookings(t, bookingService, flight, "p1", "p2", "p3") {
+		_, err := bookingService.Seat(context.Background(), booking)
+		if err != nil {
+			t.Fatal("Expected to seat booking successfully")
+		}
+	}
+
+	result, err := flightService.Relayout(context.Background(), flight, &models.FlightLayout{
+		Blocks: []models.Block{{Rows: 1, SideSeatNumbers: []int{1, 1}}},
+	})
+	if err != nil || len(result.Reassigned) != 2 || len(result.Unaccommodated) != 1 {
+		t.Fatal("Expected to re-layout flight successfully")
+	}
+
+	seated := map[string]int{}
+	for _, reassigned := range result.Reassigned {
+		seated[reassigned.Owner] = reassigned.To.Index
+	}
+	for _, row := range db.tables["bookings"] {
+		booking := row.(*models.Booking)
+		index, ok := seated[booking.Owner]
+		if ok && (booking.Status != models.BookingStatusSeated || booking.SeatIndex != index) {
+			t.Errorf("Expected booking to follow occupant to seat %v, got %+v", index, *booking)
+		}
+		if !ok && (booking.Status != models.BookingStatusConfirmed || booking.SeatIndex != 0) {
+			t.Errorf("Expected unaccommodated booking to wait for seat again, got %+v", *booking)
+		}
+	}
+}
+
+func Test_FlightService_Relayout_Blocked_Success(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+	newTestOccupants(t, seatService, flight)
+
+	_, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action: models.SeatActionBlock, Seats: []models.SeatRef{{Row: 1, Line: "B"}, {Row: 1, Line: "C"}}})
+	if err != nil {
+		t.Fatal("Expected to block seats successfully")
+	}
+
+	_, err = flightService.Relayout(context.Background(), flight, &models.FlightLayout{
+		Blocks: []models.Block{{Rows: 1, SideSeatNumbers: []int{2, 2}}},
+	})
+	if err != nil {
+		t.Fatal("Expected to re-layout flight successfully")
+	}
+
+	seats, _ := seatService.ListAll(context.Background(), "a", flight.ID, "", "", "")
+	for _, seat := range seats {
+		if (seat.Line == "B" || seat.Line == "C") != seat.Blocked {
+			t.Errorf("Expected to keep block state of seat %v%v", seat.Row, seat.Line)
+		}
+		if seat.Blocked && seat.Assigned {
+			t.Errorf("Expected not to move occupant onto blocked seat %v%v", seat.Row, seat.Line)
+		}
+	}
+}
+
+func Test_FlightService_Relayout_Closed_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := NewBookingService(db, newTestCacheManager()).Close(context.Background(), flight)
+	if err != nil {
+		t.Fatal("Expected to close flight successfully")
+	}
+	flight, _ = flightService.Retrieve(context.Background(), "a", flight.ID)
+
+	for _, dryRun := range []bool{true, false} {
+		_, err = flightService.Relayout(context.Background(), flight, &models.FlightLayout{
+			Blocks: []models.Block{{Rows: 1, SideSeatNumbers: []int{2, 2}}},
+			DryRun: dryRun,
+		})
+		if !errors.Is(err, apperrors.ErrFlightClosed) {
+			t.Errorf("Expected to reject re-layout of closed flight, dry run %v", dryRun)
+		}
+	}
+
+	seats, _ := seatService.ListAll(context.Background(), "a", flight.ID, "", "", "")
+	if len(seats) != 8 {
+		t.Error("Expected to keep seats of closed flight intact")
+	}
+}
+
+func Test_FlightService_Relayout_DryRun_Success(t *testing.T) {
+	flightService, seatService := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+	newTestOccupants(t, seatService, flight)
+
+	result, err := flightService.Relayout(context.Background(), flight, &models.FlightLayout{
+		Blocks: []models.Block{{Rows: 1, SideSeatNumbers: []int{1, 1}}},
+		DryRun: true,
+	})
+	if err != nil || !result.DryRun {
+		t.Fatal("Expected to preview re-layout successfully")
+	}
+	if len(result.Unaccommodated) != 1 || result.Unaccommodated[0].Owner != "p2" {
+		t.Error("Expected to report passenger without seat of the same type")
+	}
+
+	seats, _ := seatService.ListAll(context.Background(), "a", flight.ID, "", "", "")
+	if len(seats) != 8 {
+		t.Error("Expected dry run to keep seats intact")
+	}
+}
+
+func Test_FlightService_Relayout_Stale_Failure(t *testing.T) {
+	flightService, _ := newTestServices(NewFakeDB())
+
+	flight := newTestFlight(t, flightService, "a")
+	stale := *flight
+	stale.Version++
+
+	_, err := flightService.Relayout(context.Background(), &stale, &models.FlightLayout{
+		Blocks: []models.Block{{Rows: 1, SideSeatNumbers: []int{2, 2}}},
+	})
+	if _, ok := err.(gorp.OptimisticLockError); !ok {
+		t.Error("Expected to reject stale flight version")
+	}
+}
+
+// FakePublisher is fake event publisher
+type FakePublisher struct {
+	events []*events.Event
+}
+
+// Publish records event
+func (publisher *FakePublisher) Publish(event *events.Event) error {
+	publisher.events = append(publisher.events, event)
+	return nil
+}
+
+func newTestFullFlight(t *testing.T, db sqldb.DBInterface) (*models.Flight, SeatServiceInterface,
+	WaitlistServiceInterface, *FakePublisher) {
+	flightService, seatService := newTestServices(db)
+	publisher := &FakePublisher{}
+	waitlistService := NewWaitlistService(db, publisher, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	batch := &models.SeatBatch{Action: models.SeatActionAssign, Owner: "owner"}
+	for i := 1; i <= 8; i++ {
+		batch.Seats = append(batch.Seats, models.SeatRef{Index: i})
+	}
+
+	_, err := seatService.Batch(context.Background(), "a", flight.ID, batch)
+	if err != nil {
+		t.Fatal("Expected to fill flight successfully")
+	}
+
+	return flight, seatService, waitlistService, publisher
+}
+
+func Test_WaitlistService_Promote_Priority_Success(t *testing.T) {
+	flight, seatService, waitlistService, publisher := newTestFullFlight(t, NewFakeDB())
+
+	standard := &models.WaitlistEntry{TenantID: "a", FlightID: flight.ID, Owner: "standard"}
+	gold := &models.WaitlistEntry{TenantID: "a", FlightID: flight.ID, Owner: "gold", Tier: models.TierGold}
+	for _, entry := range []*models.WaitlistEntry{standard, gold} {
+		err := waitlistService.Join(context.Background(), entry)
+		if err != nil || entry.Status != models.WaitlistStatusWaiting {
+			t.Fatal("Expected to wait for seat on full flight")
+		}
+	}
+
+	entries, err := waitlistService.ListAll(context.Background(), "a", flight.ID)
+	if err != nil || len(entries) != 2 || entries[0].Owner != "gold" || entries[1].Position != 2 {
+		t.Fatal("Expected to list entries by tier and then by time")
+	}
+
+	seat, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 3)
+	seat.Assigned = false
+	seat.Owner = ""
+	_ = seatService.Update(context.Background(), seat)
+
+	granted, err := waitlistService.Promote(context.Background(), "a", flight.ID)
+	if err != nil || len(granted) != 1 || granted[0].Owner != "gold" || granted[0].SeatIndex != 3 {
+		t.Fatal("Expected to grant released seat to the higher tier")
+	}
+
+	seat, _ = seatService.Retrieve(context.Background(), "a", flight.ID, 3)
+	if !seat.Assigned || seat.Owner != "gold" {
+		t.Error("Expected to assign granted seat")
+	}
+
+	if len(publisher.events) != 1 || publisher.events[0].Type != events.TypeSeatGranted {
+		t.Error("Expected to publish seat granted event")
+	}
+
+	entries, _ = waitlistService.ListAll(context.Background(), "a", flight.ID)
+	if len(entries) != 1 || entries[0].Owner != "standard" || entries[0].Position != 1 {
+		t.Error("Expected to keep lower tier waiting")
+	}
+}
+
+func Test_WaitlistService_Join_FreeSeat_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	waitlistService := NewWaitlistService(db, &FakePublisher{}, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	entry := &models.WaitlistEntry{TenantID: "a", FlightID: flight.ID, Owner: "owner"}
+	err := waitlistService.Join(context.Background(), entry)
+	if err != nil || entry.Status != models.WaitlistStatusGranted || entry.SeatIndex == 0 {
+		t.Fatal("Expected to grant free seat at once")
+	}
+
+	seat, _ := seatService.Retrieve(context.Background(), "a", flight.ID, int64(entry.SeatIndex))
+	if seat.Owner != "owner" {
+		t.Error("Expected to assign granted seat")
+	}
+}
+
+func Test_WaitlistService_Join_Duplicate_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flight, _, waitlistService, _ := newTestFullFlight(t, db)
+
+	_ = waitlistService.Join(context.Background(), &models.WaitlistEntry{
+		TenantID: "a", FlightID: flight.ID, Owner: "owner"})
+
+	err := waitlistService.Join(context.Background(), &models.WaitlistEntry{
+		TenantID: "a", FlightID: flight.ID, Owner: "owner"})
+	if !errors.Is(err, apperrors.ErrWaitlistDuplicate) {
+		t.Error("Expected to reject owner already waiting")
+	}
+	if db.outside != 0 {
+		t.Errorf("Expected waiting entries to be counted in the transaction, got %v reads outside", db.outside)
+	}
+}
+
+func Test_WaitlistService_Cancel_Success(t *testing.T) {
+	flight, _, waitlistService, _ := newTestFullFlight(t, NewFakeDB())
+
+	entry := &models.WaitlistEntry{TenantID: "a", FlightID: flight.ID, Owner: "owner"}
+	_ = waitlistService.Join(context.Background(), entry)
+
+	err := waitlistService.Cancel(context.Background(), entry)
+	if err != nil {
+		t.Fatal("Expected to cancel entry successfully")
+	}
+
+	entries, _ := waitlistService.ListAll(context.Background(), "a", flight.ID)
+	if len(entries) != 0 {
+		t.Error("Expected to remove cancelled entry from waitlist")
+	}
+
+	err = waitlistService.Cancel(context.Background(), entry)
+	if !errors.Is(err, apperrors.ErrWaitlistNotWaiting) {
+		t.Error("Expected to reject cancelling entry twice")
+	}
+}
+
+func Test_WaitlistService_Retrieve_OtherTenant_Failure(t *testing.T) {
+	flight, _, waitlistService, _ := newTestFullFlight(t, NewFakeDB())
+
+	entry := &models.WaitlistEntry{TenantID: "a", FlightID: flight.ID, Owner: "owner"}
+	_ = waitlistService.Join(context.Background(), entry)
+
+	_, err := waitlistService.Retrieve(context.Background(), "b", flight.ID, entry.ID)
+	if !errors.Is(err, apperrors.ErrWaitlistEntryNotFound) {
+		t.Error("Expected to hide entry of other tenant")
+	}
+}
+
+func newTestBookings(t *testing.T, bookingService BookingServiceInterface, flight *models.Flight,
+	owners ...string) []*models.Booking {
+	var bookings []*models.Booking
+
+	for _, owner := range owners {
+		booking := &models.Booking{TenantID: flight.TenantID, FlightID: flight.ID, Owner: owner}
+		err := bookingService.Create(context.Background(), booking)
+		if err != nil {
+			t.Fatal("Expected to create booking successfully")
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings
+}
+
+func Test_BookingService_Create_Overbooking_Success(t *testing.T) {
+	db := NewFakeDB()
+	flight, _, _, _ := newTestFullFlight(t, db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+
+	flight.Overbooking = 25
+	_ = NewFlightService(db, NewBlockService(db), NewSeatService(db, newTestCacheManager()),
+		newTestCacheManager()).Update(context.Background(), flight)
+
+	newTestBookings(t, bookingService, flight, "p1", "p2")
+
+	err := bookingService.Create(context.Background(), &models.Booking{TenantID: "a", FlightID: flight.ID, Owner: "p3"})
+	if !errors.Is(err, apperrors.ErrOverbookingLimit) {
+		t.Error("Expected to reject booking beyond overbooking limit")
+	}
+}
+
+func Test_SeatService_Assign_Overbooking_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+	newTestBookings(t, bookingService, flight, "p1", "p2")
+
+	for i := 0; i < 6; i++ {
+		_, err := seatService.Assign(context.Background(), "a", flight.ID, "owner")
+		if err != nil {
+			t.Fatal("Expected to assign seat successfully")
+		}
+	}
+
+	_, err := seatService.Assign(context.Background(), "a", flight.ID, "owner")
+	if !errors.Is(err, apperrors.ErrOverbookingLimit) {
+		t.Error("Expected to keep free seats for unseated bookings")
+	}
+	if db.outside != 0 {
+		t.Errorf("Expected passengers to be counted in the transaction, got %v reads outside", db.outside)
+	}
+}
+
+func Test_SeatService_Batch_Overbooking_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+	newTestBookings(t, bookingService, flight, "p1", "p2")
+
+	refs := make([]models.SeatRef, 8)
+	for i := range refs {
+		refs[i].Index = i + 1
+	}
+
+	results, err := seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action:     models.SeatActionAssign,
+		Owner:      "group",
+		BestEffort: true,
+		Seats:      refs,
+	})
+	if err != nil || len(results) != 8 {
+		t.Fatal("Expected to apply best effort batch successfully")
+	}
+	if !results[5].Succeeded || results[6].Succeeded || results[6].Error.Code != "flight.OverbookingLimit" {
+		t.Error("Expected to keep free seats for unseated bookings")
+	}
+}
+
+func Test_SeatService_Update_Release_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+	booking := newTestBookings(t, bookingService, flight, "p1")[0]
+
+	seat, err := bookingService.Seat(context.Background(), booking)
+	if err != nil {
+		t.Fatal("Expected to seat booking successfully")
+	}
+
+	seat.Assigned = false
+	seat.Owner = ""
+
+	err = seatService.Update(context.Background(), seat)
+	if err != nil {
+		t.Fatal("Expected to release seat successfully")
+	}
+
+	booking = db.tables["bookings"][0].(*models.Booking)
+	if booking.Status != models.BookingStatusConfirmed || booking.SeatIndex != 0 {
+		t.Errorf("Expected booking of released seat to wait for seat again, got %+v", *booking)
+	}
+}
+
+func Test_SeatService_Update_Closed_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+	_, err := NewBookingService(db, newTestCacheManager()).Close(context.Background(), flight)
+	if err != nil {
+		t.Fatal("Expected to close flight successfully")
+	}
+
+	seat, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	seat.Assigned = true
+	seat.Owner = "owner"
+
+	err = seatService.Update(context.Background(), seat)
+	if !errors.Is(err, apperrors.ErrFlightClosed) {
+		t.Error("Expected to reject seat assignment on closed flight")
+	}
+
+	seat, _ = seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	if seat.Assigned {
+		t.Error("Expected seat to stay free")
+	}
+}
+
+func Test_SeatService_Update_Taken_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, _ := seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	seat.Assigned = true
+	seat.Owner = "p1"
+
+	err := seatService.Update(context.Background(), seat)
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	seat, _ = seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	seat.Owner = "agent"
+
+	err = seatService.Update(context.Background(), seat)
+	if !errors.Is(err, apperrors.ErrSeatTaken) {
+		t.Error("Expected to reject reassigning seat of another owner")
+	}
+
+	seat, _ = seatService.Retrieve(context.Background(), "a", flight.ID, 1)
+	if !seat.Assigned || seat.Owner != "p1" {
+		t.Errorf("Expected seat to stay with its owner, got %+v", *seat)
+	}
+}
+
+func Test_BookingService_Seat_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+	booking := newTestBookings(t, bookingService, flight, "p1")[0]
+
+	seat, err := bookingService.Seat(context.Background(), booking)
+	if err != nil || booking.Status != models.BookingStatusSeated || booking.SeatIndex != seat.Index {
+		t.Fatal("Expected to seat booking successfully")
+	}
+
+	seat, _ = seatService.Retrieve(context.Background(), "a", flight.ID, int64(booking.SeatIndex))
+	if !seat.Assigned || seat.Owner != "p1" {
+		t.Error("Expected to assign seat to the booking owner")
+	}
+
+	_, err = bookingService.Seat(context.Background(), booking)
+	if !errors.Is(err, apperrors.ErrBookingNotConfirmed) {
+		t.Error("Expected to reject seating booking twice")
+	}
+}
+
+func Test_BookingService_Close_Success(t *testing.T) {
+	db := NewFakeDB()
+	flight, seatService, _, _ := newTestFullFlight(t, db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+
+	flight.Overbooking = 25
+	_ = NewFlightService(db, NewBlockService(db), seatService, newTestCacheManager()).Update(context.Background(), flight)
+
+	newTestBookings(t, bookingService, flight, "p1", "p2")
+
+	report, err := bookingService.Close(context.Background(), flight)
+	if err != nil || flight.Status != models.FlightStatusClosed || db.outside != 0 {
+		t.Fatal("Expected to close flight successfully in one transaction")
+	}
+	if report.Seats != 8 || report.Limit != 10 || report.Seated != 8 || len(report.Denied) != 2 ||
+		report.Denied[0].Status != models.BookingStatusDenied {
+		t.Error("Expected to report unseated bookings as denied boarding")
+	}
+
+	report, err = bookingService.DeniedBoarding(context.Background(), flight)
+	if err != nil || len(report.Denied) != 2 {
+		t.Error("Expected to get denied boarding report of closed flight")
+	}
+
+	_, err = bookingService.Close(context.Background(), flight)
+	if !errors.Is(err, apperrors.ErrFlightClosed) {
+		t.Error("Expected to reject closing flight twice")
+	}
+}
+
+func Test_BookingService_DeniedBoarding_Open_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+	bookingService := NewBookingService(db, newTestCacheManager())
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := bookingService.DeniedBoarding(context.Background(), flight)
+	if !errors.Is(err, apperrors.ErrFlightNotClosed) {
+		t.Error("Expected to have no report for open flight")
+	}
+}
+
+func newTestCheckin(flight *models.Flight, seat *models.Seat) *models.Checkin {
+	return &models.Checkin{TenantID: flight.TenantID, FlightID: flight.ID, SeatIndex: seat.Index, Owner: seat.Owner,
+		FirstName: "Luc", LastName: "Desmarais"}
+}
+
+func Test_CheckinService_Create_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	checkinService := NewCheckinService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	for sequence := 1; sequence <= 2; sequence++ {
+		seat, err := seatService.Assign(context.Background(), flight.TenantID, flight.ID, "owner")
+		if err != nil {
+			t.Fatal("Expected to assign seat successfully")
+		}
+
+		checkin := newTestCheckin(flight, seat)
+		err = checkinService.Create(context.Background(), checkin)
+		if err != nil || checkin.Sequence != sequence || checkin.Row != seat.Row || checkin.Line != seat.Line {
+			t.Errorf("Expected to check in with sequence %v, got %+v", sequence, checkin)
+		}
+	}
+
+	if db.outside != 0 {
+		t.Errorf("Expected check-ins to be counted in the transaction, got %v reads outside", db.outside)
+	}
+}
+
+func Test_CheckinService_Create_NotAssigned_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	checkinService := NewCheckinService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Retrieve(context.Background(), flight.TenantID, flight.ID, 1)
+	if err != nil {
+		t.Fatal("Expected to retrieve seat successfully")
+	}
+
+	err = checkinService.Create(context.Background(), newTestCheckin(flight, seat))
+	if !errors.Is(err, apperrors.ErrSeatNotAssigned) || len(db.tables["checkins"]) != 0 {
+		t.Error("Expected to reject check-in of free seat")
+	}
+}
+
+func Test_CheckinService_Create_Duplicate_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	checkinService := NewCheckinService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Assign(context.Background(), flight.TenantID, flight.ID, "owner")
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	err = checkinService.Create(context.Background(), newTestCheckin(flight, seat))
+	if err != nil {
+		t.Fatal("Expected to check in successfully")
+	}
+
+	err = checkinService.Create(context.Background(), newTestCheckin(flight, seat))
+	if !errors.Is(err, apperrors.ErrCheckinExists) {
+		t.Error("Expected to reject checking in twice")
+	}
+}
+
+func Test_CheckinService_Move_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	checkinService := NewCheckinService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Assign(context.Background(), flight.TenantID, flight.ID, "owner")
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	checkin := newTestCheckin(flight, seat)
+	err = checkinService.Create(context.Background(), checkin)
+	if err != nil {
+		t.Fatal("Expected to check in successfully")
+	}
+
+	seats, err := seatService.Move(context.Background(), seat, 6)
+	if err != nil {
+		t.Fatal("Expected to move checked in passenger successfully")
+	}
+
+	checkin, _ = checkinService.Retrieve(context.Background(), flight.TenantID, flight.ID, checkin.ID)
+	target := seats[1]
+	if checkin.SeatIndex != target.Index || checkin.Row != target.Row || checkin.Line != target.Line {
+		t.Errorf("Expected check-in to follow passenger to seat %v, got %+v", target.Index, *checkin)
+	}
+
+	pass := checkinService.BoardingPass(flight, checkin)
+	if pass.Seat != fmt.Sprintf("%d%s", target.Row, target.Line) {
+		t.Errorf("Expected boarding pass of the new seat, got %v", pass.Seat)
+	}
+
+	seat, err = seatService.Assign(context.Background(), flight.TenantID, flight.ID, "other")
+	if err != nil || seat.Index != seats[0].Index {
+		t.Fatal("Expected to assign vacated seat successfully")
+	}
+
+	err = checkinService.Create(context.Background(), newTestCheckin(flight, seat))
+	if err != nil {
+		t.Error("Expected to check in new passenger of the vacated seat")
+	}
+}
+
+func Test_CheckinService_Release_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	checkinService := NewCheckinService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Assign(context.Background(), flight.TenantID, flight.ID, "owner")
+	if err == nil {
+		err = checkinService.Create(context.Background(), newTestCheckin(flight, seat))
+	}
+	if err != nil {
+		t.Fatal("Expected to check in successfully")
+	}
+
+	_, err = seatService.Batch(context.Background(), "a", flight.ID, &models.SeatBatch{
+		Action: models.SeatActionRelease, Seats: []models.SeatRef{{Index: seat.Index}}})
+	if !errors.Is(err, apperrors.ErrSeatCheckedIn) {
+		t.Error("Expected to reject releasing seat of checked in passenger")
+	}
+
+	seat, _ = seatService.Retrieve(context.Background(), "a", flight.ID, int64(seat.Index))
+	if !seat.Assigned || seat.Owner != "owner" {
+		t.Error("Expected seat to stay assigned")
+	}
+}
+
+func Test_CheckinService_Create_Closed_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	checkinService := NewCheckinService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Assign(context.Background(), flight.TenantID, flight.ID, "owner")
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	_, err = NewBookingService(db, newTestCacheManager()).Close(context.Background(), flight)
+	if err != nil {
+		t.Fatal("Expected to close flight successfully")
+	}
+
+	err = checkinService.Create(context.Background(), newTestCheckin(flight, seat))
+	if !errors.Is(err, apperrors.ErrFlightClosed) {
+		t.Error("Expected to reject check-in of closed flight")
+	}
+}
+
+func Test_CheckinService_BoardingPass_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	checkinService := NewCheckinService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+	flight.Carrier, flight.Number, flight.Origin, flight.Destination = "AC", "834", "YUL", "FRA"
+
+	seat, err := seatService.Assign(context.Background(), flight.TenantID, flight.ID, "owner")
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	checkin := newTestCheckin(flight, seat)
+	err = checkinService.Create(context.Background(), checkin)
+	if err != nil {
+		t.Fatal("Expected to check in successfully")
+	}
+
+	pass := checkinService.BoardingPass(flight, checkin)
+	if pass.Seat != "1"+seat.Line || pass.Sequence != 1 || len(pass.PNR) != 6 || len(pass.BCBP) != 60 ||
+		!strings.Contains(pass.BCBP, "YULFRAAC 0834") || !strings.Contains(pass.BCBP, "001"+seat.Line+"0001") {
+		t.Errorf("Expected to make boarding pass, got %+v", pass)
+	}
+}
+
+func newTestBoardingFlight(t *testing.T, db sqldb.DBInterface) *models.Flight {
+	flightService, seatService := newTestServices(db)
+
+	flight := &models.Flight{
+		TenantID: "a",
+		Name:     "Flight a",
+		Blocks: []models.Block{
+			{Rows: 1, SideSeatNumbers: []int{2, 2}},
+			{Rows: 2, SideSeatNumbers: []int{2, 2}},
+		},
+	}
+
+	err := flightService.Create(context.Background(), flight)
+	if err != nil {
+		t.Fatal("Expected to create flight successfully")
+	}
+
+	for i := 0; i < 12; i++ {
+		_, err = seatService.Assign(context.Background(), flight.TenantID, flight.ID, "owner")
+		if err != nil {
+			t.Fatal("Expected to assign seat successfully")
+		}
+	}
+
+	return flight
+}
+
+func Test_BoardingService_Manifest_BackToFront_Success(t *testing.T) {
+	db := NewFakeDB()
+	boardingService := NewBoardingService(db)
+
+	flight := newTestBoardingFlight(t, db)
+
+	query := &models.BoardingQuery{Strategy: models.BoardingStrategyBackToFront, Zones: 2, PremiumBlocks: 1}
+	manifest, err := boardingService.Manifest(context.Background(), flight, query)
+	if err != nil || manifest.Passengers != 12 || len(manifest.Zones) != 3 {
+		t.Fatalf("Expected to make boarding manifest successfully, got %+v", manifest)
+	}
+
+	for i, expected := range []struct{ block, row int }{{1, 1}, {2, 2}, {2, 1}} {
+		zone := manifest.Zones[i]
+		if zone.Zone != i+1 || len(zone.Passengers) != 4 || zone.Passengers[0].Block != expected.block ||
+			zone.Passengers[0].Row != expected.row {
+			t.Errorf("Expected to board premium block first and then rear rows, got %+v", zone)
+		}
+	}
+}
+
+func Test_BoardingService_Manifest_Wilma_Success(t *testing.T) {
+	db := NewFakeDB()
+	boardingService := NewBoardingService(db)
+
+	flight := newTestBoardingFlight(t, db)
+
+	manifest, err := boardingService.Manifest(context.Background(), flight,
+		&models.BoardingQuery{Strategy: models.BoardingStrategyWilma})
+	if err != nil || len(manifest.Zones) != 2 {
+		t.Fatalf("Expected to make boarding manifest successfully, got %+v", manifest)
+	}
+
+	if manifest.Zones[0].Zone != 1 || manifest.Zones[0].Passengers[0].Type != models.SeatTypeWindow ||
+		manifest.Zones[1].Zone != 3 || manifest.Zones[1].Passengers[0].Type != models.SeatTypeAisle {
+		t.Error("Expected to board window seats before aisle seats")
+	}
+	if first := manifest.Zones[0].Passengers[0]; first.Block != 2 || first.Row != 2 {
+		t.Error("Expected to list rear passengers of the zone first")
+	}
+}
+
+func Test_BoardingService_Manifest_Block_Success(t *testing.T) {
+	db := NewFakeDB()
+	boardingService := NewBoardingService(db)
+
+	flight := newTestBoardingFlight(t, db)
+	flight.Blocks = append(flight.Blocks, models.Block{Rows: 1, SideSeatNumbers: []int{3, 3}})
+
+	manifest, err := boardingService.Manifest(context.Background(), flight,
+		&models.BoardingQuery{Strategy: models.BoardingStrategyBlock})
+	if err != nil || len(manifest.Zones) != 2 {
+		t.Fatalf("Expected to make boarding manifest successfully, got %+v", manifest)
+	}
+
+	for i, expected := range []struct{ zone, block, passengers int }{{2, 2, 8}, {3, 1, 4}} {
+		zone := manifest.Zones[i]
+		if zone.Zone != expected.zone || len(zone.Passengers) != expected.passengers ||
+			zone.Passengers[0].Block != expected.block {
+			t.Errorf("Expected to count empty rear block of the flight, got %+v", zone)
+		}
+	}
+}
+
+func Test_ManifestService_Export_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	checkinService := NewCheckinService(db)
+	manifestService := NewManifestService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	seat, err := seatService.Assign(context.Background(), flight.TenantID, flight.ID, "owner")
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	err = checkinService.Create(context.Background(), newTestCheckin(flight, seat))
+	if err != nil {
+		t.Fatal("Expected to check in successfully")
+	}
+
+	cursor, err := manifestService.Export(context.Background(), flight, "", "")
+	if err != nil {
+		t.Fatal("Expected to export manifest successfully")
+	}
+
+	var rows []models.ManifestRow
+	for {
+		row, err := cursor.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected to iterate manifest successfully")
+		}
+		rows = append(rows, *row)
+	}
+
+	if len(rows) != 8 || rows[0].Index != 1 || rows[7].Index != 8 {
+		t.Fatalf("Expected to export all seats by index, got %+v", rows)
+	}
+	for _, row := range rows {
+		if row.Index == seat.Index && (!row.Assigned || row.LastName != "Desmarais" || row.Sequence != 1) {
+			t.Errorf("Expected to export passenger data of checked in seat, got %+v", row)
+		}
+		if row.Index != seat.Index && (row.Assigned || row.LastName != "") {
+			t.Errorf("Expected to export free seat without passenger data, got %+v", row)
+		}
+	}
+
+	if cursor.Close() != nil || !cursor.rows.(*fakeRows).closed {
+		t.Error("Expected to close cursor")
+	}
+}
+
+func Test_ManifestService_Export_Filter_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	manifestService := NewManifestService(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	c, _ := gin.CreateTestContext(nil)
+	c.Request, _ = http.NewRequest("GET", "/?filter=row:eq:2&filter=assigned:eq:false&sort=line:desc", nil)
+	queryManager := helpers.NewQueryManager()
+	filtering, errs := queryManager.GetFiltering(&models.Seat{}, c)
+	sorting, sortErrs := queryManager.GetSorting(&models.Seat{}, c)
+	if len(errs) != 0 || len(sortErrs) != 0 {
+		t.Fatalf("Expected seat list parameters to be valid, got %v %v", errs, sortErrs)
+	}
+
+	cursor, err := manifestService.Export(context.Background(), flight, filtering, sorting)
+	if err != nil {
+		t.Fatal("Expected to export manifest successfully")
+	}
+	if db.query != manifestQuery+filtering+sorting {
+		t.Errorf("Expected seat list filtering and sorting to apply to manifest columns, got %v", db.query)
+	}
+
+	var indexes []int
+	for {
+		row, err := cursor.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected to iterate manifest successfully")
+		}
+		indexes = append(indexes, row.Index)
+	}
+
+	seats, err := seatService.ListAll(context.Background(), "a", flight.ID, filtering, sorting, "")
+	if err != nil {
+		t.Fatal("Expected to list seats successfully")
+	}
+	if len(indexes) != 4 || len(seats) != len(indexes) || indexes[0] != 8 || indexes[3] != 5 {
+		t.Fatalf("Expected to export filtered seats sorted by line, got %v", indexes)
+	}
+	for i, seat := range seats {
+		if seat.Index != indexes[i] {
+			t.Errorf("Expected manifest to match seat list, got %v and %v", indexes[i], seat.Index)
+		}
+	}
+}
+
+func newTestScheduleReader(t *testing.T, input string) schedule.Reader {
+	reader, err := schedule.NewReader(schedule.FormatNDJSON, strings.NewReader(input))
+	if err != nil {
+		t.Fatal("Expected to make schedule reader successfully")
+	}
+
+	return reader
+}
+
+func Test_ScheduleService_Import_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+	scheduleService := NewScheduleService(flightService)
+
+	template := newTestFlight(t, flightService, "a")
+
+	input := fmt.Sprintf(`{"name":"One","blocks":[{"rows":1,"side_seat_numbers":[1,1]}]}
+{"name":"Two","template":%d}
+{"name":"Three","template":%d,"carrier":"a"}
+{"name":"Four","template":%d}
+{"name":"Five","template":999}`, template.ID, template.ID, template.ID)
+
+	var progress []models.ImportProgress
+	report, err := scheduleService.Import(context.Background(), "a", newTestScheduleReader(t, input),
+		&models.ImportOptions{ChunkSize: 2}, func(item models.ImportProgress) { progress = append(progress, item) })
+	if err != nil {
+		t.Fatal("Expected to import schedule successfully")
+	}
+
+	if report.Rows != 5 || report.Valid != 3 || report.Created != 3 || report.Failed != 2 || len(report.Errors) != 2 ||
+		report.Errors[0].Row != 3 || report.Errors[0].Errors[0].Code != "carrier.Invalid" ||
+		report.Errors[1].Row != 5 || report.Errors[1].Errors[0].Code != "template.NotFound" {
+		t.Fatalf("Expected to report imported flights and row errors, got %+v", report)
+	}
+	if len(progress) != 2 || progress[0].Created != 2 || progress[1].Created != 3 {
+		t.Errorf("Expected to report progress after every chunk, got %+v", progress)
+	}
+	if len(db.tables["flights"]) != 4 || len(db.tables["seats"]) != 8+2+8+8 {
+		t.Error("Expected to create flights with inline and template seats")
+	}
+}
+
+// FakeFlightService is fake flight service failing to create flights
+type FakeFlightService struct {
+	FlightServiceInterface
+}
+
+// CreateAll fails to create flights
+func (flightService *FakeFlightService) CreateAll(ctx context.Context, flights []*models.Flight) error {
+	return errors.New("db is unavailable")
+}
+
+func Test_ScheduleService_Import_Chunk_Failure(t *testing.T) {
+	scheduleService := NewScheduleService(&FakeFlightService{})
+
+	input := `{"name":"One","blocks":[{"rows":1,"side_seat_numbers":[1,1]}]}
+{"name":"Two","blocks":[{"rows":0,"side_seat_numbers":[1,1]}]}
+{"name":"Three","blocks":[{"rows":1,"side_seat_numbers":[1,1]}]}`
+
+	report, err := scheduleService.Import(context.Background(), "a", newTestScheduleReader(t, input),
+		&models.ImportOptions{ChunkSize: 10}, nil)
+	if err != nil {
+		t.Fatal("Expected to report failed chunk in the import report")
+	}
+	if report.Rows != 3 || report.Valid != 0 || report.Created != 0 || report.Failed != 3 ||
+		report.Valid+report.Failed != report.Rows || len(report.Errors) != 3 {
+		t.Errorf("Expected to count rows of the failed chunk only as failed, got %+v", report)
+	}
+}
+
+func Test_ScheduleService_Import_Cancelled_Failure(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+	scheduleService := NewScheduleService(flightService)
+
+	input := `{"name":"One","blocks":[{"rows":1,"side_seat_numbers":[1,1]}]}
+{"name":"Two","blocks":[{"rows":1,"side_seat_numbers":[1,1]}]}`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	report, err := scheduleService.Import(ctx, "a", newTestScheduleReader(t, input),
+		&models.ImportOptions{ChunkSize: 1}, func(item models.ImportProgress) { cancel() })
+	if !errors.Is(err, context.Canceled) || report.Rows != 1 || report.Created != 1 {
+		t.Errorf("Expected to stop cancelled import after the chunk in progress, got %+v", report)
+	}
+	if len(db.tables["flights"]) != 1 {
+		t.Error("Expected to keep flights created before cancellation")
+	}
+}
+
+func Test_ScheduleService_Import_DryRun_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+	scheduleService := NewScheduleService(flightService)
+
+	input := `{"name":"One","blocks":[{"rows":1,"side_seat_numbers":[1,1]}]}
+{"name":"Two","blocks":[{"rows":0,"side_seat_numbers":[1,1]}]}`
+
+	report, err := scheduleService.Import(context.Background(), "a", newTestScheduleReader(t, input),
+		&models.ImportOptions{DryRun: true, ChunkSize: 10}, nil)
+	if err != nil || !report.DryRun || report.Valid != 1 || report.Created != 0 || report.Failed != 1 {
+		t.Errorf("Expected to validate schedule without creating flights, got %+v", report)
+	}
+	if len(db.tables["flights"]) != 0 {
+		t.Error("Expected to create no flights in dry run")
+	}
+}
+
+// newTestMaxFlight makes flight of max size, models maxRows rows by maxLines lines
+func newTestMaxFlight() *models.Flight {
+	return &models.Flight{
+		TenantID: "a",
+		Name:     "Flight max",
+		Blocks: []models.Block{
+			{Rows: 200, SideSeatNumbers: []int{5, 5}, MiddleSeatNumbers: []int{10}},
+		},
+	}
+}
+
+func Test_FlightService_Create_Batch_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+
+	flight := newTestMaxFlight()
+	err := flightService.Create(context.Background(), flight)
+	if err != nil {
+		t.Fatal("Expected to create flight successfully")
+	}
+
+	seats := db.tables["seats"]
+	if len(seats) != 4000 || db.statements != 1+1+1+4000/maxInsertRows {
+		t.Fatalf("Expected to create seats by multi-row inserts, got %v seats by %v statements", len(seats),
+			db.statements)
+	}
+	for i, row := range seats {
+		seat := row.(*models.Seat)
+		if seat.Index != i+1 || seat.CreatedAt != flight.CreatedAt || seat.FlightID != flight.ID || seat.Version != 1 {
+			t.Fatalf("Expected to create seats with flight creation time, got %+v", seat)
+		}
+	}
+}
+
+func Test_BlockService_ListAll_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+	blockService := NewBlockService(db)
+
+	flight := &models.Flight{
+		TenantID: "a",
+		Name:     "Flight a",
+		Blocks: []models.Block{
+			{Rows: 1, SideSeatNumbers: []int{2, 2}},
+			{Rows: 2, SideSeatNumbers: []int{3, 1}, MiddleSeatNumbers: []int{4, 2}},
+		},
+	}
+	if err := flightService.Create(context.Background(), flight); err != nil {
+		t.Fatal("Expected to create flight successfully")
+	}
+	newTestFlight(t, flightService, "a")
+
+	db.selects = 0
+	blocks, err := blockService.ListAll(context.Background(), "a", flight.ID)
+	if err != nil || db.selects != 2 {
+		t.Fatalf("Expected to list blocks by two queries, got %v", db.selects)
+	}
+	if len(blocks) != 2 || !reflect.DeepEqual(blocks[0].SideSeatNumbers, []int{2, 2}) ||
+		blocks[0].MiddleSeatNumbers != nil || !reflect.DeepEqual(blocks[1].SideSeatNumbers, []int{3, 1}) ||
+		!reflect.DeepEqual(blocks[1].MiddleSeatNumbers, []int{4, 2}) {
+		t.Errorf("Expected to list blocks with their seat numbers, got %+v", blocks)
+	}
+}
+
+func Test_FlightService_Retrieve_Cached_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := flightService.Retrieve(context.Background(), "a", flight.ID)
+	if err != nil {
+		t.Fatal("Expected to retrieve flight successfully")
+	}
+
+	db.selects = 0
+	cached, err := flightService.Retrieve(context.Background(), "a", flight.ID)
+	if err != nil || db.selects != 0 {
+		t.Fatalf("Expected to retrieve flight from cache, got %v selects", db.selects)
+	}
+	if cached.TenantID != "a" || len(cached.Blocks) != 1 || cached.Blocks[0].ID != flight.Blocks[0].ID {
+		t.Error("Expected cached flight to keep tenant and block ids")
+	}
+
+	_, err = flightService.Retrieve(context.Background(), "b", flight.ID)
+	if !errors.Is(err, apperrors.ErrFlightNotFound) {
+		t.Error("Expected cached flight to stay invisible to other tenants")
+	}
+}
+
+func Test_FlightService_Update_Invalidate_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, _ := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	retrieved, err := flightService.Retrieve(context.Background(), "a", flight.ID)
+	if err != nil {
+		t.Fatal("Expected to retrieve flight successfully")
+	}
+
+	retrieved.Name = "Renamed"
+	err = flightService.Update(context.Background(), retrieved)
+	if err != nil {
+		t.Fatal("Expected to update flight successfully")
+	}
+
+	retrieved, err = flightService.Retrieve(context.Background(), "a", flight.ID)
+	if err != nil || retrieved.Name != "Renamed" {
+		t.Error("Expected updated flight to be retrieved after invalidation")
+	}
+}
+
+func Test_BookingService_Close_Invalidate_Success(t *testing.T) {
+	db := NewFakeDB()
+	cacheManager := newTestCacheManager()
+	flightService := NewFlightService(db, NewBlockService(db), NewSeatService(db, cacheManager), cacheManager)
+	bookingService := NewBookingService(db, cacheManager)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	retrieved, err := flightService.Retrieve(context.Background(), "a", flight.ID)
+	if err != nil {
+		t.Fatal("Expected to retrieve flight successfully")
+	}
+
+	_, err = bookingService.Close(context.Background(), retrieved)
+	if err != nil {
+		t.Fatal("Expected to close flight successfully")
+	}
+
+	retrieved, err = flightService.Retrieve(context.Background(), "a", flight.ID)
+	if err != nil || retrieved.Status != models.FlightStatusClosed {
+		t.Error("Expected closed flight to be retrieved after invalidation")
+	}
+}
+
+func Test_SeatService_Summary_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	summary, err := seatService.Summary(context.Background(), "a", flight.ID)
+	if err != nil || summary.Total != 8 || summary.Free != 8 || summary.Assigned != 0 {
+		t.Fatalf("Expected all seats to be free, got %+v", summary)
+	}
+
+	seat, err := seatService.Assign(context.Background(), "a", flight.ID, "owner")
+	if err != nil {
+		t.Fatal("Expected to assign seat successfully")
+	}
+
+	summary, err = seatService.Summary(context.Background(), "a", flight.ID)
+	if err != nil || summary.Free != 7 || summary.Assigned != 1 {
+		t.Fatalf("Expected assignment to invalidate summary, got %+v", summary)
+	}
+
+	seat.Assigned, seat.Owner, seat.Blocked = false, "", true
+	err = seatService.Update(context.Background(), seat)
+	if err != nil {
+		t.Fatal("Expected to update seat successfully")
+	}
+
+	summary, err = seatService.Summary(context.Background(), "a", flight.ID)
+	if err != nil || summary.Free != 7 || summary.Assigned != 0 || summary.Blocked != 1 {
+		t.Errorf("Expected update to invalidate summary, got %+v", summary)
+	}
+}
+
+func Test_SeatService_Summary_Relayout_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+
+	flight := newTestFlight(t, flightService, "a")
+
+	_, err := seatService.Summary(context.Background(), "a", flight.ID)
+	if err != nil {
+		t.Fatal("Expected to count seats successfully")
+	}
+
+	_, err = flightService.Relayout(context.Background(), flight, &models.FlightLayout{Blocks: []models.Block{
+		{Rows: 3, SideSeatNumbers: []int{2, 2}},
+	}})
+	if err != nil {
+		t.Fatal("Expected to re-lay out flight successfully")
+	}
+
+	summary, err := seatService.Summary(context.Background(), "a", flight.ID)
+	if err != nil || summary.Total != 12 {
+		t.Errorf("Expected re-layout to invalidate summary, got %+v", summary)
+	}
+}
+
+func Test_KPIService_Collect_Success(t *testing.T) {
+	db := NewFakeDB()
+	flightService, seatService := newTestServices(db)
+	registry := metrics.NewRegistry()
+	kpiService := NewKPIService(db, registry)
+
+	open := newTestFlight(t, flightService, "a")
+	closed := newTestFlight(t, flightService, "b")
+
+	for i := 0; i < 2; i++ {
+		_, err := seatService.Assign(context.Background(), "a", open.ID, "owner")
+		if err != nil {
+			t.Fatal("Expected to assign seat successfully")
+		}
+	}
+
+	_, err := NewBookingService(db, newTestCacheManager()).Close(context.Background(), closed)
+	if err != nil {
+		t.Fatal("Expected to close flight successfully")
+	}
+
+	err = kpiService.Collect()
+	if err != nil {
+		t.Fatal("Expected to collect flight loads successfully")
+	}
+
+	var buffer bytes.Buffer
+	_ = registry.Write(&buffer)
+	output := buffer.String()
+
+	flight := strconv.FormatInt(open.ID, 10)
+	for _, line := range []string{
+		"booking_open_flights 1",
+		`booking_flight_seats{tenant="a",flight="` + flight + `"} 8`,
+		`booking_flight_seats_assigned{tenant="a",flight="` + flight + `"} 2`,
+		`booking_flight_load_factor{tenant="a",flight="` + flight + `"} 0.25`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected metrics to contain %v", line)
+		}
+	}
+	if strings.Contains(output, `tenant="b"`) {
+		t.Error("Expected closed flight to have no gauges")
+	}
+}
+
+func Benchmark_FlightService_Create_MaxSize(b *testing.B) {
+	statements := 0
+
+	for i := 0; i < b.N; i++ {
+		db := NewFakeDB()
+		flightService, _ := newTestServices(db)
+
+		err := flightService.Create(context.Background(), newTestMaxFlight())
+		if err != nil {
+			b.Fatal("Expected to create flight successfully")
+		}
+		statements += db.statements
+	}
+
+	b.ReportMetric(float64(statements)/float64(b.N), "statements/op")
+}
+
+func Benchmark_SeatService_Create_PerSeat_MaxSize(b *testing.B) {
+	statements := 0
+
+	for i := 0; i < b.N; i++ {
+		db := NewFakeDB()
+		seatService := NewSeatService(db, newTestCacheManager())
+
+		flight := newTestMaxFlight()
+		for _, seat := range layoutSeats(flight.TenantID, flight.ID, flight.Blocks, time.Now().Unix()) {
+			seat := seat
+			err := seatService.Create(context.Background(), nil, &seat)
+			if err != nil {
+				b.Fatal("Expected to create seat successfully")
+			}
+		}
+		statements += db.statements
+	}
+
+	b.ReportMetric(float64(statements)/float64(b.N), "statements/op")
+}
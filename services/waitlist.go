@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	gorp "github.com/go-gorp/gorp/v3"
+
+	"github.com/vsukhin/booking/apperrors"
+	"github.com/vsukhin/booking/cache"
+	"github.com/vsukhin/booking/events"
+	"github.com/vsukhin/booking/logging"
+	"github.com/vsukhin/booking/models"
+	"github.com/vsukhin/booking/persistence/sqldb"
+	"github.com/vsukhin/booking/tracing"
+)
+
+// WaitlistService is a waitlist service
+type WaitlistService struct {
+	db           sqldb.DBInterface
+	publisher    events.Publisher
+	cacheManager cache.ManagerInterface
+}
+
+// WaitlistServiceInterface is an interface for waitlist service methods
+type WaitlistServiceInterface interface {
+	Join(ctx context.Context, entry *models.WaitlistEntry) error
+	Promote(ctx context.Context, tenantID string, flightID int64) ([]models.WaitlistEntry, error)
+	Cancel(ctx context.Context, entry *models.WaitlistEntry) error
+	Retrieve(ctx context.Context, tenantID string, flightID int64, id int64) (*models.WaitlistEntry, error)
+	ListAll(ctx context.Context, tenantID string, flightID int64) ([]models.WaitlistEntry, error)
+}
+
+// NewWaitlistService is a constructor for waitlist service
+func NewWaitlistService(db sqldb.DBInterface, publisher events.Publisher,
+	cacheManager cache.ManagerInterface) WaitlistServiceInterface {
+	db.AddTableWithName(models.WaitlistEntry{}, "waitlist").SetKeys(true, "ID").SetVersionCol("Version")
+
+	return &WaitlistService{db: db, publisher: publisher, cacheManager: cacheManager}
+}
+
+// Join puts waiting entry to the waitlist and grants it with seat at once if flight has free seats
+func (waitlistService *WaitlistService) Join(ctx context.Context, entry *models.WaitlistEntry) error {
+	ctx, span := tracing.Start(ctx, "WaitlistService.Join")
+	defer span.End()
+
+	var flight models.Flight
+
+	trans, err := waitlistService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"entry": *entry,
+		}).Error("Error creating transaction")
+		return err
+	}
+
+	// the flight is locked so that joins of the same owner are serialized by the duplicate check
+	err = waitlistService.db.SelectOne(ctx, trans, &flight, "SELECT * FROM flights WHERE tenant_id = ? AND id = ? "+
+		"FOR UPDATE", entry.TenantID, entry.FlightID)
+	if err == sql.ErrNoRows {
+		err = apperrors.ErrFlightNotFound
+	}
+
+	var count int64
+	if err == nil {
+		count, err = waitlistService.db.SelectInt(ctx, trans, "SELECT COUNT(*) FROM waitlist WHERE tenant_id = ? "+
+			"AND flight_id = ? AND owner = ? AND status = ?", entry.TenantID, entry.FlightID, entry.Owner,
+			models.WaitlistStatusWaiting)
+	}
+	if err != nil {
+		waitlistService.rollback(ctx, trans, entry.TenantID, entry.FlightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"entry": *entry,
+		}).Error("Error counting waitlist entries")
+		return err
+	}
+
+	if count != 0 {
+		waitlistService.rollback(ctx, trans, entry.TenantID, entry.FlightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"entry": *entry,
+		}).Error("Owner is already on the waitlist")
+		return apperrors.ErrWaitlistDuplicate
+	}
+
+	now := time.Now().Unix()
+	entry.Priority = entry.Tier.Rank()
+	entry.Status = models.WaitlistStatusWaiting
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+
+	err = waitlistService.db.Insert(ctx, trans, entry)
+	if err != nil {
+		waitlistService.rollback(ctx, trans, entry.TenantID, entry.FlightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"entry": *entry,
+		}).Error("Error creating waitlist entry")
+		return err
+	}
+
+	err = waitlistService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"entry": *entry,
+		}).Error("Error committing transaction")
+		return err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"entry": *entry,
+	}).Debug("Waitlist entry successfully created")
+
+	granted, err := waitlistService.Promote(ctx, entry.TenantID, entry.FlightID)
+	if err != nil {
+		return err
+	}
+
+	for _, grant := range granted {
+		if grant.ID == entry.ID {
+			*entry = grant
+		}
+	}
+
+	return nil
+}
+
+// Promote grants free seats to the waiting entries by tier and then by time of joining
+func (waitlistService *WaitlistService) Promote(ctx context.Context, tenantID string,
+	flightID int64) ([]models.WaitlistEntry, error) {
+	ctx, span := tracing.Start(ctx, "WaitlistService.Promote")
+	defer span.End()
+
+	granted := []models.WaitlistEntry{}
+
+	for {
+		entry, seat, err := waitlistService.grant(ctx, tenantID, flightID)
+		if err != nil {
+			return granted, err
+		}
+		if entry == nil {
+			break
+		}
+
+		granted = append(granted, *entry)
+
+		err = waitlistService.publisher.Publish(events.NewEvent(events.TypeSeatGranted, tenantID,
+			map[string]interface{}{"entry": *entry, "seat": *seat}))
+		if err != nil {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"error": err,
+				"entry": *entry,
+			}).Error("Error publishing seat granted event")
+		}
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"flightID": flightID,
+		"granted":  granted,
+	}).Debug("Waitlist successfully promoted")
+	return granted, nil
+}
+
+// grant assigns free seat to the first waiting entry in one transaction, returns nothing if flight is full,
+// sold up to the overbooking limit, closed or nobody is waiting
+func (waitlistService *WaitlistService) grant(ctx context.Context, tenantID string,
+	flightID int64) (*models.WaitlistEntry,
+	*models.Seat, error) {
+	var entry models.WaitlistEntry
+	var seat models.Seat
+
+	trans, err := waitlistService.db.Begin(ctx)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error creating transaction")
+		return nil, nil, err
+	}
+
+	capacity, err := loadCapacity(ctx, waitlistService.db, trans, tenantID, flightID)
+	if err == nil && capacity.admit(true) != nil {
+		err = sql.ErrNoRows
+	}
+	if err == nil {
+		err = waitlistService.db.SelectOne(ctx, trans, &entry, "SELECT * FROM waitlist WHERE tenant_id = ? "+
+			"AND flight_id = ? AND status = ? ORDER BY priority DESC, created_at ASC, id ASC LIMIT 1 FOR UPDATE",
+			tenantID, flightID, models.WaitlistStatusWaiting)
+	}
+	if err == nil {
+		err = waitlistService.db.SelectOne(ctx, trans, &seat, freeSeatQuery+" FOR UPDATE", tenantID, flightID)
+	}
+	if err != nil {
+		waitlistService.rollback(ctx, trans, tenantID, flightID)
+
+		if err == sql.ErrNoRows {
+			return nil, nil, nil
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error returning waitlist entry and free seat")
+		return nil, nil, err
+	}
+
+	now := time.Now().Unix()
+
+	seat.Assigned = true
+	seat.Owner = entry.Owner
+	seat.UpdatedAt = now
+	_, err = waitlistService.db.Update(ctx, trans, &seat)
+	if err == nil {
+		entry.Status = models.WaitlistStatusGranted
+		entry.SeatIndex = seat.Index
+		entry.UpdatedAt = now
+		_, err = waitlistService.db.Update(ctx, trans, &entry)
+	}
+	if err != nil {
+		waitlistService.rollback(ctx, trans, tenantID, flightID)
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"entry": entry,
+			"seat":  seat,
+		}).Error("Error granting seat")
+		return nil, nil, err
+	}
+
+	err = waitlistService.db.Commit(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error committing transaction")
+		return nil, nil, err
+	}
+
+	waitlistService.cacheManager.Delete(cache.SeatSummaryKey(tenantID, flightID))
+
+	return &entry, &seat, nil
+}
+
+func (waitlistService *WaitlistService) rollback(ctx context.Context, trans *gorp.Transaction, tenantID string,
+	flightID int64) {
+	err := waitlistService.db.Rollback(ctx, trans)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error rollbacking transaction")
+	}
+}
+
+// Cancel cancels waiting entry
+func (waitlistService *WaitlistService) Cancel(ctx context.Context, entry *models.WaitlistEntry) error {
+	ctx, span := tracing.Start(ctx, "WaitlistService.Cancel")
+	defer span.End()
+
+	if entry.Status != models.WaitlistStatusWaiting {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"entry": *entry,
+		}).Error("Waitlist entry is not waiting")
+		return apperrors.ErrWaitlistNotWaiting
+	}
+
+	entry.Status = models.WaitlistStatusCancelled
+	entry.UpdatedAt = time.Now().Unix()
+
+	_, err := waitlistService.db.Update(ctx, nil, entry)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error": err,
+			"entry": *entry,
+		}).Error("Error cancelling waitlist entry")
+		return err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"entry": *entry,
+	}).Debug("Waitlist entry successfully cancelled")
+	return nil
+}
+
+// Retrieve retrieves waitlist entry
+func (waitlistService *WaitlistService) Retrieve(ctx context.Context, tenantID string, flightID int64,
+	id int64) (*models.WaitlistEntry, error) {
+	ctx, span := tracing.Start(ctx, "WaitlistService.Retrieve")
+	defer span.End()
+
+	var entry models.WaitlistEntry
+
+	err := waitlistService.db.SelectOne(ctx, nil, &entry, "SELECT * FROM waitlist WHERE tenant_id = ? AND flight_id = ? "+
+		"AND id = ?", tenantID, flightID, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+				"tenantID": tenantID,
+				"flightID": flightID,
+				"id":       id,
+			}).Error("Waitlist entry not found")
+			return nil, apperrors.ErrWaitlistEntryNotFound
+		}
+
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+			"id":       id,
+		}).Error("Error returning waitlist entry")
+		return nil, err
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"entry": entry,
+	}).Debug("Waitlist entry successfully retrieved")
+	return &entry, nil
+}
+
+// ListAll lists waiting entries in order of promotion with their positions
+func (waitlistService *WaitlistService) ListAll(ctx context.Context, tenantID string,
+	flightID int64) ([]models.WaitlistEntry, error) {
+	ctx, span := tracing.Start(ctx, "WaitlistService.ListAll")
+	defer span.End()
+
+	var entries []models.WaitlistEntry
+
+	_, err := waitlistService.db.Select(ctx, nil, &entries, "SELECT * FROM waitlist WHERE tenant_id = ? "+
+		"AND flight_id = ? AND status = ? ORDER BY priority DESC, created_at ASC, id ASC", tenantID, flightID,
+		models.WaitlistStatusWaiting)
+	if err != nil {
+		logging.Log.WithContext(ctx, logging.DepthModerate, logging.Fields{
+			"error":    err,
+			"tenantID": tenantID,
+			"flightID": flightID,
+		}).Error("Error returning waitlist entries")
+		return nil, err
+	}
+
+	for i := range entries {
+		entries[i].Position = i + 1
+	}
+
+	logging.Log.WithContext(ctx, logging.DepthLow, logging.Fields{
+		"tenantID": tenantID,
+		"flightID": flightID,
+		"entries":  entries,
+	}).Debug("Waitlist entries successfully listed")
+	return entries, nil
+}
@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	// ExporterNone drops spans
+	ExporterNone = "none"
+	// ExporterStdout writes otlp json spans to stdout
+	ExporterStdout = "stdout"
+	// ExporterFile appends otlp json spans to the file
+	ExporterFile = "file"
+	// scopeName is instrumentation scope of exported spans
+	scopeName = "github.com/vsukhin/booking/tracing"
+	// statusCodeOK is otlp status of the successful span
+	statusCodeOK = 1
+	// statusCodeError is otlp status of the failed span
+	statusCodeError = 2
+)
+
+// NopExporter drops spans, traces are still propagated and logged
+type NopExporter struct{}
+
+// Export drops span
+func (exporter *NopExporter) Export(span *Span) error {
+	return nil
+}
+
+// OTLPExporter writes every span as one line of otlp json trace export request, e.g. to stdout or otlp file
+type OTLPExporter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	service string
+}
+
+// NewOTLPExporter is a constructor of otlp json exporter of the service spans
+func NewOTLPExporter(writer io.Writer, service string) Exporter {
+	return &OTLPExporter{encoder: json.NewEncoder(writer), service: service}
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewExporter makes exporter of the kind, file is used by file exporter only
+func NewExporter(kind string, file string, service string) (Exporter, error) {
+	switch kind {
+	case ExporterNone, "":
+		return &NopExporter{}, nil
+	case ExporterStdout:
+		return NewOTLPExporter(os.Stdout, service), nil
+	case ExporterFile:
+		opened, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewOTLPExporter(opened, service), nil
+	}
+
+	return nil, fmt.Errorf("unknown trace exporter %v", kind)
+}
+
+// attribute makes otlp attribute of the value, 64-bit integers are strings in otlp json
+func attribute(key string, value interface{}) otlpAttribute {
+	switch typed := value.(type) {
+	case string:
+		return otlpAttribute{Key: key, Value: map[string]interface{}{"stringValue": typed}}
+	case bool:
+		return otlpAttribute{Key: key, Value: map[string]interface{}{"boolValue": typed}}
+	case int:
+		return otlpAttribute{Key: key, Value: map[string]interface{}{"intValue": strconv.Itoa(typed)}}
+	case int64:
+		return otlpAttribute{Key: key, Value: map[string]interface{}{"intValue": strconv.FormatInt(typed, 10)}}
+	case float64:
+		return otlpAttribute{Key: key, Value: map[string]interface{}{"doubleValue": typed}}
+	}
+
+	return otlpAttribute{Key: key, Value: map[string]interface{}{"stringValue": fmt.Sprint(value)}}
+}
+
+// Export writes span line
+func (exporter *OTLPExporter) Export(span *Span) error {
+	exported := otlpSpan{
+		TraceID:           span.Context.TraceID,
+		SpanID:            span.Context.SpanID,
+		ParentSpanID:      span.ParentID,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		Status:            otlpStatus{Code: statusCodeOK},
+	}
+	if span.Error != "" {
+		exported.Status = otlpStatus{Code: statusCodeError, Message: span.Error}
+	}
+
+	var keys []string
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		exported.Attributes = append(exported.Attributes, attribute(key, span.Attributes[key]))
+	}
+
+	request := otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{attribute("service.name", exporter.service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: []otlpSpan{exported}}},
+	}}}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	return exporter.encoder.Encode(request)
+}
@@ -0,0 +1,221 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderTraceparent is w3c trace context header
+	HeaderTraceparent = "traceparent"
+	// traceparentVersion is supported traceparent version
+	traceparentVersion = "00"
+	// flagSampled is traceparent flag of the sampled trace
+	flagSampled = 0x01
+	// invalidTraceID is trace id of all zeros
+	invalidTraceID = "00000000000000000000000000000000"
+	// invalidSpanID is span id of all zeros
+	invalidSpanID = "0000000000000000"
+)
+
+// SpanKind is role of the span in the trace
+type SpanKind int
+
+const (
+	// SpanKindInternal is span of the operation inside the service
+	SpanKindInternal SpanKind = iota + 1
+	// SpanKindServer is span of the handled incoming request
+	SpanKindServer
+	// SpanKindClient is span of the outgoing request, e.g. sql statement
+	SpanKindClient
+)
+
+var (
+	// traceparentExp is traceparent header of version, trace id, parent span id and flags
+	traceparentExp = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+)
+
+// contextKey is key of the span in the context
+type contextKey struct{}
+
+// SpanContext identifies span propagated between services
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// Span is timed operation of the trace, methods of nil span do nothing
+type Span struct {
+	mutex      sync.Mutex
+	tracer     *Tracer
+	ended      bool
+	Context    SpanContext
+	ParentID   string
+	Name       string
+	Kind       SpanKind
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+	Error      string
+}
+
+// Exporter exports ended sampled spans
+type Exporter interface {
+	Export(span *Span) error
+}
+
+// Tracer starts spans exported by the exporter, export errors are passed to the error handler
+type Tracer struct {
+	exporter Exporter
+	onError  func(err error)
+}
+
+// TracerInterface is tracer interface
+type TracerInterface interface {
+	Start(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span)
+}
+
+// NewTracer is a constructor of tracer
+func NewTracer(exporter Exporter, onError func(err error)) TracerInterface {
+	return &Tracer{exporter: exporter, onError: onError}
+}
+
+// ParseTraceparent parses traceparent header, returns false for malformed or invalid one
+func ParseTraceparent(header string) (SpanContext, bool) {
+	match := traceparentExp.FindStringSubmatch(header)
+	if match == nil || match[1] == "ff" || match[2] == invalidTraceID || match[3] == invalidSpanID {
+		return SpanContext{}, false
+	}
+
+	var flags byte
+	_, err := fmt.Sscanf(match[4], "%02x", &flags)
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	return SpanContext{TraceID: match[2], SpanID: match[3], Sampled: flags&flagSampled != 0}, true
+}
+
+// Traceparent formats span context as traceparent header
+func (spanContext SpanContext) Traceparent() string {
+	flags := 0
+	if spanContext.Sampled {
+		flags = flagSampled
+	}
+
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, spanContext.TraceID, spanContext.SpanID, flags)
+}
+
+// IsValid checks that span context has trace and span ids
+func (spanContext SpanContext) IsValid() bool {
+	return spanContext.TraceID != "" && spanContext.SpanID != ""
+}
+
+func newID(size int) string {
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+
+	return hex.EncodeToString(data)
+}
+
+// Start starts span of the parent span context, new sampled trace is started without valid parent
+func (tracer *Tracer) Start(ctx context.Context, name string, kind SpanKind,
+	parent SpanContext) (context.Context, *Span) {
+	span := &Span{
+		tracer:     tracer,
+		Context:    SpanContext{TraceID: parent.TraceID, SpanID: newID(8), Sampled: parent.Sampled},
+		ParentID:   parent.SpanID,
+		Name:       name,
+		Kind:       kind,
+		StartTime:  time.Now(),
+		Attributes: map[string]interface{}{},
+	}
+
+	if !parent.IsValid() {
+		span.Context.TraceID = newID(16)
+		span.Context.Sampled = true
+		span.ParentID = ""
+	}
+
+	return context.WithValue(ctx, contextKey{}, span), span
+}
+
+// Start starts child span of the context span, nothing is started if context has no span
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return StartKind(ctx, name, SpanKindInternal)
+}
+
+// StartKind starts child span of the kind
+func StartKind(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	return parent.tracer.Start(ctx, name, kind, parent.Context)
+}
+
+// FromContext returns span of the context, nil if there is none
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+
+	span, _ := ctx.Value(contextKey{}).(*Span)
+	return span
+}
+
+// SetAttribute sets attribute of the span
+func (span *Span) SetAttribute(key string, value interface{}) {
+	if span == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	span.Attributes[key] = value
+}
+
+// SetError marks span failed with the error
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	span.Error = err.Error()
+}
+
+// End ends span and exports it if trace is sampled, span is ended once
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	if span.ended {
+		span.mutex.Unlock()
+		return
+	}
+	span.ended = true
+	span.EndTime = time.Now()
+	span.mutex.Unlock()
+
+	if !span.Context.Sampled {
+		return
+	}
+
+	err := span.tracer.exporter.Export(span)
+	if err != nil && span.tracer.onError != nil {
+		span.tracer.onError(err)
+	}
+}
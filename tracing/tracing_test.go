@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// FakeExporter collects exported spans
+type FakeExporter struct {
+	spans []*Span
+	err   error
+}
+
+// Export collects span
+func (exporter *FakeExporter) Export(span *Span) error {
+	exporter.spans = append(exporter.spans, span)
+	return exporter.err
+}
+
+func Test_ParseTraceparent_Success(t *testing.T) {
+	header := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	spanContext, ok := ParseTraceparent(header)
+	if !ok {
+		t.Fatal("Expected to parse traceparent")
+	}
+	if spanContext.TraceID != "0af7651916cd43dd8448eb211c80319c" || spanContext.SpanID != "b7ad6b7169203331" ||
+		!spanContext.Sampled {
+		t.Errorf("Unexpected span context %+v", spanContext)
+	}
+	if spanContext.Traceparent() != header {
+		t.Errorf("Unexpected traceparent %v", spanContext.Traceparent())
+	}
+}
+
+func Test_ParseTraceparent_Failure(t *testing.T) {
+	for _, header := range []string{
+		"",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+	} {
+		_, ok := ParseTraceparent(header)
+		if ok {
+			t.Errorf("Expected not to parse traceparent %q", header)
+		}
+	}
+}
+
+func Test_Tracer_Start_Child_Success(t *testing.T) {
+	exporter := &FakeExporter{}
+	tracer := NewTracer(exporter, nil)
+
+	ctx, parent := tracer.Start(context.Background(), "request", SpanKindServer, SpanContext{})
+	if !parent.Context.IsValid() || !parent.Context.Sampled || parent.ParentID != "" {
+		t.Fatal("Expected to start new sampled trace")
+	}
+
+	_, child := Start(ctx, "service")
+	child.End()
+	child.End()
+	parent.End()
+
+	if child.Context.TraceID != parent.Context.TraceID || child.ParentID != parent.Context.SpanID {
+		t.Error("Expected child span to continue trace of the parent")
+	}
+	if len(exporter.spans) != 2 || exporter.spans[0] != child || exporter.spans[1] != parent {
+		t.Error("Expected ended spans to be exported once")
+	}
+}
+
+func Test_Tracer_Start_NotSampled_Success(t *testing.T) {
+	exporter := &FakeExporter{}
+	tracer := NewTracer(exporter, nil)
+
+	parent, _ := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	_, span := tracer.Start(context.Background(), "request", SpanKindServer, parent)
+	span.End()
+
+	if span.Context.TraceID != parent.TraceID || span.Context.Sampled {
+		t.Error("Expected to continue not sampled trace")
+	}
+	if len(exporter.spans) != 0 {
+		t.Error("Expected not sampled span not to be exported")
+	}
+}
+
+func Test_Start_NoSpan_Success(t *testing.T) {
+	ctx, span := Start(context.Background(), "service")
+
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("failed"))
+	span.End()
+
+	if span != nil || FromContext(ctx) != nil {
+		t.Error("Expected no span without span of the context")
+	}
+}
+
+func Test_Span_End_Export_Failure(t *testing.T) {
+	var exported error
+	tracer := NewTracer(&FakeExporter{err: errors.New("exporter is unavailable")}, func(err error) {
+		exported = err
+	})
+
+	_, span := tracer.Start(context.Background(), "request", SpanKindServer, SpanContext{})
+	span.End()
+
+	if exported == nil {
+		t.Error("Expected export error to be handled")
+	}
+}
+
+func Test_OTLPExporter_Export_Success(t *testing.T) {
+	var buffer bytes.Buffer
+	tracer := NewTracer(NewOTLPExporter(&buffer, "booking"), nil)
+
+	ctx, parent := tracer.Start(context.Background(), "request", SpanKindServer, SpanContext{})
+	_, span := StartKind(ctx, "db.exec", SpanKindClient)
+	span.SetAttribute("db.statement", "DELETE FROM seats")
+	span.SetAttribute("db.rows", int64(3))
+	span.SetError(errors.New("deadlock"))
+	span.End()
+
+	var request otlpRequest
+	err := json.Unmarshal(buffer.Bytes(), &request)
+	if err != nil {
+		t.Fatal("Expected to export otlp json")
+	}
+
+	resource := request.ResourceSpans[0]
+	if resource.Resource.Attributes[0].Value["stringValue"] != "booking" {
+		t.Error("Expected service name of the resource")
+	}
+
+	exported := resource.ScopeSpans[0].Spans[0]
+	if exported.TraceID != parent.Context.TraceID || exported.ParentSpanID != parent.Context.SpanID ||
+		exported.Kind != SpanKindClient {
+		t.Errorf("Unexpected exported span %+v", exported)
+	}
+	if len(exported.Attributes) != 2 || exported.Attributes[0].Key != "db.rows" ||
+		exported.Attributes[0].Value["intValue"] != "3" {
+		t.Errorf("Expected sorted attributes, got %+v", exported.Attributes)
+	}
+	if exported.Status.Code != statusCodeError || exported.Status.Message != "deadlock" {
+		t.Errorf("Unexpected status %+v", exported.Status)
+	}
+}
+
+func Test_NewExporter_Failure(t *testing.T) {
+	_, err := NewExporter("jaeger", "", "booking")
+	if err == nil {
+		t.Error("Expected unknown exporter to fail")
+	}
+}